@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/thinkwright/claude-chronicle/internal/archive"
+	"github.com/thinkwright/claude-chronicle/internal/config"
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+const archiveUsage = `usage: clog archive status
+       clog archive prune [--max-size MB]
+
+status reports how much the transcript archive holds. prune deletes blobs
+no session refers to, then evicts the oldest archived sessions until the
+archive fits archive.max_size_mb (or --max-size, when given).
+`
+
+// runArchive implements `clog archive` and returns the process exit code.
+func runArchive(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, archiveUsage)
+		return exitError
+	}
+
+	fs := flag.NewFlagSet("archive "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, archiveUsage)
+		fs.PrintDefaults()
+	}
+	var maxSize *int64
+	switch args[0] {
+	case "status":
+	case "prune":
+		maxSize = fs.Int64("max-size", -1, "size budget in MB, overriding the config")
+	case "-h", "--help", "help":
+		fmt.Fprint(stdout, archiveUsage)
+		return exitMatch
+	default:
+		fmt.Fprintf(stderr, "unknown archive command %q\n\n", args[0])
+		fmt.Fprint(stderr, archiveUsage)
+		return exitError
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitMatch
+		}
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	cfg := config.Load()
+	if maxSize != nil && *maxSize >= 0 {
+		cfg.Archive.MaxSizeMB = *maxSize
+	}
+	db, err := store.Open(store.DBPath())
+	if err != nil {
+		fmt.Fprintf(stderr, "error opening index: %v\n", err)
+		return exitError
+	}
+	defer db.Close()
+	// Always open the archive here, so an archive left behind after
+	// disabling archive mode can still be inspected and pruned.
+	db.EnableArchive(archive.New(store.ArchiveDir()), cfg.Archive.Budget())
+
+	if args[0] == "prune" {
+		res, err := db.PruneArchive()
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitError
+		}
+		fmt.Fprintf(stdout, "evicted %d sessions, deleted %d blobs, freed %s\n",
+			res.Evicted, res.Blobs, formatBytes(res.FreedBytes))
+		return exitMatch
+	}
+
+	st, err := db.ArchiveStatus()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	enabled := "off"
+	if cfg.Archive.Enabled {
+		enabled = "on"
+	}
+	fmt.Fprintf(stdout, "archive:   %s (%s)\n", st.Dir, enabled)
+	fmt.Fprintf(stdout, "sessions:  %d archived, %d no longer on disk\n", st.Sessions, st.SourcesMissing)
+	fmt.Fprintf(stdout, "size:      %s stored, %s original\n", formatBytes(st.StoredBytes), formatBytes(st.OriginalBytes))
+	if st.Budget > 0 {
+		fmt.Fprintf(stdout, "budget:    %s (%.0f%% used)\n", formatBytes(st.Budget),
+			float64(st.StoredBytes)*100/float64(st.Budget))
+	} else {
+		fmt.Fprintln(stdout, "budget:    unlimited")
+	}
+	if st.OrphanBlobs > 0 {
+		fmt.Fprintf(stdout, "orphans:   %d blobs, %s (run `clog archive prune`)\n",
+			st.OrphanBlobs, formatBytes(st.OrphanBytes))
+	}
+	return exitMatch
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "12.3 MB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
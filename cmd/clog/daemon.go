@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/config"
+	"github.com/thinkwright/claude-chronicle/internal/daemon"
+)
+
+const daemonUsage = `usage: clog daemon [--log-format text|json]
+       clog daemon status
+       clog daemon stop
+
+Without a command, indexes sessions as Claude Code writes them and runs
+watchlist matching and actions, in the foreground, until interrupted. Logs
+go to stderr. While it runs, the dashboard reads the index without indexing
+itself. status reports whether a daemon is running; stop asks it to exit.
+`
+
+// runDaemon implements `clog daemon` and returns the process exit code.
+func runDaemon(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "status":
+			if pid, ok := daemon.Running(daemon.PIDFile()); ok {
+				fmt.Fprintf(stdout, "clog daemon is running (pid %d)\n", pid)
+				return exitMatch
+			}
+			fmt.Fprintln(stdout, "clog daemon is not running")
+			return exitNoMatch
+		case "stop":
+			if err := daemon.Stop(daemon.PIDFile(), 10*time.Second); err != nil {
+				fmt.Fprintf(stderr, "error: %v\n", err)
+				return exitError
+			}
+			fmt.Fprintln(stdout, "clog daemon stopped")
+			return exitMatch
+		}
+	}
+
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, daemonUsage)
+		fs.PrintDefaults()
+	}
+	format := fs.String("log-format", "text", "log format: text or json")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitMatch
+		}
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "unknown daemon command %q\n\n", fs.Arg(0))
+		fs.Usage()
+		return exitError
+	}
+	var log *slog.Logger
+	switch *format {
+	case "text":
+		log = slog.New(slog.NewTextHandler(stderr, nil))
+	case "json":
+		log = slog.New(slog.NewJSONHandler(stderr, nil))
+	default:
+		fmt.Fprintf(stderr, "unknown log format %q\n", *format)
+		return exitError
+	}
+
+	pidfile, err := daemon.Acquire(daemon.PIDFile())
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	defer pidfile.Release()
+
+	cfg := config.Load()
+	db, err := openIndex(cfg, "daemon")
+	if err != nil {
+		log.Error("open index", "err", err)
+		return exitError
+	}
+	defer db.Close()
+	db.runActions().SetLogger(log)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Info("daemon started", "pid", os.Getpid(), "version", version, "pidfile", daemon.PIDFile())
+	if err := daemon.Run(ctx, db.Store, cfg.ProjectPaths, log); err != nil {
+		log.Error("daemon stopped", "err", err)
+		return exitError
+	}
+	log.Info("daemon stopped")
+	return exitMatch
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/thinkwright/claude-chronicle/internal/config"
+	"github.com/thinkwright/claude-chronicle/internal/export"
+)
+
+const exportUsage = `usage: clog export <session-id> [--format md|html|json] [-o FILE]
+
+Writes a session transcript to FILE, or to stdout when -o is omitted or "-".
+The session id may be any unambiguous prefix. Without --format, the format
+is taken from the -o extension and defaults to md.
+`
+
+// runExport implements `clog export` and returns the process exit code.
+func runExport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, exportUsage)
+		fs.PrintDefaults()
+	}
+	formatName := fs.String("format", "", "output format: md, html or json")
+	output := fs.String("o", "", "output file (default stdout)")
+
+	positional, err := parseInterspersed(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitMatch
+	}
+	if err != nil {
+		return exitError
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return exitError
+	}
+
+	if *formatName == "" {
+		*formatName = "md"
+		if ext := strings.TrimPrefix(filepath.Ext(*output), "."); ext != "" {
+			*formatName = ext
+		}
+	}
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	cfg := config.Load()
+	db, err := openIndex(cfg, "export")
+	if err != nil {
+		fmt.Fprintf(stderr, "error opening index: %v\n", err)
+		return exitError
+	}
+	defer db.Close()
+
+	sess, err := db.ResolveSession(positional[0])
+	if err != nil {
+		// The session may be newer than the index
+		if _, ierr := db.IndexChanged(cfg.ProjectPaths); ierr == nil {
+			sess, err = db.ResolveSession(positional[0])
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	if *output == "" || *output == "-" {
+		if err := export.Session(stdout, *sess, format); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitError
+		}
+		return exitMatch
+	}
+
+	if err := export.WriteFile(*output, *sess, format); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintf(stderr, "exported %s to %s\n", sess.SessionID, *output)
+	return exitMatch
+}
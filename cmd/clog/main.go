@@ -1,11 +1,15 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thinkwright/claude-chronicle/internal/actions"
+	"github.com/thinkwright/claude-chronicle/internal/archive"
+	"github.com/thinkwright/claude-chronicle/internal/claude"
 	"github.com/thinkwright/claude-chronicle/internal/config"
 	"github.com/thinkwright/claude-chronicle/internal/store"
 	"github.com/thinkwright/claude-chronicle/internal/ui"
@@ -15,8 +19,27 @@ import (
 var version = "dev"
 
 func main() {
-	reindex := false
 	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "search":
+			os.Exit(runSearch(args[1:], os.Stdout, os.Stderr))
+		case "export":
+			os.Exit(runExport(args[1:], os.Stdout, os.Stderr))
+		case "archive":
+			os.Exit(runArchive(args[1:], os.Stdout, os.Stderr))
+		case "serve":
+			os.Exit(runServe(args[1:], os.Stdout, os.Stderr))
+		case "mcp":
+			os.Exit(runMCP(args[1:], os.Stdin, os.Stdout, os.Stderr))
+		case "watch":
+			os.Exit(runWatch(args[1:], os.Stdout, os.Stderr))
+		case "daemon":
+			os.Exit(runDaemon(args[1:], os.Stdout, os.Stderr))
+		}
+	}
+
+	reindex := false
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
 		case "--version", "-v":
@@ -62,15 +85,19 @@ func main() {
 		}
 	}
 
-	db, err := store.Open(store.DBPath())
+	db, err := openIndex(config.Load(), "dashboard")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error opening index: %v\n", err)
 		os.Exit(1)
 	}
 	defer db.Close()
+	db.runActions()
 
 	if reindex {
-		if err := db.Reset(); err != nil {
+		if err := db.Reset(); errors.Is(err, store.ErrReadOnly) {
+			fmt.Fprintf(os.Stderr, "%v; close it before --reindex\n", err)
+			os.Exit(1)
+		} else if err != nil {
 			fmt.Fprintf(os.Stderr, "error resetting index: %v\n", err)
 			os.Exit(1)
 		}
@@ -92,7 +119,7 @@ func main() {
 	}
 
 	p := tea.NewProgram(
-		ui.NewModel(db),
+		ui.NewModel(db.Store),
 		tea.WithAltScreen(),
 		tea.WithMouseCellMotion(),
 	)
@@ -102,3 +129,46 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// index is the store as the commands use it, with watchlist actions run on
+// the matches its indexing finds once runActions is called.
+type index struct {
+	*store.Store
+	actions *actions.Runner // nil unless runActions was called
+}
+
+// runActions attaches a runner that delivers the matches this process
+// indexes to their watch's action. Only the long-running commands (the
+// dashboard, serve, mcp and the daemon) call it, so a one-shot command
+// never runs a command or calls a webhook because it took the lease.
+func (ix *index) runActions() *actions.Runner {
+	ix.actions = actions.New(ix.Store)
+	ix.OnMatch(ix.actions.Handle)
+	return ix.actions
+}
+
+// Close waits for watchlist actions in flight, then closes the store.
+func (ix *index) Close() error {
+	if ix.actions != nil {
+		ix.actions.Close()
+	}
+	return ix.Store.Close()
+}
+
+// openIndex opens the index and applies the indexing options from cfg.
+// kind names the command in the writer lease, so other clog processes can
+// say who is writing the index.
+func openIndex(cfg config.Config, kind string) (*index, error) {
+	db, err := store.Open(store.DBPath())
+	if err != nil {
+		return nil, err
+	}
+	db.SetLeaseKind(kind)
+	db.SetKeepMissing(cfg.KeepMissingSessions)
+	db.SetRanking(store.Ranking(cfg.Search))
+	if cfg.Archive.Enabled {
+		db.EnableArchive(archive.New(store.ArchiveDir()), cfg.Archive.Budget())
+		claude.SetArchive(db.OpenArchived)
+	}
+	return &index{Store: db}, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/thinkwright/claude-chronicle/internal/config"
+	"github.com/thinkwright/claude-chronicle/internal/mcp"
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+const mcpUsage = `usage: clog mcp [--no-index]
+
+Runs a Model Context Protocol server on stdin and stdout, so Claude Code can
+search past conversations. Register it with:
+  claude mcp add --scope user clog -- clog mcp
+`
+
+// runMCP implements `clog mcp` and returns the process exit code.
+func runMCP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("mcp", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, mcpUsage)
+		fs.PrintDefaults()
+	}
+	noIndex := fs.Bool("no-index", false, "answer from the index as it is, without indexing changed sessions first")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitMatch
+		}
+		return exitError
+	}
+
+	cfg := config.Load()
+	db, err := openIndex(cfg, "mcp")
+	if err != nil {
+		fmt.Fprintf(stderr, "error opening index: %v\n", err)
+		return exitError
+	}
+	defer db.Close()
+	db.runActions()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Index in the background: clients time out a slow handshake, and
+	// searches see each session as soon as it is indexed. The pass is
+	// stopped and waited for before the index is closed.
+	if !*noIndex {
+		indexCtx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := db.IndexChangedContext(indexCtx, cfg.ProjectPaths)
+			if err != nil && !errors.Is(err, store.ErrReadOnly) && !errors.Is(err, context.Canceled) {
+				fmt.Fprintf(stderr, "error indexing: %v\n", err)
+			}
+		}()
+		defer func() {
+			cancel()
+			wg.Wait()
+		}()
+	}
+	if err := mcp.New(db.Store, version).Serve(ctx, stdin, stdout); err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	return exitMatch
+}
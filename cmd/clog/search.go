@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/thinkwright/claude-chronicle/internal/config"
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+// Exit codes for headless subcommands, following grep: 0 when something
+// matched, 1 when nothing did, 2 on usage or runtime errors.
+const (
+	exitMatch   = 0
+	exitNoMatch = 1
+	exitError   = 2
+)
+
+const searchUsage = `usage: clog search "<query>" [--project NAME] [--limit N] [--format table|json|ndjson] [--refresh]
+
+Searches the index with the same query syntax as the dashboard, e.g.
+  clog search "deploy model:opus age:<7d"
+  clog search "tool:Bash tokens:>5000" --format ndjson
+`
+
+// runSearch implements `clog search` and returns the process exit code.
+func runSearch(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, searchUsage)
+		fs.PrintDefaults()
+	}
+	project := fs.String("project", "", "only search sessions from this project")
+	limit := fs.Int("limit", 50, "maximum number of results")
+	format := fs.String("format", "table", "output format: table, json or ndjson")
+	refresh := fs.Bool("refresh", false, "index new and changed sessions before searching")
+
+	positional, err := parseInterspersed(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitMatch
+	}
+	if err != nil {
+		return exitError
+	}
+	query := strings.TrimSpace(strings.Join(positional, " "))
+	if query == "" {
+		fs.Usage()
+		return exitError
+	}
+	if *limit <= 0 {
+		fmt.Fprintln(stderr, "--limit must be positive")
+		return exitError
+	}
+	switch *format {
+	case "table", "json", "ndjson":
+	default:
+		fmt.Fprintf(stderr, "unknown format %q (want table, json or ndjson)\n", *format)
+		return exitError
+	}
+
+	cfg := config.Load()
+	db, err := openIndex(cfg, "search")
+	if err != nil {
+		fmt.Fprintf(stderr, "error opening index: %v\n", err)
+		return exitError
+	}
+	defer db.Close()
+
+	if *refresh {
+		// Another clog writing the index keeps it current already
+		if _, err := db.IndexChanged(cfg.ProjectPaths); err != nil && !errors.Is(err, store.ErrReadOnly) {
+			fmt.Fprintf(stderr, "error indexing: %v\n", err)
+			return exitError
+		}
+	} else if db.FileCount() == 0 {
+		fmt.Fprintln(stderr, "index is empty; run clog once or pass --refresh")
+	}
+
+	results, err := db.SearchProject(query, *project, *limit)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	if err := writeResults(stdout, results, *format); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	if len(results) == 0 {
+		return exitNoMatch
+	}
+	return exitMatch
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments, which the flag package alone does not allow. Only
+// fs's own flags are taken as flags: anything else starting with a dash,
+// such as a negated query term like -tool:Bash, is positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var flags, positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		// Everything after a literal "--" is positional
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		name, hasValue := flagName(arg)
+		if name == "h" || name == "help" {
+			flags = append(flags, arg)
+			continue
+		}
+		f := fs.Lookup(name)
+		if f == nil {
+			positional = append(positional, arg)
+			continue
+		}
+		flags = append(flags, arg)
+		// A flag that takes a value may have it in the next argument
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !hasValue && !(ok && b.IsBoolFlag()) && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+	if err := fs.Parse(flags); err != nil {
+		return nil, err
+	}
+	return positional, nil
+}
+
+// flagName returns the flag an argument names, if it looks like one, and
+// whether it carries its value after an "=".
+func flagName(arg string) (string, bool) {
+	if len(arg) < 2 || arg[0] != '-' {
+		return "", false
+	}
+	name := strings.TrimPrefix(arg[1:], "-")
+	name, _, hasValue := strings.Cut(name, "=")
+	return name, hasValue
+}
+
+func writeResults(w io.Writer, results []store.SearchResult, format string) error {
+	switch format {
+	case "json":
+		if results == nil {
+			results = []store.SearchResult{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		for _, r := range results {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if len(results) == 0 {
+			return nil
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tPROJECT\tSESSION\tTYPE\tTEXT")
+		for _, r := range results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				shortTimestamp(r.Timestamp), r.Project, shortID(r.SessionID),
+				r.MessageType, oneLine(r.Text, 100))
+		}
+		return tw.Flush()
+	}
+}
+
+// shortTimestamp trims an RFC3339 timestamp to minute precision.
+func shortTimestamp(ts string) string {
+	if len(ts) >= 16 {
+		return strings.Replace(ts[:16], "T", " ", 1)
+	}
+	return ts
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// oneLine collapses whitespace so a message fits on one table row.
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	tests := []struct {
+		args       []string
+		positional string
+		limit      int
+		refresh    bool
+	}{
+		{[]string{"deploy", "--limit", "5"}, "deploy", 5, false},
+		{[]string{"--refresh", "deploy", "-limit=3", "prod"}, "deploy prod", 3, true},
+		// Negated terms are part of the query, not unknown flags
+		{[]string{"-tool:Bash"}, "-tool:Bash", 50, false},
+		{[]string{"-test", "deploy", "--limit", "2"}, "-test deploy", 2, false},
+		{[]string{"--limit", "2", "--", "--refresh"}, "--refresh", 2, false},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("search", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		limit := fs.Int("limit", 50, "")
+		refresh := fs.Bool("refresh", false, "")
+
+		positional, err := parseInterspersed(fs, tt.args)
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if got := strings.Join(positional, " "); got != tt.positional || *limit != tt.limit || *refresh != tt.refresh {
+			t.Errorf("%q = %q, limit %d, refresh %v", tt.args, got, *limit, *refresh)
+		}
+	}
+}
+
+func TestRunSearch_NegatedQuery(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("CLAUDE_CONFIG_DIR", t.TempDir())
+	var stderr strings.Builder
+	if code := runSearch([]string{"-tool:Bash"}, io.Discard, &stderr); code == exitError {
+		t.Errorf("clog search -tool:Bash = %d: %s", code, stderr.String())
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/config"
+	"github.com/thinkwright/claude-chronicle/internal/server"
+)
+
+const serveUsage = `usage: clog serve [--addr HOST:PORT] [--allow-origin ORIGIN,...] [--no-watch]
+
+Serves the index as a JSON API, with a server-sent events stream of index
+updates and new watchlist matches at /api/events. Binds to localhost unless
+--addr names another interface.
+`
+
+// runServe implements `clog serve` and returns the process exit code.
+func runServe(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, serveUsage)
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", "127.0.0.1:7420", "address to listen on")
+	origins := fs.String("allow-origin", "", "comma-separated browser origins allowed to call the API")
+	noWatch := fs.Bool("no-watch", false, "serve the index as it is, without indexing changes")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitMatch
+		}
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	host, _, err := net.SplitHostPort(*addr)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid --addr %q: %v\n", *addr, err)
+		return exitError
+	}
+	ip := net.ParseIP(host)
+	local := host == "localhost" || (ip != nil && ip.IsLoopback())
+	if !local {
+		fmt.Fprintf(stderr, "warning: serving the index on %s; anyone who can reach it can read your sessions\n", *addr)
+	}
+
+	cfg := config.Load()
+	db, err := openIndex(cfg, "serve")
+	if err != nil {
+		fmt.Fprintf(stderr, "error opening index: %v\n", err)
+		return exitError
+	}
+	defer db.Close()
+	db.runActions()
+
+	opts := server.Options{ProjectPaths: cfg.ProjectPaths, LocalOnly: local}
+	if *origins != "" {
+		for _, o := range strings.Split(*origins, ",") {
+			opts.AllowOrigins = append(opts.AllowOrigins, strings.TrimRight(strings.TrimSpace(o), "/"))
+		}
+	}
+	srv := server.New(db.Store, opts)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	httpSrv := &http.Server{
+		Handler:           srv,
+		ReadHeaderTimeout: 10 * time.Second,
+		// End event streams on shutdown rather than waiting them out
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	if !*noWatch {
+		go func() {
+			if err := srv.Watch(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				fmt.Fprintf(stderr, "watch: %v\n", err)
+			}
+		}()
+	}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpSrv.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(stdout, "clog serving on http://%s\n", ln.Addr())
+	if err := httpSrv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	return exitMatch
+}
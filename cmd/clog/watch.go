@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/thinkwright/claude-chronicle/internal/actions"
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+const watchUsage = `usage: clog watch list
+       clog watch action WATCH (--command CMD | --webhook URL | --pipe PATH | --none) [--limit N]
+       clog watch test WATCH
+       clog watch log [WATCH] [--limit N]
+
+WATCH is a watchlist item's name or id. action sets what happens when new
+messages match it: run CMD with sh -c (match details in CLOG_* variables and
+as JSON on stdin), POST the match as JSON to URL, or write it as a JSON line
+to the named pipe at PATH. Actions run while clog, clog serve or clog mcp is
+indexing. test delivers a sample match; log lists recent deliveries.
+`
+
+// runWatch implements `clog watch` and returns the process exit code.
+func runWatch(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, watchUsage)
+		return exitError
+	}
+
+	fs := flag.NewFlagSet("watch "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, watchUsage)
+		fs.PrintDefaults()
+	}
+	var command, webhook, pipe *string
+	var none *bool
+	var limit *int
+	switch args[0] {
+	case "list", "test":
+	case "action":
+		command = fs.String("command", "", "shell command to run for each match")
+		webhook = fs.String("webhook", "", "URL to POST each match to")
+		pipe = fs.String("pipe", "", "named pipe to write each match to")
+		none = fs.Bool("none", false, "remove the watch's action")
+		limit = fs.Int("limit", 0, fmt.Sprintf("deliveries per hour (default %d)", store.DefaultActionLimit))
+	case "log":
+		limit = fs.Int("limit", 20, "number of deliveries to show")
+	case "-h", "--help", "help":
+		fmt.Fprint(stdout, watchUsage)
+		return exitMatch
+	default:
+		fmt.Fprintf(stderr, "unknown watch command %q\n\n", args[0])
+		fmt.Fprint(stderr, watchUsage)
+		return exitError
+	}
+	positional, err := parseInterspersed(fs, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return exitMatch
+	}
+	if err != nil {
+		return exitError
+	}
+	// Every command but list names one watch; log may name none
+	want := 1
+	switch args[0] {
+	case "list":
+		want = 0
+	case "log":
+		want = min(len(positional), 1)
+	}
+	if len(positional) != want {
+		fs.Usage()
+		return exitError
+	}
+
+	db, err := store.Open(store.DBPath())
+	if err != nil {
+		fmt.Fprintf(stderr, "error opening index: %v\n", err)
+		return exitError
+	}
+	defer db.Close()
+
+	var item *store.WatchItem
+	if len(positional) == 1 {
+		if item, err = findWatch(db, positional[0]); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitError
+		}
+	}
+
+	switch args[0] {
+	case "list":
+		return listWatches(db, stdout, stderr)
+	case "action":
+		var action store.WatchAction
+		set := 0
+		for kind, target := range map[string]string{
+			store.ActionCommand: *command, store.ActionWebhook: *webhook, store.ActionPipe: *pipe,
+		} {
+			if target != "" {
+				action = store.WatchAction{Kind: kind, Target: target, Limit: *limit}
+				set++
+			}
+		}
+		if *none {
+			set++
+		}
+		if set != 1 {
+			fmt.Fprintln(stderr, "give exactly one of --command, --webhook, --pipe or --none")
+			return exitError
+		}
+		if err := db.SetWatchAction(item.ID, action); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitError
+		}
+		if action.Kind == "" {
+			fmt.Fprintf(stdout, "removed the action from %s\n", item.Name)
+		} else {
+			fmt.Fprintf(stdout, "%s: %s %s, at most %d per hour\n", item.Name, action.Kind, action.Target, action.HourlyLimit())
+		}
+	case "test":
+		runner := actions.New(db)
+		defer runner.Close()
+		if err := runner.Test(*item); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitError
+		}
+		fmt.Fprintf(stdout, "delivered a test match to %s %s\n", item.Action.Kind, item.Action.Target)
+	case "log":
+		var watchID int64
+		if item != nil {
+			watchID = item.ID
+		}
+		deliveries, err := db.Deliveries(watchID, *limit)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitError
+		}
+		if len(deliveries) == 0 {
+			return exitNoMatch
+		}
+		tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tWATCH\tACTION\tSTATUS\tSESSION\tDETAIL")
+		for _, d := range deliveries {
+			detail := d.Error
+			if detail == "" {
+				detail = oneLine(d.MatchedText, 60)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				d.AttemptedAt.Format("2006-01-02 15:04:05"), d.WatchName, d.Action,
+				d.Status, shortID(d.SessionID), detail)
+		}
+		tw.Flush()
+	}
+	return exitMatch
+}
+
+func listWatches(db *store.Store, stdout, stderr io.Writer) int {
+	items, err := db.ListWatches()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	if len(items) == 0 {
+		return exitNoMatch
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPATTERN\tENABLED\tUNSEEN\tACTION")
+	for _, w := range items {
+		action := "-"
+		if w.Action.Kind != "" {
+			action = fmt.Sprintf("%s %s (%d/h)", w.Action.Kind, w.Action.Target, w.Action.HourlyLimit())
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%t\t%d\t%s\n", w.ID, w.Name, w.Pattern, w.Enabled, w.UnseenCount, action)
+	}
+	tw.Flush()
+	return exitMatch
+}
+
+// findWatch resolves a watchlist item by name, or by id when no item has
+// that name.
+func findWatch(db *store.Store, ref string) (*store.WatchItem, error) {
+	items, err := db.ListWatches()
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if items[i].Name == ref {
+			return &items[i], nil
+		}
+	}
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		for i := range items {
+			if items[i].ID == id {
+				return &items[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no watchlist item named %q", ref)
+}
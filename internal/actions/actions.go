@@ -0,0 +1,209 @@
+// Package actions runs the action attached to a watch when new messages
+// match it: a shell command, a webhook POST, or a line written to a named
+// pipe. Every attempt is recorded in the index's delivery log.
+package actions
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+// MaxAge bounds how old a matched message may be and still be delivered.
+// Matches found when clog catches up on sessions written while it was not
+// running are history, not news.
+const MaxAge = time.Hour
+
+const (
+	commandTimeout = 30 * time.Second
+	webhookTimeout = 10 * time.Second
+	closeGrace     = 5 * time.Second
+	finishAttempts = 3
+)
+
+// Payload describes a match. Webhooks receive it as the request body, pipes
+// as one JSON line, and commands on stdin and as CLOG_* environment variables.
+type Payload struct {
+	Watch       string `json:"watch"`
+	WatchID     int64  `json:"watch_id"`
+	Pattern     string `json:"pattern"`
+	MatchID     int64  `json:"match_id"`
+	SessionID   string `json:"session_id"`
+	Project     string `json:"project"`
+	Timestamp   string `json:"timestamp"`
+	MatchedText string `json:"matched_text"`
+	// Text is a one-line summary, so chat webhooks that expect a text
+	// field can take the payload as it is.
+	Text string `json:"text"`
+}
+
+func newPayload(w store.WatchItem, m store.WatchMatch) Payload {
+	return Payload{
+		Watch:       w.Name,
+		WatchID:     w.ID,
+		Pattern:     w.Pattern,
+		MatchID:     m.ID,
+		SessionID:   m.SessionID,
+		Project:     m.Project,
+		Timestamp:   m.Timestamp,
+		MatchedText: m.MatchedText,
+		Text:        fmt.Sprintf("clog: %s matched in %s (session %s): %s", w.Name, m.Project, m.SessionID, m.MatchedText),
+	}
+}
+
+// Runner delivers new matches to their watch's action. Register Handle with
+// Store.OnMatch. Deliveries run in the background; Close waits for them.
+type Runner struct {
+	db     *store.Store
+	client *http.Client
+	now    func() time.Time
+	log    *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex // serializes rate-limit decisions
+	closed bool
+}
+
+// New returns a runner for db.
+func New(db *store.Store) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		db:     db,
+		client: &http.Client{Timeout: webhookTimeout},
+		now:    time.Now,
+		log:    slog.New(slog.DiscardHandler),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Handle starts a delivery for each match whose watch has an action. Matches
+// already delivered, restored as seen, or older than MaxAge are skipped; a
+// watch over its hourly limit has the match logged as rate limited instead.
+func (r *Runner) Handle(matches []store.WatchMatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+
+	watches := make(map[int64]*store.WatchItem)
+	for _, m := range matches {
+		w, ok := watches[m.WatchItemID]
+		if !ok {
+			w, _ = r.db.GetWatch(m.WatchItemID)
+			watches[m.WatchItemID] = w
+		}
+		if w == nil || w.Action.Kind == "" || m.Seen || !r.recent(m) || r.db.Delivered(m) {
+			continue
+		}
+		if r.db.DeliveryCount(w.ID, r.now().Add(-time.Hour)) >= w.Action.HourlyLimit() {
+			r.db.LogDelivery(m, w.Action, store.DeliveryRateLimited)
+			continue
+		}
+		id, err := r.db.LogDelivery(m, w.Action, store.DeliveryPending)
+		if err != nil {
+			continue
+		}
+
+		r.wg.Add(1)
+		go func(w store.WatchItem, m store.WatchMatch) {
+			defer r.wg.Done()
+			start := time.Now()
+			status, msg := store.DeliveryOK, ""
+			if err := r.deliver(w.Action, newPayload(w, m)); err != nil {
+				status, msg = store.DeliveryFailed, err.Error()
+			}
+			if err := r.finish(id, status, msg, time.Since(start)); err != nil {
+				r.log.Warn("record delivery", "watch", w.Name, "delivery", id, "status", status, "err", err)
+			}
+		}(*w, m)
+	}
+}
+
+// SetLogger sets where the runner reports deliveries it could not record.
+// By default they are discarded.
+func (r *Runner) SetLogger(log *slog.Logger) {
+	r.log = log
+}
+
+// finish records a delivery's outcome, retrying a few times: a delivery
+// left pending counts toward its watch's rate limit for the next hour.
+func (r *Runner) finish(id int64, status, msg string, took time.Duration) error {
+	var err error
+	for attempt := 0; attempt < finishAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		}
+		if err = r.db.FinishDelivery(id, status, msg, took); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// Test delivers a sample match for w right away, without logging it or
+// counting it toward the rate limit.
+func (r *Runner) Test(w store.WatchItem) error {
+	if w.Action.Kind == "" {
+		return fmt.Errorf("watch %q has no action", w.Name)
+	}
+	m := store.WatchMatch{
+		WatchItemID: w.ID,
+		SessionID:   "test",
+		Project:     "clog",
+		Timestamp:   r.now().UTC().Format(time.RFC3339),
+		MatchedText: "test delivery for /" + w.Pattern + "/",
+	}
+	return r.deliver(w.Action, newPayload(w, m))
+}
+
+// recent reports whether the matched message is new enough to deliver.
+func (r *Runner) recent(m store.WatchMatch) bool {
+	t, err := time.Parse(time.RFC3339, m.Timestamp)
+	if err != nil {
+		return true
+	}
+	return r.now().Sub(t) <= MaxAge
+}
+
+func (r *Runner) deliver(action store.WatchAction, p Payload) error {
+	switch action.Kind {
+	case store.ActionCommand:
+		return r.runCommand(action.Target, p)
+	case store.ActionWebhook:
+		return r.postWebhook(action.Target, p)
+	case store.ActionPipe:
+		return writePipe(action.Target, p)
+	}
+	return fmt.Errorf("unknown action %q", action.Kind)
+}
+
+// Close stops accepting matches and waits for deliveries in flight,
+// cancelling any still running after a few seconds.
+func (r *Runner) Close() {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(closeGrace):
+		r.cancel()
+		<-done
+	}
+	r.cancel()
+}
@@ -0,0 +1,200 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+// fixture is an index whose watch "prod" matches "production", with a
+// runner attached.
+type fixture struct {
+	t      *testing.T
+	db     *store.Store
+	runner *Runner
+	watch  *store.WatchItem
+	path   string
+	n      int
+}
+
+func newFixture(t *testing.T, action store.WatchAction) *fixture {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("CLAUDE_CONFIG_DIR", dir)
+	path := filepath.Join(dir, "projects", "-work-demo", "sess-1.jsonl")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+
+	db, err := store.Open(filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	runner := New(db)
+	db.OnMatch(runner.Handle)
+
+	watch, err := db.AddWatch("prod", "production", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetWatchAction(watch.ID, action); err != nil {
+		t.Fatal(err)
+	}
+	return &fixture{t: t, db: db, runner: runner, watch: watch, path: path}
+}
+
+// say appends a user message written at ts to the session and indexes it.
+func (f *fixture) say(text string, ts time.Time) {
+	f.t.Helper()
+	f.n++
+	line := fmt.Sprintf(`{"type":"user","uuid":"u%d","cwd":"/work/demo","timestamp":%q,"message":{"role":"user","content":%q}}`+"\n",
+		f.n, ts.UTC().Format(time.RFC3339Nano), text)
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	file.WriteString(line)
+	file.Close()
+	if _, err := f.db.IndexChanged(nil); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// log waits for deliveries in flight and returns the log, oldest first.
+func (f *fixture) log() []store.Delivery {
+	f.t.Helper()
+	f.runner.wg.Wait()
+	deliveries, err := f.db.Deliveries(f.watch.ID, 100)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	for i, j := 0, len(deliveries)-1; i < j; i, j = i+1, j-1 {
+		deliveries[i], deliveries[j] = deliveries[j], deliveries[i]
+	}
+	return deliveries
+}
+
+func TestRunner_Command(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	f := newFixture(t, store.WatchAction{
+		Kind:   store.ActionCommand,
+		Target: `cat > "$OUT" && echo "$CLOG_WATCH $CLOG_SESSION_ID" >> "$OUT"`,
+	})
+	t.Setenv("OUT", out)
+
+	f.say("deploying to production now", time.Now())
+	log := f.log()
+	if len(log) != 1 || log[0].Status != store.DeliveryOK {
+		t.Fatalf("log = %+v", log)
+	}
+	data, _ := os.ReadFile(out)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || lines[1] != "prod sess-1" {
+		t.Fatalf("command output = %q", data)
+	}
+	var p Payload
+	if err := json.Unmarshal([]byte(lines[0]), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Watch != "prod" || p.Project != "demo" || !strings.Contains(p.MatchedText, "production") {
+		t.Errorf("payload = %+v", p)
+	}
+
+	f.db.SetWatchAction(f.watch.ID, store.WatchAction{Kind: store.ActionCommand, Target: "echo nope >&2; exit 3"})
+	f.say("production is down", time.Now())
+	log = f.log()
+	if len(log) != 2 || log[1].Status != store.DeliveryFailed || log[1].Error != "exit status 3: nope" {
+		t.Errorf("failed command = %+v", log[len(log)-1])
+	}
+}
+
+func TestRunner_Webhook(t *testing.T) {
+	bodies := make(chan Payload, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p Payload
+		json.NewDecoder(r.Body).Decode(&p)
+		if p.Text == "" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad payload", http.StatusBadRequest)
+			return
+		}
+		bodies <- p
+	}))
+	defer srv.Close()
+
+	f := newFixture(t, store.WatchAction{Kind: store.ActionWebhook, Target: srv.URL})
+	f.say("rm -rf build in production", time.Now())
+	if log := f.log(); len(log) != 1 || log[0].Status != store.DeliveryOK {
+		t.Fatalf("log = %+v", log)
+	}
+	if p := <-bodies; p.SessionID != "sess-1" || !strings.Contains(p.Text, "prod matched in demo") {
+		t.Errorf("payload = %+v", p)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, "relay down", http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	f.db.SetWatchAction(f.watch.ID, store.WatchAction{Kind: store.ActionWebhook, Target: failing.URL})
+	f.say("production again", time.Now())
+	if log := f.log(); log[1].Status != store.DeliveryFailed || log[1].Error != "HTTP 502: relay down" {
+		t.Errorf("failed webhook = %+v", log[1])
+	}
+}
+
+func TestRunner_RateLimit(t *testing.T) {
+	f := newFixture(t, store.WatchAction{Kind: store.ActionCommand, Target: "true", Limit: 2})
+	for i := range 4 {
+		f.say(fmt.Sprintf("production %d", i), time.Now())
+	}
+	var statuses []string
+	for _, d := range f.log() {
+		statuses = append(statuses, d.Status)
+	}
+	want := "ok ok rate_limited rate_limited"
+	if strings.Join(statuses, " ") != want {
+		t.Errorf("statuses = %v, want %s", statuses, want)
+	}
+}
+
+func TestRunner_SkipsOldAndRedelivery(t *testing.T) {
+	f := newFixture(t, store.WatchAction{Kind: store.ActionCommand, Target: "true"})
+
+	f.say("production, last week", time.Now().Add(-7*24*time.Hour))
+	if log := f.log(); len(log) != 0 {
+		t.Fatalf("old message delivered: %+v", log)
+	}
+
+	f.say("production, just now", time.Now())
+	if log := f.log(); len(log) != 1 {
+		t.Fatalf("log = %+v", log)
+	}
+
+	// Re-indexing finds the same matches under new IDs
+	if err := f.db.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.db.IndexChanged(nil); err != nil {
+		t.Fatal(err)
+	}
+	if log := f.log(); len(log) != 1 {
+		t.Errorf("re-index delivered again: %+v", log)
+	}
+}
+
+func TestRunner_Close(t *testing.T) {
+	f := newFixture(t, store.WatchAction{Kind: store.ActionCommand, Target: "true"})
+	f.runner.Close()
+	f.say("production after close", time.Now())
+	if log := f.log(); len(log) != 0 {
+		t.Errorf("closed runner delivered: %+v", log)
+	}
+}
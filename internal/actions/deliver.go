@@ -0,0 +1,118 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorOutput caps how much of a failed command's output or a rejected
+// webhook's response body is kept in the delivery log.
+const maxErrorOutput = 500
+
+// env returns the payload as CLOG_* environment variables.
+func (p Payload) env() []string {
+	return []string{
+		"CLOG_WATCH=" + p.Watch,
+		"CLOG_WATCH_ID=" + strconv.FormatInt(p.WatchID, 10),
+		"CLOG_PATTERN=" + p.Pattern,
+		"CLOG_MATCH_ID=" + strconv.FormatInt(p.MatchID, 10),
+		"CLOG_SESSION_ID=" + p.SessionID,
+		"CLOG_PROJECT=" + p.Project,
+		"CLOG_TIMESTAMP=" + p.Timestamp,
+		"CLOG_MATCHED_TEXT=" + p.MatchedText,
+	}
+}
+
+// runCommand runs script with the shell, passing the payload as JSON on
+// stdin and in the environment. A non-zero exit fails the delivery.
+func (r *Runner) runCommand(script string, p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(r.ctx, commandTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, script)
+	cmd.Env = append(os.Environ(), p.env()...)
+	cmd.Stdin = bytes.NewReader(append(body, '\n'))
+	// Don't wait on background children that inherited the output pipe
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s", commandTimeout)
+		}
+		if msg := lastBytes(out); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// postWebhook POSTs the payload as JSON. Any status outside 2xx fails the
+// delivery.
+func (r *Runner) postWebhook(url string, p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "clog")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorOutput))
+		if msg := lastBytes(data); msg != "" {
+			return fmt.Errorf("HTTP %d: %s", resp.StatusCode, msg)
+		}
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// writePipe writes the payload as one JSON line to the named pipe at path.
+// Lines are short enough that concurrent writers do not interleave.
+func writePipe(path string, p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	f, err := openPipe(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(body, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lastBytes returns the end of out, trimmed to maxErrorOutput bytes and one
+// line.
+func lastBytes(out []byte) string {
+	s := strings.TrimSpace(string(out))
+	if len(s) > maxErrorOutput {
+		s = "..." + s[len(s)-maxErrorOutput:]
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,43 @@
+//go:build !windows
+
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func shellCommand(ctx context.Context, script string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", script)
+}
+
+// openPipe opens the named pipe at path for writing without blocking, so a
+// pipe nobody is reading fails the delivery instead of hanging it. Anything
+// but a named pipe is refused, so a target replaced by a regular file or a
+// symlink is never appended to.
+func openPipe(path string) (*os.File, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.Mode()&os.ModeNamedPipe == 0 {
+		return nil, fmt.Errorf("%s is not a named pipe", path)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|syscall.O_NONBLOCK|syscall.O_NOFOLLOW, 0)
+	if errors.Is(err, syscall.ENXIO) {
+		return nil, fmt.Errorf("no process is reading %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The path may have been swapped between the Lstat and the open
+	if fi, err := f.Stat(); err != nil || fi.Mode()&os.ModeNamedPipe == 0 {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a named pipe", path)
+	}
+	return f, nil
+}
@@ -0,0 +1,56 @@
+//go:build !windows
+
+package actions
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+func TestRunner_Pipe(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "feed")
+	if err := syscall.Mkfifo(target, 0o600); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+	f := newFixture(t, store.WatchAction{Kind: store.ActionPipe, Target: target})
+
+	// Nobody is reading, so the delivery fails instead of blocking
+	f.say("production one", time.Now())
+	if log := f.log(); log[0].Status != store.DeliveryFailed || !strings.Contains(log[0].Error, "no process is reading") {
+		t.Errorf("unread pipe = %+v", log[0])
+	}
+
+	reader, err := os.OpenFile(target, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.say("production two", time.Now())
+	if log := f.log(); log[1].Status != store.DeliveryOK {
+		t.Errorf("pipe delivery = %+v", log[1])
+	}
+	buf := make([]byte, 4096)
+	n, _ := reader.Read(buf)
+	reader.Close()
+	var p Payload
+	if err := json.Unmarshal(buf[:n], &p); err != nil || !strings.Contains(p.MatchedText, "production two") {
+		t.Errorf("pipe got %q (%v)", buf[:n], err)
+	}
+
+	// A regular file swapped in for the pipe is never appended to
+	os.Remove(target)
+	os.WriteFile(target, nil, 0o644)
+	f.say("production three", time.Now())
+	if log := f.log(); log[2].Status != store.DeliveryFailed || !strings.Contains(log[2].Error, "not a named pipe") {
+		t.Errorf("regular file = %+v", log[2])
+	}
+	if data, _ := os.ReadFile(target); len(data) != 0 {
+		t.Errorf("regular file written: %q", data)
+	}
+}
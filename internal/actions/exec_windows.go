@@ -0,0 +1,16 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"os/exec"
+)
+
+func shellCommand(ctx context.Context, script string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", script)
+}
+
+// openPipe opens a named pipe such as \\.\pipe\clog for writing.
+func openPipe(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+}
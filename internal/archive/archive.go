@@ -0,0 +1,176 @@
+// Package archive is a content-addressed store of gzip-compressed session
+// transcripts. Blobs are named by the SHA-256 of their uncompressed content,
+// so identical snapshots are stored once. Which file a blob belongs to is
+// recorded by the caller; the archive itself only knows about blobs.
+package archive
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const blobExt = ".gz"
+
+type Archive struct {
+	dir string
+}
+
+// New returns an archive rooted at dir. The directory is created on the
+// first Put.
+func New(dir string) *Archive {
+	return &Archive{dir: dir}
+}
+
+// Dir returns the archive's root directory.
+func (a *Archive) Dir() string {
+	return a.dir
+}
+
+// Blob is a stored snapshot.
+type Blob struct {
+	Hash string
+	Size int64 // compressed size on disk
+}
+
+func (a *Archive) blobPath(hash string) string {
+	return filepath.Join(a.dir, hash[:2], hash+blobExt)
+}
+
+func validHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// PutFile snapshots the file at path. It returns the content hash and the
+// compressed size. A file whose content is already archived is not written
+// again.
+func (a *Archive) PutFile(path string) (Blob, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Blob{}, err
+	}
+	defer f.Close()
+	return a.Put(f)
+}
+
+// Put compresses r into the archive. The blob is written to a temp file and
+// renamed into place, so readers never see a partial blob.
+func (a *Archive) Put(r io.Reader) (Blob, error) {
+	if err := os.MkdirAll(a.dir, 0o755); err != nil {
+		return Blob{}, err
+	}
+	tmp, err := os.CreateTemp(a.dir, ".blob-*")
+	if err != nil {
+		return Blob{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	zw := gzip.NewWriter(tmp)
+	if _, err := io.Copy(zw, io.TeeReader(r, h)); err != nil {
+		tmp.Close()
+		return Blob{}, err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return Blob{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Blob{}, err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	dest := a.blobPath(hash)
+	if info, err := os.Stat(dest); err == nil {
+		return Blob{Hash: hash, Size: info.Size()}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return Blob{}, err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return Blob{}, err
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		return Blob{}, err
+	}
+	return Blob{Hash: hash, Size: info.Size()}, nil
+}
+
+// Open returns the uncompressed content of a blob.
+func (a *Archive) Open(hash string) (io.ReadCloser, error) {
+	if !validHash(hash) {
+		return nil, fmt.Errorf("invalid blob hash %q", hash)
+	}
+	f, err := os.Open(a.blobPath(hash))
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("blob %s: %w", hash[:12], err)
+	}
+	return &blobReader{Reader: zr, f: f}, nil
+}
+
+type blobReader struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (r *blobReader) Close() error {
+	r.Reader.Close()
+	return r.f.Close()
+}
+
+// Remove deletes a blob. Removing a blob that does not exist is not an error.
+func (a *Archive) Remove(hash string) error {
+	if !validHash(hash) {
+		return fmt.Errorf("invalid blob hash %q", hash)
+	}
+	err := os.Remove(a.blobPath(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Blobs lists every stored blob.
+func (a *Archive) Blobs() ([]Blob, error) {
+	var blobs []Blob
+	err := filepath.WalkDir(a.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == a.dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() || !strings.HasSuffix(name, blobExt) {
+			return nil
+		}
+		hash := strings.TrimSuffix(name, blobExt)
+		if !validHash(hash) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		blobs = append(blobs, Blob{Hash: hash, Size: info.Size()})
+		return nil
+	})
+	return blobs, err
+}
@@ -0,0 +1,87 @@
+package archive
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPutOpenRoundTrip(t *testing.T) {
+	a := New(filepath.Join(t.TempDir(), "archive"))
+	content := strings.Repeat(`{"type":"user","message":{"content":"hello"}}`+"\n", 200)
+
+	b, err := a.Put(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Hash) != 64 {
+		t.Errorf("hash = %q", b.Hash)
+	}
+	if b.Size <= 0 || b.Size >= int64(len(content)) {
+		t.Errorf("compressed size = %d for %d bytes", b.Size, len(content))
+	}
+
+	r, err := a.Open(b.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Error("round-tripped content differs")
+	}
+}
+
+func TestPutDeduplicates(t *testing.T) {
+	a := New(t.TempDir())
+	b1, _ := a.Put(strings.NewReader("same"))
+	b2, _ := a.Put(strings.NewReader("same"))
+	b3, _ := a.Put(strings.NewReader("different"))
+	if b1.Hash != b2.Hash {
+		t.Error("identical content stored under different hashes")
+	}
+	if b1.Hash == b3.Hash {
+		t.Error("different content stored under the same hash")
+	}
+
+	blobs, err := a.Blobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 2 {
+		t.Errorf("blobs = %d, want 2", len(blobs))
+	}
+}
+
+func TestRemove(t *testing.T) {
+	a := New(t.TempDir())
+	b, _ := a.Put(strings.NewReader("gone soon"))
+	if err := a.Remove(b.Hash); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Open(b.Hash); err == nil {
+		t.Error("removed blob still opens")
+	}
+	if err := a.Remove(b.Hash); err != nil {
+		t.Errorf("second remove: %v", err)
+	}
+}
+
+func TestInvalidHash(t *testing.T) {
+	a := New(t.TempDir())
+	if _, err := a.Open("../../etc/passwd"); err == nil {
+		t.Error("expected error for invalid hash")
+	}
+}
+
+func TestBlobsEmptyArchive(t *testing.T) {
+	a := New(filepath.Join(t.TempDir(), "never-created"))
+	blobs, err := a.Blobs()
+	if err != nil || len(blobs) != 0 {
+		t.Errorf("Blobs() = %v, %v", blobs, err)
+	}
+}
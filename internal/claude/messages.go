@@ -2,10 +2,16 @@ package claude
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"sort"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -25,25 +31,137 @@ type Message struct {
 	Model     string
 	Role      string
 
+	// ResponseID identifies the API response an assistant message came
+	// from. Claude Code writes one line per content block of a streamed
+	// response, all carrying the same id; the parser merges them.
+	ResponseID string
+
+	// Conversation structure. ParentUUID is the nearest earlier message
+	// this one follows; lines clog skips are looked through. Sidechain
+	// messages belong to a subagent rather than the main conversation.
+	ParentUUID  string
+	IsSidechain bool
+	AgentID     string // set on the lines of a subagent transcript
+
+	// Where the message's lines are in its session file: the offset of
+	// its first line and just past its last. FullText reads them back.
+	Offset, End int64
+
 	// Parsed content
-	Text      string   // plain text content
-	ToolCalls []string // tool names used (assistant messages)
-	ToolName  string   // for tool results, the originating tool
+	Text        string       // plain text content
+	FullLen     int          // length of the full text when Text is only a preview; see FullText
+	ToolCalls   []string     // tool names used (assistant messages)
+	ToolName    string       // for tool results, the originating tool
+	Tools       []ToolCall   // structured tool invocations (assistant messages)
+	ToolResults []ToolResult // tool_result blocks (tool result messages)
+
+	// Extended thinking (assistant messages). Redacted blocks carry only
+	// encrypted data, so they are counted rather than shown.
+	Thinking         string
+	RedactedThinking int
+
+	// Token usage (assistant messages). Cache reads and writes are billed
+	// differently from fresh input, so they are kept apart.
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+
+	// Environment Claude Code recorded on the line. The branch and working
+	// directory can change mid-session.
+	GitBranch  string
+	Cwd        string
+	Version    string // Claude Code version
+	UserType   string // "external" for interactive use
+	Entrypoint string // "cli", "sdk-ts", ...
+
+	// note is text the user typed in the same turn as tool results, such
+	// as feedback on a rejected call. transcript.add splits it out into a
+	// user message of its own.
+	note string
+}
+
+// Usage is token accounting summed over one or more API calls.
+type Usage struct {
+	InputTokens      int `json:"input_tokens"`
+	OutputTokens     int `json:"output_tokens"`
+	CacheReadTokens  int `json:"cache_read_tokens"`
+	CacheWriteTokens int `json:"cache_write_tokens"`
+}
+
+// Usage returns the message's token counters.
+func (m Message) Usage() Usage {
+	return Usage{
+		InputTokens:      m.InputTokens,
+		OutputTokens:     m.OutputTokens,
+		CacheReadTokens:  m.CacheReadTokens,
+		CacheWriteTokens: m.CacheWriteTokens,
+	}
+}
+
+// Add accumulates o into u.
+func (u *Usage) Add(o Usage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CacheReadTokens += o.CacheReadTokens
+	u.CacheWriteTokens += o.CacheWriteTokens
+}
 
-	// Token usage (assistant messages)
-	InputTokens  int
-	OutputTokens int
+// TotalInput is every input token the model saw, cached or not.
+func (u Usage) TotalInput() int {
+	return u.InputTokens + u.CacheReadTokens + u.CacheWriteTokens
 }
 
+// Total is all input and output tokens.
+func (u Usage) Total() int {
+	return u.TotalInput() + u.OutputTokens
+}
+
+// ToolCall is a single tool_use block, paired with its tool_result once
+// the result has been seen later in the session.
+type ToolCall struct {
+	ID       string
+	Name     string
+	Input    map[string]interface{}
+	Result   string
+	IsError  bool
+	Duration time.Duration // time between the call and its result
+	Answered bool          // true once a matching tool_result was found
+	AgentID  string        // subagent a Task call spawned, once linked
+}
+
+// ToolResult is a single tool_result block, keyed by the tool_use it answers.
+type ToolResult struct {
+	ToolUseID string
+	Text      string
+	IsError   bool
+	AgentID   string // subagent that produced a Task result
+}
+
+// maxToolResultLen caps the result text kept on a ToolCall.
+const maxToolResultLen = 2000
+
 // rawMessage is used for initial JSON parsing to determine type.
 type rawMessage struct {
-	Type      string          `json:"type"`
-	UUID      string          `json:"uuid"`
-	Timestamp string          `json:"timestamp"`
-	Message   json.RawMessage `json:"message"`
+	Type              string          `json:"type"`
+	UUID              string          `json:"uuid"`
+	ParentUUID        string          `json:"parentUuid"`
+	LogicalParentUUID string          `json:"logicalParentUuid"` // set instead of parentUuid after a compaction
+	IsSidechain       bool            `json:"isSidechain"`
+	Timestamp         string          `json:"timestamp"`
+	RequestID         string          `json:"requestId"`
+	Message           json.RawMessage `json:"message"`
+	GitBranch         string          `json:"gitBranch"`
+	Cwd               string          `json:"cwd"`
+	Version           string          `json:"version"`
+	UserType          string          `json:"userType"`
+	Entrypoint        string          `json:"entrypoint"`
+	AgentID           string          `json:"agentId"`
+	ToolUseResult     json.RawMessage `json:"toolUseResult"`
 }
 
 type messageContent struct {
+	ID      string          `json:"id"`
 	Role    string          `json:"role"`
 	Model   string          `json:"model"`
 	Content json.RawMessage `json:"content"`
@@ -56,56 +174,312 @@ type messageContent struct {
 }
 
 type contentBlock struct {
-	Type  string          `json:"type"`
-	Text  string          `json:"text"`
-	Name  string          `json:"name"`
-	Input json.RawMessage `json:"input"`
+	Type      string          `json:"type"`
+	ID        string          `json:"id"`
+	Text      string          `json:"text"`
+	Thinking  string          `json:"thinking"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	IsError   bool            `json:"is_error"`
+	Content   json.RawMessage `json:"content"`
+}
+
+// archiveOpener reads transcripts whose file is gone; see SetArchive.
+var archiveOpener func(path string) (io.ReadCloser, error)
+
+// SetArchive registers a fallback LoadMessages uses for session files that
+// no longer exist on disk, such as clog's transcript archive. Pass nil to
+// remove it.
+func SetArchive(open func(path string) (io.ReadCloser, error)) {
+	archiveOpener = open
+}
+
+// openTranscript opens a session file, falling back to the archive when it
+// has been deleted.
+func openTranscript(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err == nil {
+		return f, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) && archiveOpener != nil {
+		if r, aerr := archiveOpener(path); aerr == nil {
+			return r, nil
+		}
+	}
+	return nil, err
 }
 
 func LoadMessages(jsonlPath string) ([]Message, error) {
-	f, err := os.Open(jsonlPath)
+	f, err := openTranscript(jsonlPath)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	var messages []Message
+	t := newTranscript()
 	scanner := bufio.NewScanner(f)
 	scanner.Buffer(make([]byte, 0), 10*1024*1024) // 10MB max line
+	var start, end int64
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if token != nil {
+			start, end = end, end+int64(advance)
+		}
+		return advance, token, err
+	})
 
 	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
+		t.add(scanner.Bytes(), start, end)
+	}
+
+	LinkToolResults(t.messages)
+	return t.messages, scanner.Err()
+}
+
+// LoadMessagesFrom parses the lines of a JSONL file starting at byte offset.
+// It returns the parsed messages, the offset just past the last line it
+// consumed, and the number of lines consumed. A trailing line without a
+// newline is only consumed if it is complete JSON; otherwise it is left for
+// the next call, since Claude Code may still be writing it.
+func LoadMessagesFrom(jsonlPath string, offset int64) ([]Message, int64, int, error) {
+	f, err := os.Open(jsonlPath)
+	if err != nil {
+		return nil, offset, 0, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, 0, err
+	}
+
+	t := newTranscript()
+	end := offset
+	lines := 0
+	reader := bufio.NewReaderSize(f, 64*1024)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) == 0 || !json.Valid(line) {
+				break
+			}
+		} else if err != nil {
+			return t.messages, end, lines, err
 		}
 
-		var raw rawMessage
-		if err := json.Unmarshal(line, &raw); err != nil {
-			continue
+		start := end
+		end += int64(len(line))
+		lines++
+		t.add(bytes.TrimRight(line, "\r\n"), start, end)
+		if err == io.EOF {
+			break
 		}
+	}
 
-		msg := parseMessage(raw)
-		if msg != nil {
-			messages = append(messages, *msg)
+	LinkToolResults(t.messages)
+	return t.messages, end, lines, nil
+}
+
+// transcript accumulates the messages parsed from consecutive JSONL lines.
+type transcript struct {
+	messages  []Message
+	links     parentLinks
+	responses map[string]int // index in messages of each assistant response
+}
+
+func newTranscript() *transcript {
+	return &transcript{links: make(parentLinks), responses: make(map[string]int)}
+}
+
+// add parses the line found between offsets start and end and appends its
+// message. A line continuing a streamed response merges into the
+// response's first message instead, and its children are attached to that
+// message.
+func (t *transcript) add(line []byte, start, end int64) {
+	msg := parseLine(line, t.links)
+	if msg == nil {
+		return
+	}
+	msg.Offset, msg.End = start, end
+	if msg.note != "" {
+		t.messages = append(t.messages, msg.splitNote())
+	}
+	if msg.Type == TypeAssistant && msg.ResponseID != "" {
+		if i, ok := t.responses[msg.ResponseID]; ok {
+			first := &t.messages[i]
+			first.Merge(*msg)
+			first.End = end
+			if msg.UUID != "" && msg.UUID != first.UUID {
+				t.links[msg.UUID] = first.UUID
+			}
+			return
+		}
+		t.responses[msg.ResponseID] = len(t.messages)
+	}
+	t.messages = append(t.messages, *msg)
+}
+
+// splitNote returns the text typed alongside a tool result message as a
+// user message, and makes it the result's parent so the conversation stays
+// a single line. The result keeps the line's uuid, which later lines refer
+// to.
+func (m *Message) splitNote() Message {
+	note := *m
+	note.Type, note.Role, note.UUID, note.Text = TypeUser, "user", m.UUID+"-text", m.note
+	note.FullLen, note.ToolResults, note.note = 0, nil, ""
+	m.ParentUUID, m.note = note.UUID, ""
+	return note
+}
+
+// Merge folds o, a later line of the same streamed response, into m. Every
+// line repeats the response's usage, which only grows as it streams, so
+// the larger of each counter is kept rather than the sum.
+func (m *Message) Merge(o Message) {
+	m.Text = joinNonEmpty(m.Text, o.Text)
+	m.Thinking = joinNonEmpty(m.Thinking, o.Thinking)
+	m.RedactedThinking += o.RedactedThinking
+	m.ToolCalls = append(m.ToolCalls, o.ToolCalls...)
+	m.Tools = append(m.Tools, o.Tools...)
+	if m.Model == "" {
+		m.Model = o.Model
+	}
+	m.InputTokens = max(m.InputTokens, o.InputTokens)
+	m.OutputTokens = max(m.OutputTokens, o.OutputTokens)
+	m.CacheReadTokens = max(m.CacheReadTokens, o.CacheReadTokens)
+	m.CacheWriteTokens = max(m.CacheWriteTokens, o.CacheWriteTokens)
+}
+
+// joinNonEmpty joins two blocks of text with a newline, skipping empty ones.
+func joinNonEmpty(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return a + "\n" + b
+}
+
+// parseLine decodes a single JSONL line, returning nil for blank, malformed
+// or unsupported lines. links records the parents of skipped lines so the
+// messages after them can be attached to the nearest kept ancestor.
+func parseLine(line []byte, links parentLinks) *Message {
+	if len(line) == 0 {
+		return nil
+	}
+	var raw rawMessage
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return nil
+	}
+	parent := raw.ParentUUID
+	if parent == "" {
+		parent = raw.LogicalParentUUID
+	}
+	msg := parseMessage(raw)
+	if msg == nil {
+		if raw.UUID != "" {
+			links[raw.UUID] = parent
+		}
+		return nil
+	}
+	msg.ParentUUID = links.resolve(parent)
+	msg.IsSidechain = raw.IsSidechain
+	msg.AgentID = raw.AgentID
+	return msg
+}
+
+// parentLinks maps the uuid of each skipped line to its parent.
+type parentLinks map[string]string
+
+// resolve follows parent through skipped lines to a kept message.
+func (l parentLinks) resolve(parent string) string {
+	for range len(l) + 1 {
+		next, skipped := l[parent]
+		if !skipped {
+			return parent
+		}
+		parent = next
+	}
+	return "" // cycle
+}
+
+// LinkToolResults pairs every tool_result with the tool_use that produced it,
+// filling in the call's result, error flag and duration, and setting ToolName
+// on the result message.
+func LinkToolResults(messages []Message) {
+	type callRef struct{ msg, call int }
+	calls := make(map[string]callRef)
+
+	for i := range messages {
+		m := &messages[i]
+		for j, tc := range m.Tools {
+			if tc.ID != "" {
+				calls[tc.ID] = callRef{i, j}
+			}
+		}
+
+		var names []string
+		for _, r := range m.ToolResults {
+			ref, ok := calls[r.ToolUseID]
+			if !ok {
+				continue
+			}
+			call := &messages[ref.msg].Tools[ref.call]
+			call.Result = r.Text
+			call.IsError = r.IsError
+			call.Answered = true
+			call.Duration = Elapsed(messages[ref.msg].Timestamp, m.Timestamp)
+			if r.AgentID != "" {
+				call.AgentID = r.AgentID
+			}
+			names = append(names, call.Name)
+		}
+		if len(names) > 0 {
+			m.ToolName = strings.Join(names, ", ")
 		}
 	}
+}
+
+// Elapsed returns the time between two message timestamps, or 0 if either
+// cannot be parsed or they are out of order.
+func Elapsed(from, to string) time.Duration {
+	start, err := parseTimestamp(from)
+	if err != nil {
+		return 0
+	}
+	end, err := parseTimestamp(to)
+	if err != nil || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
 
-	return messages, scanner.Err()
+func parseTimestamp(ts string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, ts)
 }
 
 func parseMessage(raw rawMessage) *Message {
+	var msg *Message
 	switch MessageType(raw.Type) {
 	case TypeUser:
-		return parseUserMessage(raw)
+		msg = parseUserMessage(raw)
 	case TypeAssistant:
-		return parseAssistantMessage(raw)
+		msg = parseAssistantMessage(raw)
 	case TypeToolResult:
-		return parseToolResultMessage(raw)
+		msg = parseToolResultMessage(raw)
 	case TypeSystem:
-		return parseSystemMessage(raw)
-	default:
+		msg = parseSystemMessage(raw)
+	}
+	if msg == nil {
 		return nil
 	}
+	msg.GitBranch = raw.GitBranch
+	msg.Cwd = raw.Cwd
+	msg.Version = raw.Version
+	msg.UserType = raw.UserType
+	msg.Entrypoint = raw.Entrypoint
+	return msg
 }
 
 func parseUserMessage(raw rawMessage) *Message {
@@ -114,6 +488,16 @@ func parseUserMessage(raw rawMessage) *Message {
 		return nil
 	}
 
+	// Claude Code records tool results as user turns whose content is a
+	// list of tool_result blocks, sometimes with text the user typed.
+	if hasToolResults(mc.Content) {
+		msg := parseToolResultMessage(raw)
+		if msg != nil {
+			msg.note = extractText(mc.Content)
+		}
+		return msg
+	}
+
 	text := extractText(mc.Content)
 	if text == "" {
 		return nil
@@ -139,32 +523,57 @@ func parseAssistantMessage(raw rawMessage) *Message {
 		return nil
 	}
 
-	var textParts []string
+	var textParts, thinkingParts []string
 	var toolCalls []string
+	var tools []ToolCall
+	redacted := 0
 	for _, b := range blocks {
 		switch b.Type {
 		case "text":
 			if b.Text != "" {
 				textParts = append(textParts, b.Text)
 			}
+		case "thinking":
+			if b.Thinking != "" {
+				thinkingParts = append(thinkingParts, b.Thinking)
+			}
+		case "redacted_thinking":
+			redacted++
 		case "tool_use":
 			toolCalls = append(toolCalls, b.Name)
+			tc := ToolCall{ID: b.ID, Name: b.Name}
+			if len(b.Input) > 0 {
+				_ = json.Unmarshal(b.Input, &tc.Input)
+			}
+			tools = append(tools, tc)
 		}
 	}
 
+	responseID := mc.ID
+	if responseID == "" {
+		responseID = raw.RequestID
+	}
+
 	msg := &Message{
-		Type:      TypeAssistant,
-		UUID:      raw.UUID,
-		Timestamp: raw.Timestamp,
-		Model:     mc.Model,
-		Role:      "assistant",
-		Text:      strings.Join(textParts, "\n"),
-		ToolCalls: toolCalls,
+		Type:       TypeAssistant,
+		UUID:       raw.UUID,
+		Timestamp:  raw.Timestamp,
+		Model:      mc.Model,
+		Role:       "assistant",
+		ResponseID: responseID,
+		Text:       strings.Join(textParts, "\n"),
+		ToolCalls:  toolCalls,
+		Tools:      tools,
+
+		Thinking:         strings.Join(thinkingParts, "\n"),
+		RedactedThinking: redacted,
 	}
 
 	if mc.Usage != nil {
-		msg.InputTokens = mc.Usage.InputTokens + mc.Usage.CacheReadInputTokens + mc.Usage.CacheCreationInputTokens
+		msg.InputTokens = mc.Usage.InputTokens
 		msg.OutputTokens = mc.Usage.OutputTokens
+		msg.CacheReadTokens = mc.Usage.CacheReadInputTokens
+		msg.CacheWriteTokens = mc.Usage.CacheCreationInputTokens
 	}
 
 	return msg
@@ -176,17 +585,80 @@ func parseToolResultMessage(raw rawMessage) *Message {
 		return nil
 	}
 
-	text := extractToolResultText(mc.Content)
+	results := extractToolResults(mc.Content)
+	text, fullLen := "", 0
+	if len(results) > 0 {
+		text = truncate(results[0].Text, 200)
+	}
+	if full := joinResults(results); full != text {
+		fullLen = len(full)
+	}
+	for i := range results {
+		results[i].Text = truncate(results[i].Text, maxToolResultLen)
+	}
+	// A Task result names the subagent that ran it
+	var tur struct {
+		AgentID string `json:"agentId"`
+	}
+	if len(results) == 1 && json.Unmarshal(raw.ToolUseResult, &tur) == nil {
+		results[0].AgentID = tur.AgentID
+	}
 
 	return &Message{
-		Type:      TypeToolResult,
-		UUID:      raw.UUID,
-		Timestamp: raw.Timestamp,
-		Role:      "tool",
-		Text:      text,
+		Type:        TypeToolResult,
+		UUID:        raw.UUID,
+		Timestamp:   raw.Timestamp,
+		Role:        "tool",
+		Text:        text,
+		FullLen:     fullLen,
+		ToolResults: results,
 	}
 }
 
+// joinResults is the full text of a message's tool results.
+func joinResults(results []ToolResult) string {
+	parts := make([]string, len(results))
+	for i, r := range results {
+		parts[i] = r.Text
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// FullText returns msg's complete text, reading it back from the session
+// file at path when Text is only a preview. Tool results are cut short
+// when a transcript is loaded, so sessions that read large files stay
+// small in memory; the full text of a tool result message joins all its
+// results.
+func FullText(path string, msg Message) (string, error) {
+	if msg.FullLen == 0 || msg.End <= msg.Offset {
+		return msg.Text, nil
+	}
+	f, err := openTranscript(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if s, ok := f.(io.Seeker); ok {
+		_, err = s.Seek(msg.Offset, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, f, msg.Offset)
+	}
+	if err != nil {
+		return "", err
+	}
+	line := make([]byte, msg.End-msg.Offset)
+	if _, err := io.ReadFull(f, line); err != nil {
+		return "", err
+	}
+
+	var raw rawMessage
+	var mc messageContent
+	if json.Unmarshal(line, &raw) != nil || raw.UUID != msg.UUID || json.Unmarshal(raw.Message, &mc) != nil {
+		return "", fmt.Errorf("message %s is no longer at offset %d", msg.UUID, msg.Offset)
+	}
+	return joinResults(extractToolResults(mc.Content)), nil
+}
+
 func parseSystemMessage(raw rawMessage) *Message {
 	// System messages store content in the nested message field.
 	var sys struct {
@@ -226,31 +698,58 @@ func extractText(raw json.RawMessage) string {
 	return strings.Join(parts, "\n")
 }
 
-func extractToolResultText(raw json.RawMessage) string {
-	var blocks []struct {
-		Type    string          `json:"type"`
-		Content json.RawMessage `json:"content"`
+// hasToolResults reports whether content is a block list containing at
+// least one tool_result.
+func hasToolResults(raw json.RawMessage) bool {
+	var blocks []contentBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return false
 	}
+	for _, b := range blocks {
+		if b.Type == "tool_result" {
+			return true
+		}
+	}
+	return false
+}
+
+// extractToolResults returns every tool_result block in content, untruncated.
+func extractToolResults(raw json.RawMessage) []ToolResult {
+	var blocks []contentBlock
 	if err := json.Unmarshal(raw, &blocks); err != nil {
-		return ""
+		return nil
 	}
 
+	var results []ToolResult
 	for _, b := range blocks {
-		if b.Type == "tool_result" {
-			// content is either a string or array of {type, text}
-			var s string
-			if err := json.Unmarshal(b.Content, &s); err == nil {
-				return truncate(s, 200)
-			}
-			var inner []contentBlock
-			if err := json.Unmarshal(b.Content, &inner); err == nil {
-				for _, ib := range inner {
-					if ib.Type == "text" {
-						return truncate(ib.Text, 200)
-					}
-				}
+		if b.Type != "tool_result" {
+			continue
+		}
+		results = append(results, ToolResult{
+			ToolUseID: b.ToolUseID,
+			Text:      toolResultText(b.Content),
+			IsError:   b.IsError,
+		})
+	}
+	return results
+}
+
+// toolResultText flattens tool_result content, which is either a string or
+// an array of {type, text} blocks.
+func toolResultText(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var inner []contentBlock
+	if err := json.Unmarshal(raw, &inner); err == nil {
+		var parts []string
+		for _, ib := range inner {
+			if ib.Type == "text" && ib.Text != "" {
+				parts = append(parts, ib.Text)
 			}
 		}
+		return strings.Join(parts, "\n")
 	}
 	return ""
 }
@@ -268,6 +767,29 @@ func truncate(s string, n int) string {
 	return s[:i] + "..."
 }
 
+// Summary returns a one-line description of what the call did: the command
+// for Bash, the path for file tools, the pattern for searches, and so on.
+func (tc ToolCall) Summary() string {
+	for _, key := range []string{"command", "file_path", "path", "pattern", "url", "query", "description"} {
+		if v, ok := tc.Input[key].(string); ok && v != "" {
+			return strings.ReplaceAll(v, "\n", " ")
+		}
+	}
+	if len(tc.Input) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tc.Input))
+	for k := range tc.Input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	data, err := json.Marshal(tc.Input[keys[0]])
+	if err != nil {
+		return ""
+	}
+	return keys[0] + "=" + string(data)
+}
+
 func FormatModel(model string) string {
 	switch {
 	case strings.Contains(model, "opus"):
@@ -281,6 +803,21 @@ func FormatModel(model string) string {
 	}
 }
 
+// FormatCost formats a dollar amount compactly for status bars and lists.
+func FormatCost(usd float64) string {
+	switch {
+	case usd <= 0:
+		return "$0"
+	case usd < 0.01:
+		return "<$0.01"
+	case usd < 100:
+		return fmt.Sprintf("$%.2f", usd)
+	case usd < 10_000:
+		return fmt.Sprintf("$%.0f", usd)
+	}
+	return fmt.Sprintf("$%.1fK", usd/1_000)
+}
+
 func FormatTokens(n int) string {
 	if n >= 1_000_000 {
 		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
@@ -1,9 +1,12 @@
 package claude
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 // writeTestJSONL creates a temp JSONL file and returns its path.
@@ -87,9 +90,15 @@ func TestLoadMessages_AssistantMessage(t *testing.T) {
 	if m.Model != "claude-3-5-sonnet-20241022" {
 		t.Errorf("model = %q", m.Model)
 	}
-	// Token aggregation: 100 + 20 + 10 = 130 input
-	if m.InputTokens != 130 {
-		t.Errorf("input_tokens = %d, want 130", m.InputTokens)
+	// Cache tokens are counted apart from input; together they make 130
+	if m.InputTokens != 100 {
+		t.Errorf("input_tokens = %d, want 100", m.InputTokens)
+	}
+	if m.CacheReadTokens != 20 || m.CacheWriteTokens != 10 {
+		t.Errorf("cache read/write = %d/%d, want 20/10", m.CacheReadTokens, m.CacheWriteTokens)
+	}
+	if u := m.Usage(); u.TotalInput() != 130 || u.Total() != 180 {
+		t.Errorf("usage totals = %d in, %d total", u.TotalInput(), u.Total())
 	}
 	if m.OutputTokens != 50 {
 		t.Errorf("output_tokens = %d, want 50", m.OutputTokens)
@@ -119,6 +128,29 @@ func TestLoadMessages_AssistantMultipleToolCalls(t *testing.T) {
 	}
 }
 
+func TestLoadMessages_Thinking(t *testing.T) {
+	path := writeTestJSONL(t,
+		`{"type":"assistant","uuid":"a3","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"opus","content":[{"type":"thinking","thinking":"The user wants X.","signature":"sig"},{"type":"redacted_thinking","data":"EncryptedBlob"},{"type":"thinking","thinking":"Check Y first."},{"type":"text","text":"Done."}]}}`,
+	)
+	msgs, err := LoadMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+	m := msgs[0]
+	if m.Text != "Done." {
+		t.Errorf("text = %q, want %q", m.Text, "Done.")
+	}
+	if m.Thinking != "The user wants X.\nCheck Y first." {
+		t.Errorf("thinking = %q", m.Thinking)
+	}
+	if m.RedactedThinking != 1 {
+		t.Errorf("redacted thinking = %d, want 1", m.RedactedThinking)
+	}
+}
+
 func TestLoadMessages_ToolResultStringContent(t *testing.T) {
 	path := writeTestJSONL(t,
 		`{"type":"tool-result","uuid":"t1","timestamp":"2025-01-01T00:00:02Z","message":{"role":"user","content":[{"type":"tool_result","content":"file contents here"}]}}`,
@@ -154,6 +186,36 @@ func TestLoadMessages_ToolResultNestedContent(t *testing.T) {
 	}
 }
 
+func TestLoadMessages_ToolResultWithText(t *testing.T) {
+	path := writeTestJSONL(t,
+		`{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"call-1","name":"Edit","input":{}}]}}`,
+		`{"type":"user","uuid":"u2","parentUuid":"a1","timestamp":"2025-01-01T00:00:02Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"call-1","content":"rejected"},{"type":"text","text":"edit the other config instead"}]}}`,
+		`{"type":"assistant","uuid":"a3","parentUuid":"u2","timestamp":"2025-01-01T00:00:03Z","message":{"role":"assistant","content":[{"type":"text","text":"Will do."}]}}`,
+	)
+	msgs, err := LoadMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 messages, got %d: %+v", len(msgs), msgs)
+	}
+
+	// The typed text is a user message of its own, ahead of the result
+	note, result := msgs[1], msgs[2]
+	if note.Type != TypeUser || note.Text != "edit the other config instead" || note.ParentUUID != "a1" {
+		t.Errorf("note = %+v", note)
+	}
+	if result.Type != TypeToolResult || result.UUID != "u2" || result.ParentUUID != note.UUID || result.Text != "rejected" {
+		t.Errorf("result = %+v", result)
+	}
+	if !msgs[0].Tools[0].Answered || msgs[3].ParentUUID != "u2" {
+		t.Errorf("call = %+v, reply parent = %q", msgs[0].Tools[0], msgs[3].ParentUUID)
+	}
+	if n := BuildTree(msgs).Forks(); n != 0 {
+		t.Errorf("note made %d forks", n)
+	}
+}
+
 func TestLoadMessages_ToolResultTruncated(t *testing.T) {
 	long := string(make([]byte, 300)) // 300 null bytes won't be useful but tests truncation
 	// Use a real long string
@@ -177,6 +239,89 @@ func TestLoadMessages_ToolResultTruncated(t *testing.T) {
 	}
 }
 
+func TestLoadMessages_ToolCallsPairedWithResults(t *testing.T) {
+	path := writeTestJSONL(t,
+		`{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"sonnet","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"go test ./...","timeout":60000}},{"type":"tool_use","id":"toolu_2","name":"Read","input":{"file_path":"main.go"}}]}}`,
+		`{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:03.500Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","is_error":true,"content":"FAIL: TestFoo"},{"type":"tool_result","tool_use_id":"toolu_2","content":[{"type":"text","text":"package main"}]}]}}`,
+	)
+	msgs, err := LoadMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+
+	calls := msgs[0].Tools
+	if len(calls) != 2 {
+		t.Fatalf("tools len = %d, want 2", len(calls))
+	}
+	bash := calls[0]
+	if bash.ID != "toolu_1" || bash.Name != "Bash" {
+		t.Errorf("call[0] = %q/%q", bash.ID, bash.Name)
+	}
+	if bash.Input["command"] != "go test ./..." {
+		t.Errorf("input command = %v", bash.Input["command"])
+	}
+	if !bash.Answered || !bash.IsError {
+		t.Errorf("answered = %v, is_error = %v, want both true", bash.Answered, bash.IsError)
+	}
+	if bash.Result != "FAIL: TestFoo" {
+		t.Errorf("result = %q", bash.Result)
+	}
+	if bash.Duration != 2500*time.Millisecond {
+		t.Errorf("duration = %v, want 2.5s", bash.Duration)
+	}
+	if calls[1].IsError || calls[1].Result != "package main" {
+		t.Errorf("call[1] = %+v", calls[1])
+	}
+
+	res := msgs[1]
+	if res.Type != TypeToolResult {
+		t.Errorf("result type = %q, want %q", res.Type, TypeToolResult)
+	}
+	if res.ToolName != "Bash, Read" {
+		t.Errorf("tool_name = %q", res.ToolName)
+	}
+	if len(res.ToolResults) != 2 || res.ToolResults[0].ToolUseID != "toolu_1" {
+		t.Errorf("tool_results = %+v", res.ToolResults)
+	}
+}
+
+func TestLoadMessages_UnansweredToolCall(t *testing.T) {
+	path := writeTestJSONL(t,
+		`{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"sonnet","content":[{"type":"tool_use","id":"toolu_9","name":"Bash","input":{"command":"sleep 100"}}]}}`,
+	)
+	msgs, err := LoadMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || len(msgs[0].Tools) != 1 {
+		t.Fatalf("unexpected messages: %+v", msgs)
+	}
+	if msgs[0].Tools[0].Answered {
+		t.Error("expected call without result to be unanswered")
+	}
+}
+
+func TestToolCallSummary(t *testing.T) {
+	tests := []struct {
+		call ToolCall
+		want string
+	}{
+		{ToolCall{Name: "Bash", Input: map[string]interface{}{"command": "ls -la\npwd"}}, "ls -la pwd"},
+		{ToolCall{Name: "Edit", Input: map[string]interface{}{"file_path": "a.go", "old_string": "x"}}, "a.go"},
+		{ToolCall{Name: "Grep", Input: map[string]interface{}{"pattern": "TODO"}}, "TODO"},
+		{ToolCall{Name: "Custom", Input: map[string]interface{}{"b": 2, "a": true}}, "a=true"},
+		{ToolCall{Name: "Empty"}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.call.Summary(); got != tt.want {
+			t.Errorf("%s.Summary() = %q, want %q", tt.call.Name, got, tt.want)
+		}
+	}
+}
+
 func TestLoadMessages_SkipsUnknownTypes(t *testing.T) {
 	path := writeTestJSONL(t,
 		`{"type":"progress","uuid":"p1","timestamp":"2025-01-01T00:00:00Z"}`,
@@ -266,6 +411,28 @@ func TestLoadMessages_MixedConversation(t *testing.T) {
 	}
 }
 
+func TestLoadMessages_Environment(t *testing.T) {
+	path := writeTestJSONL(t,
+		`{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","gitBranch":"main","cwd":"/src/app","version":"1.0.80","userType":"external","entrypoint":"cli","message":{"role":"user","content":"hi"}}`,
+		`{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:01Z","gitBranch":"feature/x","cwd":"/src/app/web","version":"1.0.80","message":{"role":"assistant","content":[{"type":"text","text":"ok"}]}}`,
+	)
+	msgs, err := LoadMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	u := msgs[0]
+	if u.GitBranch != "main" || u.Cwd != "/src/app" || u.Version != "1.0.80" ||
+		u.UserType != "external" || u.Entrypoint != "cli" {
+		t.Errorf("user environment = %q %q %q %q %q", u.GitBranch, u.Cwd, u.Version, u.UserType, u.Entrypoint)
+	}
+	if a := msgs[1]; a.GitBranch != "feature/x" || a.Cwd != "/src/app/web" {
+		t.Errorf("assistant branch/cwd = %q %q", a.GitBranch, a.Cwd)
+	}
+}
+
 func TestLoadMessages_MissingFile(t *testing.T) {
 	_, err := LoadMessages("/nonexistent/path/file.jsonl")
 	if err == nil {
@@ -316,6 +483,26 @@ func TestFormatTokens(t *testing.T) {
 	}
 }
 
+func TestFormatCost(t *testing.T) {
+	tests := []struct {
+		input float64
+		want  string
+	}{
+		{0, "$0"},
+		{0.004, "<$0.01"},
+		{0.42, "$0.42"},
+		{12.345, "$12.35"},
+		{1234.5, "$1234"},
+		{25000, "$25.0K"},
+	}
+	for _, tt := range tests {
+		got := FormatCost(tt.input)
+		if got != tt.want {
+			t.Errorf("FormatCost(%v) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
 func TestTruncate(t *testing.T) {
 	if got := truncate("short", 200); got != "short" {
 		t.Errorf("truncate short = %q", got)
@@ -328,3 +515,206 @@ func TestTruncate(t *testing.T) {
 		t.Errorf("truncate exact = %q", got)
 	}
 }
+
+func TestLoadMessagesFrom_Offsets(t *testing.T) {
+	line1 := `{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"first"}}`
+	line2 := `{"type":"user","uuid":"u2","timestamp":"2025-01-01T00:00:01Z","message":{"role":"user","content":"second"}}`
+	path := writeTestJSONL(t, line1, line2)
+
+	msgs, end, lines, err := LoadMessagesFrom(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || lines != 2 {
+		t.Fatalf("got %d msgs / %d lines, want 2/2", len(msgs), lines)
+	}
+	if want := int64(len(line1) + len(line2) + 2); end != want {
+		t.Errorf("end = %d, want %d", end, want)
+	}
+
+	msgs, _, _, err = LoadMessagesFrom(path, int64(len(line1)+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Text != "second" {
+		t.Errorf("from offset = %+v", msgs)
+	}
+}
+
+func TestLoadMessagesFrom_PartialTrailingLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "partial.jsonl")
+	complete := `{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"done"}}` + "\n"
+	os.WriteFile(path, []byte(complete+`{"type":"user","uuid":"u2","mess`), 0o644)
+
+	msgs, end, lines, err := LoadMessagesFrom(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || lines != 1 || end != int64(len(complete)) {
+		t.Errorf("got %d msgs, %d lines, end %d; want 1, 1, %d", len(msgs), lines, end, len(complete))
+	}
+
+	// A final line without a newline is consumed once it is complete JSON
+	unterminated := `{"type":"user","uuid":"u2","timestamp":"2025-01-01T00:00:01Z","message":{"role":"user","content":"no newline"}}`
+	os.WriteFile(path, []byte(complete+unterminated), 0o644)
+	msgs, end, _, _ = LoadMessagesFrom(path, 0)
+	if len(msgs) != 2 || end != int64(len(complete)+len(unterminated)) {
+		t.Errorf("unterminated line: %d msgs, end %d", len(msgs), end)
+	}
+}
+
+// streamedResponse is one API response as Claude Code writes it: a line
+// per content block, each repeating the response's id, request id and
+// usage, chained by parentUuid, followed by the results of its tool calls.
+var streamedResponse = []string{
+	`{"parentUuid":null,"isSidechain":false,"userType":"external","cwd":"/work/api","sessionId":"5f0c","version":"1.0.83","gitBranch":"main","type":"user","message":{"role":"user","content":"why is the build failing?"},"uuid":"7d1e","timestamp":"2025-08-20T10:00:00.000Z"}`,
+	`{"parentUuid":"7d1e","isSidechain":false,"userType":"external","cwd":"/work/api","sessionId":"5f0c","version":"1.0.83","gitBranch":"main","message":{"id":"msg_01XdQ","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"thinking","thinking":"Check the build log first.","signature":"EqkB"}],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":4,"cache_creation_input_tokens":1200,"cache_read_input_tokens":15000,"output_tokens":8,"service_tier":"standard"}},"requestId":"req_011CS","type":"assistant","uuid":"a1b2","timestamp":"2025-08-20T10:00:02.100Z"}`,
+	`{"parentUuid":"a1b2","isSidechain":false,"userType":"external","cwd":"/work/api","sessionId":"5f0c","version":"1.0.83","gitBranch":"main","message":{"id":"msg_01XdQ","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"text","text":"Let me look at the build output."}],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":4,"cache_creation_input_tokens":1200,"cache_read_input_tokens":15000,"output_tokens":8,"service_tier":"standard"}},"requestId":"req_011CS","type":"assistant","uuid":"c3d4","timestamp":"2025-08-20T10:00:02.600Z"}`,
+	`{"parentUuid":"c3d4","isSidechain":false,"userType":"external","cwd":"/work/api","sessionId":"5f0c","version":"1.0.83","gitBranch":"main","message":{"id":"msg_01XdQ","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"tool_use","id":"toolu_01A","name":"Bash","input":{"command":"make build"}}],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":4,"cache_creation_input_tokens":1200,"cache_read_input_tokens":15000,"output_tokens":8,"service_tier":"standard"}},"requestId":"req_011CS","type":"assistant","uuid":"e5f6","timestamp":"2025-08-20T10:00:03.000Z"}`,
+	`{"parentUuid":"e5f6","isSidechain":false,"userType":"external","cwd":"/work/api","sessionId":"5f0c","version":"1.0.83","gitBranch":"main","message":{"id":"msg_01XdQ","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"tool_use","id":"toolu_01B","name":"Read","input":{"file_path":"/work/api/Makefile"}}],"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":4,"cache_creation_input_tokens":1200,"cache_read_input_tokens":15000,"output_tokens":142,"service_tier":"standard"}},"requestId":"req_011CS","type":"assistant","uuid":"g7h8","timestamp":"2025-08-20T10:00:03.400Z"}`,
+	`{"parentUuid":"g7h8","isSidechain":false,"userType":"external","cwd":"/work/api","sessionId":"5f0c","version":"1.0.83","gitBranch":"main","type":"user","message":{"role":"user","content":[{"tool_use_id":"toolu_01A","type":"tool_result","content":"undefined: NewClient","is_error":true}]},"uuid":"i9j0","timestamp":"2025-08-20T10:00:05.000Z"}`,
+	`{"parentUuid":"i9j0","isSidechain":false,"userType":"external","cwd":"/work/api","sessionId":"5f0c","version":"1.0.83","gitBranch":"main","type":"user","message":{"role":"user","content":[{"tool_use_id":"toolu_01B","type":"tool_result","content":"build:\n\tgo build ./..."}]},"uuid":"k1l2","timestamp":"2025-08-20T10:00:05.200Z"}`,
+}
+
+func TestLoadMessages_MergesStreamedResponse(t *testing.T) {
+	msgs, err := LoadMessages(writeTestJSONL(t, streamedResponse...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("got %d messages, want prompt, one assistant turn and two results", len(msgs))
+	}
+	a := msgs[1]
+	if a.Type != TypeAssistant || a.UUID != "a1b2" || a.ResponseID != "msg_01XdQ" {
+		t.Fatalf("assistant = %+v", a)
+	}
+	if a.Timestamp != "2025-08-20T10:00:02.100Z" {
+		t.Errorf("timestamp = %q, want the first line's", a.Timestamp)
+	}
+	if a.Thinking != "Check the build log first." || a.Text != "Let me look at the build output." {
+		t.Errorf("thinking = %q, text = %q", a.Thinking, a.Text)
+	}
+	if len(a.Tools) != 2 || a.Tools[0].Name != "Bash" || a.Tools[1].Name != "Read" {
+		t.Fatalf("tools = %+v", a.Tools)
+	}
+	if !a.Tools[0].Answered || !a.Tools[0].IsError || !a.Tools[1].Answered {
+		t.Errorf("tool results not linked: %+v", a.Tools)
+	}
+
+	// Usage is counted once, with the final output count
+	want := Usage{InputTokens: 4, OutputTokens: 142, CacheReadTokens: 15000, CacheWriteTokens: 1200}
+	if a.Usage() != want {
+		t.Errorf("usage = %+v, want %+v", a.Usage(), want)
+	}
+
+	// The first result followed the last line of the response
+	if msgs[2].ParentUUID != "a1b2" {
+		t.Errorf("result parent = %q, want a1b2", msgs[2].ParentUUID)
+	}
+	if msgs[3].ParentUUID != "i9j0" {
+		t.Errorf("second result parent = %q", msgs[3].ParentUUID)
+	}
+}
+
+func TestLoadMessages_MergesByRequestID(t *testing.T) {
+	// Older Claude Code versions wrote no message id
+	path := writeTestJSONL(t,
+		`{"type":"assistant","uuid":"a1","requestId":"req_1","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"opus","content":[{"type":"text","text":"first"}],"usage":{"input_tokens":10,"output_tokens":5}}}`,
+		`{"type":"assistant","uuid":"a2","parentUuid":"a1","requestId":"req_1","timestamp":"2025-01-01T00:00:02Z","message":{"role":"assistant","model":"opus","content":[{"type":"text","text":"second"}],"usage":{"input_tokens":10,"output_tokens":5}}}`,
+		`{"type":"assistant","uuid":"a3","parentUuid":"a2","requestId":"req_2","timestamp":"2025-01-01T00:00:03Z","message":{"role":"assistant","model":"opus","content":[{"type":"text","text":"next call"}],"usage":{"input_tokens":20,"output_tokens":5}}}`,
+	)
+	msgs, err := LoadMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want 2", len(msgs))
+	}
+	if msgs[0].Text != "first\nsecond" || msgs[0].InputTokens != 10 {
+		t.Errorf("merged = %q, %d input tokens", msgs[0].Text, msgs[0].InputTokens)
+	}
+	if msgs[1].Text != "next call" || msgs[1].ParentUUID != "a1" {
+		t.Errorf("second response = %q, parent %q", msgs[1].Text, msgs[1].ParentUUID)
+	}
+}
+
+func TestLoadMessagesFrom_MidResponse(t *testing.T) {
+	path := writeTestJSONL(t, streamedResponse...)
+	offset := int64(len(streamedResponse[0]) + len(streamedResponse[1]) + 2)
+
+	// Lines after the first of a response merge with each other; joining
+	// them to the part read earlier is up to the caller
+	msgs, _, lines, err := LoadMessagesFrom(path, offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines != 5 || len(msgs) != 3 {
+		t.Fatalf("lines = %d, messages = %d", lines, len(msgs))
+	}
+	if msgs[0].UUID != "c3d4" || msgs[0].ResponseID != "msg_01XdQ" || len(msgs[0].Tools) != 2 {
+		t.Errorf("continuation = %+v", msgs[0])
+	}
+	if msgs[1].ParentUUID != "c3d4" {
+		t.Errorf("result parent = %q, want c3d4", msgs[1].ParentUUID)
+	}
+}
+
+func TestFullText(t *testing.T) {
+	long := strings.Repeat("line of build output\n", 300)
+	result, _ := json.Marshal(long)
+	path := writeTestJSONL(t,
+		`{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"build it"}}`,
+		`{"type":"user","uuid":"r1","timestamp":"2025-01-01T00:00:02Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":`+string(result)+`},{"type":"tool_result","tool_use_id":"t2","content":"second"}]}}`,
+		`{"type":"user","uuid":"r2","timestamp":"2025-01-01T00:00:03Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t3","content":"short"}]}}`,
+	)
+	msgs, err := LoadMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := msgs[1]
+	if len(r.Text) > 210 || r.FullLen != len(long)+len("\n\nsecond") {
+		t.Fatalf("preview %d bytes, FullLen %d", len(r.Text), r.FullLen)
+	}
+	full, err := FullText(path, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full != long+"\n\nsecond" {
+		t.Errorf("full text is %d bytes, want %d", len(full), r.FullLen)
+	}
+
+	// A complete message is returned as is, without reading the file
+	if msgs[2].FullLen != 0 {
+		t.Errorf("short result FullLen = %d", msgs[2].FullLen)
+	}
+	if got, _ := FullText("/nonexistent", msgs[2]); got != "short" {
+		t.Errorf("FullText = %q", got)
+	}
+
+	// The spans from an offset load agree
+	from, _, _, err := LoadMessagesFrom(path, msgs[1].Offset)
+	if err != nil || len(from) != 2 || from[0].Offset != r.Offset || from[0].End != r.End {
+		t.Fatalf("LoadMessagesFrom spans = %+v, %v", from, err)
+	}
+
+	// A file rewritten since it was loaded is reported, not misread
+	os.WriteFile(path, []byte(strings.Repeat("x", int(r.End))), 0o644)
+	if _, err := FullText(path, r); err == nil {
+		t.Error("FullText read a rewritten file without error")
+	}
+}
+
+func TestLoadMessages_SpansOfMergedResponse(t *testing.T) {
+	path := writeTestJSONL(t, streamedResponse...)
+	msgs, err := LoadMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	a := msgs[1]
+	span := string(data[a.Offset:a.End])
+	if !strings.HasPrefix(span, streamedResponse[1]) || !strings.HasSuffix(span, streamedResponse[4]+"\n") {
+		t.Errorf("span of merged response = %q", span)
+	}
+}
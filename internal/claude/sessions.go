@@ -26,6 +26,12 @@ type SessionEntry struct {
 	GitBranch    string `json:"gitBranch"`
 	ProjectPath  string `json:"projectPath"`
 	IsSidechain  bool   `json:"isSidechain"`
+
+	// Filled in from the index only.
+	Branches []string `json:"-"` // every branch the session was on, in order
+	Cwd      string   `json:"-"` // latest working directory
+	Version  string   `json:"-"` // latest Claude Code version
+	Missing  bool     `json:"-"` // JSONL file is gone; only the index remains
 }
 
 func LoadSessionsIndex(projectDataDir string) (*SessionsIndex, error) {
@@ -83,7 +89,8 @@ func scanJSONLFiles(dir string) ([]SessionEntry, error) {
 		}
 	}
 
-	// Scan UUID subdirectories
+	// Scan UUID subdirectories, leaving out subagent transcripts: those are
+	// part of the session that spawned them
 	for _, e := range entries {
 		if !e.IsDir() {
 			continue
@@ -94,10 +101,11 @@ func scanJSONLFiles(dir string) ([]SessionEntry, error) {
 			continue
 		}
 		for _, se := range subEntries {
-			if se.IsDir() || !strings.HasSuffix(se.Name(), ".jsonl") {
+			path := filepath.Join(subDir, se.Name())
+			if se.IsDir() || !strings.HasSuffix(se.Name(), ".jsonl") || IsSubagentFile(path) {
 				continue
 			}
-			if entry, ok := sessionFromFile(filepath.Join(subDir, se.Name()), se); ok {
+			if entry, ok := sessionFromFile(path, se); ok {
 				sessions = append(sessions, entry)
 			}
 		}
@@ -0,0 +1,171 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Subagent is a conversation a Task tool call spawned.
+type Subagent struct {
+	AgentID  string
+	Path     string // transcript file; "" for a sidechain inside the session file
+	Prompt   string // first user message, which is the Task prompt
+	Messages []Message
+}
+
+// Usage sums the subagent's token usage.
+func (a *Subagent) Usage() Usage {
+	var u Usage
+	for _, m := range a.Messages {
+		u.Add(m.Usage())
+	}
+	return u
+}
+
+// subagentTools are the tools that spawn subagents.
+var subagentTools = map[string]bool{"Task": true, "Agent": true}
+
+// IsSubagentFile reports whether path is a subagent transcript rather than a
+// session: an agent-*.jsonl file, or any file in a subagents directory.
+func IsSubagentFile(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), "agent-") ||
+		filepath.Base(filepath.Dir(path)) == "subagents"
+}
+
+// ParentSessionID returns the session a subagent transcript belongs to: the
+// directory Claude Code keeps it under, <session-id>/ or
+// <session-id>/subagents/.
+func ParentSessionID(path string) string {
+	dir := filepath.Dir(path)
+	if filepath.Base(dir) == "subagents" {
+		dir = filepath.Dir(dir)
+	}
+	return filepath.Base(dir)
+}
+
+// SubagentFiles lists the subagent transcripts of the session stored at
+// sessionPath, from the <session-id>/ directory beside it.
+func SubagentFiles(sessionPath string) []string {
+	dir := strings.TrimSuffix(sessionPath, ".jsonl")
+	var files []string
+	for _, d := range []string{dir, filepath.Join(dir, "subagents")} {
+		entries, err := os.ReadDir(d)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			path := filepath.Join(d, e.Name())
+			if !e.IsDir() && strings.HasSuffix(e.Name(), ".jsonl") && IsSubagentFile(path) {
+				files = append(files, path)
+			}
+		}
+	}
+	return files
+}
+
+// LoadSubagents loads a session's subagents, from their own transcripts and
+// from sidechains inside the session file, and links each to the Task call
+// that spawned it by setting ToolCall.AgentID. They are keyed by agent id.
+func LoadSubagents(sessionPath string, messages []Message) map[string]*Subagent {
+	var agents []*Subagent
+	for _, path := range SubagentFiles(sessionPath) {
+		msgs, err := LoadMessages(path)
+		if err != nil || len(msgs) == 0 {
+			continue
+		}
+		id := strings.TrimPrefix(strings.TrimSuffix(filepath.Base(path), ".jsonl"), "agent-")
+		if msgs[0].AgentID != "" {
+			id = msgs[0].AgentID
+		}
+		agents = append(agents, newSubagent(id, path, msgs))
+	}
+	agents = append(agents, inlineSidechains(messages)...)
+
+	sort.SliceStable(agents, func(i, j int) bool {
+		return agents[i].Messages[0].Timestamp < agents[j].Messages[0].Timestamp
+	})
+	LinkSubagents(messages, agents)
+
+	byID := make(map[string]*Subagent, len(agents))
+	for _, a := range agents {
+		byID[a.AgentID] = a
+	}
+	return byID
+}
+
+func newSubagent(id, path string, messages []Message) *Subagent {
+	a := &Subagent{AgentID: id, Path: path, Messages: messages}
+	for _, m := range messages {
+		if m.Type == TypeUser {
+			a.Prompt = m.Text
+			break
+		}
+	}
+	return a
+}
+
+// inlineSidechains groups the sidechain messages of a session file into one
+// subagent per sidechain root. Older Claude Code versions wrote subagent
+// turns into the parent's file this way.
+func inlineSidechains(messages []Message) []*Subagent {
+	tree := BuildTree(messages)
+	var agents []*Subagent
+	groups := make(map[int]int) // root message → index in agents
+	for i, m := range messages {
+		if !m.IsSidechain {
+			continue
+		}
+		root := i
+		for tree.Parent(root) >= 0 {
+			root = tree.Parent(root)
+		}
+		g, ok := groups[root]
+		if !ok {
+			id := m.AgentID
+			if id == "" {
+				id = "sidechain-" + messages[root].UUID
+			}
+			g = len(agents)
+			groups[root] = g
+			agents = append(agents, &Subagent{AgentID: id})
+		}
+		agents[g].Messages = append(agents[g].Messages, m)
+	}
+	for _, a := range agents {
+		*a = *newSubagent(a.AgentID, "", a.Messages)
+	}
+	return agents
+}
+
+// LinkSubagents sets AgentID on the Task calls that spawned agents. Calls
+// whose result named its agent keep it; the rest are matched to unclaimed
+// agents by prompt, in order.
+func LinkSubagents(messages []Message, agents []*Subagent) {
+	claimed := make(map[string]bool)
+	for _, m := range messages {
+		for _, tc := range m.Tools {
+			if tc.AgentID != "" {
+				claimed[tc.AgentID] = true
+			}
+		}
+	}
+	for i := range messages {
+		for j := range messages[i].Tools {
+			tc := &messages[i].Tools[j]
+			if tc.AgentID != "" || !subagentTools[tc.Name] {
+				continue
+			}
+			prompt, _ := tc.Input["prompt"].(string)
+			prompt = strings.TrimSpace(prompt)
+			for _, a := range agents {
+				if !claimed[a.AgentID] && prompt != "" && strings.TrimSpace(a.Prompt) == prompt {
+					tc.AgentID = a.AgentID
+					claimed[a.AgentID] = true
+					break
+				}
+			}
+		}
+	}
+}
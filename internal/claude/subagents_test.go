@@ -0,0 +1,75 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSubagentPaths(t *testing.T) {
+	for _, tc := range []struct {
+		path   string
+		isSub  bool
+		parent string
+	}{
+		{"/p/proj/sess-1.jsonl", false, ""},
+		{"/p/proj/sess-1/agent-a1.jsonl", true, "sess-1"},
+		{"/p/proj/sess-1/subagents/agent-a2.jsonl", true, "sess-1"},
+		{"/p/proj/sess-1/subagents/worker.jsonl", true, "sess-1"},
+	} {
+		if got := IsSubagentFile(tc.path); got != tc.isSub {
+			t.Errorf("IsSubagentFile(%q) = %v", tc.path, got)
+		}
+		if tc.isSub && ParentSessionID(tc.path) != tc.parent {
+			t.Errorf("ParentSessionID(%q) = %q, want %q", tc.path, ParentSessionID(tc.path), tc.parent)
+		}
+	}
+}
+
+func TestLoadSubagents(t *testing.T) {
+	dir := t.TempDir()
+	session := filepath.Join(dir, "sess-1.jsonl")
+	write := func(path, content string) {
+		t.Helper()
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(session, `{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"assistant","model":"opus","content":[{"type":"tool_use","id":"toolu_1","name":"Task","input":{"prompt":"Find the bug"}},{"type":"tool_use","id":"toolu_2","name":"Task","input":{"prompt":"Write the docs"}},{"type":"tool_use","id":"toolu_3","name":"Task","input":{"prompt":"Review it"}}]}}
+{"type":"user","uuid":"r1","timestamp":"2025-01-01T00:00:09Z","toolUseResult":{"status":"completed","agentId":"abc"},"message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"found it"}]}}
+{"type":"user","uuid":"s1","isSidechain":true,"timestamp":"2025-01-01T00:00:05Z","message":{"role":"user","content":"Review it"}}
+{"type":"assistant","uuid":"s2","parentUuid":"s1","isSidechain":true,"timestamp":"2025-01-01T00:00:06Z","message":{"role":"assistant","model":"haiku","content":[{"type":"text","text":"LGTM"}],"usage":{"input_tokens":5,"output_tokens":2}}}
+`)
+	// Linked by the agentId in the Task result
+	write(filepath.Join(dir, "sess-1", "subagents", "agent-abc.jsonl"),
+		`{"type":"user","uuid":"b1","agentId":"abc","isSidechain":true,"timestamp":"2025-01-01T00:00:01Z","message":{"role":"user","content":"Find the bug, please"}}
+{"type":"assistant","uuid":"b2","parentUuid":"b1","agentId":"abc","isSidechain":true,"timestamp":"2025-01-01T00:00:02Z","message":{"role":"assistant","model":"sonnet","content":[{"type":"text","text":"found"}],"usage":{"input_tokens":100,"output_tokens":50}}}
+`)
+	// Linked by prompt
+	write(filepath.Join(dir, "sess-1", "agent-def.jsonl"),
+		`{"type":"user","uuid":"c1","isSidechain":true,"timestamp":"2025-01-01T00:00:03Z","message":{"role":"user","content":"Write the docs"}}
+`)
+
+	messages, err := LoadMessages(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agents := LoadSubagents(session, messages)
+	if len(agents) != 3 {
+		t.Fatalf("agents = %d, want 3", len(agents))
+	}
+
+	want := []string{"abc", "def", "sidechain-s1"}
+	for i, tc := range messages[0].Tools {
+		if tc.AgentID != want[i] {
+			t.Errorf("%s agent = %q, want %q", tc.ID, tc.AgentID, want[i])
+		}
+	}
+	if u := agents["abc"].Usage(); u.Total() != 150 {
+		t.Errorf("abc usage = %d, want 150", u.Total())
+	}
+	if inline := agents["sidechain-s1"]; inline.Path != "" || len(inline.Messages) != 2 {
+		t.Errorf("inline sidechain = %+v", inline)
+	}
+}
@@ -0,0 +1,162 @@
+package claude
+
+// TreeNode is the part of a message the conversation tree is built from.
+type TreeNode struct {
+	UUID       string
+	ParentUUID string
+	Sidechain  bool
+}
+
+// Tree is the reply structure of a session. Every line names the line it
+// follows with parentUuid, and editing a prompt or rewinding starts a new
+// branch from an earlier message, so file order interleaves abandoned
+// branches with the live one. Nodes are indexed in file order. Sidechain
+// (subagent) messages only link to each other, never into the main tree.
+type Tree struct {
+	nodes    []TreeNode
+	parent   []int   // -1 for roots
+	children [][]int // in file order
+	last     []int   // latest node in each subtree
+	root     int     // first main-conversation node, or -1
+	byUUID   map[string]int
+}
+
+// BuildTree builds the tree of a session's messages.
+func BuildTree(messages []Message) *Tree {
+	nodes := make([]TreeNode, len(messages))
+	for i, m := range messages {
+		nodes[i] = TreeNode{UUID: m.UUID, ParentUUID: m.ParentUUID, Sidechain: m.IsSidechain}
+	}
+	return NewTree(nodes)
+}
+
+// NewTree builds a tree from nodes in file order. A main-conversation node
+// whose parent is unknown (a file without uuids, or a parent read in an
+// earlier chunk) continues from the main node before it.
+func NewTree(nodes []TreeNode) *Tree {
+	n := len(nodes)
+	t := &Tree{
+		nodes:    nodes,
+		parent:   make([]int, n),
+		children: make([][]int, n),
+		last:     make([]int, n),
+		root:     -1,
+		byUUID:   make(map[string]int, n),
+	}
+
+	prevMain := -1
+	for i, node := range nodes {
+		p := -1
+		if j, ok := t.byUUID[node.ParentUUID]; ok && nodes[j].Sidechain == node.Sidechain {
+			p = j
+		} else if !node.Sidechain {
+			p = prevMain
+		}
+		t.parent[i] = p
+		if p >= 0 {
+			t.children[p] = append(t.children[p], i)
+		}
+		if _, dup := t.byUUID[node.UUID]; node.UUID != "" && !dup {
+			t.byUUID[node.UUID] = i
+		}
+		if !node.Sidechain {
+			if t.root < 0 {
+				t.root = i
+			}
+			prevMain = i
+		}
+		t.last[i] = i
+	}
+
+	// Parents always precede their children, so one backward pass works
+	for i := n - 1; i >= 0; i-- {
+		if p := t.parent[i]; p >= 0 {
+			t.last[p] = max(t.last[p], t.last[i])
+		}
+	}
+	return t
+}
+
+// Index returns the node for a message uuid.
+func (t *Tree) Index(uuid string) (int, bool) {
+	i, ok := t.byUUID[uuid]
+	return i, ok
+}
+
+// Parent returns the node i follows, or -1 for a root.
+func (t *Tree) Parent(i int) int {
+	return t.parent[i]
+}
+
+// Children returns the replies to node i in file order.
+func (t *Tree) Children(i int) []int {
+	return t.children[i]
+}
+
+// IsFork reports whether the conversation branches after node i.
+func (t *Tree) IsFork(i int) bool {
+	return len(t.children[i]) > 1
+}
+
+// Forks returns the number of fork points in the main conversation.
+func (t *Tree) Forks() int {
+	n := 0
+	for i, node := range t.nodes {
+		if !node.Sidechain && t.IsFork(i) {
+			n++
+		}
+	}
+	return n
+}
+
+// Path returns the main-conversation nodes along one branch, root first. At
+// each fork it follows choices[fork uuid] when that names one of the fork's
+// children, and otherwise the branch written to most recently, which is
+// where the conversation carried on.
+func (t *Tree) Path(choices map[string]string) []int {
+	var path []int
+	for i := t.root; i >= 0; {
+		path = append(path, i)
+		kids := t.children[i]
+		if len(kids) == 0 {
+			break
+		}
+		next := kids[0]
+		for _, c := range kids[1:] {
+			if t.last[c] > t.last[next] {
+				next = c
+			}
+		}
+		if want, ok := choices[t.nodes[i].UUID]; ok && want != "" {
+			for _, c := range kids {
+				if t.nodes[c].UUID == want {
+					next = c
+				}
+			}
+		}
+		i = next
+	}
+	return path
+}
+
+// Abandoned marks the main-conversation nodes that are not on the default
+// path: prompts that were edited away and the replies that followed them.
+func (t *Tree) Abandoned() []bool {
+	abandoned := make([]bool, len(t.nodes))
+	for i, node := range t.nodes {
+		abandoned[i] = !node.Sidechain
+	}
+	for _, i := range t.Path(nil) {
+		abandoned[i] = false
+	}
+	return abandoned
+}
+
+// Reveal updates choices so that node i is on the path.
+func (t *Tree) Reveal(i int, choices map[string]string) {
+	for c := i; t.parent[c] >= 0; c = t.parent[c] {
+		if p := t.parent[c]; t.IsFork(p) {
+			choices[t.nodes[p].UUID] = t.nodes[c].UUID
+		}
+	}
+}
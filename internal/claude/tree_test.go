@@ -0,0 +1,107 @@
+package claude
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTree_LinearWithoutUUIDs(t *testing.T) {
+	tree := NewTree([]TreeNode{{}, {}, {}})
+	if got := tree.Path(nil); !slices.Equal(got, []int{0, 1, 2}) {
+		t.Errorf("path = %v, want file order", got)
+	}
+	if tree.Forks() != 0 {
+		t.Errorf("forks = %d, want 0", tree.Forks())
+	}
+}
+
+// forkedTree is a session whose second prompt was edited: u2 was replaced
+// by u2b, and the conversation carried on from there.
+func forkedTree() *Tree {
+	return NewTree([]TreeNode{
+		{UUID: "u1"},
+		{UUID: "a1", ParentUUID: "u1"},
+		{UUID: "u2", ParentUUID: "a1"},
+		{UUID: "a2", ParentUUID: "u2"},
+		{UUID: "u2b", ParentUUID: "a1"},
+		{UUID: "a2b", ParentUUID: "u2b"},
+	})
+}
+
+func TestTree_ForkFollowsLatestBranch(t *testing.T) {
+	tree := forkedTree()
+	if got := tree.Path(nil); !slices.Equal(got, []int{0, 1, 4, 5}) {
+		t.Errorf("path = %v, want [0 1 4 5]", got)
+	}
+	if !tree.IsFork(1) || tree.Forks() != 1 {
+		t.Errorf("fork at a1 not found")
+	}
+	want := []bool{false, false, true, true, false, false}
+	if got := tree.Abandoned(); !slices.Equal(got, want) {
+		t.Errorf("abandoned = %v, want %v", got, want)
+	}
+}
+
+func TestTree_Choices(t *testing.T) {
+	tree := forkedTree()
+	if got := tree.Path(map[string]string{"a1": "u2"}); !slices.Equal(got, []int{0, 1, 2, 3}) {
+		t.Errorf("chosen path = %v, want [0 1 2 3]", got)
+	}
+	// A choice naming something else is ignored
+	if got := tree.Path(map[string]string{"a1": "nope"}); !slices.Equal(got, []int{0, 1, 4, 5}) {
+		t.Errorf("path with bad choice = %v", got)
+	}
+
+	choices := map[string]string{}
+	tree.Reveal(3, choices)
+	if choices["a1"] != "u2" || !slices.Contains(tree.Path(choices), 3) {
+		t.Errorf("reveal choices = %v", choices)
+	}
+}
+
+func TestTree_SidechainsStayOutOfPath(t *testing.T) {
+	tree := NewTree([]TreeNode{
+		{UUID: "u1"},
+		{UUID: "s1", Sidechain: true},
+		{UUID: "s2", ParentUUID: "s1", Sidechain: true},
+		{UUID: "a1", ParentUUID: "u1"},
+	})
+	if got := tree.Path(nil); !slices.Equal(got, []int{0, 3}) {
+		t.Errorf("path = %v, want [0 3]", got)
+	}
+	if got := tree.Children(1); !slices.Equal(got, []int{2}) {
+		t.Errorf("sidechain children = %v", got)
+	}
+	if tree.Abandoned()[1] {
+		t.Error("sidechain marked abandoned")
+	}
+}
+
+func TestLoadMessages_ParentLinks(t *testing.T) {
+	path := writeTestJSONL(t,
+		`{"type":"user","uuid":"u1","parentUuid":null,"timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"hi"}}`,
+		`{"type":"progress","uuid":"p1","parentUuid":"u1","timestamp":"2025-01-01T00:00:01Z"}`,
+		`{"type":"assistant","uuid":"a1","parentUuid":"p1","timestamp":"2025-01-01T00:00:02Z","message":{"role":"assistant","model":"opus","content":[{"type":"text","text":"hello"}]}}`,
+		`{"type":"system","uuid":"c1","parentUuid":null,"logicalParentUuid":"a1","timestamp":"2025-01-01T00:00:03Z","content":"compacted"}`,
+		`{"type":"user","uuid":"u2","parentUuid":"c1","isSidechain":true,"timestamp":"2025-01-01T00:00:04Z","message":{"role":"user","content":"subtask"}}`,
+	)
+	msgs, err := LoadMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parents := map[string]string{}
+	for _, m := range msgs {
+		parents[m.UUID] = m.ParentUUID
+	}
+	// The skipped progress line is looked through
+	if parents["a1"] != "u1" {
+		t.Errorf("a1 parent = %q, want u1", parents["a1"])
+	}
+	if p, ok := parents["c1"]; ok && p != "a1" {
+		t.Errorf("c1 parent = %q, want a1", p)
+	}
+	last := msgs[len(msgs)-1]
+	if last.UUID != "u2" || !last.IsSidechain {
+		t.Errorf("last = %+v, want sidechain u2", last)
+	}
+}
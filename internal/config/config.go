@@ -10,6 +10,47 @@ type Config struct {
 	WatchlistVisible bool     `json:"watchlist_visible"`
 	DefaultScope     string   `json:"default_search_scope"` // "project", "global", "local"
 	ProjectPaths     []string `json:"project_paths,omitempty"`
+
+	// KeepMissingSessions keeps sessions whose JSONL file was deleted or
+	// moved in the index, flagged source-missing, instead of purging them.
+	KeepMissingSessions bool `json:"keep_missing_sessions,omitempty"`
+
+	// Archive snapshots session files into clog's data dir so they outlive
+	// Claude Code's transcript cleanup.
+	Archive ArchiveConfig `json:"archive"`
+
+	// Pricing overrides or extends DefaultPricing.
+	Pricing []ModelPrice `json:"pricing,omitempty"`
+
+	// Search tunes how search ranks results by relevance.
+	Search SearchConfig `json:"search"`
+}
+
+// SearchConfig has the fields of store.Ranking, which it converts to; see
+// there for their meaning. Zero fields keep the default.
+type SearchConfig struct {
+	TextWeight     float64 `json:"text_weight,omitempty"`
+	ToolsWeight    float64 `json:"tools_weight,omitempty"`
+	ThinkingWeight float64 `json:"thinking_weight,omitempty"`
+
+	UserWeight       float64 `json:"user_weight,omitempty"`
+	AssistantWeight  float64 `json:"assistant_weight,omitempty"`
+	ToolResultWeight float64 `json:"tool_result_weight,omitempty"`
+
+	RecencyBoost float64 `json:"recency_boost,omitempty"`
+	HalfLifeDays float64 `json:"half_life_days,omitempty"`
+
+	ProjectBoost float64 `json:"project_boost,omitempty"`
+}
+
+type ArchiveConfig struct {
+	Enabled   bool  `json:"enabled"`
+	MaxSizeMB int64 `json:"max_size_mb,omitempty"` // compressed; 0 = unlimited
+}
+
+// Budget returns the archive size cap in bytes, or 0 for no cap.
+func (a ArchiveConfig) Budget() int64 {
+	return a.MaxSizeMB << 20
 }
 
 // AddProjectPath adds a directory to the custom paths list. Returns false if already present.
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"path"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+// ModelPrice is the cost of one model family, in USD per million tokens.
+type ModelPrice struct {
+	Model      string  `json:"model"` // glob matched against the model id, e.g. "claude-opus-4*"
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheWrite float64 `json:"cache_write"`
+	CacheRead  float64 `json:"cache_read"`
+}
+
+// Cost prices a usage total.
+func (p ModelPrice) Cost(u claude.Usage) float64 {
+	return (float64(u.InputTokens)*p.Input +
+		float64(u.OutputTokens)*p.Output +
+		float64(u.CacheWriteTokens)*p.CacheWrite +
+		float64(u.CacheReadTokens)*p.CacheRead) / 1_000_000
+}
+
+// DefaultPricing is Anthropic's list pricing with 5-minute cache writes.
+// Entries are checked in order, so narrower patterns come first.
+func DefaultPricing() []ModelPrice {
+	return []ModelPrice{
+		{Model: "claude-opus-4-5*", Input: 5, Output: 25, CacheWrite: 6.25, CacheRead: 0.50},
+		{Model: "claude-opus-4*", Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+		{Model: "claude-3-opus*", Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+		{Model: "claude-sonnet-4*", Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+		{Model: "claude-3-7-sonnet*", Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+		{Model: "claude-3-5-sonnet*", Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+		{Model: "claude-haiku-4*", Input: 1, Output: 5, CacheWrite: 1.25, CacheRead: 0.10},
+		{Model: "claude-3-5-haiku*", Input: 0.80, Output: 4, CacheWrite: 1, CacheRead: 0.08},
+		{Model: "claude-3-haiku*", Input: 0.25, Output: 1.25, CacheWrite: 0.30, CacheRead: 0.03},
+	}
+}
+
+// PriceFor finds the price for a model id. Entries from the config file take
+// precedence over the built-in table, so users can override or add models.
+func (c Config) PriceFor(model string) (ModelPrice, bool) {
+	if model == "" {
+		return ModelPrice{}, false
+	}
+	for _, table := range [][]ModelPrice{c.Pricing, DefaultPricing()} {
+		for _, p := range table {
+			if ok, _ := path.Match(p.Model, model); ok {
+				return p, true
+			}
+		}
+	}
+	return ModelPrice{}, false
+}
+
+// Cost prices usage for a model, returning 0 for unknown models.
+func (c Config) Cost(model string, u claude.Usage) float64 {
+	p, ok := c.PriceFor(model)
+	if !ok {
+		return 0
+	}
+	return p.Cost(u)
+}
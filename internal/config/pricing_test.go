@@ -0,0 +1,77 @@
+package config
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+func TestPriceFor_Defaults(t *testing.T) {
+	cfg := DefaultConfig()
+	tests := []struct {
+		model string
+		input float64
+	}{
+		{"claude-opus-4-5-20251101", 5},
+		{"claude-opus-4-1-20250805", 15},
+		{"claude-sonnet-4-5-20250929", 3},
+		{"claude-3-5-haiku-20241022", 0.80},
+	}
+	for _, tt := range tests {
+		p, ok := cfg.PriceFor(tt.model)
+		if !ok || p.Input != tt.input {
+			t.Errorf("PriceFor(%q) = %+v, %v; want input %v", tt.model, p, ok, tt.input)
+		}
+	}
+	if _, ok := cfg.PriceFor("gpt-4"); ok {
+		t.Error("expected no price for unknown model")
+	}
+	if _, ok := cfg.PriceFor(""); ok {
+		t.Error("expected no price for empty model")
+	}
+}
+
+func TestPriceFor_ConfigOverridesDefaults(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Pricing = []ModelPrice{{Model: "claude-sonnet-*", Input: 1, Output: 2}}
+
+	p, ok := cfg.PriceFor("claude-sonnet-4-5-20250929")
+	if !ok || p.Input != 1 {
+		t.Errorf("override not applied: %+v", p)
+	}
+	// Models the override does not cover still use the defaults
+	if p, _ := cfg.PriceFor("claude-opus-4-1"); p.Input != 15 {
+		t.Errorf("opus input = %v, want 15", p.Input)
+	}
+}
+
+func TestCost(t *testing.T) {
+	cfg := DefaultConfig()
+	u := claude.Usage{InputTokens: 1_000_000, OutputTokens: 100_000, CacheReadTokens: 2_000_000, CacheWriteTokens: 400_000}
+
+	// sonnet: 3 + 1.5 + 0.6 + 1.5
+	got := cfg.Cost("claude-sonnet-4-20250514", u)
+	if math.Abs(got-6.6) > 1e-9 {
+		t.Errorf("cost = %v, want 6.6", got)
+	}
+	if cfg.Cost("unknown", u) != 0 {
+		t.Error("unknown model should cost 0")
+	}
+}
+
+func TestLoad_Pricing(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	os.MkdirAll(filepath.Join(dir, "clog"), 0o755)
+	os.WriteFile(filepath.Join(dir, "clog", "config.json"),
+		[]byte(`{"pricing":[{"model":"my-proxy-*","input":2,"output":8,"cache_write":2.5,"cache_read":0.2}]}`), 0o644)
+
+	cfg := Load()
+	p, ok := cfg.PriceFor("my-proxy-large")
+	if !ok || p.Output != 8 || p.CacheRead != 0.2 {
+		t.Errorf("loaded price = %+v, %v", p, ok)
+	}
+}
@@ -0,0 +1,174 @@
+// Package daemon keeps the index current without the dashboard: it indexes
+// sessions as Claude Code writes them, which runs watchlist matching and
+// actions, and records its pid so other clog processes can tell it is
+// running and leave indexing to it.
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/store"
+	"github.com/thinkwright/claude-chronicle/internal/watcher"
+)
+
+// ErrRunning is returned by Acquire when another daemon holds the pidfile.
+var ErrRunning = errors.New("clog daemon is already running")
+
+// PIDFile is where a running daemon records its process id, next to the
+// index.
+func PIDFile() string {
+	return filepath.Join(filepath.Dir(store.DBPath()), "daemon.pid")
+}
+
+// Pidfile is a pidfile held locked for as long as the daemon runs, so one
+// left behind by a crash does not count as a running daemon.
+type Pidfile struct {
+	f    *os.File
+	path string
+}
+
+// Acquire creates and locks the pidfile at path and writes this process's
+// id to it.
+func Acquire(path string) (*Pidfile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, path); err != nil {
+		f.Close()
+		if errors.Is(err, ErrRunning) {
+			if pid, ok := Running(path); ok {
+				return nil, fmt.Errorf("%w (pid %d)", ErrRunning, pid)
+			}
+		}
+		return nil, err
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Pidfile{f: f, path: path}, nil
+}
+
+// Release removes the pidfile and unlocks it.
+func (p *Pidfile) Release() error {
+	os.Remove(p.path)
+	return p.f.Close()
+}
+
+// readPID returns the pid recorded in the pidfile at path.
+func readPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// Stop asks the daemon recorded in the pidfile at path to exit and waits up
+// to timeout for it to release the pidfile.
+func Stop(path string, timeout time.Duration) error {
+	pid, ok := Running(path)
+	if !ok {
+		return errors.New("clog daemon is not running")
+	}
+	if err := stopProcess(pid); err != nil {
+		return fmt.Errorf("stop pid %d: %w", pid, err)
+	}
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if _, ok := Running(path); !ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("pid %d did not exit within %s", pid, timeout)
+}
+
+// Run indexes changed sessions, then again each time session files change,
+// until ctx is done. Watchlist matching and actions run as part of indexing;
+// Run logs each pass that changed something and each new match. It first
+// waits for the writer lease: a dashboard hands the lease to a running
+// daemon, and any other clog process holds it until it exits.
+func Run(ctx context.Context, db *store.Store, projectPaths []string, log *slog.Logger) error {
+	changes, err := watcher.Changes(ctx, projectPaths)
+	if err != nil {
+		return fmt.Errorf("watch: %w", err)
+	}
+	db.OnMatch(func(matches []store.WatchMatch) {
+		for _, m := range matches {
+			name := ""
+			if w, err := db.GetWatch(m.WatchItemID); err == nil {
+				name = w.Name
+			}
+			log.Info("watch match", "watch", name, "session", m.SessionID, "project", m.Project, "text", m.MatchedText)
+		}
+	})
+
+	if !waitForLease(ctx, db, log) {
+		return nil
+	}
+	index(db, projectPaths, log)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-changes:
+			if !ok {
+				return ctx.Err()
+			}
+			index(db, projectPaths, log)
+		}
+	}
+}
+
+// waitForLease takes the writer lease, retrying every second while another
+// process holds it. It reports false if ctx ends first.
+func waitForLease(ctx context.Context, db *store.Store, log *slog.Logger) bool {
+	for logged := false; ; {
+		ok, err := db.TryAcquireWriter()
+		if ok {
+			return true
+		}
+		if !logged {
+			if err != nil {
+				log.Warn("waiting for writer lease", "err", err)
+			} else if h, _ := db.WriterLease(); h != nil {
+				log.Info("waiting for writer lease", "holder", h.String())
+			}
+			logged = true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// index runs one IndexChanged pass and logs its outcome.
+func index(db *store.Store, projectPaths []string, log *slog.Logger) {
+	start := time.Now()
+	changed, err := db.IndexChanged(projectPaths)
+	if err != nil {
+		log.Error("index failed", "err", err, "changed", changed)
+		return
+	}
+	if changed > 0 {
+		log.Info("indexed", "changed", changed, "files", db.FileCount(), "messages", db.MessageCount(),
+			"took", time.Since(start).Round(time.Millisecond))
+	}
+}
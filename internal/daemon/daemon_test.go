@@ -0,0 +1,171 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.pid")
+	if _, ok := Running(path); ok {
+		t.Fatal("Running before Acquire")
+	}
+
+	p, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, ok := Running(path); !ok || pid != os.Getpid() {
+		t.Errorf("Running = %d, %v; want %d", pid, ok, os.Getpid())
+	}
+	if _, err := Acquire(path); !errors.Is(err, ErrRunning) {
+		t.Errorf("second Acquire = %v, want ErrRunning", err)
+	}
+
+	p.Release()
+	if _, ok := Running(path); ok {
+		t.Error("Running after Release")
+	}
+
+	// A pidfile left behind by a crash is not a running daemon
+	os.WriteFile(path, []byte("999999\n"), 0o644)
+	if _, ok := Running(path); ok {
+		t.Error("stale pidfile reported as running")
+	}
+	p, err = Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire over stale pidfile: %v", err)
+	}
+	p.Release()
+}
+
+// syncBuffer is a log destination safe for the daemon goroutine and the
+// test to share.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLAUDE_CONFIG_DIR", dir)
+	os.MkdirAll(filepath.Join(dir, "projects"), 0o755)
+
+	db, err := store.Open(filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.AddWatch("prod", "production", "")
+
+	var logs syncBuffer
+	log := slog.New(slog.NewJSONHandler(&logs, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, db, nil, log) }()
+
+	// A project created while the daemon runs is picked up
+	time.Sleep(200 * time.Millisecond)
+	sessions := filepath.Join(dir, "projects", "-work-demo")
+	os.MkdirAll(sessions, 0o755)
+	line := `{"type":"user","uuid":"u1","cwd":"/work/demo","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"deploy to production"}}` + "\n"
+	os.WriteFile(filepath.Join(sessions, "sess-1.jsonl"), []byte(line), 0o644)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(logs.String(), `"msg":"watch match"`) {
+		if time.Now().After(deadline) {
+			t.Fatalf("no match logged; logs:\n%s", logs.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if db.MessageCount() != 1 {
+		t.Errorf("messages = %d, want 1", db.MessageCount())
+	}
+	out := logs.String()
+	if !strings.Contains(out, `"msg":"indexed","changed":1`) || !strings.Contains(out, `"watch":"prod"`) {
+		t.Errorf("logs:\n%s", out)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestRun_WaitsForLease(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLAUDE_CONFIG_DIR", dir)
+	os.MkdirAll(filepath.Join(dir, "projects", "-work-demo"), 0o755)
+	line := `{"type":"user","uuid":"u1","cwd":"/work/demo","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"hello"}}` + "\n"
+	os.WriteFile(filepath.Join(dir, "projects", "-work-demo", "sess-1.jsonl"), []byte(line), 0o644)
+
+	// Another clog process is writing the index
+	other, err := store.Open(filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	other.SetLeaseKind("serve")
+	if ok, _ := other.TryAcquireWriter(); !ok {
+		t.Fatal("TryAcquireWriter failed")
+	}
+
+	db, err := store.Open(filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var logs syncBuffer
+	log := slog.New(slog.NewJSONHandler(&logs, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, db, nil, log)
+
+	time.Sleep(300 * time.Millisecond)
+	if out := logs.String(); !strings.Contains(out, `"msg":"waiting for writer lease","holder":"serve pid`) {
+		t.Errorf("logs:\n%s", out)
+	}
+	if db.FileCount() != 0 {
+		t.Fatal("indexed without the lease")
+	}
+
+	other.ReleaseWriter()
+	deadline := time.Now().Add(5 * time.Second)
+	for db.FileCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("not indexed after the lease was released; logs:\n%s", logs.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !db.IsWriter() {
+		t.Error("daemon does not hold the lease")
+	}
+}
@@ -0,0 +1,38 @@
+package daemon
+
+import (
+	"os"
+)
+
+// lockFile refuses the pidfile while the process it names is alive. Windows
+// has no advisory locks in the standard library, so a recycled pid can
+// make a stale file look held until it is removed.
+func lockFile(f *os.File, path string) error {
+	if _, ok := Running(path); ok {
+		return ErrRunning
+	}
+	return nil
+}
+
+// Running reports whether the process recorded in the pidfile at path is
+// alive, and its pid.
+func Running(path string) (int, bool) {
+	pid, err := readPID(path)
+	if err != nil || pid == os.Getpid() {
+		return 0, false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return 0, false
+	}
+	p.Release()
+	return pid, true
+}
+
+func stopProcess(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
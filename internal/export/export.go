@@ -0,0 +1,199 @@
+// Package export renders a session transcript to shareable formats.
+package export
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+type Format string
+
+const (
+	Markdown Format = "md"
+	HTML     Format = "html"
+	JSON     Format = "json"
+)
+
+// Formats lists the supported formats in the order they are offered.
+var Formats = []Format{Markdown, HTML, JSON}
+
+// ParseFormat accepts a format name or its common aliases.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "md", "markdown":
+		return Markdown, nil
+	case "html", "htm":
+		return HTML, nil
+	case "json":
+		return JSON, nil
+	}
+	return "", fmt.Errorf("unknown export format %q (want md, html or json)", s)
+}
+
+// Filename is the default file name for an exported session.
+func Filename(sess claude.SessionEntry, f Format) string {
+	id := sess.SessionID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return fmt.Sprintf("clog-%s.%s", id, f)
+}
+
+// Session loads a session's messages from its JSONL file and renders them.
+func Session(w io.Writer, sess claude.SessionEntry, f Format) error {
+	messages, err := claude.LoadMessages(sess.FullPath)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", sess.SessionID, err)
+	}
+	return Render(w, sess, messages, f)
+}
+
+// WriteFile exports a session to path. It renders into a temp file beside
+// path and renames it into place, so a failed export never leaves a
+// truncated transcript behind.
+func WriteFile(path string, sess claude.SessionEntry, f Format) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".clog-export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := Session(tmp, sess, f); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Render writes an already loaded session in the given format.
+func Render(w io.Writer, sess claude.SessionEntry, messages []claude.Message, f Format) error {
+	messages = transcript(messages)
+	switch f {
+	case Markdown:
+		return renderMarkdown(w, sess, messages)
+	case HTML:
+		return renderHTML(w, sess, messages)
+	case JSON:
+		return renderJSON(w, sess, messages)
+	}
+	return fmt.Errorf("unknown export format %q", f)
+}
+
+// transcript drops tool result messages whose every result is already shown
+// alongside the call that produced it.
+func transcript(messages []claude.Message) []claude.Message {
+	calls := make(map[string]bool)
+	for _, msg := range messages {
+		for _, tc := range msg.Tools {
+			if tc.Answered {
+				calls[tc.ID] = true
+			}
+		}
+	}
+
+	out := make([]claude.Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Type == claude.TypeToolResult && len(msg.ToolResults) > 0 {
+			paired := true
+			for _, r := range msg.ToolResults {
+				if !calls[r.ToolUseID] {
+					paired = false
+					break
+				}
+			}
+			if paired {
+				continue
+			}
+		}
+		out = append(out, msg)
+	}
+	return out
+}
+
+// title is the first line of the session's opening prompt.
+func title(sess claude.SessionEntry, messages []claude.Message) string {
+	t := sess.FirstPrompt
+	if t == "" {
+		for _, msg := range messages {
+			if msg.Type == claude.TypeUser {
+				t = msg.Text
+				break
+			}
+		}
+	}
+	if i := strings.IndexByte(t, '\n'); i >= 0 {
+		t = t[:i]
+	}
+	t = strings.TrimSpace(t)
+	if r := []rune(t); len(r) > 80 {
+		t = string(r[:79]) + "…"
+	}
+	if t == "" {
+		t = "Session " + sess.SessionID
+	}
+	return t
+}
+
+// roleLabel is the heading used for a message in the rendered transcript.
+func roleLabel(t claude.MessageType) string {
+	switch t {
+	case claude.TypeUser:
+		return "User"
+	case claude.TypeAssistant:
+		return "Assistant"
+	case claude.TypeToolResult:
+		return "Tool result"
+	case claude.TypeSystem:
+		return "System"
+	}
+	return string(t)
+}
+
+// displayTime formats a JSONL timestamp for headings, falling back to the
+// raw value when it does not parse.
+func displayTime(ts string) string {
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return ts
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// toolStatus is a short outcome for a tool call: ok, failed or no result.
+func toolStatus(tc claude.ToolCall) string {
+	var s string
+	switch {
+	case !tc.Answered:
+		return "no result"
+	case tc.IsError:
+		s = "failed"
+	default:
+		s = "ok"
+	}
+	if tc.Duration > 0 {
+		s += ", " + formatDuration(tc.Duration)
+	}
+	return s
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,165 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+const testSession = `{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"why does this fail?\n\n` + "```go\\nfunc main() { <-done }\\n```" + `"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"claude-opus-4","content":[{"type":"text","text":"Let me run it."},{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"go run ."}}],"usage":{"input_tokens":100,"output_tokens":20}}}
+{"type":"user","uuid":"u2","timestamp":"2025-01-01T00:00:03Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","is_error":true,"content":"fatal error: all goroutines are asleep\n` + "```" + `"}]}}
+{"type":"assistant","uuid":"a2","timestamp":"2025-01-01T00:00:04Z","message":{"role":"assistant","model":"claude-opus-4","content":[{"type":"text","text":"It deadlocks on <script>."}],"usage":{"input_tokens":150,"output_tokens":30}}}
+`
+
+func loadTestSession(t *testing.T) (claude.SessionEntry, []claude.Message) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "abcdef123456.jsonl")
+	if err := os.WriteFile(path, []byte(testSession), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := claude.LoadMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := claude.SessionEntry{
+		SessionID:   "abcdef123456",
+		FullPath:    path,
+		FirstPrompt: "why does this fail?",
+		GitBranch:   "main",
+	}
+	return sess, messages
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := map[string]Format{"md": Markdown, "Markdown": Markdown, "html": HTML, "json": JSON}
+	for in, want := range tests {
+		got, err := ParseFormat(in)
+		if err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("pdf"); err == nil {
+		t.Error("expected error for pdf")
+	}
+}
+
+func TestFilename(t *testing.T) {
+	got := Filename(claude.SessionEntry{SessionID: "abcdef123456"}, HTML)
+	if got != "clog-abcdef12.html" {
+		t.Errorf("Filename = %q", got)
+	}
+}
+
+func TestMarkdown_PreservesCodeFences(t *testing.T) {
+	sess, messages := loadTestSession(t)
+	var buf bytes.Buffer
+	if err := Render(&buf, sess, messages, Markdown); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, "# why does this fail?\n") {
+		t.Errorf("missing title:\n%s", out)
+	}
+	if !strings.Contains(out, "```go\nfunc main() { <-done }\n```") {
+		t.Errorf("user code fence not preserved:\n%s", out)
+	}
+	// The tool result contains ``` so its fence must be longer
+	if !strings.Contains(out, "````\nfatal error: all goroutines are asleep\n```\n````") {
+		t.Errorf("tool result fence not escaped:\n%s", out)
+	}
+	if !strings.Contains(out, "<summary>⚙ <b>Bash</b> <code>go run .</code> (failed, 2.0s)</summary>") {
+		t.Errorf("tool summary missing:\n%s", out)
+	}
+	// Paired tool results are shown with their call, not as their own message
+	if strings.Contains(out, "### Tool result") {
+		t.Errorf("paired tool result rendered separately:\n%s", out)
+	}
+}
+
+func TestHTML_SelfContainedAndEscaped(t *testing.T) {
+	sess, messages := loadTestSession(t)
+	var buf bytes.Buffer
+	if err := Render(&buf, sess, messages, HTML); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	if !strings.Contains(out, "<style>") || strings.Contains(out, "<link") || strings.Contains(out, "<script") {
+		t.Error("expected inline styles and no external resources or scripts")
+	}
+	if !strings.Contains(out, "It deadlocks on &lt;script&gt;.") {
+		t.Error("message text not escaped")
+	}
+	if !strings.Contains(out, `<details class="failed">`) {
+		t.Error("failed tool call should render as a collapsible details block")
+	}
+}
+
+func TestJSON_FoldsToolResults(t *testing.T) {
+	sess, messages := loadTestSession(t)
+	var buf bytes.Buffer
+	if err := Render(&buf, sess, messages, JSON); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.SessionID != "abcdef123456" || doc.GitBranch != "main" {
+		t.Errorf("header = %+v", doc)
+	}
+	if len(doc.Messages) != 3 {
+		t.Fatalf("messages = %d, want 3 (tool result folded)", len(doc.Messages))
+	}
+	tools := doc.Messages[1].Tools
+	if len(tools) != 1 || !tools[0].IsError || tools[0].DurationMs != 2000 || tools[0].Input["command"] != "go run ." {
+		t.Errorf("tool = %+v", tools)
+	}
+}
+
+func TestTranscript_KeepsUnpairedResults(t *testing.T) {
+	messages := []claude.Message{
+		{Type: claude.TypeToolResult, ToolResults: []claude.ToolResult{{ToolUseID: "orphan", Text: "output"}}},
+	}
+	if got := transcript(messages); len(got) != 1 {
+		t.Errorf("unpaired result dropped: %d messages", len(got))
+	}
+}
+
+func TestSession_MissingFile(t *testing.T) {
+	sess := claude.SessionEntry{SessionID: "gone", FullPath: filepath.Join(t.TempDir(), "gone.jsonl")}
+	if err := Session(&bytes.Buffer{}, sess, Markdown); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	sess, _ := loadTestSession(t)
+	path := filepath.Join(t.TempDir(), "out.md")
+	if err := WriteFile(path, sess, Markdown); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.HasPrefix(string(data), "# why does this fail?") {
+		t.Errorf("WriteFile wrote %q, err=%v", data, err)
+	}
+
+	// A failed export leaves nothing behind
+	sess.FullPath = filepath.Join(t.TempDir(), "gone.jsonl")
+	bad := filepath.Join(filepath.Dir(path), "bad.md")
+	if err := WriteFile(bad, sess, Markdown); err == nil {
+		t.Error("expected error")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want only out.md", len(entries))
+	}
+}
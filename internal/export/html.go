@@ -0,0 +1,146 @@
+package export
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+type htmlTool struct {
+	Name    string
+	Summary string
+	Status  string
+	Failed  bool
+	Input   string
+	Result  string
+}
+
+type htmlMessage struct {
+	Role      string
+	Class     string
+	Timestamp string
+	Model     string
+	Text      string
+	Results   []string
+	Tools     []htmlTool
+}
+
+type htmlPage struct {
+	Title    string
+	Session  claude.SessionEntry
+	Started  string
+	Messages []htmlMessage
+}
+
+// renderHTML writes a single self-contained page: styles are inlined and
+// tool calls use <details> so they collapse without any JavaScript.
+func renderHTML(w io.Writer, sess claude.SessionEntry, messages []claude.Message) error {
+	page := htmlPage{
+		Title:   title(sess, messages),
+		Session: sess,
+	}
+	if sess.Created != "" {
+		page.Started = displayTime(sess.Created)
+	}
+
+	for _, msg := range messages {
+		hm := htmlMessage{
+			Role:      roleLabel(msg.Type),
+			Class:     string(msg.Type),
+			Timestamp: displayTime(msg.Timestamp),
+			Model:     msg.Model,
+			Text:      msg.Text,
+		}
+		if msg.Type == claude.TypeToolResult && len(msg.ToolResults) > 0 {
+			hm.Text = ""
+			for _, r := range msg.ToolResults {
+				hm.Results = append(hm.Results, r.Text)
+			}
+		}
+		for _, tc := range msg.Tools {
+			ht := htmlTool{
+				Name:    tc.Name,
+				Summary: tc.Summary(),
+				Status:  toolStatus(tc),
+				Failed:  tc.IsError,
+				Result:  tc.Result,
+			}
+			if len(tc.Input) > 0 {
+				input, _ := json.MarshalIndent(tc.Input, "", "  ")
+				ht.Input = string(input)
+			}
+			hm.Tools = append(hm.Tools, ht)
+		}
+		page.Messages = append(page.Messages, hm)
+	}
+
+	return htmlTemplate.Execute(w, page)
+}
+
+var htmlTemplate = template.Must(template.New("session").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font: 14px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 960px; margin: 2em auto; padding: 0 1em; color: #1f2328; background: #fff; }
+h1 { font-size: 1.4em; margin-bottom: .3em; }
+.meta { color: #59636e; margin: 0 0 2em; padding: 0; list-style: none; }
+.meta code { font-size: .95em; }
+.msg { border-left: 3px solid #d0d7de; padding: .2em 0 .2em 1em; margin: 1.2em 0; }
+.msg.user { border-color: #5a9fd4; }
+.msg.assistant { border-color: #8a7fbf; }
+.msg.tool-result { border-color: #8c959f; }
+.msg.system { border-color: #d4a72c; }
+.head { color: #59636e; font-size: .85em; margin-bottom: .3em; }
+.head b { color: #1f2328; }
+pre { white-space: pre-wrap; word-wrap: break-word; margin: .3em 0; font: 13px/1.45 ui-monospace, SFMono-Regular, Menlo, monospace; }
+pre.code { background: #f6f8fa; padding: .6em .8em; border-radius: 6px; }
+details { margin: .4em 0; }
+summary { cursor: pointer; color: #59636e; }
+summary code { color: #1f2328; }
+.failed summary { color: #cf222e; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ul class="meta">
+<li>Session <code>{{.Session.SessionID}}</code></li>
+{{- with .Session.ProjectPath}}
+<li>Project {{.}}</li>
+{{- end}}
+{{- with .Session.GitBranch}}
+<li>Branch <code>{{.}}</code></li>
+{{- end}}
+{{- with .Started}}
+<li>Started {{.}}</li>
+{{- end}}
+</ul>
+{{range .Messages}}
+<div class="msg {{.Class}}">
+<div class="head"><b>{{.Role}}</b> · {{.Timestamp}}{{with .Model}} · <code>{{.}}</code>{{end}}</div>
+{{- with .Text}}
+<pre>{{.}}</pre>
+{{- end}}
+{{- range .Results}}
+<pre class="code">{{.}}</pre>
+{{- end}}
+{{- range .Tools}}
+<details{{if .Failed}} class="failed"{{end}}>
+<summary>⚙ <b>{{.Name}}</b>{{with .Summary}} <code>{{.}}</code>{{end}} ({{.Status}})</summary>
+{{- with .Input}}
+<pre class="code">{{.}}</pre>
+{{- end}}
+{{- with .Result}}
+<pre class="code">{{.}}</pre>
+{{- end}}
+</details>
+{{- end}}
+</div>
+{{end}}
+</body>
+</html>
+`))
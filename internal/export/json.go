@@ -0,0 +1,103 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+// Document is the normalized JSON form of a session. Tool results are folded
+// into the call that produced them; the raw JSONL layout is not preserved.
+type Document struct {
+	SessionID   string    `json:"session_id"`
+	Project     string    `json:"project,omitempty"`
+	GitBranch   string    `json:"git_branch,omitempty"`
+	FirstPrompt string    `json:"first_prompt,omitempty"`
+	Created     string    `json:"created,omitempty"`
+	Modified    string    `json:"modified,omitempty"`
+	Messages    []Message `json:"messages"`
+}
+
+type Message struct {
+	Type             string   `json:"type"`
+	UUID             string   `json:"uuid,omitempty"`
+	Timestamp        string   `json:"timestamp"`
+	Model            string   `json:"model,omitempty"`
+	Text             string   `json:"text,omitempty"`
+	Tools            []Tool   `json:"tools,omitempty"`
+	Results          []Result `json:"results,omitempty"`
+	InputTokens      int      `json:"input_tokens,omitempty"`
+	OutputTokens     int      `json:"output_tokens,omitempty"`
+	CacheReadTokens  int      `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int      `json:"cache_write_tokens,omitempty"`
+}
+
+type Tool struct {
+	ID         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	Input      map[string]interface{} `json:"input,omitempty"`
+	Answered   bool                   `json:"answered"`
+	IsError    bool                   `json:"is_error,omitempty"`
+	Result     string                 `json:"result,omitempty"`
+	DurationMs int64                  `json:"duration_ms,omitempty"`
+}
+
+// Result is a tool result that could not be paired with its call.
+type Result struct {
+	ToolUseID string `json:"tool_use_id"`
+	IsError   bool   `json:"is_error,omitempty"`
+	Text      string `json:"text"`
+}
+
+// NewDocument builds the normalized form of a transcript.
+func NewDocument(sess claude.SessionEntry, messages []claude.Message) Document {
+	doc := Document{
+		SessionID:   sess.SessionID,
+		Project:     sess.ProjectPath,
+		GitBranch:   sess.GitBranch,
+		FirstPrompt: sess.FirstPrompt,
+		Created:     sess.Created,
+		Modified:    sess.Modified,
+		Messages:    make([]Message, 0, len(messages)),
+	}
+	for _, msg := range messages {
+		m := Message{
+			Type:             string(msg.Type),
+			UUID:             msg.UUID,
+			Timestamp:        msg.Timestamp,
+			Model:            msg.Model,
+			Text:             msg.Text,
+			InputTokens:      msg.InputTokens,
+			OutputTokens:     msg.OutputTokens,
+			CacheReadTokens:  msg.CacheReadTokens,
+			CacheWriteTokens: msg.CacheWriteTokens,
+		}
+		if msg.Type == claude.TypeToolResult && len(msg.ToolResults) > 0 {
+			m.Text = ""
+			for _, r := range msg.ToolResults {
+				m.Results = append(m.Results, Result{ToolUseID: r.ToolUseID, IsError: r.IsError, Text: r.Text})
+			}
+		}
+		for _, tc := range msg.Tools {
+			m.Tools = append(m.Tools, Tool{
+				ID:         tc.ID,
+				Name:       tc.Name,
+				Input:      tc.Input,
+				Answered:   tc.Answered,
+				IsError:    tc.IsError,
+				Result:     tc.Result,
+				DurationMs: tc.Duration.Milliseconds(),
+			})
+		}
+		doc.Messages = append(doc.Messages, m)
+	}
+	return doc
+}
+
+func renderJSON(w io.Writer, sess claude.SessionEntry, messages []claude.Message) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(NewDocument(sess, messages))
+}
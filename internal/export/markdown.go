@@ -0,0 +1,102 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+// renderMarkdown writes message text verbatim, so fenced code in prompts and
+// replies survives as-is. Tool calls fold into <details> blocks, which
+// GitHub and most Markdown renderers display collapsed.
+func renderMarkdown(w io.Writer, sess claude.SessionEntry, messages []claude.Message) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# %s\n\n", title(sess, messages))
+	fmt.Fprintf(bw, "- **Session:** `%s`\n", sess.SessionID)
+	if sess.ProjectPath != "" {
+		fmt.Fprintf(bw, "- **Project:** %s\n", sess.ProjectPath)
+	}
+	if sess.GitBranch != "" {
+		fmt.Fprintf(bw, "- **Branch:** `%s`\n", sess.GitBranch)
+	}
+	if sess.Created != "" {
+		fmt.Fprintf(bw, "- **Started:** %s\n", displayTime(sess.Created))
+	}
+	fmt.Fprintf(bw, "- **Messages:** %d\n\n", len(messages))
+
+	for _, msg := range messages {
+		fmt.Fprintf(bw, "---\n\n### %s", roleLabel(msg.Type))
+		if msg.Timestamp != "" {
+			fmt.Fprintf(bw, " · %s", displayTime(msg.Timestamp))
+		}
+		if msg.Model != "" {
+			fmt.Fprintf(bw, " · `%s`", msg.Model)
+		}
+		bw.WriteString("\n\n")
+
+		switch {
+		case msg.Type == claude.TypeToolResult && len(msg.ToolResults) > 0:
+			for _, r := range msg.ToolResults {
+				writeFenced(bw, "", r.Text)
+			}
+		case msg.Text != "":
+			bw.WriteString(strings.TrimRight(msg.Text, "\n"))
+			bw.WriteString("\n\n")
+		}
+
+		for _, tc := range msg.Tools {
+			writeMarkdownTool(bw, tc)
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeMarkdownTool(w *bufio.Writer, tc claude.ToolCall) {
+	summary := tc.Summary()
+	fmt.Fprintf(w, "<details>\n<summary>⚙ <b>%s</b>", htmlEscape(tc.Name))
+	if summary != "" {
+		fmt.Fprintf(w, " <code>%s</code>", htmlEscape(summary))
+	}
+	fmt.Fprintf(w, " (%s)</summary>\n\n", toolStatus(tc))
+
+	if len(tc.Input) > 0 {
+		input, _ := json.MarshalIndent(tc.Input, "", "  ")
+		writeFenced(w, "json", string(input))
+	}
+	if tc.Answered && tc.Result != "" {
+		writeFenced(w, "", tc.Result)
+	}
+	w.WriteString("</details>\n\n")
+}
+
+// writeFenced wraps text in a code fence long enough that backtick runs
+// inside the text cannot close it early.
+func writeFenced(w *bufio.Writer, lang, text string) {
+	fence := strings.Repeat("`", max(3, longestRun(text, '`')+1))
+	fmt.Fprintf(w, "%s%s\n%s\n%s\n\n", fence, lang, strings.TrimRight(text, "\n"), fence)
+}
+
+func longestRun(s string, c rune) int {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == c {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+func htmlEscape(s string) string {
+	return htmlEscaper.Replace(s)
+}
@@ -0,0 +1,208 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+const testSession = `{"type":"user","uuid":"u1","cwd":"/work/demo","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"the nightly build fails with a flaky test"}}
+{"type":"assistant","uuid":"a1","parentUuid":"u1","cwd":"/work/demo","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"claude-sonnet-4","content":[{"type":"text","text":"The flaky test depended on map order; sorting the keys fixed it."}],"usage":{"input_tokens":100,"output_tokens":20}}}
+`
+
+// stubClient drives a Server over in-process pipes the way an MCP client
+// drives clog mcp over stdio.
+type stubClient struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Scanner
+	nextID int
+}
+
+func newStubClient(t *testing.T) *stubClient {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("CLAUDE_CONFIG_DIR", dir)
+	path := filepath.Join(dir, "projects", "-work-demo", "sess-1.jsonl")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, []byte(testSession), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := store.Open(filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.IndexChanged(nil); err != nil {
+		t.Fatal(err)
+	}
+	db.AddWatch("flaky", "flaky", "")
+
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- New(db, "test").Serve(context.Background(), reqR, respW)
+		respW.Close()
+	}()
+	t.Cleanup(func() {
+		reqW.Close()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Serve: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("Serve did not return after stdin closed")
+		}
+	})
+	return &stubClient{t: t, in: reqW, out: bufio.NewScanner(respR)}
+}
+
+// send writes one raw line and returns the decoded response.
+func (c *stubClient) send(line string) map[string]any {
+	c.t.Helper()
+	if _, err := io.WriteString(c.in, line+"\n"); err != nil {
+		c.t.Fatal(err)
+	}
+	if !c.out.Scan() {
+		c.t.Fatalf("no response to %s", line)
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(c.out.Bytes(), &resp); err != nil {
+		c.t.Fatalf("bad response %s: %v", c.out.Bytes(), err)
+	}
+	return resp
+}
+
+func (c *stubClient) call(method string, params any) map[string]any {
+	c.t.Helper()
+	c.nextID++
+	req, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+	resp := c.send(string(req))
+	if resp["id"] != float64(c.nextID) {
+		c.t.Errorf("response id = %v, want %d", resp["id"], c.nextID)
+	}
+	return resp
+}
+
+func (c *stubClient) notify(method string) {
+	req, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": method})
+	io.WriteString(c.in, string(req)+"\n")
+}
+
+// callTool runs a tool and returns its text and error flag.
+func (c *stubClient) callTool(name string, args map[string]any) (string, bool) {
+	c.t.Helper()
+	resp := c.call("tools/call", map[string]any{"name": name, "arguments": args})
+	result, ok := resp["result"].(map[string]any)
+	if !ok {
+		c.t.Fatalf("%s: %v", name, resp["error"])
+	}
+	content := result["content"].([]any)[0].(map[string]any)
+	isError, _ := result["isError"].(bool)
+	return content["text"].(string), isError
+}
+
+func TestMCP_Handshake(t *testing.T) {
+	c := newStubClient(t)
+
+	resp := c.call("initialize", map[string]any{
+		"protocolVersion": "2025-03-26",
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "stub", "version": "0"},
+	})
+	result := resp["result"].(map[string]any)
+	if result["protocolVersion"] != "2025-03-26" {
+		t.Errorf("protocolVersion = %v", result["protocolVersion"])
+	}
+	if result["serverInfo"].(map[string]any)["name"] != "clog" {
+		t.Errorf("serverInfo = %v", result["serverInfo"])
+	}
+	// Notifications get no response; the next line answers the ping
+	c.notify("notifications/initialized")
+	if resp := c.call("ping", nil); resp["error"] != nil {
+		t.Errorf("ping: %v", resp["error"])
+	}
+
+	tools := c.call("tools/list", nil)["result"].(map[string]any)["tools"].([]any)
+	var names []string
+	for _, tl := range tools {
+		names = append(names, tl.(map[string]any)["name"].(string))
+	}
+	want := "search_history get_session list_recent_sessions get_watch_matches"
+	if strings.Join(names, " ") != want {
+		t.Errorf("tools = %v, want %s", names, want)
+	}
+}
+
+func TestMCP_Errors(t *testing.T) {
+	c := newStubClient(t)
+
+	if code := c.send("{not json")["error"].(map[string]any)["code"]; code != float64(codeParseError) {
+		t.Errorf("parse error code = %v", code)
+	}
+	if code := c.call("resources/list", nil)["error"].(map[string]any)["code"]; code != float64(codeMethodNotFound) {
+		t.Errorf("unknown method code = %v", code)
+	}
+	if code := c.call("tools/call", map[string]any{"name": "nope"})["error"].(map[string]any)["code"]; code != float64(codeInvalidParams) {
+		t.Errorf("unknown tool code = %v", code)
+	}
+	// Tool failures go to the model, not the protocol
+	if text, isError := c.callTool("search_history", map[string]any{"qeury": "x"}); !isError || !strings.Contains(text, "qeury") {
+		t.Errorf("misspelled argument = %q, isError %v", text, isError)
+	}
+	if _, isError := c.callTool("get_session", map[string]any{"session_id": "missing"}); !isError {
+		t.Error("unknown session should be a tool error")
+	}
+}
+
+func TestMCP_Tools(t *testing.T) {
+	c := newStubClient(t)
+
+	text, isError := c.callTool("search_history", map[string]any{"query": "flaky type:assistant"})
+	if isError {
+		t.Fatal(text)
+	}
+	var hits []searchHit
+	if err := json.Unmarshal([]byte(text), &hits); err != nil {
+		t.Fatalf("%v: %s", err, text)
+	}
+	if len(hits) != 1 || hits[0].SessionID != "sess-1" || !strings.Contains(hits[0].Snippet, "<<flaky>>") {
+		t.Errorf("hits = %+v", hits)
+	}
+
+	text, _ = c.callTool("get_session", map[string]any{"session_id": "sess"})
+	if !strings.Contains(text, "sorting the keys fixed it") {
+		t.Errorf("get_session = %s", text)
+	}
+	text, _ = c.callTool("get_session", map[string]any{"session_id": "sess-1", "limit": 1})
+	if strings.Contains(text, "sorting the keys") || !strings.Contains(text, "offset=1") {
+		t.Errorf("first page = %s", text)
+	}
+
+	text, _ = c.callTool("list_recent_sessions", map[string]any{"limit": 5})
+	if !strings.Contains(text, `"session_id": "sess-1"`) {
+		t.Errorf("list_recent_sessions = %s", text)
+	}
+
+	text, _ = c.callTool("get_watch_matches", map[string]any{"watch": "flaky"})
+	var watches []watchMatches
+	if err := json.Unmarshal([]byte(text), &watches); err != nil {
+		t.Fatalf("%v: %s", err, text)
+	}
+	if len(watches) != 1 || len(watches[0].Matches) != 2 {
+		t.Errorf("watch matches = %+v", watches)
+	}
+	if _, isError := c.callTool("get_watch_matches", map[string]any{"watch": "nope"}); !isError {
+		t.Error("unknown watch should be a tool error")
+	}
+}
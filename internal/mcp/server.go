@@ -0,0 +1,188 @@
+// Package mcp serves the index to Model Context Protocol clients such as
+// Claude Code. Messages are JSON-RPC 2.0, one per line, over stdio.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+// protocolVersions are the MCP revisions this server speaks, newest last.
+var protocolVersions = []string{"2024-11-05", "2025-03-26", "2025-06-18"}
+
+// instructions tell the client's model when the tools are useful.
+const instructions = `clog indexes past Claude Code conversations on this machine. ` +
+	`Use search_history to find how a problem was solved before, then get_session to read that conversation.`
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Server answers MCP requests from the index.
+type Server struct {
+	db      *store.Store
+	version string
+	tools   []tool
+}
+
+// New returns a server for db. version is reported to clients.
+func New(db *store.Store, version string) *Server {
+	s := &Server{db: db, version: version}
+	s.tools = s.toolset()
+	return s
+}
+
+// Serve answers requests read from r on w, one at a time, until r ends or
+// ctx is done.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		resp := s.handle(ctx, line)
+		if resp == nil {
+			continue
+		}
+		if err := enc.Encode(resp); err != nil {
+			return fmt.Errorf("write response: %w", err)
+		}
+	}
+	return scanner.Err()
+}
+
+// handle answers one message. Notifications get no response.
+func (s *Server) handle(ctx context.Context, line []byte) *response {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return errorResponse(nil, codeParseError, "parse error: "+err.Error())
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, codeInvalidRequest, "invalid request")
+	}
+	if req.ID == nil {
+		return nil
+	}
+
+	var result any
+	var rerr *rpcError
+	switch req.Method {
+	case "initialize":
+		result, rerr = s.initialize(req.Params)
+	case "ping":
+		result = struct{}{}
+	case "tools/list":
+		result = map[string]any{"tools": s.tools}
+	case "tools/call":
+		result, rerr = s.callTool(ctx, req.Params)
+	default:
+		rerr = &rpcError{codeMethodNotFound, "method not found: " + req.Method}
+	}
+	if rerr != nil {
+		return errorResponse(req.ID, rerr.Code, rerr.Message)
+	}
+	return &response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+func errorResponse(id json.RawMessage, code int, msg string) *response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &response{JSONRPC: "2.0", ID: id, Error: &rpcError{code, msg}}
+}
+
+// initialize agrees on the client's protocol version when this server
+// speaks it, and otherwise offers the newest one it does.
+func (s *Server) initialize(params json.RawMessage) (any, *rpcError) {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+	}
+	version := protocolVersions[len(protocolVersions)-1]
+	if slices.Contains(protocolVersions, p.ProtocolVersion) {
+		version = p.ProtocolVersion
+	}
+	return map[string]any{
+		"protocolVersion": version,
+		"capabilities":    map[string]any{"tools": map[string]any{}},
+		"serverInfo":      map[string]string{"name": "clog", "version": s.version},
+		"instructions":    instructions,
+	}, nil
+}
+
+// toolResult is the result of tools/call. Failures inside a tool are
+// reported to the model with isError rather than as protocol errors.
+type toolResult struct {
+	Content []textContent `json:"content"`
+	IsError bool          `json:"isError,omitempty"`
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (any, *rpcError) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{codeInvalidParams, err.Error()}
+	}
+	i := slices.IndexFunc(s.tools, func(t tool) bool { return t.Name == p.Name })
+	if i < 0 {
+		return nil, &rpcError{codeInvalidParams, "unknown tool: " + p.Name}
+	}
+	if len(p.Arguments) == 0 {
+		p.Arguments = json.RawMessage("{}")
+	}
+
+	text, err := s.tools[i].call(ctx, p.Arguments)
+	if err != nil {
+		return toolResult{Content: []textContent{{"text", err.Error()}}, IsError: true}, nil
+	}
+	return toolResult{Content: []textContent{{"text", text}}}, nil
+}
@@ -0,0 +1,289 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+	"github.com/thinkwright/claude-chronicle/internal/export"
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+// tool is one MCP tool: its listing, and the function that runs it on the
+// call's JSON arguments and returns text for the model.
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+
+	call func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// schema builds a JSON Schema for an object with the given properties.
+func schema(required []string, props map[string]any) map[string]any {
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func prop(typ, description string) map[string]any {
+	return map[string]any{"type": typ, "description": description}
+}
+
+func (s *Server) toolset() []tool {
+	return []tool{
+		{
+			Name: "search_history",
+			Description: "Search past Claude Code conversations on this machine. " +
+				"Free text is matched with full-text search; filters narrow it: " +
+				"project:NAME, type:user|assistant, model:opus, tool:Bash, branch:NAME, " +
+				"cwd:PATH, age:<7d, tokens:>5000, in:thinking|text|tools. " +
+				"Returns matching messages with their session_id; snippets mark matched words with << >>.",
+			InputSchema: schema([]string{"query"}, map[string]any{
+				"query":   prop("string", `Search query, e.g. "flaky test tool:Bash age:<30d"`),
+				"project": prop("string", "Only search this project"),
+				"limit":   prop("integer", "Maximum results (default 20, at most 100)"),
+			}),
+			call: s.searchHistory,
+		},
+		{
+			Name: "get_session",
+			Description: "Read a past conversation as Markdown, by session_id " +
+				"(an unambiguous prefix is enough). Long sessions are returned in pages.",
+			InputSchema: schema([]string{"session_id"}, map[string]any{
+				"session_id": prop("string", "Session id or prefix, from search_history or list_recent_sessions"),
+				"offset":     prop("integer", "First message to return (default 0)"),
+				"limit":      prop("integer", "Messages to return (default 100, at most 500)"),
+			}),
+			call: s.getSession,
+		},
+		{
+			Name:        "list_recent_sessions",
+			Description: "List the most recently active conversations, newest first.",
+			InputSchema: schema(nil, map[string]any{
+				"project": prop("string", "Only list this project's sessions"),
+				"limit":   prop("integer", "Maximum sessions (default 20, at most 100)"),
+			}),
+			call: s.listRecentSessions,
+		},
+		{
+			Name: "get_watch_matches",
+			Description: "List matches of the user's watchlist: regex patterns clog " +
+				"checks every new message against.",
+			InputSchema: schema(nil, map[string]any{
+				"watch":       prop("string", "Watchlist item name or id (default: all items)"),
+				"unseen_only": prop("boolean", "Only matches the user has not seen"),
+				"limit":       prop("integer", "Maximum matches per item (default 50, at most 500)"),
+			}),
+			call: s.getWatchMatches,
+		},
+	}
+}
+
+// decodeArgs unmarshals tool arguments, rejecting unknown ones so typos
+// surface to the model.
+func decodeArgs(args json.RawMessage, v any) error {
+	dec := json.NewDecoder(strings.NewReader(string(args)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// clamp returns n, or def when it is unset, capped at max.
+func clamp(n, def, max int) int {
+	if n <= 0 {
+		return def
+	}
+	return min(n, max)
+}
+
+func toJSON(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	return string(data), err
+}
+
+type searchHit struct {
+	SessionID   string `json:"session_id"`
+	Subagent    string `json:"subagent,omitempty"`
+	Project     string `json:"project"`
+	Timestamp   string `json:"timestamp"`
+	Type        string `json:"type"`
+	Snippet     string `json:"snippet"`
+	FirstPrompt string `json:"session_first_prompt"`
+	GitBranch   string `json:"git_branch,omitempty"`
+}
+
+func (s *Server) searchHistory(ctx context.Context, args json.RawMessage) (string, error) {
+	var a struct {
+		Query   string `json:"query"`
+		Project string `json:"project"`
+		Limit   int    `json:"limit"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(a.Query) == "" {
+		return "", errors.New("query is required")
+	}
+
+	results, err := s.db.SearchProject(a.Query, a.Project, clamp(a.Limit, 20, 100))
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "No matching messages.", nil
+	}
+	hits := make([]searchHit, 0, len(results))
+	for _, r := range results {
+		snippet := r.Highlighted
+		if snippet == "" {
+			snippet = r.Text
+		}
+		hits = append(hits, searchHit{
+			SessionID:   r.SessionID,
+			Subagent:    r.Subagent,
+			Project:     r.Project,
+			Timestamp:   r.Timestamp,
+			Type:        r.MessageType,
+			Snippet:     snippet,
+			FirstPrompt: r.FirstPrompt,
+			GitBranch:   r.GitBranch,
+		})
+	}
+	return toJSON(hits)
+}
+
+func (s *Server) getSession(ctx context.Context, args json.RawMessage) (string, error) {
+	var a struct {
+		SessionID string `json:"session_id"`
+		Offset    int    `json:"offset"`
+		Limit     int    `json:"limit"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	sess, err := s.db.ResolveSession(a.SessionID)
+	if err != nil {
+		return "", err
+	}
+	messages, err := claude.LoadMessages(sess.FullPath)
+	if err != nil {
+		// The file is gone; the index still has the text
+		if messages, err = s.db.SessionMessages(sess.SessionID); err != nil {
+			return "", err
+		}
+	}
+
+	total := len(messages)
+	start := min(max(a.Offset, 0), total)
+	end := min(start+clamp(a.Limit, 100, 500), total)
+
+	var b strings.Builder
+	if err := export.Render(&b, *sess, messages[start:end], export.Markdown); err != nil {
+		return "", err
+	}
+	if start > 0 || end < total {
+		fmt.Fprintf(&b, "\n---\nMessages %d–%d of %d.", start, end, total)
+		if end < total {
+			fmt.Fprintf(&b, " Call get_session with offset=%d for more.", end)
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+type sessionInfo struct {
+	SessionID    string `json:"session_id"`
+	Project      string `json:"project"`
+	FirstPrompt  string `json:"first_prompt"`
+	Modified     string `json:"modified"`
+	MessageCount int    `json:"message_count"`
+	GitBranch    string `json:"git_branch,omitempty"`
+	Cwd          string `json:"cwd,omitempty"`
+}
+
+func (s *Server) listRecentSessions(ctx context.Context, args json.RawMessage) (string, error) {
+	var a struct {
+		Project string `json:"project"`
+		Limit   int    `json:"limit"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	sessions, err := s.db.RecentSessions(a.Project, clamp(a.Limit, 20, 100))
+	if err != nil {
+		return "", err
+	}
+	if len(sessions) == 0 {
+		return "No sessions indexed.", nil
+	}
+	infos := make([]sessionInfo, 0, len(sessions))
+	for _, e := range sessions {
+		infos = append(infos, sessionInfo{
+			SessionID:    e.SessionID,
+			Project:      e.ProjectPath,
+			FirstPrompt:  e.FirstPrompt,
+			Modified:     e.Modified,
+			MessageCount: e.MessageCount,
+			GitBranch:    e.GitBranch,
+			Cwd:          e.Cwd,
+		})
+	}
+	return toJSON(infos)
+}
+
+type watchMatches struct {
+	Watch   string             `json:"watch"`
+	Pattern string             `json:"pattern"`
+	Unseen  int                `json:"unseen"`
+	Matches []store.WatchMatch `json:"matches"`
+}
+
+func (s *Server) getWatchMatches(ctx context.Context, args json.RawMessage) (string, error) {
+	var a struct {
+		Watch      string `json:"watch"`
+		UnseenOnly bool   `json:"unseen_only"`
+		Limit      int    `json:"limit"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	items, err := s.db.ListWatches()
+	if err != nil {
+		return "", err
+	}
+	limit := clamp(a.Limit, 50, 500)
+
+	var out []watchMatches
+	for _, item := range items {
+		if a.Watch != "" && item.Name != a.Watch && strconv.FormatInt(item.ID, 10) != a.Watch {
+			continue
+		}
+		matches, err := s.db.MatchesForWatch(item.ID, limit)
+		if err != nil {
+			return "", err
+		}
+		wm := watchMatches{Watch: item.Name, Pattern: item.Pattern, Unseen: item.UnseenCount, Matches: []store.WatchMatch{}}
+		for _, m := range matches {
+			if !a.UnseenOnly || !m.Seen {
+				wm.Matches = append(wm.Matches, m)
+			}
+		}
+		out = append(out, wm)
+	}
+	if len(out) == 0 {
+		if a.Watch != "" {
+			return "", fmt.Errorf("no watchlist item named %q", a.Watch)
+		}
+		return "The watchlist is empty.", nil
+	}
+	return toJSON(out)
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/watcher"
+)
+
+// event is one server-sent event.
+type event struct {
+	name string
+	data any
+}
+
+// indexEvent reports an index pass that changed something.
+type indexEvent struct {
+	Changed int `json:"changed"`
+	status
+}
+
+// hub fans events out to the connected event streams. A client too slow to
+// keep up loses events rather than holding up the others.
+type hub struct {
+	mu   sync.Mutex
+	subs map[chan event]struct{}
+}
+
+func newHub() *hub {
+	return &hub{subs: make(map[chan event]struct{})}
+}
+
+func (h *hub) subscribe() chan event {
+	ch := make(chan event, 64)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *hub) unsubscribe(ch chan event) {
+	h.mu.Lock()
+	delete(h.subs, ch)
+	h.mu.Unlock()
+}
+
+func (h *hub) publish(e event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// keepAlive is how often an idle event stream gets a comment line, so
+// proxies and clients don't time it out.
+const keepAlive = 30 * time.Second
+
+// handleEvents streams "index" and "match" events until the client leaves.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	ch := s.hub.subscribe()
+	defer s.hub.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e := <-ch:
+			data, err := json.Marshal(e.data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// Watch indexes new and changed sessions, then re-indexes whenever session
+// files change, publishing the results to event streams until ctx is done.
+func (s *Server) Watch(ctx context.Context) error {
+	changes, err := watcher.Changes(ctx, s.opts.ProjectPaths)
+	if err != nil {
+		return err
+	}
+	s.refresh()
+	for range changes {
+		s.refresh()
+	}
+	return ctx.Err()
+}
+
+// refresh indexes changed files, then publishes an "index" event and a
+// "match" event for each watchlist match on a newly indexed message.
+// Matches a new or edited watchlist item found in older messages are not
+// published; clients list them with /api/watchlist/{id}/matches.
+func (s *Server) refresh() {
+	before := s.db.LastMessageID()
+	changed, err := s.db.IndexChanged(s.opts.ProjectPaths)
+	if err != nil || changed == 0 {
+		return
+	}
+	s.hub.publish(event{"index", indexEvent{Changed: changed, status: s.status()}})
+
+	const batch = 500
+	for {
+		matches, err := s.db.MatchesSince(s.lastMatch, batch)
+		if err != nil {
+			return
+		}
+		for _, m := range matches {
+			s.lastMatch = m.ID
+			if m.MessageID > before {
+				s.hub.publish(event{"match", m})
+			}
+		}
+		if len(matches) < batch {
+			return
+		}
+	}
+}
@@ -0,0 +1,536 @@
+// Package server exposes the index over a local HTTP JSON API, with a
+// server-sent events stream of index updates and new watchlist matches.
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+	"github.com/thinkwright/claude-chronicle/internal/export"
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+// Options configure a Server.
+type Options struct {
+	// ProjectPaths are the extra project directories from the config.
+	ProjectPaths []string
+
+	// LocalOnly rejects requests whose Host is not a loopback name, so a
+	// web page cannot reach the API through DNS rebinding.
+	LocalOnly bool
+
+	// AllowOrigins are the browser origins, besides the server's own, that
+	// may call the API, e.g. "http://localhost:5173" for a dashboard.
+	AllowOrigins []string
+}
+
+// Server serves the index over HTTP.
+type Server struct {
+	db   *store.Store
+	opts Options
+	mux  *http.ServeMux
+	hub  *hub
+
+	lastMatch int64 // newest watchlist match already looked at, owned by Watch
+}
+
+// New returns a server for db. Call Watch to publish index updates.
+func New(db *store.Store, opts Options) *Server {
+	s := &Server{
+		db:        db,
+		opts:      opts,
+		mux:       http.NewServeMux(),
+		hub:       newHub(),
+		lastMatch: db.LastMatchID(),
+	}
+
+	s.mux.HandleFunc("GET /api/status", s.handleStatus)
+	s.mux.HandleFunc("GET /api/projects", s.handleProjects)
+	s.mux.HandleFunc("GET /api/sessions", s.handleSessions)
+	s.mux.HandleFunc("GET /api/sessions/{id}", s.handleSession)
+	s.mux.HandleFunc("GET /api/sessions/{id}/messages", s.handleMessages)
+	s.mux.HandleFunc("POST /api/sessions/{id}/seen", s.handleSessionSeen)
+	s.mux.HandleFunc("GET /api/search", s.handleSearch)
+	s.mux.HandleFunc("GET /api/watchlist", s.handleWatchlist)
+	s.mux.HandleFunc("POST /api/watchlist", s.handleAddWatch)
+	s.mux.HandleFunc("GET /api/watchlist/{id}", s.handleWatch)
+	s.mux.HandleFunc("PATCH /api/watchlist/{id}", s.handleUpdateWatch)
+	s.mux.HandleFunc("DELETE /api/watchlist/{id}", s.handleRemoveWatch)
+	s.mux.HandleFunc("GET /api/watchlist/{id}/matches", s.handleMatches)
+	s.mux.HandleFunc("POST /api/watchlist/{id}/seen", s.handleWatchSeen)
+	s.mux.HandleFunc("GET /api/watchlist/{id}/deliveries", s.handleDeliveries)
+	s.mux.HandleFunc("GET /api/events", s.handleEvents)
+	return s
+}
+
+// ServeHTTP checks the request's host and origin before routing it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.opts.LocalOnly && !isLoopbackHost(r.Host) {
+		writeError(w, http.StatusForbidden, "host not allowed")
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !s.originAllowed(origin, r.Host) {
+			writeError(w, http.StatusForbidden, "origin not allowed")
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) originAllowed(origin, host string) bool {
+	if slices.Contains(s.opts.AllowOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == host
+}
+
+// isLoopbackHost reports whether a Host header names this machine.
+func isLoopbackHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+type status struct {
+	Files         int   `json:"files"`
+	Messages      int   `json:"messages"`
+	LastIndexedAt int64 `json:"last_indexed_at"`
+	Unseen        int   `json:"unseen_matches"`
+
+	// Writer is the clog process writing the index, which may be this
+	// server; null when none is.
+	Writer *store.LeaseHolder `json:"writer"`
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.status())
+}
+
+func (s *Server) status() status {
+	writer, _ := s.db.WriterLease()
+	return status{
+		Files:         s.db.FileCount(),
+		Messages:      s.db.MessageCount(),
+		LastIndexedAt: s.db.LastIndexedAt(),
+		Unseen:        s.db.TotalUnseenCount(),
+		Writer:        writer,
+	}
+}
+
+type project struct {
+	Name         string `json:"name"`
+	Path         string `json:"path"`
+	SessionCount int    `json:"session_count"`
+	LastModified int64  `json:"last_modified"`
+}
+
+func (s *Server) handleProjects(w http.ResponseWriter, r *http.Request) {
+	found, err := claude.DiscoverProjects(s.opts.ProjectPaths)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	projects := make([]project, 0, len(found))
+	for _, p := range found {
+		projects = append(projects, project{
+			Name:         p.Name,
+			Path:         p.Path,
+			SessionCount: p.SessionCount,
+			LastModified: p.LastModified,
+		})
+	}
+	writeJSON(w, http.StatusOK, projects)
+}
+
+// session is the JSON form of an indexed session.
+type session struct {
+	SessionID    string   `json:"session_id"`
+	Project      string   `json:"project"`
+	Path         string   `json:"path,omitempty"`
+	FirstPrompt  string   `json:"first_prompt"`
+	MessageCount int      `json:"message_count"`
+	Created      string   `json:"created"`
+	Modified     string   `json:"modified"`
+	GitBranch    string   `json:"git_branch,omitempty"`
+	Branches     []string `json:"branches,omitempty"`
+	Cwd          string   `json:"cwd,omitempty"`
+	Version      string   `json:"version,omitempty"`
+	Missing      bool     `json:"source_missing,omitempty"`
+}
+
+func newSession(e claude.SessionEntry) session {
+	return session{
+		SessionID:    e.SessionID,
+		Project:      e.ProjectPath,
+		Path:         e.FullPath,
+		FirstPrompt:  e.FirstPrompt,
+		MessageCount: e.MessageCount,
+		Created:      e.Created,
+		Modified:     e.Modified,
+		GitBranch:    e.GitBranch,
+		Branches:     e.Branches,
+		Cwd:          e.Cwd,
+		Version:      e.Version,
+		Missing:      e.Missing,
+	}
+}
+
+func newSessions(entries []claude.SessionEntry) []session {
+	sessions := make([]session, 0, len(entries))
+	for _, e := range entries {
+		sessions = append(sessions, newSession(e))
+	}
+	return sessions
+}
+
+// handleSessions lists a project's sessions (?project=NAME) or looks up
+// sessions by ID (?ids=a,b).
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var entries []claude.SessionEntry
+	var err error
+	switch {
+	case q.Get("ids") != "":
+		entries, err = s.db.SessionsByIDs(strings.Split(q.Get("ids"), ","))
+	case q.Get("project") != "":
+		entries, err = s.db.SessionsByProject(q.Get("project"))
+	default:
+		writeError(w, http.StatusBadRequest, "project or ids is required")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newSessions(entries))
+}
+
+// handleSession returns one session. The ID may be an unambiguous prefix.
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.db.ResolveSession(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newSession(*sess))
+}
+
+// handleMessages returns a session's transcript in the normalized export
+// form, from its JSONL file or, when that is gone, from the index.
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.db.ResolveSession(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	messages, err := claude.LoadMessages(sess.FullPath)
+	if err != nil {
+		if messages, err = s.db.SessionMessages(sess.SessionID); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, export.NewDocument(*sess, messages))
+}
+
+func (s *Server) handleSessionSeen(w http.ResponseWriter, r *http.Request) {
+	if err := s.db.MarkSessionSeen(r.PathValue("id")); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSearch runs a query with the dashboard's syntax: ?q=QUERY, with
+// optional project and limit.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := strings.TrimSpace(q.Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit, ok := limitParam(w, r, 50)
+	if !ok {
+		return
+	}
+	results, err := s.db.SearchProject(query, q.Get("project"), limit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if results == nil {
+		results = []store.SearchResult{}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) handleWatchlist(w http.ResponseWriter, r *http.Request) {
+	items, err := s.db.ListWatches()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if items == nil {
+		items = []store.WatchItem{}
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// watchRequest is the body of watchlist creates and updates. Omitted
+// fields are left unchanged on update.
+type watchRequest struct {
+	Name    *string `json:"name"`
+	Pattern *string `json:"pattern"`
+	Color   string  `json:"color"`
+	Enabled *bool   `json:"enabled"`
+
+	Action *store.WatchAction `json:"action"`
+}
+
+// checkAction validates an action sent to the API. Commands run arbitrary
+// shell and pipes write to local files, so both can only be set locally
+// with clog watch action.
+func checkAction(a *store.WatchAction) error {
+	if a == nil {
+		return nil
+	}
+	if a.Kind == store.ActionCommand || a.Kind == store.ActionPipe {
+		return errors.New(a.Kind + " actions can only be set with `clog watch action`")
+	}
+	return a.Validate()
+}
+
+func (s *Server) handleAddWatch(w http.ResponseWriter, r *http.Request) {
+	var req watchRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Pattern == nil || *req.Pattern == "" {
+		writeError(w, http.StatusBadRequest, "pattern is required")
+		return
+	}
+	if err := checkAction(req.Action); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	name := *req.Pattern
+	if req.Name != nil && *req.Name != "" {
+		name = *req.Name
+	}
+	item, err := s.db.AddWatch(name, *req.Pattern, req.Color)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Action != nil {
+		if err := s.db.SetWatchAction(item.ID, *req.Action); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		item.Action = *req.Action
+	}
+	writeJSON(w, http.StatusCreated, item)
+}
+
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.watchParam(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) handleUpdateWatch(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.watchParam(w, r)
+	if !ok {
+		return
+	}
+	var req watchRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := checkAction(req.Action); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name, pattern := item.Name, item.Pattern
+	if req.Name != nil {
+		name = *req.Name
+	}
+	if req.Pattern != nil {
+		pattern = *req.Pattern
+	}
+	// UpdateWatch re-runs matching from scratch, so skip it if nothing changed
+	if name != item.Name || pattern != item.Pattern {
+		if err := s.db.UpdateWatch(item.ID, name, pattern); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Enabled != nil && *req.Enabled != item.Enabled {
+		if err := s.db.ToggleWatch(item.ID); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if req.Action != nil {
+		if err := s.db.SetWatchAction(item.ID, *req.Action); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	updated, err := s.db.GetWatch(item.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (s *Server) handleRemoveWatch(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.watchParam(w, r)
+	if !ok {
+		return
+	}
+	if err := s.db.RemoveWatch(item.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleMatches(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.watchParam(w, r)
+	if !ok {
+		return
+	}
+	limit, ok := limitParam(w, r, 100)
+	if !ok {
+		return
+	}
+	matches, err := s.db.MatchesForWatch(item.ID, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if matches == nil {
+		matches = []store.WatchMatch{}
+	}
+	writeJSON(w, http.StatusOK, matches)
+}
+
+func (s *Server) handleWatchSeen(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.watchParam(w, r)
+	if !ok {
+		return
+	}
+	if err := s.db.MarkWatchSeen(item.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeliveries(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.watchParam(w, r)
+	if !ok {
+		return
+	}
+	limit, ok := limitParam(w, r, 100)
+	if !ok {
+		return
+	}
+	deliveries, err := s.db.Deliveries(item.ID, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if deliveries == nil {
+		deliveries = []store.Delivery{}
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// watchParam loads the watchlist item named by the {id} path segment,
+// writing an error response if there is none.
+func (s *Server) watchParam(w http.ResponseWriter, r *http.Request) (*store.WatchItem, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid watchlist id")
+		return nil, false
+	}
+	item, err := s.db.GetWatch(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "watchlist item not found")
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return item, true
+}
+
+// limitParam parses the optional ?limit= parameter, capped at 1000.
+func limitParam(w http.ResponseWriter, r *http.Request, def int) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+		return 0, false
+	}
+	return min(n, 1000), true
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
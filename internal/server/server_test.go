@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+const testSession = `{"type":"user","uuid":"u1","cwd":"/work/demo","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"fix the deploy script"}}
+{"type":"assistant","uuid":"a1","parentUuid":"u1","cwd":"/work/demo","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"claude-sonnet-4","content":[{"type":"text","text":"The deploy script skips migrations."}],"usage":{"input_tokens":100,"output_tokens":20}}}
+`
+
+// newTestServer indexes one session under a temporary Claude config dir and
+// serves it. It returns the session file so tests can append to it.
+func newTestServer(t *testing.T) (*Server, *httptest.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("CLAUDE_CONFIG_DIR", dir)
+	path := filepath.Join(dir, "projects", "-work-demo", "sess-1.jsonl")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, []byte(testSession), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := store.Open(filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	srv := New(db, Options{LocalOnly: true})
+	srv.refresh()
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return srv, ts, path
+}
+
+func call(t *testing.T, ts *httptest.Server, method, path, body string, out any) int {
+	t.Helper()
+	req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAPI_SessionsAndSearch(t *testing.T) {
+	_, ts, _ := newTestServer(t)
+
+	var projects []project
+	call(t, ts, "GET", "/api/projects", "", &projects)
+	if len(projects) != 1 || projects[0].Name != "demo" {
+		t.Fatalf("projects = %+v", projects)
+	}
+
+	var sessions []session
+	call(t, ts, "GET", "/api/sessions?project=demo", "", &sessions)
+	if len(sessions) != 1 || sessions[0].SessionID != "sess-1" {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+	call(t, ts, "GET", "/api/sessions?ids=sess-1,nope", "", &sessions)
+	if len(sessions) != 1 {
+		t.Errorf("sessions by id = %+v", sessions)
+	}
+
+	var doc struct {
+		SessionID string `json:"session_id"`
+		Messages  []struct {
+			Text string `json:"text"`
+		} `json:"messages"`
+	}
+	if code := call(t, ts, "GET", "/api/sessions/sess/messages", "", &doc); code != http.StatusOK {
+		t.Fatalf("messages status = %d", code)
+	}
+	if doc.SessionID != "sess-1" || len(doc.Messages) != 2 {
+		t.Errorf("document = %+v", doc)
+	}
+
+	var results []store.SearchResult
+	call(t, ts, "GET", "/api/search?q=migrations&limit=5", "", &results)
+	if len(results) != 1 || results[0].SessionID != "sess-1" {
+		t.Errorf("results = %+v", results)
+	}
+	if code := call(t, ts, "GET", "/api/search", "", nil); code != http.StatusBadRequest {
+		t.Errorf("search without q = %d, want 400", code)
+	}
+	if code := call(t, ts, "GET", "/api/sessions/nope", "", nil); code != http.StatusNotFound {
+		t.Errorf("unknown session = %d, want 404", code)
+	}
+}
+
+func TestAPI_Watchlist(t *testing.T) {
+	_, ts, _ := newTestServer(t)
+
+	var item store.WatchItem
+	if code := call(t, ts, "POST", "/api/watchlist", `{"name":"deploys","pattern":"deploy"}`, &item); code != http.StatusCreated {
+		t.Fatalf("create status = %d", code)
+	}
+	if code := call(t, ts, "POST", "/api/watchlist", `{"pattern":"("}`, nil); code != http.StatusBadRequest {
+		t.Errorf("invalid pattern = %d, want 400", code)
+	}
+
+	var matches []store.WatchMatch
+	call(t, ts, "GET", "/api/watchlist/"+itoa(item.ID)+"/matches", "", &matches)
+	if len(matches) != 2 {
+		t.Errorf("matches = %+v, want 2", matches)
+	}
+	if code := call(t, ts, "POST", "/api/watchlist/"+itoa(item.ID)+"/seen", "", nil); code != http.StatusNoContent {
+		t.Errorf("mark seen = %d", code)
+	}
+
+	var updated store.WatchItem
+	call(t, ts, "PATCH", "/api/watchlist/"+itoa(item.ID), `{"enabled":false}`, &updated)
+	if updated.Enabled || updated.Pattern != "deploy" || updated.UnseenCount != 0 {
+		t.Errorf("updated = %+v", updated)
+	}
+	call(t, ts, "PATCH", "/api/watchlist/"+itoa(item.ID), `{"action":{"kind":"webhook","target":"http://127.0.0.1:9/hook"}}`, &updated)
+	if updated.Action.Kind != store.ActionWebhook || updated.Action.Target != "http://127.0.0.1:9/hook" {
+		t.Errorf("action = %+v", updated.Action)
+	}
+	for _, kind := range []string{"command", "pipe"} {
+		body := `{"action":{"kind":"` + kind + `","target":"/tmp/x"}}`
+		if code := call(t, ts, "PATCH", "/api/watchlist/"+itoa(item.ID), body, nil); code != http.StatusBadRequest {
+			t.Errorf("%s action over the API = %d, want 400", kind, code)
+		}
+	}
+	var deliveries []store.Delivery
+	if code := call(t, ts, "GET", "/api/watchlist/"+itoa(item.ID)+"/deliveries", "", &deliveries); code != http.StatusOK || deliveries == nil {
+		t.Errorf("deliveries = %d %v", code, deliveries)
+	}
+
+	if code := call(t, ts, "DELETE", "/api/watchlist/"+itoa(item.ID), "", nil); code != http.StatusNoContent {
+		t.Errorf("delete = %d", code)
+	}
+	if code := call(t, ts, "GET", "/api/watchlist/"+itoa(item.ID), "", nil); code != http.StatusNotFound {
+		t.Errorf("deleted item = %d, want 404", code)
+	}
+}
+
+func TestAPI_RejectsForeignRequests(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+	srv.opts.AllowOrigins = []string{"http://localhost:5173"}
+
+	for _, tc := range []struct {
+		host, origin string
+		want         int
+	}{
+		{"127.0.0.1:7420", "", http.StatusOK},
+		{"localhost:7420", "http://localhost:5173", http.StatusOK},
+		{"localhost:7420", "http://localhost:7420", http.StatusOK},
+		{"localhost:7420", "https://evil.example", http.StatusForbidden},
+		{"evil.example:7420", "", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest("GET", "/api/status", nil)
+		req.Host = tc.host
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("host %s origin %q = %d, want %d", tc.host, tc.origin, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestEvents_PublishesNewMatches(t *testing.T) {
+	srv, ts, path := newTestServer(t)
+	srv.db.AddWatch("rollbacks", "rollback", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/api/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	stream := bufio.NewReader(resp.Body)
+	if line, _ := stream.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("first line = %q", line)
+	}
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`{"type":"user","uuid":"u2","parentUuid":"a1","timestamp":"2025-01-01T00:00:02Z","message":{"role":"user","content":"plan a rollback"}}` + "\n")
+	f.Close()
+	srv.refresh()
+
+	var events []string
+	for len(events) < 2 {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended after %v: %v", events, err)
+		}
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, strings.TrimSpace(name))
+			data, _ := stream.ReadString('\n')
+			if events[len(events)-1] == "match" && !strings.Contains(data, "rollback") {
+				t.Errorf("match data = %s", data)
+			}
+		}
+	}
+	if events[0] != "index" || events[1] != "match" {
+		t.Errorf("events = %v, want index then match", events)
+	}
+}
+
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,226 @@
+package store
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+// Bucket is activity within one time slot, starting at Start (local time).
+type Bucket struct {
+	Start    time.Time
+	Tokens   int
+	Messages int
+	Sessions int
+}
+
+// RankedItem is one row of a top-N breakdown.
+type RankedItem struct {
+	Name  string
+	Count int // calls for tools, messages for models, sessions for projects
+	Usage claude.Usage
+}
+
+// tokensExpr sums every token counter on a messages row.
+const tokensExpr = "(m.input_tokens + m.output_tokens + m.cache_read_tokens + m.cache_write_tokens)"
+
+// sinceParam formats a cutoff for comparison against the RFC3339 UTC
+// timestamps Claude Code writes. The zero time matches everything.
+func sinceParam(since time.Time) string {
+	if since.IsZero() {
+		return ""
+	}
+	return since.UTC().Format("2006-01-02T15:04:05")
+}
+
+// HourlyUsage returns token and message totals per hour since the cutoff,
+// oldest first. Hours with no activity are omitted.
+func (s *Store) HourlyUsage(since time.Time) ([]Bucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT substr(m.timestamp, 1, 13), SUM(`+tokensExpr+`), COUNT(*)
+		FROM messages m
+		WHERE m.timestamp >= ? AND m.timestamp != ''
+		GROUP BY 1
+		ORDER BY 1
+	`, sinceParam(since))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []Bucket
+	for rows.Next() {
+		var hour string
+		var b Bucket
+		if err := rows.Scan(&hour, &b.Tokens, &b.Messages); err != nil {
+			continue
+		}
+		t, err := time.Parse("2006-01-02T15", hour)
+		if err != nil {
+			continue
+		}
+		b.Start = t.Local()
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+// DailyUsage returns token, message and new-session totals per local
+// calendar day since the cutoff, oldest first. Days with no activity are
+// omitted.
+func (s *Store) DailyUsage(since time.Time) ([]Bucket, error) {
+	hourly, err := s.HourlyUsage(since)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	rows, err := s.db.Query(`
+		SELECT substr(created_at, 1, 13), COUNT(*)
+		FROM sessions
+		WHERE created_at >= ? AND created_at != '' AND parent_session_id = ''
+		GROUP BY 1
+	`, sinceParam(since))
+	if err != nil {
+		s.mu.RUnlock()
+		return nil, err
+	}
+	created := make(map[time.Time]int)
+	for rows.Next() {
+		var hour string
+		var n int
+		if rows.Scan(&hour, &n) != nil {
+			continue
+		}
+		if t, err := time.Parse("2006-01-02T15", hour); err == nil {
+			created[StartOfDay(t.Local())] += n
+		}
+	}
+	rows.Close()
+	s.mu.RUnlock()
+
+	byDay := make(map[time.Time]*Bucket)
+	var days []time.Time
+	day := func(t time.Time) *Bucket {
+		if b, ok := byDay[t]; ok {
+			return b
+		}
+		b := &Bucket{Start: t}
+		byDay[t] = b
+		days = append(days, t)
+		return b
+	}
+	for _, h := range hourly {
+		b := day(StartOfDay(h.Start))
+		b.Tokens += h.Tokens
+		b.Messages += h.Messages
+	}
+	for t, n := range created {
+		day(t).Sessions += n
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	out := make([]Bucket, len(days))
+	for i, t := range days {
+		out[i] = *byDay[t]
+	}
+	return out, nil
+}
+
+// TopTools returns the most frequently called tools since the cutoff.
+func (s *Store) TopTools(since time.Time, limit int) ([]RankedItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT name, COUNT(*)
+		FROM tool_calls
+		WHERE timestamp >= ?
+		GROUP BY name
+		ORDER BY 2 DESC, name
+		LIMIT ?
+	`, sinceParam(since), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []RankedItem
+	for rows.Next() {
+		var it RankedItem
+		if err := rows.Scan(&it.Name, &it.Count); err != nil {
+			continue
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// ModelMix returns token usage per model since the cutoff, largest first.
+func (s *Store) ModelMix(since time.Time) ([]RankedItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT m.model, COUNT(*),
+			SUM(m.input_tokens), SUM(m.output_tokens),
+			SUM(m.cache_read_tokens), SUM(m.cache_write_tokens)
+		FROM messages m
+		WHERE m.type = 'assistant' AND m.model != '' AND m.timestamp >= ?
+		GROUP BY m.model
+		ORDER BY SUM(`+tokensExpr+`) DESC
+	`, sinceParam(since))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRanked(rows), nil
+}
+
+// TopProjects returns the projects with the most token usage since the
+// cutoff. Count is the number of sessions active in the range.
+func (s *Store) TopProjects(since time.Time, limit int) ([]RankedItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT s.project, COUNT(DISTINCT s.session_id),
+			SUM(m.input_tokens), SUM(m.output_tokens),
+			SUM(m.cache_read_tokens), SUM(m.cache_write_tokens)
+		FROM messages m
+		`+sessionJoin+`
+		WHERE m.timestamp >= ?
+		GROUP BY s.project
+		ORDER BY SUM(`+tokensExpr+`) DESC
+		LIMIT ?
+	`, sinceParam(since), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRanked(rows), nil
+}
+
+func scanRanked(rows *sql.Rows) []RankedItem {
+	var items []RankedItem
+	for rows.Next() {
+		var it RankedItem
+		if err := rows.Scan(&it.Name, &it.Count, &it.Usage.InputTokens, &it.Usage.OutputTokens,
+			&it.Usage.CacheReadTokens, &it.Usage.CacheWriteTokens); err != nil {
+			continue
+		}
+		items = append(items, it)
+	}
+	return items
+}
+
+// StartOfDay truncates t to local midnight.
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
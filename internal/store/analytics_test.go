@@ -0,0 +1,132 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func seedAnalytics(t *testing.T, s *Store) {
+	t.Helper()
+	dir := t.TempDir()
+
+	a := `{"type":"user","uuid":"u1","timestamp":"2025-03-01T10:00:00Z","message":{"role":"user","content":"first"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-03-01T10:00:05Z","message":{"role":"assistant","model":"claude-opus-4-1","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"ls"}},{"type":"tool_use","id":"t2","name":"Read","input":{"file_path":"a.go"}}],"usage":{"input_tokens":100,"output_tokens":50,"cache_read_input_tokens":1000}}}
+{"type":"assistant","uuid":"a2","timestamp":"2025-03-01T11:30:00Z","message":{"role":"assistant","model":"claude-opus-4-1","content":[{"type":"tool_use","id":"t3","name":"Bash","input":{"command":"make"}}],"usage":{"input_tokens":10,"output_tokens":10}}}
+`
+	b := `{"type":"user","uuid":"u1","timestamp":"2025-03-03T09:00:00Z","message":{"role":"user","content":"second"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-03-03T09:00:02Z","message":{"role":"assistant","model":"claude-haiku-4-5","content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":5,"output_tokens":5}}}
+`
+	pa := filepath.Join(dir, "sess-a.jsonl")
+	pb := filepath.Join(dir, "sess-b.jsonl")
+	writeFile(t, pa, a)
+	writeFile(t, pb, b)
+	if _, err := s.indexFile(pa, "Alpha"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.indexFile(pb, "Beta"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHourlyUsage(t *testing.T) {
+	s := openTestStore(t)
+	seedAnalytics(t, s)
+
+	buckets, err := s.HourlyUsage(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 3 {
+		t.Fatalf("buckets = %d, want 3", len(buckets))
+	}
+	if buckets[0].Tokens != 1150 || buckets[0].Messages != 2 {
+		t.Errorf("first hour = %+v", buckets[0])
+	}
+	want := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	if !buckets[0].Start.Equal(want) {
+		t.Errorf("first hour starts %v, want %v", buckets[0].Start, want)
+	}
+
+	// The cutoff excludes earlier hours
+	recent, _ := s.HourlyUsage(time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC))
+	if len(recent) != 1 || recent[0].Tokens != 10 {
+		t.Errorf("recent = %+v", recent)
+	}
+}
+
+func TestDailyUsage(t *testing.T) {
+	s := openTestStore(t)
+	seedAnalytics(t, s)
+
+	days, err := s.DailyUsage(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokens, sessions int
+	for _, d := range days {
+		tokens += d.Tokens
+		sessions += d.Sessions
+		if !d.Start.Equal(StartOfDay(d.Start)) {
+			t.Errorf("bucket %v is not a day boundary", d.Start)
+		}
+	}
+	if tokens != 1180 || sessions != 2 {
+		t.Errorf("totals = %d tokens, %d sessions", tokens, sessions)
+	}
+	for i := 1; i < len(days); i++ {
+		if !days[i-1].Start.Before(days[i].Start) {
+			t.Error("days not in order")
+		}
+	}
+}
+
+func TestTopTools(t *testing.T) {
+	s := openTestStore(t)
+	seedAnalytics(t, s)
+
+	tools, err := s.TopTools(time.Time{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 2 || tools[0].Name != "Bash" || tools[0].Count != 2 || tools[1].Name != "Read" {
+		t.Errorf("tools = %+v", tools)
+	}
+	if limited, _ := s.TopTools(time.Time{}, 1); len(limited) != 1 {
+		t.Errorf("limit ignored: %d", len(limited))
+	}
+}
+
+func TestModelMix(t *testing.T) {
+	s := openTestStore(t)
+	seedAnalytics(t, s)
+
+	models, err := s.ModelMix(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[0].Name != "claude-opus-4-1" {
+		t.Fatalf("models = %+v", models)
+	}
+	if models[0].Count != 2 || models[0].Usage.CacheReadTokens != 1000 || models[0].Usage.Total() != 1170 {
+		t.Errorf("opus = %+v", models[0])
+	}
+}
+
+func TestTopProjects(t *testing.T) {
+	s := openTestStore(t)
+	seedAnalytics(t, s)
+
+	projects, err := s.TopProjects(time.Time{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 2 || projects[0].Name != "Alpha" || projects[0].Count != 1 {
+		t.Errorf("projects = %+v", projects)
+	}
+
+	recent, _ := s.TopProjects(time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), 10)
+	if len(recent) != 1 || recent[0].Name != "Beta" {
+		t.Errorf("recent projects = %+v", recent)
+	}
+}
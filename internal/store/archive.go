@@ -0,0 +1,290 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/archive"
+)
+
+// EnableArchive turns on archive mode: every indexed session file is
+// snapshotted into a, and sessions whose file vanishes are kept rather than
+// purged. budget caps the archive's compressed size in bytes (0 = no cap);
+// the oldest sessions are evicted first when it is exceeded.
+func (s *Store) EnableArchive(a *archive.Archive, budget int64) {
+	s.mu.Lock()
+	s.archive = a
+	s.archiveBudget = budget
+	s.mu.Unlock()
+}
+
+// Each snapshot rewrites the whole transcript, so a session that is still
+// being written is snapshotted at most every snapshotInterval. Once it has
+// been left alone for snapshotQuiet its final version is taken.
+const (
+	snapshotQuiet    = 2 * time.Minute
+	snapshotInterval = time.Hour
+)
+
+// snapshotFile archives path unless the archive already holds this version
+// of it, or holds a recent one and the file is still changing. The caller
+// must hold the write lock.
+func (s *Store) snapshotFile(path, sessionID string) error {
+	if s.archive == nil {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	mtime := info.ModTime().UnixMilli()
+
+	var oldHash string
+	var oldMtime, oldSize, archivedAt int64
+	err = s.db.QueryRow("SELECT hash, mtime, size, archived_at FROM archived_files WHERE path = ?", path).
+		Scan(&oldHash, &oldMtime, &oldSize, &archivedAt)
+	if err == nil && oldMtime == mtime && oldSize == info.Size() {
+		return nil
+	}
+	if err == nil && time.Since(info.ModTime()) < snapshotQuiet &&
+		time.Since(time.UnixMilli(archivedAt)) < snapshotInterval {
+		return nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	blob, err := s.archive.PutFile(path)
+	if err != nil {
+		return fmt.Errorf("archive %s: %w", path, err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO archived_files (path, session_id, hash, size, mtime, stored_size, archived_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET
+			hash = excluded.hash, size = excluded.size, mtime = excluded.mtime,
+			stored_size = excluded.stored_size, archived_at = excluded.archived_at
+	`, path, sessionID, blob.Hash, info.Size(), mtime, blob.Size, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	// A growing session leaves its previous snapshot behind
+	if oldHash != "" && oldHash != blob.Hash {
+		return s.dropBlobIfUnused(oldHash)
+	}
+	return nil
+}
+
+// dropBlobIfUnused deletes a blob no archived file refers to any more.
+func (s *Store) dropBlobIfUnused(hash string) error {
+	var refs int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM archived_files WHERE hash = ?", hash).Scan(&refs); err != nil {
+		return err
+	}
+	if refs > 0 {
+		return nil
+	}
+	return s.archive.Remove(hash)
+}
+
+// isArchived reports whether the archive holds a snapshot of path.
+func (s *Store) isArchived(path string) bool {
+	if s.archive == nil {
+		return false
+	}
+	var n int
+	s.db.QueryRow("SELECT COUNT(*) FROM archived_files WHERE path = ?", path).Scan(&n)
+	return n > 0
+}
+
+// OpenArchived returns the archived content of a session file. It has the
+// signature claude.SetArchive expects.
+func (s *Store) OpenArchived(path string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.archive == nil {
+		return nil, fmt.Errorf("archive mode is off")
+	}
+	var hash string
+	err := s.db.QueryRow("SELECT hash FROM archived_files WHERE path = ?", path).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s is not archived", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.archive.Open(hash)
+}
+
+// ArchiveStatus summarizes what the archive holds.
+type ArchiveStatus struct {
+	Dir            string
+	Sessions       int   // archived session files
+	SourcesMissing int   // archived files no longer on disk
+	OriginalBytes  int64 // uncompressed size of the archived files
+	StoredBytes    int64 // compressed size on disk, including orphans
+	OrphanBlobs    int   // blobs no archived file refers to
+	OrphanBytes    int64
+	Budget         int64 // 0 = unlimited
+}
+
+// ArchiveStatus reports archive usage. It walks the archive directory, so it
+// is meant for `clog archive status`, not for every refresh.
+func (s *Store) ArchiveStatus() (ArchiveStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.archive == nil {
+		return ArchiveStatus{}, fmt.Errorf("archive mode is off")
+	}
+	st := ArchiveStatus{Dir: s.archive.Dir(), Budget: s.archiveBudget}
+
+	rows, err := s.db.Query("SELECT path, hash, size FROM archived_files")
+	if err != nil {
+		return st, err
+	}
+	used := make(map[string]bool)
+	for rows.Next() {
+		var path, hash string
+		var size int64
+		if err := rows.Scan(&path, &hash, &size); err != nil {
+			continue
+		}
+		st.Sessions++
+		st.OriginalBytes += size
+		used[hash] = true
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			st.SourcesMissing++
+		}
+	}
+	rows.Close()
+
+	blobs, err := s.archive.Blobs()
+	if err != nil {
+		return st, err
+	}
+	for _, b := range blobs {
+		st.StoredBytes += b.Size
+		if !used[b.Hash] {
+			st.OrphanBlobs++
+			st.OrphanBytes += b.Size
+		}
+	}
+	return st, nil
+}
+
+// PruneResult reports what PruneArchive removed.
+type PruneResult struct {
+	Evicted    int // archived sessions dropped to fit the budget
+	Blobs      int // blob files deleted
+	FreedBytes int64
+}
+
+// PruneArchive deletes orphaned blobs and then evicts the oldest archived
+// sessions until the archive fits its budget.
+func (s *Store) PruneArchive() (PruneResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.archive == nil {
+		return PruneResult{}, fmt.Errorf("archive mode is off")
+	}
+
+	var res PruneResult
+	used := make(map[string]bool)
+	hashes, err := queryStrings(s.db, "SELECT DISTINCT hash FROM archived_files")
+	if err != nil {
+		return res, err
+	}
+	for _, h := range hashes {
+		used[h] = true
+	}
+	blobs, err := s.archive.Blobs()
+	if err != nil {
+		return res, err
+	}
+	for _, b := range blobs {
+		if used[b.Hash] {
+			continue
+		}
+		if err := s.archive.Remove(b.Hash); err != nil {
+			return res, err
+		}
+		res.Blobs++
+		res.FreedBytes += b.Size
+	}
+
+	evicted, err := s.enforceArchiveBudget()
+	res.Evicted = evicted.Evicted
+	res.Blobs += evicted.Blobs
+	res.FreedBytes += evicted.FreedBytes
+	return res, err
+}
+
+// enforceArchiveBudget evicts archived sessions, least recently modified
+// first, until the stored size fits the budget. The caller must hold the
+// write lock.
+func (s *Store) enforceArchiveBudget() (PruneResult, error) {
+	var res PruneResult
+	if s.archive == nil || s.archiveBudget <= 0 {
+		return res, nil
+	}
+
+	var total int64
+	if err := s.db.QueryRow(`
+		SELECT COALESCE(SUM(stored_size), 0)
+		FROM (SELECT DISTINCT hash, stored_size FROM archived_files)
+	`).Scan(&total); err != nil {
+		return res, err
+	}
+	if total <= s.archiveBudget {
+		return res, nil
+	}
+
+	rows, err := s.db.Query("SELECT path, hash, stored_size FROM archived_files ORDER BY mtime ASC")
+	if err != nil {
+		return res, err
+	}
+	type entry struct {
+		path, hash string
+		stored     int64
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.path, &e.hash, &e.stored); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	for _, e := range entries {
+		if total <= s.archiveBudget {
+			break
+		}
+		if _, err := s.db.Exec("DELETE FROM archived_files WHERE path = ?", e.path); err != nil {
+			return res, err
+		}
+		res.Evicted++
+
+		var refs int
+		s.db.QueryRow("SELECT COUNT(*) FROM archived_files WHERE hash = ?", e.hash).Scan(&refs)
+		if refs > 0 {
+			continue
+		}
+		if err := s.archive.Remove(e.hash); err != nil {
+			return res, err
+		}
+		res.Blobs++
+		res.FreedBytes += e.stored
+		total -= e.stored
+	}
+	return res, nil
+}
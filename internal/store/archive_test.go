@@ -0,0 +1,52 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/archive"
+)
+
+func TestSnapshotFile_Throttled(t *testing.T) {
+	s := openTestStore(t)
+	s.EnableArchive(archive.New(t.TempDir()), 0)
+	path := filepath.Join(t.TempDir(), "sess-1.jsonl")
+	writeFile(t, path, "one\n")
+
+	archivedSize := func() int64 {
+		t.Helper()
+		if err := s.snapshotFile(path, "sess-1"); err != nil {
+			t.Fatal(err)
+		}
+		var size int64
+		s.db.QueryRow("SELECT size FROM archived_files WHERE path = ?", path).Scan(&size)
+		return size
+	}
+
+	// A new session is snapshotted right away
+	if got := archivedSize(); got != 4 {
+		t.Fatalf("first snapshot size = %d, want 4", got)
+	}
+
+	// While it is being written, a recent snapshot is kept
+	writeFile(t, path, "one\ntwo\n")
+	if got := archivedSize(); got != 4 {
+		t.Errorf("snapshot of a growing file = %d, want 4", got)
+	}
+
+	// Once the file is left alone, its final version is taken
+	old := time.Now().Add(-2 * snapshotQuiet)
+	os.Chtimes(path, old, old)
+	if got := archivedSize(); got != 8 {
+		t.Errorf("snapshot of a quiet file = %d, want 8", got)
+	}
+
+	// A file that never stops growing is still snapshotted now and then
+	writeFile(t, path, "one\ntwo\nthree\n")
+	s.db.Exec("UPDATE archived_files SET archived_at = ?", time.Now().Add(-2*snapshotInterval).UnixMilli())
+	if got := archivedSize(); got != 14 {
+		t.Errorf("snapshot after the interval = %d, want 14", got)
+	}
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"time"
+)
+
+// Delivery statuses. A delivery is logged as pending before its action runs,
+// so it counts toward the rate limit while in flight.
+const (
+	DeliveryPending     = "pending"
+	DeliveryOK          = "ok"
+	DeliveryFailed      = "failed"
+	DeliveryRateLimited = "rate_limited"
+)
+
+// Delivery is one attempt to run a watch's action for a match.
+type Delivery struct {
+	ID               int64     `json:"id"`
+	WatchItemID      int64     `json:"watch_id"`
+	WatchName        string    `json:"watch"`
+	SessionID        string    `json:"session_id"`
+	MessageTimestamp string    `json:"message_timestamp"`
+	MatchedText      string    `json:"matched_text"`
+	Action           string    `json:"action"`
+	Target           string    `json:"target"`
+	Status           string    `json:"status"`
+	Error            string    `json:"error,omitempty"`
+	AttemptedAt      time.Time `json:"attempted_at"`
+	DurationMs       int64     `json:"duration_ms"`
+}
+
+// LogDelivery records a delivery attempt for match with the given status and
+// returns its ID.
+func (s *Store) LogDelivery(match WatchMatch, action WatchAction, status string) (int64, error) {
+	res, err := s.db.Exec(`
+		INSERT INTO watchlist_deliveries
+			(watchlist_id, session_id, message_timestamp, matched_text, action, target, status, attempted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, match.WatchItemID, match.SessionID, match.Timestamp, match.MatchedText,
+		action.Kind, action.Target, status, time.Now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// FinishDelivery records the outcome of a pending delivery.
+func (s *Store) FinishDelivery(id int64, status, errMsg string, took time.Duration) error {
+	_, err := s.db.Exec("UPDATE watchlist_deliveries SET status = ?, error = ?, duration_ms = ? WHERE id = ?",
+		status, errMsg, took.Milliseconds(), id)
+	return err
+}
+
+// Delivered reports whether a delivery was already attempted for the match,
+// in any status. Matches come back with new IDs when their session is
+// re-indexed; this keeps them from being delivered again.
+func (s *Store) Delivered(match WatchMatch) bool {
+	var exists bool
+	s.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM watchlist_deliveries
+		WHERE watchlist_id = ? AND session_id = ? AND message_timestamp = ?)
+	`, match.WatchItemID, match.SessionID, match.Timestamp).Scan(&exists)
+	return exists
+}
+
+// DeliveryCount returns the number of deliveries a watch attempted since the
+// given time, not counting those dropped by its rate limit.
+func (s *Store) DeliveryCount(watchID int64, since time.Time) int {
+	var n int
+	s.db.QueryRow(`
+		SELECT COUNT(*) FROM watchlist_deliveries
+		WHERE watchlist_id = ? AND attempted_at >= ? AND status != ?
+	`, watchID, since.UnixMilli(), DeliveryRateLimited).Scan(&n)
+	return n
+}
+
+// Deliveries returns up to limit delivery attempts, newest first. A watchID
+// of 0 returns them for every watch.
+func (s *Store) Deliveries(watchID int64, limit int) ([]Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT d.id, d.watchlist_id, w.name, d.session_id, d.message_timestamp, d.matched_text,
+			d.action, d.target, d.status, d.error, d.attempted_at, d.duration_ms
+		FROM watchlist_deliveries d
+		JOIN watchlist w ON w.id = d.watchlist_id
+		WHERE ? = 0 OR d.watchlist_id = ?
+		ORDER BY d.id DESC
+		LIMIT ?
+	`, watchID, watchID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var d Delivery
+		var attempted int64
+		if err := rows.Scan(&d.ID, &d.WatchItemID, &d.WatchName, &d.SessionID, &d.MessageTimestamp,
+			&d.MatchedText, &d.Action, &d.Target, &d.Status, &d.Error, &attempted, &d.DurationMs); err != nil {
+			continue
+		}
+		d.AttemptedAt = time.UnixMilli(attempted)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+// DeliveryRetention is how long the delivery log keeps an attempt.
+const DeliveryRetention = 30 * 24 * time.Hour
+
+// pruneDeliveryLog drops entries older than DeliveryRetention, at most once
+// an hour. Index passes call it, so only the process holding the writer
+// lease writes to the log. The caller must hold the write lock.
+func (s *Store) pruneDeliveryLog() {
+	if time.Since(s.lastPrune) < time.Hour {
+		return
+	}
+	if _, err := s.PruneDeliveries(time.Now().Add(-DeliveryRetention)); err == nil {
+		s.lastPrune = time.Now()
+	}
+}
+
+// PruneDeliveries deletes delivery log entries older than before and returns
+// how many were removed.
+func (s *Store) PruneDeliveries(before time.Time) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM watchlist_deliveries WHERE attempted_at < ?", before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
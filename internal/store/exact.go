@@ -0,0 +1,182 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+// Free text is matched against one of two full-text indexes. messages_fts
+// uses the porter tokenizer, which suits prose: "deploying" finds "deploy".
+// messages_code uses the trigram tokenizer over message text and tool
+// inputs, which finds any substring of three characters or more, so
+// identifiers, flags and paths like handleKey, --reindex or internal/store
+// are found as they were written.
+
+// SearchMode selects the full-text index free text is matched against.
+type SearchMode int
+
+const (
+	SearchAuto    SearchMode = iota // exact for queries that look like code, natural otherwise
+	SearchNatural                   // words, stemmed (messages_fts)
+	SearchExact                     // case-insensitive substrings (messages_code)
+)
+
+func (m SearchMode) String() string {
+	switch m {
+	case SearchNatural:
+		return "natural"
+	case SearchExact:
+		return "exact"
+	}
+	return "auto"
+}
+
+// minExactLen is the shortest substring the trigram index can find.
+const minExactLen = 3
+
+// codeColumns maps in: values to messages_code columns. Thinking is only
+// in messages_fts.
+var codeColumns = map[string]string{
+	"text":  "text",
+	"tools": "tool_input",
+	"tool":  "tool_input",
+}
+
+// ParseMode is Parse with the full-text index chosen by mode. SearchAuto
+// picks exact matching when a term contains punctuation inside it or is
+// camelCase, unless the query searches thinking, which only the natural
+// index holds, or a term is too short to match exactly.
+func ParseMode(query string, mode SearchMode) *FilterSet {
+	fs := parse(query)
+	if fs.Err != nil || fs.Root == nil {
+		return fs
+	}
+	terms := textTerms(fs.Root)
+	thinking := false
+	for _, f := range fs.Filters {
+		if f.Field == FilterIn && inColumns[strings.ToLower(f.Value)] == "thinking" {
+			thinking = true
+		}
+	}
+
+	switch mode {
+	case SearchExact:
+		if thinking {
+			return &FilterSet{Err: errors.New("in:thinking needs natural search")}
+		}
+		for _, t := range terms {
+			if utf8.RuneCountInString(t) < minExactLen {
+				return &FilterSet{Err: fmt.Errorf("%q is too short for exact search, which needs %d characters", t, minExactLen)}
+			}
+		}
+		fs.Exact = true
+	case SearchAuto:
+		code, short := false, false
+		for _, t := range terms {
+			code = code || looksLikeCode(t)
+			short = short || utf8.RuneCountInString(t) < minExactLen
+		}
+		fs.Exact = code && !short && !thinking
+	}
+	return fs
+}
+
+// Resolve returns the mode a query is searched in: m itself, or for
+// SearchAuto the mode ParseMode picks.
+func (m SearchMode) Resolve(query string) SearchMode {
+	if m != SearchAuto {
+		return m
+	}
+	if ParseMode(query, m).Exact {
+		return SearchExact
+	}
+	return SearchNatural
+}
+
+// textTerms returns the words and phrases of every text node under n, as
+// exact search matches them.
+func textTerms(n Node) []string {
+	switch n := n.(type) {
+	case TextNode:
+		var terms []string
+		for _, alt := range strings.Split(n.Text, "|") {
+			if alt = exactTerm(alt); alt != "" {
+				terms = append(terms, alt)
+			}
+		}
+		return terms
+	case NotNode:
+		return textTerms(n.Child)
+	case AndNode, OrNode:
+		children, _ := nodeChildren(n)
+		var terms []string
+		for _, c := range children {
+			terms = append(terms, textTerms(c)...)
+		}
+		return terms
+	}
+	return nil
+}
+
+// exactTerm strips the query syntax from one alternative of a text term: a
+// phrase's quotes and a prefix search's trailing "*".
+func exactTerm(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return s[1 : len(s)-1]
+	}
+	return strings.TrimSuffix(s, "*")
+}
+
+// looksLikeCode reports whether a term is an identifier, flag or path
+// rather than a word: it has punctuation other than at the end, where prose
+// puts it, or changes from lower to upper case.
+func looksLikeCode(term string) bool {
+	term = strings.TrimRight(term, ".,;:!?")
+	prev := ' '
+	for _, r := range term {
+		if (unicode.IsPunct(r) || unicode.IsSymbol(r)) && r != '\'' && r != '’' {
+			return true
+		}
+		if unicode.IsLower(prev) && unicode.IsUpper(r) {
+			return true
+		}
+		prev = r
+	}
+	return false
+}
+
+// codeQuery turns a text term into a messages_code query: each "|"
+// alternative becomes a quoted string, which the trigram index matches as
+// a substring.
+func codeQuery(text string) string {
+	var alts []string
+	for _, alt := range strings.Split(text, "|") {
+		if alt = exactTerm(alt); alt != "" {
+			alts = append(alts, `"`+strings.ReplaceAll(alt, `"`, `""`)+`"`)
+		}
+	}
+	return strings.Join(alts, " OR ")
+}
+
+// toolInputText is what messages.tool_input holds for a message's tool
+// calls: each call's name and JSON input on a line of its own.
+func toolInputText(tools []claude.ToolCall) string {
+	var lines []string
+	for _, tc := range tools {
+		line := tc.Name
+		if len(tc.Input) > 0 {
+			if data, err := json.Marshal(tc.Input); err == nil {
+				line += " " + string(data)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -18,8 +19,43 @@ const (
 	FilterTool
 	FilterTokens
 	FilterAge
+	FilterCwd
+	FilterVersion
+	FilterIn // which part of a message free text is matched against
+	FilterSession
+	FilterAfter  // message written on or after a date
+	FilterBefore // message written before a date
+	FilterSort   // order of results; taken out of the tree into FilterSet.Sort
 )
 
+// filterNames maps query field names to fields.
+var filterNames = map[string]FilterField{
+	"model":   FilterModel,
+	"branch":  FilterBranch,
+	"project": FilterProject,
+	"type":    FilterType,
+	"tool":    FilterTool,
+	"tokens":  FilterTokens,
+	"age":     FilterAge,
+	"cwd":     FilterCwd,
+	"version": FilterVersion,
+	"in":      FilterIn,
+	"session": FilterSession,
+	"after":   FilterAfter,
+	"before":  FilterBefore,
+	"sort":    FilterSort,
+}
+
+// String returns the field's name in queries.
+func (f FilterField) String() string {
+	for name, field := range filterNames {
+		if field == f {
+			return name
+		}
+	}
+	return ""
+}
+
 type FilterOp int
 
 const (
@@ -27,6 +63,9 @@ const (
 	OpGreaterThan
 	OpLessThan
 	OpLike
+	OpAtLeast
+	OpAtMost
+	OpRange // Value is "lo..hi"; either end may be left out
 )
 
 type Filter struct {
@@ -35,9 +74,16 @@ type Filter struct {
 	Value string
 }
 
+// FilterSet is a parsed query. Root holds the whole expression; FreeText
+// and Filters are the free text and filters ANDed at its top level, which
+// is all most queries have.
 type FilterSet struct {
 	FreeText string
 	Filters  []Filter
+	Root     Node  // nil for an empty query
+	Err      error // why the query does not parse; the rest is empty
+	Exact    bool  // free text is matched as substrings in messages_code; see ParseMode
+	Sort     SortOrder
 }
 
 // Parse parses a query string into free-text search terms and structured filters.
@@ -47,23 +93,49 @@ type FilterSet struct {
 //	"model:opus branch:main" → Filters: [{FilterModel, OpEquals, "opus"}, ...]
 //	"deploy model:opus tokens:>10000" → FreeText: "deploy", Filters: [...]
 //	"age:<1h" → Filters: [{FilterAge, OpLessThan, "1h"}]
+//	"retry in:thinking" → FreeText: "retry", Filters: [{FilterIn, OpEquals, "thinking"}]
+//	"-tool:Bash (model:opus OR model:sonnet)" → Root: And(Not(tool), Or(model, model))
+//
+// A query that does not parse returns a FilterSet with only Err set. Free
+// text is matched in SearchAuto mode; see ParseMode.
 func Parse(query string) *FilterSet {
-	fs := &FilterSet{}
-	var freeWords []string
+	return ParseMode(query, SearchAuto)
+}
 
-	tokens := tokenize(query)
-	for _, tok := range tokens {
-		if f, ok := parseFilter(tok); ok {
+// parse parses a query without choosing its full-text index.
+func parse(query string) *FilterSet {
+	root, err := parseQuery(query)
+	if err != nil {
+		return &FilterSet{Err: err}
+	}
+	root, sort, err := takeSort(root)
+	if err != nil {
+		return &FilterSet{Err: err}
+	}
+	fs := &FilterSet{Root: root, Sort: sort}
+	var freeWords []string
+	for _, n := range fs.topLevel() {
+		if isText(n) {
+			freeWords = append(freeWords, n.String())
+		} else if f, ok := n.(Filter); ok {
 			fs.Filters = append(fs.Filters, f)
-		} else {
-			freeWords = append(freeWords, tok)
 		}
 	}
-
 	fs.FreeText = strings.Join(freeWords, " ")
 	return fs
 }
 
+// topLevel returns the nodes ANDed at the root of the query.
+func (fs *FilterSet) topLevel() []Node {
+	switch n := fs.Root.(type) {
+	case nil:
+		return nil
+	case AndNode:
+		return n.Children
+	}
+	return []Node{fs.Root}
+}
+
 // tokenize splits a query string respecting quoted phrases.
 func tokenize(query string) []string {
 	var tokens []string
@@ -90,67 +162,163 @@ func tokenize(query string) []string {
 	return tokens
 }
 
-// parseFilter attempts to parse a token as field:value or field:>value.
+// parseFilter attempts to parse a token as field:value, field:>value,
+// field:lo..hi or field:"quoted value".
 func parseFilter(token string) (Filter, bool) {
 	idx := strings.Index(token, ":")
 	if idx < 1 || idx == len(token)-1 {
 		return Filter{}, false
 	}
 
-	field := strings.ToLower(token[:idx])
+	field, ok := filterNames[strings.ToLower(token[:idx])]
+	if !ok {
+		return Filter{}, false
+	}
+	f := Filter{Field: field}
 	value := token[idx+1:]
 
-	var f Filter
-
-	switch field {
-	case "model":
-		f.Field = FilterModel
-	case "branch":
-		f.Field = FilterBranch
-	case "project":
-		f.Field = FilterProject
-	case "type":
-		f.Field = FilterType
-	case "tool":
-		f.Field = FilterTool
-	case "tokens":
-		f.Field = FilterTokens
-	case "age":
-		f.Field = FilterAge
-	default:
-		return Filter{}, false
+	// A quoted value is taken as it is, operators and all
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		f.Value = value[1 : len(value)-1]
+		return f, true
 	}
 
 	// Parse operator prefix
-	if strings.HasPrefix(value, ">") {
-		f.Op = OpGreaterThan
-		f.Value = value[1:]
-	} else if strings.HasPrefix(value, "<") {
-		f.Op = OpLessThan
-		f.Value = value[1:]
-	} else {
-		f.Op = OpEquals
-		f.Value = value
+	switch {
+	case strings.HasPrefix(value, ">="):
+		f.Op, f.Value = OpAtLeast, value[2:]
+	case strings.HasPrefix(value, "<="):
+		f.Op, f.Value = OpAtMost, value[2:]
+	case strings.HasPrefix(value, ">"):
+		f.Op, f.Value = OpGreaterThan, value[1:]
+	case strings.HasPrefix(value, "<"):
+		f.Op, f.Value = OpLessThan, value[1:]
+	case strings.HasPrefix(value, "="):
+		f.Op, f.Value = OpEquals, value[1:]
+	case strings.Contains(value, ".."):
+		f.Op, f.Value = OpRange, value
+	default:
+		f.Op, f.Value = OpEquals, value
 	}
 
 	return f, true
 }
 
+// validate reports a filter whose value cannot mean anything, so the query
+// fails to parse instead of quietly matching nothing or everything.
+func (f Filter) validate() error {
+	name := f.Field.String()
+	if f.Value == "" {
+		return fmt.Errorf("%s: needs a value", name)
+	}
+	if f.Op == OpRange && f.Field != FilterTokens {
+		return fmt.Errorf("%s: ranges only work with tokens:", name)
+	}
+	switch f.Field {
+	case FilterTokens:
+		if f.Op == OpRange {
+			if _, _, err := f.tokenRange(); err != nil {
+				return err
+			}
+		} else if _, err := strconv.Atoi(f.Value); err != nil {
+			return fmt.Errorf("tokens: %q is not a number", f.Value)
+		}
+	case FilterAge:
+		if _, err := parseAge(f.Value); err != nil {
+			return fmt.Errorf("age: %q is not an age like 30m, 12h, 7d or 2w", f.Value)
+		}
+	case FilterAfter, FilterBefore:
+		if f.Op != OpEquals {
+			return fmt.Errorf("%s: takes a date, without > or <", name)
+		}
+		if _, err := parseDate(f.Value); err != nil {
+			return fmt.Errorf("%s: %q is not a date like 2026-09-01", name, f.Value)
+		}
+	case FilterIn:
+		if _, ok := inColumns[strings.ToLower(f.Value)]; !ok {
+			return fmt.Errorf("in: %q is not one of text, thinking or tools", f.Value)
+		}
+	case FilterSort:
+		if _, ok := sortNames[strings.ToLower(f.Value)]; !ok || f.Op != OpEquals {
+			return fmt.Errorf("sort: %q is not one of relevance, recent or tokens", f.Value)
+		}
+	}
+	return nil
+}
+
+// tokenRange returns the inclusive bounds of a tokens:lo..hi filter, -1
+// for an end left open.
+func (f Filter) tokenRange() (lo, hi int, err error) {
+	a, b, _ := strings.Cut(f.Value, "..")
+	if a == "" && b == "" {
+		return 0, 0, fmt.Errorf("tokens: %q has no bounds", f.Value)
+	}
+	bound := func(s string) (int, error) {
+		if s == "" {
+			return -1, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("tokens: %q is not a number", s)
+		}
+		return n, nil
+	}
+	if lo, err = bound(a); err != nil {
+		return 0, 0, err
+	}
+	if hi, err = bound(b); err != nil {
+		return 0, 0, err
+	}
+	return lo, hi, nil
+}
+
+// String returns the filter as it is written in a query.
+func (f Filter) String() string {
+	op := ""
+	switch f.Op {
+	case OpGreaterThan:
+		op = ">"
+	case OpLessThan:
+		op = "<"
+	case OpAtLeast:
+		op = ">="
+	case OpAtMost:
+		op = "<="
+	}
+	value := f.Value
+	if needsQuotes(value) || (f.Op == OpEquals && strings.IndexAny(value, "<>=") == 0) ||
+		(f.Op == OpEquals && strings.Contains(value, "..")) {
+		value = `"` + value + `"`
+	}
+	return fmt.Sprintf("%s:%s%s", f.Field, op, value)
+}
+
 // ToSQL generates a SQL WHERE clause and parameters from the filter set.
-// The query joins messages (m), sessions (s), and optionally messages_fts.
+// The query joins messages (m), sessions (s), and optionally the FTS table
+// FTSTable names.
 // Returns the WHERE clause (without "WHERE") and the parameter list.
+//
+// Free text at the top level becomes the one ranked MATCH against the
+// joined FTS table; free text inside a group or negation is matched
+// with a subquery instead, which needs no join.
 func (fs *FilterSet) ToSQL() (string, []interface{}) {
 	var conditions []string
 	var params []interface{}
 
 	// FTS5 match
 	if fs.FreeText != "" {
-		conditions = append(conditions, "messages_fts MATCH ?")
-		params = append(params, ftsQuery(fs.FreeText))
+		var terms []Node
+		for _, n := range fs.topLevel() {
+			if isText(n) {
+				terms = append(terms, n)
+			}
+		}
+		conditions = append(conditions, fs.FTSTable()+" MATCH ?")
+		params = append(params, fs.ftsMatch(terms...))
 	}
 
-	for _, f := range fs.Filters {
-		cond, p := filterToSQL(f)
+	for _, n := range fs.conditions() {
+		cond, p := fs.nodeSQL(n)
 		if cond != "" {
 			conditions = append(conditions, cond)
 			params = append(params, p...)
@@ -163,6 +331,112 @@ func (fs *FilterSet) ToSQL() (string, []interface{}) {
 	return strings.Join(conditions, " AND "), params
 }
 
+// conditions returns the top-level nodes that are not part of the FreeText
+// MATCH.
+func (fs *FilterSet) conditions() []Node {
+	var nodes []Node
+	for _, n := range fs.topLevel() {
+		if isText(n) {
+			continue // in FreeText
+		}
+		if f, ok := n.(Filter); ok && f.Field == FilterIn && fs.FreeText != "" {
+			continue // folded into the MATCH
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// ftsMatch turns text nodes, ANDed, into an FTS5 query restricted to the
+// columns the query's in: filters name.
+func (fs *FilterSet) ftsMatch(terms ...Node) string {
+	parts := make([]string, len(terms))
+	for i, n := range terms {
+		parts[i] = fs.ftsNode(n)
+		if len(terms) > 1 && strings.Contains(parts[i], " OR ") {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	q := strings.Join(parts, " ")
+	if cols := fs.ftsColumns(); len(cols) > 0 {
+		q = "{" + strings.Join(cols, " ") + "} : (" + q + ")"
+	}
+	return q
+}
+
+// nodeSQL compiles one node of the query to a condition.
+func (fs *FilterSet) nodeSQL(n Node) (string, []interface{}) {
+	switch n := n.(type) {
+	case Filter:
+		return filterToSQL(n)
+	case TextNode:
+		return fmt.Sprintf("m.id IN (SELECT rowid FROM %[1]s WHERE %[1]s MATCH ?)", fs.FTSTable()),
+			[]interface{}{fs.ftsMatch(n)}
+	case NotNode:
+		cond, params := fs.nodeSQL(n.Child)
+		if cond == "" {
+			return "", nil
+		}
+		return "NOT (" + cond + ")", params
+	case AndNode, OrNode:
+		children, sep := nodeChildren(n)
+		var conds []string
+		var params []interface{}
+		for _, c := range children {
+			cond, p := fs.nodeSQL(c)
+			if cond == "" {
+				cond = "1=1"
+			}
+			conds = append(conds, cond)
+			params = append(params, p...)
+		}
+		return "(" + strings.Join(conds, " "+sep+" ") + ")", params
+	}
+	return "", nil
+}
+
+// inColumns maps in: values to messages_fts columns.
+var inColumns = map[string]string{
+	"text":     "text",
+	"thinking": "thinking",
+	"tools":    "tool_calls",
+	"tool":     "tool_calls",
+}
+
+// FTSTable returns the full-text table free text is matched against:
+// messages_code for exact queries, messages_fts otherwise.
+func (fs *FilterSet) FTSTable() string {
+	if fs.Exact {
+		return "messages_code"
+	}
+	return "messages_fts"
+}
+
+// ftsColumns returns the FTS columns named by in: filters, or nil to match
+// every column.
+func (fs *FilterSet) ftsColumns() []string {
+	columns := inColumns
+	if fs.Exact {
+		columns = codeColumns
+	}
+	var cols []string
+	for _, f := range fs.Filters {
+		if f.Field != FilterIn {
+			continue
+		}
+		if col, ok := columns[strings.ToLower(f.Value)]; ok && !slices.Contains(cols, col) {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// ThinkingOnly reports whether the query is restricted to thinking blocks.
+func (fs *FilterSet) ThinkingOnly() bool {
+	cols := fs.ftsColumns()
+	return len(cols) == 1 && cols[0] == "thinking"
+}
+
 // HasFTS returns true if this filter set includes a free-text search.
 func (fs *FilterSet) HasFTS() bool {
 	return fs.FreeText != ""
@@ -170,7 +444,7 @@ func (fs *FilterSet) HasFTS() bool {
 
 // IsEmpty returns true if there are no filters or free text.
 func (fs *FilterSet) IsEmpty() bool {
-	return fs.FreeText == "" && len(fs.Filters) == 0
+	return fs.Root == nil
 }
 
 func filterToSQL(f Filter) (string, []interface{}) {
@@ -179,12 +453,32 @@ func filterToSQL(f Filter) (string, []interface{}) {
 		return "s.model LIKE ?", []interface{}{"%" + f.Value + "%"}
 
 	case FilterBranch:
+		// Match the branch each message was written on, so sessions that
+		// switched branches are found under every branch they touched.
 		if strings.Contains(f.Value, "*") {
 			// Convert glob to SQL LIKE
 			pattern := strings.ReplaceAll(f.Value, "*", "%")
-			return "s.git_branch LIKE ?", []interface{}{pattern}
+			return "m.git_branch LIKE ?", []interface{}{pattern}
+		}
+		return "m.git_branch = ?", []interface{}{f.Value}
+
+	case FilterCwd:
+		if strings.Contains(f.Value, "*") {
+			return "m.cwd LIKE ?", []interface{}{strings.ReplaceAll(f.Value, "*", "%")}
 		}
-		return "s.git_branch = ?", []interface{}{f.Value}
+		return "m.cwd LIKE ?", []interface{}{"%" + f.Value + "%"}
+
+	case FilterVersion:
+		// version:1.0 matches 1.0 and every 1.0.x release
+		return "(m.version = ? OR m.version LIKE ?)", []interface{}{f.Value, f.Value + ".%"}
+
+	case FilterIn:
+		// Without free text, in:thinking selects messages that have any
+		col, ok := inColumns[strings.ToLower(f.Value)]
+		if !ok {
+			return "", nil
+		}
+		return "m." + col + " != ''", nil
 
 	case FilterProject:
 		return "s.project LIKE ?", []interface{}{"%" + f.Value + "%"}
@@ -196,31 +490,53 @@ func filterToSQL(f Filter) (string, []interface{}) {
 		return "m.tool_calls LIKE ?", []interface{}{"%" + f.Value + "%"}
 
 	case FilterTokens:
-		n, err := strconv.Atoi(f.Value)
+		col := tokensColumn
+		switch f.Op {
+		case OpGreaterThan, OpLessThan, OpAtLeast, OpAtMost, OpEquals:
+			n, err := strconv.Atoi(f.Value)
+			if err != nil {
+				return "", nil
+			}
+			op := map[FilterOp]string{OpGreaterThan: ">", OpLessThan: "<", OpAtLeast: ">=", OpAtMost: "<=", OpEquals: "="}[f.Op]
+			return fmt.Sprintf("%s %s ?", col, op), []interface{}{n}
+		case OpRange:
+			lo, hi, err := f.tokenRange()
+			switch {
+			case err != nil:
+				return "", nil
+			case lo < 0:
+				return col + " <= ?", []interface{}{hi}
+			case hi < 0:
+				return col + " >= ?", []interface{}{lo}
+			}
+			return col + " BETWEEN ? AND ?", []interface{}{lo, hi}
+		}
+
+	case FilterSession:
+		// Session IDs may be shortened to any unique prefix
+		return "s.session_id LIKE ?", []interface{}{f.Value + "%"}
+
+	case FilterAfter, FilterBefore:
+		t, err := parseDate(f.Value)
 		if err != nil {
 			return "", nil
 		}
-		col := "(m.input_tokens + m.output_tokens)"
-		switch f.Op {
-		case OpGreaterThan:
-			return fmt.Sprintf("%s > ?", col), []interface{}{n}
-		case OpLessThan:
-			return fmt.Sprintf("%s < ?", col), []interface{}{n}
-		default:
-			return fmt.Sprintf("%s = ?", col), []interface{}{n}
+		if f.Field == FilterAfter {
+			return "m.timestamp >= ?", []interface{}{sqlTime(t)}
 		}
+		return "m.timestamp < ?", []interface{}{sqlTime(t)}
 
 	case FilterAge:
 		dur, err := parseAge(f.Value)
 		if err != nil {
 			return "", nil
 		}
-		cutoff := time.Now().Add(-dur).Format(time.RFC3339)
+		cutoff := sqlTime(time.Now().Add(-dur))
 		switch f.Op {
-		case OpLessThan:
+		case OpLessThan, OpAtMost:
 			// age:<1h means modified within the last hour
 			return "s.modified_at > ?", []interface{}{cutoff}
-		case OpGreaterThan:
+		case OpGreaterThan, OpAtLeast:
 			// age:>1h means modified more than 1 hour ago
 			return "s.modified_at < ?", []interface{}{cutoff}
 		default:
@@ -231,6 +547,67 @@ func filterToSQL(f Filter) (string, []interface{}) {
 	return "", nil
 }
 
+// Matches reports whether a message-level string field satisfies the
+// filter, with the same semantics as the SQL for branch, cwd and version.
+func (f Filter) Matches(value string) bool {
+	switch f.Field {
+	case FilterBranch:
+		if strings.Contains(f.Value, "*") {
+			return globMatch(f.Value, value)
+		}
+		return value == f.Value
+	case FilterCwd:
+		if strings.Contains(f.Value, "*") {
+			return globMatch(f.Value, value)
+		}
+		return strings.Contains(strings.ToLower(value), strings.ToLower(f.Value))
+	case FilterVersion:
+		return value == f.Value || strings.HasPrefix(value, f.Value+".")
+	}
+	return true
+}
+
+// globMatch matches s against a pattern where * stands for any run of
+// characters, like the LIKE patterns built from it. Case-insensitive, as
+// LIKE is for ASCII.
+func globMatch(pattern, s string) bool {
+	parts := strings.Split(strings.ToLower(pattern), "*")
+	s = strings.ToLower(s)
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := len(parts) - 1
+	for _, p := range parts[1:last] {
+		i := strings.Index(s, p)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(p):]
+	}
+	return strings.HasSuffix(s, parts[last])
+}
+
+// sqlTime formats t to compare with the RFC 3339 UTC timestamps Claude Code
+// writes. The milliseconds keep the comparison right whether or not a
+// timestamp has them: "…05Z" sorts after "…05.000Z", as it should.
+func sqlTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// dateLayouts are the forms after: and before: accept, in local time.
+var dateLayouts = []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339}
+
+// parseDate parses a date for after: and before:.
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", s)
+}
+
 // parseAge parses a duration like "1h", "30m", "7d", "2w".
 func parseAge(s string) (time.Duration, error) {
 	if len(s) < 2 {
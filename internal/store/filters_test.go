@@ -204,6 +204,31 @@ func TestToSQL_FreeTextOnly(t *testing.T) {
 	}
 }
 
+func TestToSQL_InFilter(t *testing.T) {
+	where, params := Parse("retry in:thinking").ToSQL()
+	if where != "messages_fts MATCH ?" {
+		t.Errorf("where = %q", where)
+	}
+	if len(params) != 1 || params[0] != "{thinking} : (retry)" {
+		t.Errorf("params = %v", params)
+	}
+
+	_, params = Parse("error|panic in:text in:tools").ToSQL()
+	if len(params) != 1 || params[0] != "{text tool_calls} : (error OR panic)" {
+		t.Errorf("params = %v", params)
+	}
+
+	// Without free text, in: selects messages that have that part
+	where, params = Parse("in:thinking").ToSQL()
+	if where != "m.thinking != ''" || len(params) != 0 {
+		t.Errorf("where = %q, params = %v", where, params)
+	}
+
+	if !Parse("x in:thinking").ThinkingOnly() || Parse("x in:thinking in:text").ThinkingOnly() {
+		t.Error("ThinkingOnly mismatch")
+	}
+}
+
 func TestToSQL_ModelFilter(t *testing.T) {
 	fs := Parse("model:opus")
 	where, params := fs.ToSQL()
@@ -218,7 +243,7 @@ func TestToSQL_ModelFilter(t *testing.T) {
 func TestToSQL_BranchExact(t *testing.T) {
 	fs := Parse("branch:main")
 	where, params := fs.ToSQL()
-	if where != "s.git_branch = ?" {
+	if where != "m.git_branch = ?" {
 		t.Errorf("where = %q", where)
 	}
 	if len(params) != 1 || params[0] != "main" {
@@ -229,7 +254,7 @@ func TestToSQL_BranchExact(t *testing.T) {
 func TestToSQL_BranchGlob(t *testing.T) {
 	fs := Parse("branch:feature*")
 	where, params := fs.ToSQL()
-	if where != "s.git_branch LIKE ?" {
+	if where != "m.git_branch LIKE ?" {
 		t.Errorf("where = %q", where)
 	}
 	if len(params) != 1 || params[0] != "feature%" {
@@ -237,10 +262,59 @@ func TestToSQL_BranchGlob(t *testing.T) {
 	}
 }
 
+func TestToSQL_CwdFilter(t *testing.T) {
+	fs := Parse("cwd:chronicle")
+	where, params := fs.ToSQL()
+	if where != "m.cwd LIKE ?" {
+		t.Errorf("where = %q", where)
+	}
+	if len(params) != 1 || params[0] != "%chronicle%" {
+		t.Errorf("params = %v", params)
+	}
+}
+
+func TestToSQL_VersionFilter(t *testing.T) {
+	fs := Parse("version:1.0")
+	where, params := fs.ToSQL()
+	if where != "(m.version = ? OR m.version LIKE ?)" {
+		t.Errorf("where = %q", where)
+	}
+	if len(params) != 2 || params[0] != "1.0" || params[1] != "1.0.%" {
+		t.Errorf("params = %v", params)
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	tests := []struct {
+		query string
+		value string
+		want  bool
+	}{
+		{"branch:main", "main", true},
+		{"branch:main", "maintenance", false},
+		{"branch:feature*", "feature/login", true},
+		{"branch:*fix*", "hotfix/x", true},
+		{"branch:feature*", "main", false},
+		{"cwd:chronicle", "/src/Claude-Chronicle/web", true},
+		{"cwd:/src/*/web", "/src/app/web", true},
+		{"cwd:/src/*/web", "/src/app/api", false},
+		{"version:1.0", "1.0.80", true},
+		{"version:1.0", "1.0", true},
+		{"version:1.0", "1.01", false},
+		{"version:1.0.80", "1.0.80", true},
+	}
+	for _, tt := range tests {
+		f := Parse(tt.query).Filters[0]
+		if got := f.Matches(tt.value); got != tt.want {
+			t.Errorf("%s matches %q = %v, want %v", tt.query, tt.value, got, tt.want)
+		}
+	}
+}
+
 func TestToSQL_TokensGreater(t *testing.T) {
 	fs := Parse("tokens:>10000")
 	where, params := fs.ToSQL()
-	if where != "(m.input_tokens + m.output_tokens) > ?" {
+	if where != "(m.input_tokens + m.cache_read_tokens + m.cache_write_tokens + m.output_tokens) > ?" {
 		t.Errorf("where = %q", where)
 	}
 	if len(params) != 1 || params[0] != 10000 {
@@ -1,9 +1,17 @@
 package store
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -24,7 +32,8 @@ var skipTypes = map[claude.MessageType]bool{
 	"file-history-snapshot": true,
 }
 
-// collectJSONLFiles returns all .jsonl file paths in dir and its immediate subdirs.
+// collectJSONLFiles returns all .jsonl file paths in dir and its immediate
+// subdirs, plus the subagent transcripts in <session-id>/subagents/.
 func collectJSONLFiles(dir string) []string {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
@@ -41,6 +50,14 @@ func collectJSONLFiles(dir string) []string {
 				if !se.IsDir() && strings.HasSuffix(se.Name(), ".jsonl") {
 					paths = append(paths, filepath.Join(dir, e.Name(), se.Name()))
 				}
+				if se.IsDir() && se.Name() == "subagents" {
+					agents, _ := os.ReadDir(filepath.Join(dir, e.Name(), se.Name()))
+					for _, ae := range agents {
+						if !ae.IsDir() && strings.HasSuffix(ae.Name(), ".jsonl") {
+							paths = append(paths, filepath.Join(dir, e.Name(), se.Name(), ae.Name()))
+						}
+					}
+				}
 			}
 		}
 	}
@@ -49,9 +66,16 @@ func collectJSONLFiles(dir string) []string {
 
 // IndexAll indexes every JSONL file across all projects.
 // Sends progress updates on the channel. Closes the channel when done.
+// Returns ErrReadOnly when another process holds the writer lease.
 func (s *Store) IndexAll(progress chan<- IndexProgress, projectPaths []string) error {
 	defer close(progress)
 
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.requireWriter(); err != nil {
+		return err
+	}
+
 	progress <- IndexProgress{Phase: "discovering"}
 
 	projects, err := claude.DiscoverProjects(projectPaths)
@@ -74,8 +98,10 @@ func (s *Store) IndexAll(progress chan<- IndexProgress, projectPaths []string) e
 
 	progress <- IndexProgress{Phase: "indexing", Total: len(files)}
 
+	present := make(map[string]bool, len(files))
 	var allMsgIDs []int64
 	for i, f := range files {
+		present[f.path] = true
 		progress <- IndexProgress{
 			Phase:   "indexing",
 			Current: i + 1,
@@ -88,6 +114,9 @@ func (s *Store) IndexAll(progress chan<- IndexProgress, projectPaths []string) e
 			continue
 		}
 		allMsgIDs = append(allMsgIDs, msgIDs...)
+		s.mu.Lock()
+		s.snapshotFile(f.path, sessionIDFor(f.path))
+		s.mu.Unlock()
 	}
 
 	// Run watchlist matching on all newly indexed messages
@@ -95,13 +124,40 @@ func (s *Store) IndexAll(progress chan<- IndexProgress, projectPaths []string) e
 		s.MatchNewMessages(allMsgIDs)
 	}
 
+	s.mu.Lock()
+	_, err = s.reconcile(projectPaths, present)
+	s.enforceArchiveBudget()
+	s.pruneDeliveryLog()
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("reconcile: %w", err)
+	}
+
 	progress <- IndexProgress{Phase: "done", Current: len(files), Total: len(files)}
 	return nil
 }
 
-// IndexChanged re-indexes only files whose mtime or size changed.
-// Returns the number of new/updated files.
+// IndexChanged indexes only files whose mtime or size changed. Files that
+// grew are indexed incrementally from the last indexed byte offset; files
+// that shrank or were rewritten are re-indexed in full. Sessions whose file
+// has vanished are purged or archived (see SetKeepMissing).
+// Returns the number of new, updated and removed files, or ErrReadOnly when
+// another process holds the writer lease.
 func (s *Store) IndexChanged(projectPaths []string) (int, error) {
+	return s.IndexChangedContext(context.Background(), projectPaths)
+}
+
+// IndexChangedContext is IndexChanged, stopping between files once ctx is
+// done. A pass cut short keeps what it indexed but does not reconcile, so
+// sessions it never reached are not taken for vanished; it returns
+// ctx.Err().
+func (s *Store) IndexChangedContext(ctx context.Context, projectPaths []string) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.requireWriter(); err != nil {
+		return 0, err
+	}
+
 	s.mu.Lock()
 
 	projects, err := claude.DiscoverProjects(projectPaths)
@@ -112,29 +168,20 @@ func (s *Store) IndexChanged(projectPaths []string) (int, error) {
 
 	changed := 0
 	var newMsgIDs []int64
+	present := make(map[string]bool)
 
+scan:
 	for _, proj := range projects {
 		for _, path := range collectJSONLFiles(proj.DataDir) {
-			info, err := os.Stat(path)
-			if err != nil {
-				continue
+			if ctx.Err() != nil {
+				break scan
 			}
-
-			mtime := info.ModTime().UnixMilli()
-			size := info.Size()
-
-			// Check if this file is already indexed with same mtime+size
-			var existingMtime, existingSize int64
-			err = s.db.QueryRow(
-				"SELECT mtime, size FROM files WHERE path = ?", path,
-			).Scan(&existingMtime, &existingSize)
-
-			if err == nil && existingMtime == mtime && existingSize == size {
-				continue // unchanged
-			}
-
-			msgIDs, err := s.indexFile(path, proj.Name)
-			if err != nil {
+			present[path] = true
+			msgIDs, updated, err := s.refreshFile(path, proj.Name)
+			// Snapshot even unchanged files, so turning archive mode on
+			// covers sessions indexed before it.
+			s.snapshotFile(path, sessionIDFor(path))
+			if err != nil || !updated {
 				continue
 			}
 			newMsgIDs = append(newMsgIDs, msgIDs...)
@@ -142,6 +189,18 @@ func (s *Store) IndexChanged(projectPaths []string) (int, error) {
 		}
 	}
 
+	rerr := ctx.Err()
+	if rerr == nil {
+		var res ReconcileResult
+		res, rerr = s.reconcile(projectPaths, present)
+		if rerr != nil {
+			rerr = fmt.Errorf("reconcile: %w", rerr)
+		}
+		changed += res.Total()
+		s.enforceArchiveBudget()
+		s.pruneDeliveryLog()
+	}
+
 	s.mu.Unlock()
 
 	// Run watchlist matching outside the write lock to avoid deadlock
@@ -149,92 +208,131 @@ func (s *Store) IndexChanged(projectPaths []string) (int, error) {
 		s.MatchNewMessages(newMsgIDs)
 	}
 
-	return changed, nil
+	return changed, rerr
 }
 
-// indexFile re-indexes a single JSONL file, returning the IDs of all inserted messages.
-func (s *Store) indexFile(path, project string) ([]int64, error) {
+// sessionIDFor derives a session ID from its JSONL file name.
+func sessionIDFor(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".jsonl")
+}
+
+// prefixHashLen is how much of a file's head is hashed to detect rewrites.
+const prefixHashLen = 4096
+
+// indexedFile is the bookkeeping stored for each indexed JSONL file.
+type indexedFile struct {
+	id         int64
+	mtime      int64
+	size       int64
+	offset     int64
+	lineCount  int
+	prefixHash string
+}
+
+// refreshFile brings a single file's index up to date. It returns the IDs of
+// newly inserted messages and whether the file needed any work.
+func (s *Store) refreshFile(path, project string) ([]int64, bool, error) {
 	info, err := os.Stat(path)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-
-	sessionID := strings.TrimSuffix(filepath.Base(path), ".jsonl")
 	mtime := info.ModTime().UnixMilli()
 	size := info.Size()
-	now := time.Now().UnixMilli()
 
-	// Load messages using existing parser
-	messages, err := claude.LoadMessages(path)
-	if err != nil {
-		return nil, fmt.Errorf("parse %s: %w", path, err)
+	var f indexedFile
+	err = s.db.QueryRow(`
+		SELECT id, mtime, size, indexed_offset, line_count, prefix_hash
+		FROM files WHERE path = ?
+	`, path).Scan(&f.id, &f.mtime, &f.size, &f.offset, &f.lineCount, &f.prefixHash)
+	if err == nil && f.mtime == mtime && f.size == size {
+		return nil, false, nil // unchanged
 	}
 
-	tx, err := s.db.Begin()
-	if err != nil {
-		return nil, err
+	// Append only when the file grew and the part we already indexed is
+	// untouched; anything else (shrink, rewrite, never indexed) is a full pass.
+	if err == nil && f.offset > 0 && size >= f.offset {
+		if hash, herr := prefixHash(path, f.offset); herr == nil && hash == f.prefixHash {
+			ids, err := s.appendFile(path, f, mtime, size)
+			return ids, err == nil, err
+		}
 	}
-	defer tx.Rollback()
 
-	// Clean up old data for this file
-	var oldFileID int64
-	err = tx.QueryRow("SELECT id FROM files WHERE path = ?", path).Scan(&oldFileID)
-	if err == nil {
-		// Delete old messages (cascade will handle watchlist_matches)
-		tx.Exec("DELETE FROM messages WHERE session_id = ?", sessionID)
-		tx.Exec("DELETE FROM sessions WHERE session_id = ?", sessionID)
-		tx.Exec("DELETE FROM files WHERE id = ?", oldFileID)
-	}
+	ids, err := s.indexFile(path, project)
+	return ids, err == nil, err
+}
 
-	// Insert file record
-	res, err := tx.Exec(
-		"INSERT INTO files (path, project, session_id, mtime, size, indexed_at) VALUES (?, ?, ?, ?, ?, ?)",
-		path, project, sessionID, mtime, size, now,
-	)
+// prefixHash hashes the first min(limit, prefixHashLen) bytes of a file.
+func prefixHash(path string, limit int64) (string, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	fileID, _ := res.LastInsertId()
+	defer f.Close()
 
-	// Insert messages, aggregate session stats
-	var (
-		firstPrompt string
-		gitBranch   string
-		model       string
-		createdAt   string
-		modifiedAt  string
-		totalIn     int
-		totalOut    int
-		toolCount   int
-		msgCount    int
-		msgIDs      []int64
-	)
+	n := min(limit, prefixHashLen)
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, n); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sessionStats aggregates per-session metadata while messages are inserted.
+type sessionStats struct {
+	firstPrompt string
+	gitBranch   string   // latest branch
+	branches    []string // every branch, in order first seen
+	cwd         string   // latest working directory
+	version     string   // latest Claude Code version
+	userType    string
+	entrypoint  string
+	model       string
+	createdAt   string
+	modifiedAt  string
+	usage       claude.Usage
+	toolCount   int
+	msgCount    int
+}
+
+// insertMessages writes messages and their tool calls for a session,
+// returning the inserted message IDs and aggregate stats.
+func insertMessages(tx *sql.Tx, sessionID string, messages []claude.Message) ([]int64, sessionStats, error) {
+	var stats sessionStats
+	var msgIDs []int64
 
 	msgStmt, err := tx.Prepare(`
-		INSERT INTO messages (session_id, type, timestamp, model, text, tool_calls, input_tokens, output_tokens)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO messages (session_id, type, timestamp, model, text, tool_calls,
+			input_tokens, output_tokens, cache_read_tokens, cache_write_tokens,
+			git_branch, cwd, version, user_type, entrypoint, thinking,
+			uuid, parent_uuid, is_sidechain, response_id, tool_input)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
-		return nil, err
+		return nil, stats, err
 	}
 	defer msgStmt.Close()
 
+	toolStmt, err := tx.Prepare(insertToolCallSQL)
+	if err != nil {
+		return nil, stats, err
+	}
+	defer toolStmt.Close()
+
 	for _, msg := range messages {
 		if skipTypes[msg.Type] {
 			continue
 		}
 
-		// Truncate very long texts for FTS (keep full text in original JSONL)
-		text := msg.Text
-		if len(text) > 50000 {
-			text = text[:50000]
-		}
-
+		text, thinking := clipForIndex(msg.Text), clipForIndex(msg.Thinking)
 		tools := strings.Join(msg.ToolCalls, ", ")
 
 		res, err := msgStmt.Exec(
 			sessionID, string(msg.Type), msg.Timestamp, msg.Model,
 			text, tools, msg.InputTokens, msg.OutputTokens,
+			msg.CacheReadTokens, msg.CacheWriteTokens,
+			msg.GitBranch, msg.Cwd, msg.Version, msg.UserType, msg.Entrypoint, thinking,
+			msg.UUID, msg.ParentUUID, msg.IsSidechain, msg.ResponseID,
+			clipForIndex(toolInputText(msg.Tools)),
 		)
 		if err != nil {
 			continue
@@ -242,46 +340,428 @@ func (s *Store) indexFile(path, project string) ([]int64, error) {
 
 		if id, err := res.LastInsertId(); err == nil {
 			msgIDs = append(msgIDs, id)
+			insertToolCalls(toolStmt, id, sessionID, msg.Timestamp, msg.Tools)
 		}
 
 		// Aggregate session stats
-		msgCount++
-		totalIn += msg.InputTokens
-		totalOut += msg.OutputTokens
-		toolCount += len(msg.ToolCalls)
+		stats.msgCount++
+		stats.usage.Add(msg.Usage())
+		stats.toolCount += len(msg.ToolCalls)
 
 		if msg.Model != "" {
-			model = claude.FormatModel(msg.Model)
+			stats.model = claude.FormatModel(msg.Model)
 		}
 		if msg.Timestamp != "" {
-			if createdAt == "" {
-				createdAt = msg.Timestamp
+			if stats.createdAt == "" {
+				stats.createdAt = msg.Timestamp
 			}
-			modifiedAt = msg.Timestamp
+			stats.modifiedAt = msg.Timestamp
 		}
-		if firstPrompt == "" && msg.Type == claude.TypeUser && msg.Text != "" {
-			firstPrompt = msg.Text
-			if len(firstPrompt) > 120 {
-				firstPrompt = firstPrompt[:120]
+		if stats.firstPrompt == "" && msg.Type == claude.TypeUser && msg.Text != "" {
+			stats.firstPrompt = msg.Text
+			if len(stats.firstPrompt) > 120 {
+				stats.firstPrompt = stats.firstPrompt[:120]
 			}
 		}
-		if gitBranch == "" && msg.Type == claude.TypeSystem {
-			// Git branch is often in system messages — the claude package
-			// handles this in extractSessionMeta but we check the raw text here
+		stats.addEnvironment(msg)
+	}
+
+	return msgIDs, stats, nil
+}
+
+// clipForIndex truncates very long texts for FTS; the full text stays in
+// the original JSONL.
+func clipForIndex(s string) string {
+	if len(s) > 50000 {
+		return s[:50000]
+	}
+	return s
+}
+
+const insertToolCallSQL = `
+	INSERT INTO tool_calls (message_id, session_id, tool_use_id, name, input,
+		result, is_error, answered, duration_ms, timestamp)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// insertToolCalls records the tool invocations of message id.
+func insertToolCalls(stmt *sql.Stmt, id int64, sessionID, timestamp string, tools []claude.ToolCall) {
+	for _, tc := range tools {
+		input := ""
+		if len(tc.Input) > 0 {
+			if data, err := json.Marshal(tc.Input); err == nil {
+				input = string(data)
+			}
 		}
+		stmt.Exec(id, sessionID, tc.ID, tc.Name, input,
+			tc.Result, tc.IsError, tc.Answered, tc.Duration.Milliseconds(), timestamp)
+	}
+}
+
+// mergeIndexedResponses folds messages that continue a streamed response an
+// earlier pass already indexed into that response's row, as the parser
+// would have had it read the whole response at once. It returns the
+// messages left to insert, with parents that named a merged line pointing
+// at the row instead, the ids of the rows it updated, and how much their
+// token usage and tool count grew.
+func mergeIndexedResponses(tx *sql.Tx, sessionID string, messages []claude.Message) ([]claude.Message, []int64, sessionStats, error) {
+	var grew sessionStats
+	var updated []int64
+	renamed := make(map[string]string)
+	rest := messages[:0:0]
+
+	for _, msg := range messages {
+		if msg.Type != claude.TypeAssistant || msg.ResponseID == "" {
+			rest = append(rest, msg)
+			continue
+		}
+		var id int64
+		var uuid, timestamp, tools, toolInput string
+		prev := claude.Message{Type: claude.TypeAssistant}
+		err := tx.QueryRow(`
+			SELECT id, uuid, timestamp, model, text, tool_calls, thinking,
+				input_tokens, output_tokens, cache_read_tokens, cache_write_tokens, tool_input
+			FROM messages WHERE session_id = ? AND response_id = ?
+			ORDER BY id LIMIT 1
+		`, sessionID, msg.ResponseID).Scan(&id, &uuid, &timestamp, &prev.Model, &prev.Text, &tools, &prev.Thinking,
+			&prev.InputTokens, &prev.OutputTokens, &prev.CacheReadTokens, &prev.CacheWriteTokens, &toolInput)
+		if errors.Is(err, sql.ErrNoRows) {
+			rest = append(rest, msg)
+			continue
+		}
+		if err != nil {
+			return nil, nil, grew, fmt.Errorf("find response %s: %w", msg.ResponseID, err)
+		}
+		if tools != "" {
+			prev.ToolCalls = strings.Split(tools, ", ")
+		}
+		before := prev.Usage()
+		prev.Merge(msg)
+		if more := toolInputText(msg.Tools); more != "" && toolInput != "" {
+			toolInput += "\n" + more
+		} else if more != "" {
+			toolInput = more
+		}
+
+		_, err = tx.Exec(`
+			UPDATE messages SET model = ?, text = ?, tool_calls = ?, thinking = ?,
+				input_tokens = ?, output_tokens = ?, cache_read_tokens = ?, cache_write_tokens = ?,
+				tool_input = ?
+			WHERE id = ?
+		`, prev.Model, clipForIndex(prev.Text), strings.Join(prev.ToolCalls, ", "), clipForIndex(prev.Thinking),
+			prev.InputTokens, prev.OutputTokens, prev.CacheReadTokens, prev.CacheWriteTokens,
+			clipForIndex(toolInput), id)
+		if err != nil {
+			return nil, nil, grew, fmt.Errorf("merge response %s: %w", msg.ResponseID, err)
+		}
+		if len(msg.Tools) > 0 {
+			stmt, err := tx.Prepare(insertToolCallSQL)
+			if err != nil {
+				return nil, nil, grew, err
+			}
+			insertToolCalls(stmt, id, sessionID, timestamp, msg.Tools)
+			stmt.Close()
+		}
+
+		after := prev.Usage()
+		grew.usage.Add(claude.Usage{
+			InputTokens:      after.InputTokens - before.InputTokens,
+			OutputTokens:     after.OutputTokens - before.OutputTokens,
+			CacheReadTokens:  after.CacheReadTokens - before.CacheReadTokens,
+			CacheWriteTokens: after.CacheWriteTokens - before.CacheWriteTokens,
+		})
+		grew.toolCount += len(msg.ToolCalls)
+		if !slices.Contains(updated, id) {
+			updated = append(updated, id)
+		}
+		if msg.UUID != "" {
+			renamed[msg.UUID] = uuid
+		}
+	}
+
+	for i := range rest {
+		if to, ok := renamed[rest[i].ParentUUID]; ok {
+			rest[i].ParentUUID = to
+		}
+	}
+	return rest, updated, grew, nil
+}
+
+// addEnvironment folds a message's recorded environment into the stats.
+// Branch, cwd and version track the latest value; user type and entrypoint
+// keep the first.
+func (st *sessionStats) addEnvironment(msg claude.Message) {
+	if msg.GitBranch != "" {
+		st.gitBranch = msg.GitBranch
+		if !slices.Contains(st.branches, msg.GitBranch) {
+			st.branches = append(st.branches, msg.GitBranch)
+		}
+	}
+	if msg.Cwd != "" {
+		st.cwd = msg.Cwd
+	}
+	if msg.Version != "" {
+		st.version = msg.Version
+	}
+	if st.userType == "" {
+		st.userType = msg.UserType
+	}
+	if st.entrypoint == "" {
+		st.entrypoint = msg.Entrypoint
+	}
+}
+
+// splitBranches parses the sessions.branches column.
+func splitBranches(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// answerToolCalls fills in tool calls from earlier appends whose results
+// arrived in this batch of messages.
+func answerToolCalls(tx *sql.Tx, sessionID string, messages []claude.Message) {
+	for _, msg := range messages {
+		for _, r := range msg.ToolResults {
+			if r.ToolUseID == "" {
+				continue
+			}
+			var id int64
+			var callTS string
+			err := tx.QueryRow(`
+				SELECT id, timestamp FROM tool_calls
+				WHERE session_id = ? AND tool_use_id = ? AND answered = 0
+			`, sessionID, r.ToolUseID).Scan(&id, &callTS)
+			if err != nil {
+				continue
+			}
+			tx.Exec(`
+				UPDATE tool_calls SET result = ?, is_error = ?, answered = 1, duration_ms = ?
+				WHERE id = ?
+			`, r.Text, r.IsError, claude.Elapsed(callTS, msg.Timestamp).Milliseconds(), id)
+		}
+	}
+}
+
+// updateForks rebuilds a session's conversation tree from its indexed
+// messages and records which messages are on abandoned branches. An appended
+// message can start a new branch, so this runs after every index pass.
+func updateForks(tx *sql.Tx, sessionID string) error {
+	rows, err := tx.Query(`
+		SELECT id, uuid, parent_uuid, is_sidechain, abandoned
+		FROM messages WHERE session_id = ? ORDER BY id
+	`, sessionID)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	var nodes []claude.TreeNode
+	var was []bool
+	for rows.Next() {
+		var id int64
+		var n claude.TreeNode
+		var abandoned bool
+		if err := rows.Scan(&id, &n.UUID, &n.ParentUUID, &n.Sidechain, &abandoned); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+		nodes = append(nodes, n)
+		was = append(was, abandoned)
+	}
+	rows.Close()
+
+	tree := claude.NewTree(nodes)
+	for i, abandoned := range tree.Abandoned() {
+		if abandoned != was[i] {
+			if _, err := tx.Exec("UPDATE messages SET abandoned = ? WHERE id = ?", abandoned, ids[i]); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = tx.Exec("UPDATE sessions SET fork_count = ? WHERE session_id = ?", tree.Forks(), sessionID)
+	return err
+}
+
+// appendFile indexes only the lines added to a file since its last index,
+// returning the IDs of the inserted messages.
+func (s *Store) appendFile(path string, f indexedFile, mtime, size int64) ([]int64, error) {
+	sessionID := sessionIDFor(path)
+
+	messages, end, lines, err := claude.LoadMessagesFrom(path, f.offset)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Seed the branch list so a switch back to an earlier branch isn't
+	// recorded twice.
+	var branches string
+	tx.QueryRow("SELECT branches FROM sessions WHERE session_id = ?", sessionID).Scan(&branches)
+	known := splitBranches(branches)
+
+	// A response Claude Code was still streaming last pass continues here
+	messages, merged, grew, err := mergeIndexedResponses(tx, sessionID, messages)
+	if err != nil {
+		return nil, err
+	}
+	msgIDs, stats, err := insertMessages(tx, sessionID, messages)
+	if err != nil {
+		return nil, err
+	}
+	stats.usage.Add(grew.usage)
+	stats.toolCount += grew.toolCount
+	msgIDs = append(merged, msgIDs...)
+	answerToolCalls(tx, sessionID, messages)
+	for _, b := range stats.branches {
+		if !slices.Contains(known, b) {
+			known = append(known, b)
+		}
+	}
+
+	res, err := tx.Exec(`
+		UPDATE sessions SET
+			first_prompt = CASE WHEN first_prompt = '' THEN ? ELSE first_prompt END,
+			model = CASE WHEN ? != '' THEN ? ELSE model END,
+			created_at = CASE WHEN created_at = '' THEN ? ELSE created_at END,
+			modified_at = CASE WHEN ? != '' THEN ? ELSE modified_at END,
+			git_branch = CASE WHEN ? != '' THEN ? ELSE git_branch END,
+			branches = ?,
+			cwd = CASE WHEN ? != '' THEN ? ELSE cwd END,
+			version = CASE WHEN ? != '' THEN ? ELSE version END,
+			user_type = CASE WHEN user_type = '' THEN ? ELSE user_type END,
+			entrypoint = CASE WHEN entrypoint = '' THEN ? ELSE entrypoint END,
+			message_count = message_count + ?,
+			total_input_tokens = total_input_tokens + ?,
+			total_output_tokens = total_output_tokens + ?,
+			total_cache_read_tokens = total_cache_read_tokens + ?,
+			total_cache_write_tokens = total_cache_write_tokens + ?,
+			tool_count = tool_count + ?
+		WHERE session_id = ? AND file_id = ?
+	`, stats.firstPrompt, stats.model, stats.model, stats.createdAt,
+		stats.modifiedAt, stats.modifiedAt,
+		stats.gitBranch, stats.gitBranch, strings.Join(known, "\n"),
+		stats.cwd, stats.cwd, stats.version, stats.version,
+		stats.userType, stats.entrypoint, stats.msgCount,
+		stats.usage.InputTokens, stats.usage.OutputTokens,
+		stats.usage.CacheReadTokens, stats.usage.CacheWriteTokens,
+		stats.toolCount, sessionID, f.id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("session %s not indexed", sessionID)
+	}
+	if err := updateForks(tx, sessionID); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE files SET mtime = ?, size = ?, indexed_offset = ?, line_count = ?, indexed_at = ?
+		WHERE id = ?
+	`, mtime, size, end, f.lineCount+lines, time.Now().UnixMilli(), f.id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return msgIDs, nil
+}
+
+// indexFile re-indexes a single JSONL file, returning the IDs of all inserted messages.
+func (s *Store) indexFile(path, project string) ([]int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID := sessionIDFor(path)
+	mtime := info.ModTime().UnixMilli()
+	size := info.Size()
+	now := time.Now().UnixMilli()
+
+	// Load messages using existing parser, remembering where we stopped so
+	// later changes can be appended.
+	messages, end, lines, err := claude.LoadMessagesFrom(path, 0)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	hash, err := prefixHash(path, end)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Clean up old data for this file
+	var oldFileID int64
+	err = tx.QueryRow("SELECT id FROM files WHERE path = ?", path).Scan(&oldFileID)
+	if err == nil {
+		if err := stashSeenMatches(tx, "wm.session_id = ?", sessionID); err != nil {
+			return nil, err
+		}
+		// Delete old messages (cascade will handle watchlist_matches)
+		tx.Exec("DELETE FROM messages WHERE session_id = ?", sessionID)
+		tx.Exec("DELETE FROM sessions WHERE session_id = ?", sessionID)
+		tx.Exec("DELETE FROM files WHERE id = ?", oldFileID)
+	}
+
+	// Insert file record
+	res, err := tx.Exec(`
+		INSERT INTO files (path, project, session_id, mtime, size, indexed_at,
+			indexed_offset, line_count, prefix_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, path, project, sessionID, mtime, size, now, end, lines, hash)
+	if err != nil {
+		return nil, err
+	}
+	fileID, _ := res.LastInsertId()
+
+	// Insert messages, aggregate session stats
+	msgIDs, stats, err := insertMessages(tx, sessionID, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	// Subagent transcripts are sessions of their own, attributed to the
+	// session that spawned them
+	var parentID string
+	if claude.IsSubagentFile(path) {
+		parentID = claude.ParentSessionID(path)
 	}
 
 	// Insert session metadata
 	_, err = tx.Exec(`
 		INSERT INTO sessions (session_id, file_id, project, first_prompt, git_branch, model,
-			created_at, modified_at, message_count, total_input_tokens, total_output_tokens, tool_count)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, sessionID, fileID, project, firstPrompt, gitBranch, model,
-		createdAt, modifiedAt, msgCount, totalIn, totalOut, toolCount,
+			created_at, modified_at, message_count, total_input_tokens, total_output_tokens,
+			total_cache_read_tokens, total_cache_write_tokens, tool_count,
+			branches, cwd, version, user_type, entrypoint, parent_session_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, sessionID, fileID, project, stats.firstPrompt, stats.gitBranch, stats.model,
+		stats.createdAt, stats.modifiedAt, stats.msgCount,
+		stats.usage.InputTokens, stats.usage.OutputTokens,
+		stats.usage.CacheReadTokens, stats.usage.CacheWriteTokens, stats.toolCount,
+		strings.Join(stats.branches, "\n"), stats.cwd, stats.version, stats.userType, stats.entrypoint,
+		parentID,
 	)
 	if err != nil {
 		return nil, err
 	}
+	if err := updateForks(tx, sessionID); err != nil {
+		return nil, err
+	}
 
 	if err := tx.Commit(); err != nil {
 		return nil, err
@@ -303,6 +783,14 @@ func (s *Store) MessageCount() int {
 	return count
 }
 
+// LastMessageID returns the highest indexed message ID. IDs only grow, so
+// messages indexed later have higher ones.
+func (s *Store) LastMessageID() int64 {
+	var id int64
+	s.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM messages").Scan(&id)
+	return id
+}
+
 // LastIndexedAt returns the most recent indexed_at timestamp (unix millis),
 // or 0 if no files are indexed.
 func (s *Store) LastIndexedAt() int64 {
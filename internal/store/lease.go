@@ -0,0 +1,212 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Only one clog process writes the index at a time. The writer holds a
+// lease row in the database and renews it while it runs; every other
+// process reads the index without indexing. A lease left by a process that
+// crashed expires after LeaseTTL and the next process to index takes over.
+
+// LeaseTTL is how long the writer lease lasts without renewal, and so how
+// long a writer that crashed keeps other processes read-only.
+const LeaseTTL = 30 * time.Second
+
+// leaseRenewInterval fits several renewals into one LeaseTTL, so one lost to
+// a busy database does not cost the lease.
+const leaseRenewInterval = 5 * time.Second
+
+// ErrReadOnly is returned by IndexAll, IndexChanged and Reset when another
+// clog process holds the writer lease.
+var ErrReadOnly = errors.New("index is read-only: another clog process is writing it")
+
+// LeaseHolder identifies the process holding the writer lease.
+type LeaseHolder struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Kind      string    `json:"kind"` // what the process is: "dashboard", "daemon", ...
+	Since     time.Time `json:"since"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// String describes the holder as "daemon pid 123", adding the host when it
+// is not this machine.
+func (h LeaseHolder) String() string {
+	s := fmt.Sprintf("%s pid %d", h.Kind, h.PID)
+	if host, _ := os.Hostname(); h.Host != host {
+		s += " on " + h.Host
+	}
+	return s
+}
+
+// lease is this Store's side of the writer lease.
+type lease struct {
+	mu    sync.Mutex
+	token string    // identifies this Store in the lease row
+	kind  string    // see SetLeaseKind
+	until time.Time // when the lease lapses unless renewed; zero when not held
+	stop  chan struct{}
+}
+
+func newLeaseToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// SetLeaseKind names what this process is ("dashboard", "daemon", "serve",
+// ...) in the writer lease, for other processes to show.
+func (s *Store) SetLeaseKind(kind string) {
+	s.lease.mu.Lock()
+	s.lease.kind = kind
+	s.lease.mu.Unlock()
+}
+
+// TryAcquireWriter takes the writer lease if it is free, expired or already
+// held by this Store, and reports whether this Store holds it. A held lease
+// is renewed in the background until ReleaseWriter or Close.
+func (s *Store) TryAcquireWriter() (bool, error) {
+	s.lease.mu.Lock()
+	defer s.lease.mu.Unlock()
+	ok, err := s.claimLease()
+	if err != nil || !ok {
+		return ok, err
+	}
+	if s.lease.stop == nil {
+		s.lease.stop = make(chan struct{})
+		go s.renewLease(s.lease.stop)
+	}
+	return true, nil
+}
+
+// IsWriter reports whether this Store holds the writer lease.
+func (s *Store) IsWriter() bool {
+	s.lease.mu.Lock()
+	defer s.lease.mu.Unlock()
+	return time.Now().Before(s.lease.until)
+}
+
+// ReleaseWriter gives up the writer lease once any index pass in progress
+// has finished, so another process can take over at once instead of
+// waiting for the lease to expire.
+func (s *Store) ReleaseWriter() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.releaseLease()
+}
+
+// WriterLease returns the current holder of the writer lease, or nil when
+// the lease is free or has expired.
+func (s *Store) WriterLease() (*LeaseHolder, error) {
+	var h LeaseHolder
+	var since, expires int64
+	err := s.db.QueryRow(`
+		SELECT pid, host, kind, acquired_at, expires_at
+		FROM writer_lease WHERE id = 1 AND expires_at >= ?
+	`, time.Now().UnixMilli()).Scan(&h.PID, &h.Host, &h.Kind, &since, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read writer lease: %w", err)
+	}
+	h.Since = time.UnixMilli(since)
+	h.ExpiresAt = time.UnixMilli(expires)
+	return &h, nil
+}
+
+// requireWriter takes the writer lease for an index pass, or returns
+// ErrReadOnly naming the process that holds it.
+func (s *Store) requireWriter() error {
+	ok, err := s.TryAcquireWriter()
+	if err != nil && !s.IsWriter() {
+		return err
+	}
+	if err == nil && !ok {
+		if h, _ := s.WriterLease(); h != nil {
+			return fmt.Errorf("%w (%s)", ErrReadOnly, h)
+		}
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// claimLease writes the lease row when it is free, expired or ours, and
+// extends it by LeaseTTL. An error leaves the lease as it was, so a busy
+// database only costs the lease once it has actually expired. Callers hold
+// lease.mu.
+func (s *Store) claimLease() (bool, error) {
+	now := time.Now()
+	host, _ := os.Hostname()
+	res, err := s.db.Exec(`
+		INSERT INTO writer_lease (id, token, pid, host, kind, acquired_at, expires_at)
+		VALUES (1, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			token = excluded.token,
+			pid = excluded.pid,
+			host = excluded.host,
+			kind = excluded.kind,
+			acquired_at = CASE WHEN writer_lease.token = excluded.token
+				THEN writer_lease.acquired_at ELSE excluded.acquired_at END,
+			expires_at = excluded.expires_at
+		WHERE writer_lease.token = excluded.token OR writer_lease.expires_at < excluded.acquired_at
+	`, s.lease.token, os.Getpid(), host, s.lease.kind, now.UnixMilli(), now.Add(LeaseTTL).UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("claim writer lease: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		s.lease.until = time.Time{}
+		return false, err
+	}
+	s.lease.until = now.Add(LeaseTTL)
+	return true, nil
+}
+
+// renewLease extends the lease every leaseRenewInterval until stop is
+// closed or another process has taken the lease over.
+func (s *Store) renewLease(stop chan struct{}) {
+	t := time.NewTicker(leaseRenewInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		s.lease.mu.Lock()
+		ok, err := s.claimLease()
+		if err == nil && !ok && s.lease.stop == stop {
+			s.lease.stop = nil
+		}
+		s.lease.mu.Unlock()
+		if err == nil && !ok {
+			return
+		}
+	}
+}
+
+// releaseLease stops renewal and deletes the lease row if it is ours.
+func (s *Store) releaseLease() error {
+	s.lease.mu.Lock()
+	defer s.lease.mu.Unlock()
+	if s.lease.stop != nil {
+		close(s.lease.stop)
+		s.lease.stop = nil
+	}
+	if s.lease.until.IsZero() {
+		return nil
+	}
+	s.lease.until = time.Time{}
+	if _, err := s.db.Exec("DELETE FROM writer_lease WHERE token = ?", s.lease.token); err != nil {
+		return fmt.Errorf("release writer lease: %w", err)
+	}
+	return nil
+}
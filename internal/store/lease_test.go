@@ -0,0 +1,152 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openPair opens two Stores on one database, standing in for two clog
+// processes.
+func openPair(t *testing.T) (*Store, *Store) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	a, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+	b, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return a, b
+}
+
+func TestWriterLease(t *testing.T) {
+	a, b := openPair(t)
+	a.SetLeaseKind("dashboard")
+	b.SetLeaseKind("daemon")
+
+	if h, err := a.WriterLease(); err != nil || h != nil {
+		t.Fatalf("WriterLease before acquire = %v, %v", h, err)
+	}
+	if _, err := a.IndexChanged(nil); err != nil {
+		t.Fatalf("first indexer: %v", err)
+	}
+	if !a.IsWriter() {
+		t.Fatal("indexing did not take the lease")
+	}
+
+	if ok, err := b.TryAcquireWriter(); ok || err != nil {
+		t.Errorf("second TryAcquireWriter = %v, %v; want false", ok, err)
+	}
+	if _, err := b.IndexChanged(nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("reader IndexChanged = %v, want ErrReadOnly", err)
+	}
+	if err := b.Reset(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("reader Reset = %v, want ErrReadOnly", err)
+	}
+	progress := make(chan IndexProgress, 10)
+	if err := b.IndexAll(progress, nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("reader IndexAll = %v, want ErrReadOnly", err)
+	}
+
+	h, err := b.WriterLease()
+	if err != nil || h == nil {
+		t.Fatalf("WriterLease = %v, %v", h, err)
+	}
+	if h.PID != os.Getpid() || h.Kind != "dashboard" {
+		t.Errorf("holder = %+v", h)
+	}
+	if got := h.String(); got != fmt.Sprintf("dashboard pid %d", os.Getpid()) {
+		t.Errorf("String = %q", got)
+	}
+
+	// Holding the lease again is a renewal
+	if ok, _ := a.TryAcquireWriter(); !ok {
+		t.Error("holder could not renew")
+	}
+
+	if err := a.ReleaseWriter(); err != nil {
+		t.Fatal(err)
+	}
+	if a.IsWriter() {
+		t.Error("IsWriter after release")
+	}
+	if ok, err := b.TryAcquireWriter(); !ok || err != nil {
+		t.Fatalf("TryAcquireWriter after release = %v, %v", ok, err)
+	}
+	if h, _ := a.WriterLease(); h == nil || h.Kind != "daemon" {
+		t.Errorf("holder after takeover = %+v", h)
+	}
+
+	// Close hands the lease back too
+	b.Close()
+	if ok, _ := a.TryAcquireWriter(); !ok {
+		t.Error("lease not released by Close")
+	}
+}
+
+func TestWriterLease_Expires(t *testing.T) {
+	a, b := openPair(t)
+	if ok, _ := a.TryAcquireWriter(); !ok {
+		t.Fatal("TryAcquireWriter failed")
+	}
+
+	// a stops renewing, as if it crashed or was suspended
+	past := time.Now().Add(-time.Second).UnixMilli()
+	if _, err := a.db.Exec("UPDATE writer_lease SET expires_at = ?", past); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := b.WriterLease(); h != nil {
+		t.Errorf("expired lease reported as held by %+v", h)
+	}
+	if ok, _ := b.TryAcquireWriter(); !ok {
+		t.Fatal("expired lease not taken over")
+	}
+
+	// a finds out on its next renewal
+	a.lease.mu.Lock()
+	ok, err := a.claimLease()
+	a.lease.mu.Unlock()
+	if ok || err != nil {
+		t.Errorf("renewal after takeover = %v, %v; want false", ok, err)
+	}
+	if a.IsWriter() {
+		t.Error("old holder still thinks it is the writer")
+	}
+	if !b.IsWriter() {
+		t.Error("new holder is not the writer")
+	}
+}
+
+func TestWriterLease_PrunesDeliveryLog(t *testing.T) {
+	a, b := openPair(t)
+	if ok, _ := a.TryAcquireWriter(); !ok {
+		t.Fatal("TryAcquireWriter failed")
+	}
+	item, err := a.AddWatch("deploy", "deploy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.LogDelivery(WatchMatch{WatchItemID: item.ID, SessionID: "s"}, WatchAction{Kind: ActionCommand, Target: "true"}, DeliveryOK)
+	old := time.Now().Add(-DeliveryRetention - time.Hour).UnixMilli()
+	a.db.Exec("UPDATE watchlist_deliveries SET attempted_at = ?", old)
+
+	// Only the writer prunes the log
+	b.IndexChanged(nil)
+	if log, _ := b.Deliveries(0, 10); len(log) != 1 {
+		t.Errorf("reader pruned the log: %d left", len(log))
+	}
+	if _, err := a.IndexChanged(nil); err != nil {
+		t.Fatal(err)
+	}
+	if log, _ := a.Deliveries(0, 10); len(log) != 0 {
+		t.Errorf("writer left %d expired deliveries", len(log))
+	}
+}
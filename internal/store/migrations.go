@@ -0,0 +1,496 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+// migration upgrades the schema from version-1 to version. Migrations are
+// append-only: once released, a migration's SQL must never change, because
+// existing databases have already applied it.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations is the ordered schema history, keyed by PRAGMA user_version.
+var migrations = []migration{
+	{1, "initial schema", execSQL(schemaV1)},
+	{2, "tool_calls", func(tx *sql.Tx) error {
+		if err := execSQL(schemaV2)(tx); err != nil {
+			return err
+		}
+		// Backfill tool invocations for files indexed before v2
+		_, err := tx.Exec("UPDATE files SET mtime = 0")
+		return err
+	}},
+	{3, "incremental file offsets", execSQL(schemaV3)},
+	{4, "watchlist seen state", execSQL(schemaV4)},
+	{5, "separate cache token counters", func(tx *sql.Tx) error {
+		if err := execSQL(schemaV5)(tx); err != nil {
+			return err
+		}
+		// input_tokens used to include cache reads and writes
+		return markFilesStale(tx)
+	}},
+	{6, "per-message environment", func(tx *sql.Tx) error {
+		if err := execSQL(schemaV6)(tx); err != nil {
+			return err
+		}
+		// sessions.git_branch was never populated before v6
+		return markFilesStale(tx)
+	}},
+	{7, "missing source files", execSQL(schemaV7)},
+	{8, "transcript archive", execSQL(schemaV8)},
+	{9, "thinking blocks", func(tx *sql.Tx) error {
+		if err := execSQL(schemaV9)(tx); err != nil {
+			return err
+		}
+		// Thinking was dropped at parse time before v9
+		return markFilesStale(tx)
+	}},
+	{10, "conversation tree", func(tx *sql.Tx) error {
+		if err := execSQL(schemaV10)(tx); err != nil {
+			return err
+		}
+		return markFilesStale(tx)
+	}},
+	{11, "subagent sessions", func(tx *sql.Tx) error {
+		if err := execSQL(schemaV11)(tx); err != nil {
+			return err
+		}
+		return linkSubagentSessions(tx)
+	}},
+	{12, "watchlist actions", execSQL(schemaV12)},
+	{13, "writer lease", execSQL(schemaV13)},
+	{14, "merged streamed responses", func(tx *sql.Tx) error {
+		if err := execSQL(schemaV14)(tx); err != nil {
+			return err
+		}
+		// Each line of a streamed response used to be its own message,
+		// counting the response's tokens once per line
+		return markFilesStale(tx)
+	}},
+	{15, "exact search index", func(tx *sql.Tx) error {
+		if err := execSQL(schemaV15)(tx); err != nil {
+			return err
+		}
+		// tool_input is filled in as files are re-indexed
+		return markFilesStale(tx)
+	}},
+}
+
+// schemaVersion is the user_version of a fully migrated database.
+func schemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate applies every migration newer than the database's user_version.
+// Each migration runs in its own transaction together with the version bump,
+// so a failure leaves the database at the last good version. Tables holding
+// user-authored data (watchlist, watchlist_matches, saved_filters) are only
+// ever altered in place, never dropped.
+func (s *Store) migrate() error {
+	return migrateTo(s.db, schemaVersion())
+}
+
+// migrateTo upgrades db to the target version.
+func migrateTo(db *sql.DB, target int) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	if version > schemaVersion() {
+		return fmt.Errorf("index schema v%d is newer than this clog supports (v%d)", version, schemaVersion())
+	}
+
+	for _, m := range migrations {
+		if m.version <= version || m.version > target {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	// PRAGMA does not accept bound parameters
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", m.version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func execSQL(stmts string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(stmts)
+		return err
+	}
+}
+
+// linkSubagentSessions sets parent_session_id on sessions indexed from
+// subagent transcripts, which are recognised by their path alone.
+func linkSubagentSessions(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT s.session_id, f.path FROM sessions s JOIN files f ON f.id = s.file_id")
+	if err != nil {
+		return err
+	}
+	parents := make(map[string]string)
+	for rows.Next() {
+		var id, path string
+		if err := rows.Scan(&id, &path); err != nil {
+			continue
+		}
+		if claude.IsSubagentFile(path) {
+			parents[id] = claude.ParentSessionID(path)
+		}
+	}
+	rows.Close()
+
+	for id, parent := range parents {
+		if _, err := tx.Exec("UPDATE sessions SET parent_session_id = ? WHERE session_id = ?", parent, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markFilesStale forces the next IndexChanged to fully re-index every file.
+// Migrations that add derived columns use it to backfill existing rows.
+func markFilesStale(tx *sql.Tx) error {
+	_, err := tx.Exec("UPDATE files SET mtime = 0, indexed_offset = 0")
+	return err
+}
+
+const schemaV1 = `
+CREATE TABLE IF NOT EXISTS files (
+    id          INTEGER PRIMARY KEY,
+    path        TEXT    UNIQUE NOT NULL,
+    project     TEXT    NOT NULL,
+    session_id  TEXT    NOT NULL,
+    mtime       INTEGER NOT NULL,
+    size        INTEGER NOT NULL,
+    indexed_at  INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id           INTEGER PRIMARY KEY,
+    session_id   TEXT    UNIQUE NOT NULL,
+    file_id      INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    project      TEXT    NOT NULL,
+    first_prompt TEXT    DEFAULT '',
+    git_branch   TEXT    DEFAULT '',
+    model        TEXT    DEFAULT '',
+    created_at   TEXT    DEFAULT '',
+    modified_at  TEXT    DEFAULT '',
+    message_count       INTEGER DEFAULT 0,
+    total_input_tokens  INTEGER DEFAULT 0,
+    total_output_tokens INTEGER DEFAULT 0,
+    tool_count          INTEGER DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_project ON sessions(project);
+CREATE INDEX IF NOT EXISTS idx_sessions_branch ON sessions(git_branch);
+CREATE INDEX IF NOT EXISTS idx_sessions_model ON sessions(model);
+CREATE INDEX IF NOT EXISTS idx_sessions_modified ON sessions(modified_at);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id            INTEGER PRIMARY KEY,
+    session_id    TEXT    NOT NULL,
+    type          TEXT    NOT NULL,
+    timestamp     TEXT    NOT NULL,
+    model         TEXT    DEFAULT '',
+    text          TEXT    DEFAULT '',
+    tool_calls    TEXT    DEFAULT '',
+    input_tokens  INTEGER DEFAULT 0,
+    output_tokens INTEGER DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_session ON messages(session_id);
+CREATE INDEX IF NOT EXISTS idx_messages_type ON messages(type);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+    text, tool_calls,
+    content=messages, content_rowid=id,
+    tokenize='porter unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS messages_ai AFTER INSERT ON messages BEGIN
+    INSERT INTO messages_fts(rowid, text, tool_calls) VALUES (new.id, new.text, new.tool_calls);
+END;
+
+CREATE TRIGGER IF NOT EXISTS messages_ad AFTER DELETE ON messages BEGIN
+    INSERT INTO messages_fts(messages_fts, rowid, text, tool_calls) VALUES ('delete', old.id, old.text, old.tool_calls);
+END;
+
+CREATE TABLE IF NOT EXISTS watchlist (
+    id         INTEGER PRIMARY KEY,
+    name       TEXT    NOT NULL,
+    pattern    TEXT    NOT NULL,
+    enabled    BOOLEAN DEFAULT 1,
+    color      TEXT    DEFAULT '#b56a6a',
+    created_at TEXT    NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS watchlist_matches (
+    id           INTEGER PRIMARY KEY,
+    watchlist_id INTEGER NOT NULL REFERENCES watchlist(id) ON DELETE CASCADE,
+    message_id   INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    session_id   TEXT    NOT NULL,
+    matched_text TEXT    DEFAULT '',
+    seen         BOOLEAN DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_wm_watchlist ON watchlist_matches(watchlist_id);
+CREATE INDEX IF NOT EXISTS idx_wm_session ON watchlist_matches(session_id);
+
+CREATE TABLE IF NOT EXISTS saved_filters (
+    id          INTEGER PRIMARY KEY,
+    name        TEXT    NOT NULL,
+    filter_json TEXT    NOT NULL,
+    created_at  TEXT    NOT NULL
+);
+`
+
+const schemaV2 = `
+CREATE TABLE IF NOT EXISTS tool_calls (
+    id          INTEGER PRIMARY KEY,
+    message_id  INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    session_id  TEXT    NOT NULL,
+    tool_use_id TEXT    DEFAULT '',
+    name        TEXT    NOT NULL,
+    input       TEXT    DEFAULT '',
+    result      TEXT    DEFAULT '',
+    is_error    BOOLEAN DEFAULT 0,
+    answered    BOOLEAN DEFAULT 0,
+    duration_ms INTEGER DEFAULT 0,
+    timestamp   TEXT    DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_tool_calls_session ON tool_calls(session_id);
+CREATE INDEX IF NOT EXISTS idx_tool_calls_message ON tool_calls(message_id);
+CREATE INDEX IF NOT EXISTS idx_tool_calls_name ON tool_calls(name);
+`
+
+// v3 tracks how far each file has been indexed. The zero default makes the
+// next index of every existing file a full pass.
+const schemaV3 = `
+ALTER TABLE files ADD COLUMN indexed_offset INTEGER DEFAULT 0;
+ALTER TABLE files ADD COLUMN line_count     INTEGER DEFAULT 0;
+ALTER TABLE files ADD COLUMN prefix_hash    TEXT    DEFAULT '';
+`
+
+// v4 keeps watchlist seen state keyed by message identity rather than row id,
+// so it outlives the message rows when a file is re-indexed.
+const schemaV4 = `
+CREATE TABLE IF NOT EXISTS watchlist_seen (
+    watchlist_id INTEGER NOT NULL REFERENCES watchlist(id) ON DELETE CASCADE,
+    session_id   TEXT    NOT NULL,
+    timestamp    TEXT    NOT NULL,
+    type         TEXT    NOT NULL,
+    PRIMARY KEY (watchlist_id, session_id, timestamp, type)
+);
+`
+
+const schemaV5 = `
+ALTER TABLE messages ADD COLUMN cache_read_tokens  INTEGER DEFAULT 0;
+ALTER TABLE messages ADD COLUMN cache_write_tokens INTEGER DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN total_cache_read_tokens  INTEGER DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN total_cache_write_tokens INTEGER DEFAULT 0;
+`
+
+// v6 records the environment each line was written in. sessions.git_branch
+// holds the latest branch; branches lists every branch the session was on,
+// newline-separated, in the order they were first seen.
+const schemaV6 = `
+ALTER TABLE messages ADD COLUMN git_branch TEXT DEFAULT '';
+ALTER TABLE messages ADD COLUMN cwd        TEXT DEFAULT '';
+ALTER TABLE messages ADD COLUMN version    TEXT DEFAULT '';
+ALTER TABLE messages ADD COLUMN user_type  TEXT DEFAULT '';
+ALTER TABLE messages ADD COLUMN entrypoint TEXT DEFAULT '';
+ALTER TABLE sessions ADD COLUMN branches   TEXT DEFAULT '';
+ALTER TABLE sessions ADD COLUMN cwd        TEXT DEFAULT '';
+ALTER TABLE sessions ADD COLUMN version    TEXT DEFAULT '';
+ALTER TABLE sessions ADD COLUMN user_type  TEXT DEFAULT '';
+ALTER TABLE sessions ADD COLUMN entrypoint TEXT DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_messages_branch ON messages(git_branch);
+`
+
+// v7 marks files that disappeared from disk but whose sessions are kept.
+// missing_since is unix millis; 0 means the file exists.
+const schemaV7 = `
+ALTER TABLE files ADD COLUMN missing_since INTEGER DEFAULT 0;
+`
+
+// v8 maps session files to their latest snapshot in the transcript archive.
+// It is not derived from the JSONL files, so Reset keeps it.
+const schemaV8 = `
+CREATE TABLE IF NOT EXISTS archived_files (
+    path        TEXT    PRIMARY KEY,
+    session_id  TEXT    NOT NULL,
+    hash        TEXT    NOT NULL,
+    size        INTEGER NOT NULL,
+    mtime       INTEGER NOT NULL,
+    stored_size INTEGER NOT NULL,
+    archived_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_archived_files_hash ON archived_files(hash);
+`
+
+// v9 indexes extended-thinking text in its own FTS column so it can be
+// searched separately with in:thinking. FTS5 tables cannot gain columns, so
+// messages_fts is recreated and rebuilt from messages.
+const schemaV9 = `
+ALTER TABLE messages ADD COLUMN thinking TEXT DEFAULT '';
+
+DROP TRIGGER IF EXISTS messages_ai;
+DROP TRIGGER IF EXISTS messages_ad;
+DROP TABLE IF EXISTS messages_fts;
+
+CREATE VIRTUAL TABLE messages_fts USING fts5(
+    text, tool_calls, thinking,
+    content=messages, content_rowid=id,
+    tokenize='porter unicode61'
+);
+
+CREATE TRIGGER messages_ai AFTER INSERT ON messages BEGIN
+    INSERT INTO messages_fts(rowid, text, tool_calls, thinking)
+    VALUES (new.id, new.text, new.tool_calls, new.thinking);
+END;
+
+CREATE TRIGGER messages_ad AFTER DELETE ON messages BEGIN
+    INSERT INTO messages_fts(messages_fts, rowid, text, tool_calls, thinking)
+    VALUES ('delete', old.id, old.text, old.tool_calls, old.thinking);
+END;
+
+INSERT INTO messages_fts(messages_fts) VALUES ('rebuild');
+`
+
+// v10 records the parentUuid tree. parent_uuid is resolved through lines clog
+// does not index. abandoned marks messages on branches the conversation left
+// when a prompt was edited or rewound; fork_count is the number of places a
+// session branched.
+const schemaV10 = `
+ALTER TABLE messages ADD COLUMN uuid         TEXT    DEFAULT '';
+ALTER TABLE messages ADD COLUMN parent_uuid  TEXT    DEFAULT '';
+ALTER TABLE messages ADD COLUMN is_sidechain BOOLEAN DEFAULT 0;
+ALTER TABLE messages ADD COLUMN abandoned    BOOLEAN DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN fork_count   INTEGER DEFAULT 0;
+`
+
+// v11 links subagent transcripts to the session whose Task call spawned
+// them. Searches and usage totals attribute a subagent's messages to its
+// parent, and the session list leaves subagents out.
+const schemaV11 = `
+ALTER TABLE sessions ADD COLUMN parent_session_id TEXT DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_sessions_parent ON sessions(parent_session_id);
+`
+
+// v12 attaches an action to each watch, run when new messages match it, and
+// logs every delivery attempt. The log is keyed by message identity rather
+// than match id, so re-indexing a session does not deliver its matches twice.
+// attempted_at is unix millis.
+const schemaV12 = `
+ALTER TABLE watchlist ADD COLUMN action_kind   TEXT    DEFAULT '';
+ALTER TABLE watchlist ADD COLUMN action_target TEXT    DEFAULT '';
+ALTER TABLE watchlist ADD COLUMN action_limit  INTEGER DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS watchlist_deliveries (
+    id                INTEGER PRIMARY KEY,
+    watchlist_id      INTEGER NOT NULL REFERENCES watchlist(id) ON DELETE CASCADE,
+    session_id        TEXT    NOT NULL,
+    message_timestamp TEXT    NOT NULL,
+    matched_text      TEXT    DEFAULT '',
+    action            TEXT    NOT NULL,
+    target            TEXT    NOT NULL,
+    status            TEXT    NOT NULL,
+    error             TEXT    DEFAULT '',
+    attempted_at      INTEGER NOT NULL,
+    duration_ms       INTEGER DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_deliveries_watch ON watchlist_deliveries(watchlist_id, attempted_at);
+CREATE INDEX IF NOT EXISTS idx_deliveries_message ON watchlist_deliveries(watchlist_id, session_id, message_timestamp);
+`
+
+// v13 records which clog process may write the index. The single row is a
+// lease the holder renews while it runs; once expires_at (unix millis) has
+// passed, any process may take it over.
+const schemaV13 = `
+CREATE TABLE IF NOT EXISTS writer_lease (
+    id          INTEGER PRIMARY KEY CHECK (id = 1),
+    token       TEXT    NOT NULL,
+    pid         INTEGER NOT NULL,
+    host        TEXT    NOT NULL,
+    kind        TEXT    NOT NULL,
+    acquired_at INTEGER NOT NULL,
+    expires_at  INTEGER NOT NULL
+);
+`
+
+// v14 records the API response each assistant message came from, so lines
+// of a streamed response appended after the response was first indexed
+// merge into its row. Merging rewrites the row's text, so the FTS index is
+// now kept current on update as well.
+const schemaV14 = `
+ALTER TABLE messages ADD COLUMN response_id TEXT DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_messages_response ON messages(session_id, response_id);
+
+CREATE TRIGGER IF NOT EXISTS messages_au AFTER UPDATE OF text, tool_calls, thinking ON messages BEGIN
+    INSERT INTO messages_fts(messages_fts, rowid, text, tool_calls, thinking)
+    VALUES ('delete', old.id, old.text, old.tool_calls, old.thinking);
+    INSERT INTO messages_fts(rowid, text, tool_calls, thinking)
+    VALUES (new.id, new.text, new.tool_calls, new.thinking);
+END;
+`
+
+// v15 adds messages_code, a second full-text index using the trigram
+// tokenizer, for exact substring search over identifiers, flags and paths
+// that the porter tokenizer splits and stems. tool_input holds each tool
+// call's name and JSON input, which are searchable there.
+const schemaV15 = `
+ALTER TABLE messages ADD COLUMN tool_input TEXT DEFAULT '';
+
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_code USING fts5(
+    text, tool_input,
+    content=messages, content_rowid=id,
+    tokenize='trigram'
+);
+
+CREATE TRIGGER IF NOT EXISTS messages_code_ai AFTER INSERT ON messages BEGIN
+    INSERT INTO messages_code(rowid, text, tool_input)
+    VALUES (new.id, new.text, new.tool_input);
+END;
+
+CREATE TRIGGER IF NOT EXISTS messages_code_ad AFTER DELETE ON messages BEGIN
+    INSERT INTO messages_code(messages_code, rowid, text, tool_input)
+    VALUES ('delete', old.id, old.text, old.tool_input);
+END;
+
+CREATE TRIGGER IF NOT EXISTS messages_code_au AFTER UPDATE OF text, tool_input ON messages BEGIN
+    INSERT INTO messages_code(messages_code, rowid, text, tool_input)
+    VALUES ('delete', old.id, old.text, old.tool_input);
+    INSERT INTO messages_code(rowid, text, tool_input)
+    VALUES (new.id, new.text, new.tool_input);
+END;
+
+INSERT INTO messages_code(messages_code) VALUES ('rebuild');
+`
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const fixtureSession = `{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"the deploy hit a panic"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"sonnet","content":[{"type":"text","text":"Looking at the panic now."}],"usage":{"input_tokens":10,"output_tokens":5}}}
+`
+
+// buildFixtureDB creates a database at schema version v, indexed the way
+// that version would have indexed fixtureSession, with a watchlist item whose
+// first match the user has already seen and one saved filter.
+func buildFixtureDB(t *testing.T, v int) (dbPath, jsonlPath string) {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath = filepath.Join(dir, "index.db")
+	jsonlPath = filepath.Join(dir, "fixture-session.jsonl")
+	if err := os.WriteFile(jsonlPath, []byte(fixtureSession), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.Exec("PRAGMA foreign_keys=ON")
+
+	if err := migrateTo(db, v); err != nil {
+		t.Fatalf("migrate fixture to v%d: %v", v, err)
+	}
+
+	// Only columns from the v1 schema, so every version accepts the seed
+	seed := []string{
+		`INSERT INTO files (id, path, project, session_id, mtime, size, indexed_at)
+			VALUES (1, '` + jsonlPath + `', 'Fixture', 'fixture-session', 1, 1, 1)`,
+		`INSERT INTO sessions (session_id, file_id, project, first_prompt, message_count)
+			VALUES ('fixture-session', 1, 'Fixture', 'the deploy hit a panic', 2)`,
+		`INSERT INTO messages (id, session_id, type, timestamp, text)
+			VALUES (1, 'fixture-session', 'user', '2025-01-01T00:00:00Z', 'the deploy hit a panic')`,
+		`INSERT INTO messages (id, session_id, type, timestamp, text)
+			VALUES (2, 'fixture-session', 'assistant', '2025-01-01T00:00:01Z', 'Looking at the panic now.')`,
+		`INSERT INTO watchlist (id, name, pattern, enabled, color, created_at)
+			VALUES (1, 'panics', 'panic', 1, '#b56a6a', '2025-01-01T00:00:00Z')`,
+		`INSERT INTO watchlist_matches (watchlist_id, message_id, session_id, matched_text, seen)
+			VALUES (1, 1, 'fixture-session', 'panic', 1)`,
+		`INSERT INTO watchlist_matches (watchlist_id, message_id, session_id, matched_text, seen)
+			VALUES (1, 2, 'fixture-session', 'panic', 0)`,
+		`INSERT INTO saved_filters (name, filter_json, created_at)
+			VALUES ('recent opus', '{"query":"model:opus age:<7d"}', '2025-01-01T00:00:00Z')`,
+	}
+	for _, stmt := range seed {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("seed v%d: %v", v, err)
+		}
+	}
+	return dbPath, jsonlPath
+}
+
+func TestMigrate_UpgradesPreserveUserData(t *testing.T) {
+	for v := 1; v < schemaVersion(); v++ {
+		dbPath, jsonlPath := buildFixtureDB(t, v)
+
+		s, err := Open(dbPath)
+		if err != nil {
+			t.Fatalf("v%d: open: %v", v, err)
+		}
+
+		var version int
+		s.db.QueryRow("PRAGMA user_version").Scan(&version)
+		if version != schemaVersion() {
+			t.Errorf("v%d: user_version = %d, want %d", v, version, schemaVersion())
+		}
+
+		watches, _ := s.ListWatches()
+		if len(watches) != 1 || watches[0].Pattern != "panic" {
+			t.Errorf("v%d: watchlist = %+v", v, watches)
+		}
+		var filterJSON string
+		s.db.QueryRow("SELECT filter_json FROM saved_filters WHERE name = 'recent opus'").Scan(&filterJSON)
+		if filterJSON != `{"query":"model:opus age:<7d"}` {
+			t.Errorf("v%d: saved filter = %q", v, filterJSON)
+		}
+
+		// The upgraded file is stale, so the next pass re-indexes it; the
+		// seen match must come back seen and the unseen one unseen.
+		ids, updated, err := s.refreshFile(jsonlPath, "Fixture")
+		if err != nil || !updated {
+			t.Fatalf("v%d: refresh: updated=%v err=%v", v, updated, err)
+		}
+		s.MatchNewMessages(ids)
+
+		matches, _ := s.MatchesForWatch(1, 10)
+		if len(matches) != 2 {
+			t.Fatalf("v%d: matches after reindex = %d, want 2", v, len(matches))
+		}
+		for _, m := range matches {
+			wantSeen := m.Timestamp == "2025-01-01T00:00:00Z"
+			if m.Seen != wantSeen {
+				t.Errorf("v%d: match at %s seen = %v, want %v", v, m.Timestamp, m.Seen, wantSeen)
+			}
+		}
+		s.Close()
+	}
+}
+
+func TestMigrate_FreshDatabaseReachesLatest(t *testing.T) {
+	s := openTestStore(t)
+
+	var version int
+	s.db.QueryRow("PRAGMA user_version").Scan(&version)
+	if version != schemaVersion() {
+		t.Errorf("user_version = %d, want %d", version, schemaVersion())
+	}
+}
+
+func TestMigrate_VersionsAreSequential(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("migration %q has version %d, want %d", m.name, m.version, i+1)
+		}
+	}
+}
+
+func TestMigrate_RejectsNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("PRAGMA user_version = 999")
+	db.Close()
+
+	if s, err := Open(dbPath); err == nil {
+		s.Close()
+		t.Fatal("expected error opening a database from a newer clog")
+	}
+}
+
+func TestMigrate_FailedMigrationRollsBack(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := migrateTo(db, 2); err != nil {
+		t.Fatal(err)
+	}
+	// Pre-create a v3 column so the ALTER fails halfway through
+	db.Exec("ALTER TABLE files ADD COLUMN line_count INTEGER DEFAULT 0")
+
+	if err := migrateTo(db, 3); err == nil {
+		t.Fatal("expected v3 to fail")
+	}
+	var version int
+	db.QueryRow("PRAGMA user_version").Scan(&version)
+	if version != 2 {
+		t.Errorf("user_version = %d, want 2 after failed migration", version)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('files') WHERE name = 'indexed_offset'").Scan(&n)
+	if n != 0 {
+		t.Error("partial v3 changes should be rolled back")
+	}
+}
+
+func TestReset_KeepsUserData(t *testing.T) {
+	s := openTestStore(t)
+	seedTestData(t, s)
+
+	item, err := s.AddWatch("deploys", "deploy", "#b56a6a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.matchAllForWatch(item)
+	s.MarkWatchSeen(item.ID)
+	s.db.Exec("INSERT INTO saved_filters (name, filter_json, created_at) VALUES ('f', '{}', '')")
+
+	var path string
+	s.db.QueryRow("SELECT path FROM files").Scan(&path)
+
+	if err := s.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	var filters int
+	s.db.QueryRow("SELECT COUNT(*) FROM saved_filters").Scan(&filters)
+	if watches, _ := s.ListWatches(); len(watches) != 1 || filters != 1 {
+		t.Errorf("after reset: %d watches, %d saved filters", len(watches), filters)
+	}
+
+	ids, _ := s.indexFile(path, "TestProject")
+	s.MatchNewMessages(ids)
+	if n := s.TotalUnseenCount(); n != 0 {
+		t.Errorf("unseen after reset+reindex = %d, want 0", n)
+	}
+	if matches, _ := s.MatchesForWatch(item.ID, 10); len(matches) == 0 {
+		t.Error("expected matches to be rebuilt")
+	}
+
+	// FTS is rebuilt through the restored triggers
+	results, err := s.Search("deployment", 10)
+	if err != nil || len(results) == 0 {
+		t.Errorf("search after reset: %d results, err=%v", len(results), err)
+	}
+}
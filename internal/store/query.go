@@ -0,0 +1,549 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+// Queries are terms ANDed together, where a term is free text, a
+// field:value filter, a NOT, or a parenthesized group:
+//
+//	query   = or { [AND] or }
+//	or      = unary { (OR | "|") unary }
+//	unary   = ( "-" | NOT ) unary | primary
+//	primary = "(" query ")" | filter | text
+//
+// OR binds tighter than AND, so "deploy model:opus OR model:sonnet" is
+// deploy AND (opus OR sonnet). The keywords are only recognized in
+// uppercase; "or" is searched for like any other word.
+
+// Node is a node of a parsed query: AndNode, OrNode, NotNode, TextNode or
+// Filter.
+type Node interface {
+	String() string
+}
+
+// AndNode matches when every child matches.
+type AndNode struct {
+	Children []Node
+}
+
+// OrNode matches when any child matches.
+type OrNode struct {
+	Children []Node
+}
+
+// NotNode matches when its child does not.
+type NotNode struct {
+	Child Node
+}
+
+// TextNode is free text, matched against the full-text index.
+type TextNode struct {
+	Text string
+}
+
+func (n AndNode) String() string {
+	parts := make([]string, len(n.Children))
+	for i, c := range n.Children {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+func (n OrNode) String() string {
+	parts := make([]string, len(n.Children))
+	for i, c := range n.Children {
+		if _, ok := c.(AndNode); ok {
+			parts[i] = "(" + c.String() + ")"
+		} else {
+			parts[i] = c.String()
+		}
+	}
+	return strings.Join(parts, " OR ")
+}
+
+func (n NotNode) String() string {
+	switch n.Child.(type) {
+	case AndNode, OrNode:
+		return "-(" + n.Child.String() + ")"
+	}
+	return "-" + n.Child.String()
+}
+
+func (n TextNode) String() string {
+	return n.Text
+}
+
+// nodeChildren returns the children of an AndNode or OrNode and the SQL
+// operator joining them.
+func nodeChildren(n Node) ([]Node, string) {
+	switch n := n.(type) {
+	case AndNode:
+		return n.Children, "AND"
+	case OrNode:
+		return n.Children, "OR"
+	}
+	return nil, ""
+}
+
+// isText reports whether n is free text alone: a TextNode, or an OR of
+// them, which the full-text index can match in one query.
+func isText(n Node) bool {
+	switch n := n.(type) {
+	case TextNode:
+		return true
+	case OrNode:
+		for _, c := range n.Children {
+			if !isText(c) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// ftsNode turns a text node into a query for the FTS table.
+func (fs *FilterSet) ftsNode(n Node) string {
+	switch n := n.(type) {
+	case TextNode:
+		if fs.Exact {
+			return codeQuery(n.Text)
+		}
+		return ftsQuery(n.Text)
+	case OrNode:
+		parts := make([]string, len(n.Children))
+		for i, c := range n.Children {
+			parts[i] = fs.ftsNode(c)
+		}
+		return strings.Join(parts, " OR ")
+	}
+	return ""
+}
+
+// needsQuotes reports whether a filter value has to be quoted to read back
+// as one token.
+func needsQuotes(value string) bool {
+	return strings.ContainsAny(value, " \t()") || strings.HasPrefix(value, "-")
+}
+
+type queryTokenKind int
+
+const (
+	tokWord queryTokenKind = iota
+	tokAnd
+	tokOr
+	tokNot
+	tokOpen
+	tokClose
+)
+
+type queryToken struct {
+	kind queryTokenKind
+	text string
+}
+
+// lexQuery splits a query into words, keywords and parentheses. Brackets
+// and a leading "-" are peeled off words, though not the "--" of a flag; a
+// closing bracket is only taken from the end of a word while one is open,
+// so text like "main()" is still searched for as it is.
+func lexQuery(query string) []queryToken {
+	var toks []queryToken
+	depth := 0
+	for _, tok := range tokenize(query) {
+		for len(tok) > 1 && (tok[0] == '(' || tok[0] == '-' && tok[1] != '-') {
+			if tok[0] == '(' {
+				toks = append(toks, queryToken{tokOpen, "("})
+				depth++
+			} else {
+				toks = append(toks, queryToken{tokNot, "-"})
+			}
+			tok = tok[1:]
+		}
+		if tok == "(" {
+			toks = append(toks, queryToken{tokOpen, "("})
+			depth++
+			continue
+		}
+		if tok == ")" {
+			toks = append(toks, queryToken{tokClose, ")"})
+			depth--
+			continue
+		}
+		closes := 0
+		for closes < depth && len(tok) > 1 && strings.HasSuffix(tok, ")") && strings.Count(tok, `"`)%2 == 0 {
+			tok = tok[:len(tok)-1]
+			closes++
+		}
+		depth -= closes
+		switch tok {
+		case "AND":
+			toks = append(toks, queryToken{tokAnd, tok})
+		case "OR", "|":
+			toks = append(toks, queryToken{tokOr, tok})
+		case "NOT", "-":
+			toks = append(toks, queryToken{tokNot, tok})
+		default:
+			toks = append(toks, queryToken{tokWord, tok})
+		}
+		for range closes {
+			toks = append(toks, queryToken{tokClose, ")"})
+		}
+	}
+	return toks
+}
+
+// queryParser is a recursive descent parser over lexQuery's tokens.
+type queryParser struct {
+	toks []queryToken
+	pos  int
+}
+
+// parseQuery parses a query into its AST; an empty query is a nil Node.
+func parseQuery(query string) (Node, error) {
+	p := &queryParser{toks: lexQuery(query)}
+	n, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, errors.New(`unmatched ")"`)
+	}
+	return n, nil
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos], true
+	}
+	return queryToken{}, false
+}
+
+// operandNext reports whether a term starts at the current token.
+func (p *queryParser) operandNext() bool {
+	t, ok := p.peek()
+	return ok && (t.kind == tokWord || t.kind == tokNot || t.kind == tokOpen)
+}
+
+func (p *queryParser) parseAnd() (Node, error) {
+	var children []Node
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind == tokClose {
+			break
+		}
+		if t.kind == tokAnd {
+			if len(children) == 0 {
+				return nil, errors.New("AND needs a term on each side")
+			}
+			p.pos++
+			if !p.operandNext() {
+				return nil, errors.New("AND needs a term on each side")
+			}
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, n)
+	}
+	return flatten(AndNode{}, children), nil
+}
+
+func (p *queryParser) parseOr() (Node, error) {
+	if t, ok := p.peek(); ok && t.kind == tokOr {
+		return nil, fmt.Errorf("%s needs a term on each side", t.text)
+	}
+	n, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	children := []Node{n}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokOr {
+			break
+		}
+		p.pos++
+		if !p.operandNext() {
+			return nil, fmt.Errorf("%s needs a term on each side", t.text)
+		}
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, n)
+	}
+	return flatten(OrNode{}, children), nil
+}
+
+func (p *queryParser) parseUnary() (Node, error) {
+	t, _ := p.peek()
+	if t.kind != tokNot {
+		return p.parsePrimary()
+	}
+	p.pos++
+	if !p.operandNext() {
+		return nil, fmt.Errorf("%s needs a term after it", t.text)
+	}
+	n, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return NotNode{Child: n}, nil
+}
+
+func (p *queryParser) parsePrimary() (Node, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, errors.New("query ends too early")
+	}
+	p.pos++
+	switch t.kind {
+	case tokOpen:
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := p.peek(); !ok || c.kind != tokClose {
+			return nil, errors.New(`unmatched "("`)
+		}
+		p.pos++
+		if n == nil {
+			return nil, errors.New(`empty "()"`)
+		}
+		return n, nil
+	case tokWord:
+		if f, ok := parseFilter(t.text); ok {
+			if err := f.validate(); err != nil {
+				return nil, err
+			}
+			return f, nil
+		}
+		if name, value, ok := strings.Cut(t.text, ":"); ok && value == "" {
+			if _, known := filterNames[strings.ToLower(name)]; known {
+				return nil, fmt.Errorf("%s: needs a value", strings.ToLower(name))
+			}
+		}
+		return TextNode{Text: t.text}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+// flatten builds an AndNode or OrNode of children, merging children of the
+// same kind into it. A single child is returned as it is, and none as nil.
+func flatten(kind Node, children []Node) Node {
+	var flat []Node
+	for _, c := range children {
+		if sub, op := nodeChildren(c); op != "" {
+			if _, same := nodeChildren(kind); (op == "AND") == (same == "AND") {
+				flat = append(flat, sub...)
+				continue
+			}
+		}
+		flat = append(flat, c)
+	}
+	switch {
+	case len(flat) == 0:
+		return nil
+	case len(flat) == 1:
+		return flat[0]
+	}
+	if _, ok := kind.(OrNode); ok {
+		return OrNode{Children: flat}
+	}
+	return AndNode{Children: flat}
+}
+
+// Nodes returns the terms ANDed at the top of the query, for showing one
+// chip each.
+func (fs *FilterSet) Nodes() []Node {
+	return fs.topLevel()
+}
+
+// Subject is what a query is matched against in memory: a message and the
+// indexed values of the session it belongs to.
+type Subject struct {
+	Message   claude.Message
+	SessionID string
+	Project   string      // project name
+	Model     string      // the session's model, as claude.FormatModel shows it
+	Modified  string      // the session's last message timestamp
+	Text      TextMatches // see Store.TextMatches; without it free text matches nothing
+}
+
+// TextMatches records, for each free-text term of a query, the uuids of the
+// messages the full-text index matches it in.
+type TextMatches map[string]map[string]bool
+
+// Match reports whether sub satisfies the query, with the semantics of the
+// SQL ToSQL generates. Free text is looked up in sub.Text, which the
+// full-text index filled in, so words are stemmed and matched the same way
+// as in search.
+func (fs *FilterSet) Match(sub Subject) bool {
+	if fs.Err != nil {
+		return false
+	}
+	if fs.Root == nil {
+		return true
+	}
+	return fs.matchNode(fs.Root, sub)
+}
+
+func (fs *FilterSet) matchNode(n Node, sub Subject) bool {
+	switch n := n.(type) {
+	case AndNode:
+		for _, c := range n.Children {
+			if !fs.matchNode(c, sub) {
+				return false
+			}
+		}
+		return true
+	case OrNode:
+		for _, c := range n.Children {
+			if fs.matchNode(c, sub) {
+				return true
+			}
+		}
+		return false
+	case NotNode:
+		return !fs.matchNode(n.Child, sub)
+	case TextNode:
+		return sub.Text[n.Text][sub.Message.UUID]
+	case Filter:
+		if n.Field == FilterIn && fs.FreeText != "" {
+			return true // scopes the free text instead
+		}
+		return n.matchSubject(sub)
+	}
+	return true
+}
+
+// TextMatches runs each free-text term of fs against one session's
+// messages, with the full-text query nodeSQL builds for it, for Match.
+func (s *Store) TextMatches(fs *FilterSet, sessionID string) (TextMatches, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := make(TextMatches)
+	if fs.Err != nil || fs.Root == nil {
+		return matches, nil
+	}
+	for _, n := range textNodes(fs.Root) {
+		if matches[n.Text] != nil {
+			continue
+		}
+		uuids, err := queryStrings(s.db, fmt.Sprintf(`
+			SELECT m.uuid FROM messages m
+			JOIN %[1]s ON %[1]s.rowid = m.id
+			WHERE %[1]s MATCH ? AND m.session_id = ? AND m.uuid != ''`, fs.FTSTable()),
+			fs.ftsMatch(n), sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("match text: %w", err)
+		}
+		set := make(map[string]bool, len(uuids))
+		for _, u := range uuids {
+			set[u] = true
+		}
+		matches[n.Text] = set
+	}
+	return matches, nil
+}
+
+// textNodes returns the free-text terms anywhere in a query.
+func textNodes(n Node) []TextNode {
+	switch n := n.(type) {
+	case TextNode:
+		return []TextNode{n}
+	case NotNode:
+		return textNodes(n.Child)
+	}
+	children, _ := nodeChildren(n)
+	var terms []TextNode
+	for _, c := range children {
+		terms = append(terms, textNodes(c)...)
+	}
+	return terms
+}
+
+// matchSubject is filterToSQL's condition evaluated in memory.
+func (f Filter) matchSubject(sub Subject) bool {
+	msg := sub.Message
+	contains := func(s, sub string) bool {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(sub))
+	}
+	switch f.Field {
+	case FilterModel:
+		return contains(sub.Model, f.Value)
+	case FilterProject:
+		return contains(sub.Project, f.Value)
+	case FilterBranch:
+		return f.Matches(msg.GitBranch)
+	case FilterCwd:
+		return f.Matches(msg.Cwd)
+	case FilterVersion:
+		return f.Matches(msg.Version)
+	case FilterType:
+		return string(msg.Type) == f.Value
+	case FilterTool:
+		return contains(strings.Join(msg.ToolCalls, ", "), f.Value)
+	case FilterIn:
+		switch inColumns[strings.ToLower(f.Value)] {
+		case "text":
+			return msg.Text != ""
+		case "thinking":
+			return msg.Thinking != ""
+		case "tool_calls":
+			return len(msg.ToolCalls) > 0
+		}
+	case FilterTokens:
+		total := msg.Usage().Total()
+		if f.Op == OpRange {
+			lo, hi, _ := f.tokenRange()
+			return (lo < 0 || total >= lo) && (hi < 0 || total <= hi)
+		}
+		n, err := strconv.Atoi(f.Value)
+		if err != nil {
+			return true
+		}
+		switch f.Op {
+		case OpGreaterThan:
+			return total > n
+		case OpLessThan:
+			return total < n
+		case OpAtLeast:
+			return total >= n
+		case OpAtMost:
+			return total <= n
+		}
+		return total == n
+	case FilterSession:
+		return len(sub.SessionID) >= len(f.Value) && strings.EqualFold(sub.SessionID[:len(f.Value)], f.Value)
+	case FilterAfter, FilterBefore:
+		t, err := parseDate(f.Value)
+		if err != nil {
+			return true
+		}
+		if f.Field == FilterAfter {
+			return msg.Timestamp >= sqlTime(t)
+		}
+		return msg.Timestamp < sqlTime(t)
+	case FilterAge:
+		dur, err := parseAge(f.Value)
+		if err != nil {
+			return true
+		}
+		cutoff := sqlTime(time.Now().Add(-dur))
+		if f.Op == OpGreaterThan || f.Op == OpAtLeast {
+			return sub.Modified < cutoff
+		}
+		return sub.Modified > cutoff
+	}
+	return true
+}
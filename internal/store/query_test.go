@@ -0,0 +1,281 @@
+package store
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+func TestParse_AST(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"deploy model:opus", "deploy model:opus"},
+		{"-tool:Bash", "-tool:Bash"},
+		{"NOT tool:Bash", "-tool:Bash"},
+		{"(model:opus OR model:sonnet) deploy", "model:opus OR model:sonnet deploy"},
+		{"model:opus | model:sonnet", "model:opus OR model:sonnet"},
+		{"a AND (b c) OR d", "a (b c) OR d"},
+		{"-(type:user tool:Read)", "-(type:user tool:Read)"},
+		{"((deploy))", "deploy"},
+		{`branch:"feature x"`, `branch:"feature x"`},
+		{"tokens:1000..5000", "tokens:1000..5000"},
+		{"main() or", "main() or"},
+	}
+	for _, tt := range tests {
+		fs := Parse(tt.query)
+		if fs.Err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, fs.Err)
+			continue
+		}
+		if got := fs.Root.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParse_TopLevel(t *testing.T) {
+	fs := Parse("deploy -tool:Bash (model:opus OR model:sonnet) tokens:>100")
+	if fs.FreeText != "deploy" {
+		t.Errorf("freetext = %q", fs.FreeText)
+	}
+	if len(fs.Filters) != 1 || fs.Filters[0].Field != FilterTokens {
+		t.Errorf("filters = %+v", fs.Filters)
+	}
+	if len(fs.Nodes()) != 4 {
+		t.Errorf("nodes = %v", fs.Nodes())
+	}
+
+	// An OR of words is still one full-text match
+	fs = Parse("error | panic model:opus")
+	if fs.FreeText != "error OR panic" {
+		t.Errorf("freetext = %q", fs.FreeText)
+	}
+}
+
+func TestParse_QuotedValue(t *testing.T) {
+	fs := Parse(`tool:"mcp read" branch:">weird"`)
+	if fs.Err != nil {
+		t.Fatal(fs.Err)
+	}
+	if fs.Filters[0].Value != "mcp read" || fs.Filters[1].Op != OpEquals || fs.Filters[1].Value != ">weird" {
+		t.Errorf("filters = %+v", fs.Filters)
+	}
+	if got := fs.Filters[1].String(); got != `branch:">weird"` {
+		t.Errorf("String = %q", got)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, q := range []string{
+		"(model:opus",
+		"()",
+		"deploy OR",
+		"OR deploy",
+		"AND deploy",
+		"NOT",
+		"deploy -",
+		"tokens:lots",
+		"tokens:5..x",
+		"tokens:..",
+		"age:soon",
+		"after:yesterday",
+		"before:>2026-01-01",
+		"in:everything",
+		"model:1..2",
+		"model:",
+	} {
+		fs := Parse(q)
+		if fs.Err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", q, fs.Root)
+			continue
+		}
+		if fs.Root != nil || fs.FreeText != "" || len(fs.Filters) != 0 {
+			t.Errorf("Parse(%q) kept %+v alongside its error", q, fs)
+		}
+	}
+}
+
+func TestToSQL_Grouping(t *testing.T) {
+	where, params := Parse("deploy -tool:Bash (model:opus OR model:sonnet)").ToSQL()
+	want := "messages_fts MATCH ? AND NOT (m.tool_calls LIKE ?) AND (s.model LIKE ? OR s.model LIKE ?)"
+	if where != want {
+		t.Errorf("where = %q", where)
+	}
+	if len(params) != 4 || params[0] != "deploy" || params[1] != "%Bash%" || params[3] != "%sonnet%" {
+		t.Errorf("params = %v", params)
+	}
+
+	// Text that is not at the top level needs no join
+	where, params = Parse("model:opus -panic").ToSQL()
+	if where != "s.model LIKE ? AND NOT (m.id IN (SELECT rowid FROM messages_fts WHERE messages_fts MATCH ?))" {
+		t.Errorf("where = %q", where)
+	}
+	if len(params) != 2 || params[1] != "panic" {
+		t.Errorf("params = %v", params)
+	}
+
+	_, params = Parse("deploy (error | panic)").ToSQL()
+	if len(params) != 1 || params[0] != "deploy (error OR panic)" {
+		t.Errorf("params = %v", params)
+	}
+}
+
+func TestToSQL_NewFields(t *testing.T) {
+	where, params := Parse("tokens:1000..5000").ToSQL()
+	if !strings.HasSuffix(where, " BETWEEN ? AND ?") || params[0] != 1000 || params[1] != 5000 {
+		t.Errorf("range = %q %v", where, params)
+	}
+	where, params = Parse("tokens:..5000").ToSQL()
+	if !strings.HasSuffix(where, " <= ?") || params[0] != 5000 {
+		t.Errorf("open range = %q %v", where, params)
+	}
+	where, _ = Parse("tokens:>=10").ToSQL()
+	if !strings.HasSuffix(where, " >= ?") {
+		t.Errorf(">= = %q", where)
+	}
+
+	where, params = Parse("session:abc123").ToSQL()
+	if where != "s.session_id LIKE ?" || params[0] != "abc123%" {
+		t.Errorf("session = %q %v", where, params)
+	}
+
+	where, params = Parse("after:2026-09-01 before:2026-10-01").ToSQL()
+	if where != "m.timestamp >= ? AND m.timestamp < ?" {
+		t.Errorf("dates = %q", where)
+	}
+	after, _ := parseDate("2026-09-01")
+	if params[0] != sqlTime(after) || !strings.HasSuffix(params[0].(string), "Z") {
+		t.Errorf("after = %v", params[0])
+	}
+}
+
+// TestMatch_AgreesWithSQL checks the in-memory predicate against the index
+// for the same queries, free text included.
+func TestMatch_AgreesWithSQL(t *testing.T) {
+	s := openTestStore(t)
+	sessionID, _ := seedTestData(t, s)
+
+	var path string
+	s.db.QueryRow("SELECT f.path FROM files f JOIN sessions s ON s.file_id = f.id WHERE s.session_id = ?", sessionID).Scan(&path)
+	if path == "" {
+		t.Fatal("no session file")
+	}
+	messages, err := claude.LoadMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := Subject{SessionID: sessionID, Project: "TestProject"}
+	for _, m := range messages {
+		if m.Model != "" {
+			sub.Model = claude.FormatModel(m.Model)
+		}
+		sub.Modified = m.Timestamp
+	}
+
+	for _, q := range []string{
+		"-tool:Bash",
+		"tool:Read OR tool:Bash",
+		"type:user -production",
+		"fix -type:user",
+		"tokens:1000..2000",
+		"tokens:>=2500",
+		"tokens:<1500",
+		"model:sonnet -(type:user OR tool:Edit)",
+		"after:2025-01-01T00:00:02Z before:2025-01-01T00:00:05Z",
+		"session:" + sessionID[:6],
+		"-session:other",
+		"project:testproj in:tools",
+		"fix | tests",
+		"age:<1h",
+		`"replica count"`,
+		"deploy.yaml",
+		"-replica_count",
+		// Stemmed like search, not matched as substrings
+		"deploying",
+		"eploy",
+		"-tes",
+		"fixes | eploy",
+	} {
+		fs := Parse(q)
+		if fs.Err != nil {
+			t.Fatalf("Parse(%q): %v", q, fs.Err)
+		}
+		sub.Text, err = s.TextMatches(fs, sessionID)
+		if err != nil {
+			t.Fatalf("TextMatches(%q): %v", q, err)
+		}
+		results, err := s.Search(q, 100)
+		if err != nil {
+			t.Fatalf("Search(%q): %v", q, err)
+		}
+		var want, got []string
+		for _, r := range results {
+			want = append(want, r.UUID)
+		}
+		for _, m := range messages {
+			sub.Message = m
+			if fs.Match(sub) {
+				got = append(got, m.UUID)
+			}
+		}
+		slices.Sort(want)
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("%q: Match = %v, SQL = %v", q, got, want)
+		}
+	}
+
+	if _, err := s.Search("(deploy", 10); err == nil {
+		t.Error("Search with a parse error returned no error")
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		query string
+		mode  SearchMode
+		exact bool
+	}{
+		{"deploy production", SearchAuto, false},
+		{"why did it fail?", SearchAuto, false},
+		{"don't retry", SearchAuto, false},
+		{"--reindex", SearchAuto, true},
+		{"internal/store", SearchAuto, true},
+		{"user_id model:opus", SearchAuto, true},
+		{"handleKey", SearchAuto, true},
+		{"fix user_id in:thinking", SearchAuto, false},
+		{"a.b", SearchAuto, true},
+		{"a_", SearchAuto, false}, // too short to match exactly
+		{"model:opus", SearchAuto, false},
+		{"deploy", SearchExact, true},
+		{"user_id", SearchNatural, false},
+	}
+	for _, tt := range tests {
+		fs := ParseMode(tt.query, tt.mode)
+		if fs.Err != nil {
+			t.Errorf("ParseMode(%q, %s): %v", tt.query, tt.mode, fs.Err)
+			continue
+		}
+		if fs.Exact != tt.exact {
+			t.Errorf("ParseMode(%q, %s).Exact = %v", tt.query, tt.mode, fs.Exact)
+		}
+	}
+
+	where, params := ParseMode(`--reindex -"go test" (a.go | b.go)`, SearchAuto).ToSQL()
+	want := "messages_code MATCH ? AND NOT (m.id IN (SELECT rowid FROM messages_code WHERE messages_code MATCH ?))"
+	if where != want {
+		t.Errorf("where = %q", where)
+	}
+	if len(params) != 2 || params[0] != `"--reindex" ("a.go" OR "b.go")` || params[1] != `"go test"` {
+		t.Errorf("params = %v", params)
+	}
+
+	for _, q := range []string{"go", "retry in:thinking"} {
+		if ParseMode(q, SearchExact).Err == nil {
+			t.Errorf("ParseMode(%q, exact) = no error", q)
+		}
+	}
+}
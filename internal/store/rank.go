@@ -0,0 +1,228 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Results with free text are ranked by relevance: bm25 over the full-text
+// columns, weighted per column, then scaled by the message's type, by how
+// recent it is and by whether it is in the project being boosted. Like
+// rank, a lower score is a better match. A sort: term lists results by
+// recency or token count instead.
+
+// SortOrder is the order search results are listed in.
+type SortOrder int
+
+const (
+	SortDefault   SortOrder = iota // relevance with free text, recent without
+	SortRelevance                  // best match first
+	SortRecent                     // newest message first
+	SortTokens                     // largest message first
+)
+
+var sortNames = map[string]SortOrder{
+	"relevance": SortRelevance,
+	"recent":    SortRecent,
+	"tokens":    SortTokens,
+}
+
+func (o SortOrder) String() string {
+	for name, order := range sortNames {
+		if order == o {
+			return name
+		}
+	}
+	return "default"
+}
+
+// takeSort removes the sort: term from the top of a query and returns the
+// order it asks for. It sorts the whole query, so one inside a group, OR or
+// NOT is an error, as is a second one.
+func takeSort(root Node) (Node, SortOrder, error) {
+	top := []Node{root}
+	if and, ok := root.(AndNode); ok {
+		top = and.Children
+	}
+	order := SortDefault
+	var rest []Node
+	for _, n := range top {
+		if f, ok := n.(Filter); ok && f.Field == FilterSort {
+			if order != SortDefault {
+				return nil, 0, errors.New("sort: can only be given once")
+			}
+			order = sortNames[strings.ToLower(f.Value)]
+			continue
+		}
+		if hasSort(n) {
+			return nil, 0, errors.New("sort: applies to the whole query, not inside a group, OR or NOT")
+		}
+		rest = append(rest, n)
+	}
+	if order == SortDefault {
+		return root, order, nil
+	}
+	return flatten(AndNode{}, rest), order, nil
+}
+
+func hasSort(n Node) bool {
+	switch n := n.(type) {
+	case Filter:
+		return n.Field == FilterSort
+	case NotNode:
+		return hasSort(n.Child)
+	}
+	children, _ := nodeChildren(n)
+	return slices.ContainsFunc(children, hasSort)
+}
+
+// tokensColumn is a message's total token count.
+const tokensColumn = "(m.input_tokens + m.cache_read_tokens + m.cache_write_tokens + m.output_tokens)"
+
+// Ranking tunes relevance. Zero fields take their DefaultRanking value.
+type Ranking struct {
+	TextWeight     float64 // bm25 weight of message text
+	ToolsWeight    float64 // of tool names, or tool inputs in exact search
+	ThinkingWeight float64 // of extended thinking
+
+	UserWeight       float64 // score multiplier for user prompts
+	AssistantWeight  float64 // for assistant replies
+	ToolResultWeight float64 // for tool output
+
+	// RecencyBoost scales a match written now by 1+RecencyBoost, tapering
+	// off with age; negative turns it off. A match HalfLifeDays old keeps
+	// half the boost.
+	RecencyBoost float64
+	HalfLifeDays float64
+
+	ProjectBoost float64 // score multiplier for matches in SearchOptions.Boost
+}
+
+// DefaultRanking favours prompts and replies over tool output, the last
+// few weeks over older history, and the current project.
+func DefaultRanking() Ranking {
+	return Ranking{
+		TextWeight:       1,
+		ToolsWeight:      0.5,
+		ThinkingWeight:   0.5,
+		UserWeight:       1.5,
+		AssistantWeight:  1,
+		ToolResultWeight: 0.5,
+		RecencyBoost:     1,
+		HalfLifeDays:     14,
+		ProjectBoost:     1.5,
+	}
+}
+
+func (r Ranking) withDefaults() Ranking {
+	d := DefaultRanking()
+	for _, f := range []struct{ v, def *float64 }{
+		{&r.TextWeight, &d.TextWeight},
+		{&r.ToolsWeight, &d.ToolsWeight},
+		{&r.ThinkingWeight, &d.ThinkingWeight},
+		{&r.UserWeight, &d.UserWeight},
+		{&r.AssistantWeight, &d.AssistantWeight},
+		{&r.ToolResultWeight, &d.ToolResultWeight},
+		{&r.RecencyBoost, &d.RecencyBoost},
+		{&r.HalfLifeDays, &d.HalfLifeDays},
+		{&r.ProjectBoost, &d.ProjectBoost},
+	} {
+		if *f.v == 0 {
+			*f.v = *f.def
+		}
+	}
+	return r
+}
+
+// SetRanking sets how search weighs relevance.
+func (s *Store) SetRanking(r Ranking) {
+	s.mu.Lock()
+	s.ranking = r
+	s.mu.Unlock()
+}
+
+// bm25 returns the rank function, with column weights, for fs's full-text
+// table.
+func (r Ranking) bm25(fs *FilterSet) string {
+	if fs.Exact {
+		return fmt.Sprintf("bm25(%s, %s)", num(r.TextWeight), num(r.ToolsWeight))
+	}
+	return fmt.Sprintf("bm25(%s, %s, %s)", num(r.TextWeight), num(r.ToolsWeight), num(r.ThinkingWeight))
+}
+
+// score returns a match's relevance as a SQL expression, with now as a
+// julian day.
+func (r Ranking) score(boost string, now float64) string {
+	terms := []string{"rank", fmt.Sprintf("CASE m.type WHEN 'user' THEN %s WHEN 'assistant' THEN %s WHEN 'tool-result' THEN %s ELSE 1 END",
+		num(r.UserWeight), num(r.AssistantWeight), num(r.ToolResultWeight))}
+	if r.RecencyBoost > 0 && r.HalfLifeDays > 0 {
+		// Messages without a timestamp count as old
+		age := fmt.Sprintf("max(%s - COALESCE(julianday(m.timestamp), 0), 0)", num(now))
+		terms = append(terms, fmt.Sprintf("(1 + %s / (1 + %s / %s))", num(r.RecencyBoost), age, num(r.HalfLifeDays)))
+	}
+	if boost != "" && r.ProjectBoost != 1 {
+		terms = append(terms, fmt.Sprintf("CASE WHEN s.project = '%s' THEN %s ELSE 1 END",
+			strings.ReplaceAll(boost, "'", "''"), num(r.ProjectBoost)))
+	}
+	return strings.Join(terms, " * ")
+}
+
+// sortKey returns the expression fs's results are sorted by, and whether
+// higher values come first.
+func (r Ranking) sortKey(fs *FilterSet, boost string, now float64) (string, bool) {
+	switch fs.Sort {
+	case SortTokens:
+		return tokensColumn, true
+	case SortRecent:
+		return "m.timestamp", true
+	}
+	if !fs.HasFTS() {
+		return "m.timestamp", true
+	}
+	return r.score(boost, now), false
+}
+
+// recencyColumn is when a message was written, as a julian day; messages
+// without a timestamp count as oldest.
+const recencyColumn = "COALESCE(julianday(m.timestamp), 0)"
+
+// messageOrder sorts matching messages by their key, then equal keys newest
+// first and by id. All keys share one direction, so the cursor can compare
+// them as a row value; ascending sorts negate the recency to keep newest
+// first.
+func (r Ranking) messageOrder(fs *FilterSet, boost string, now float64) searchOrder {
+	key, desc := r.sortKey(fs, boost, now)
+	keys := []string{key}
+	switch {
+	case key == "m.timestamp":
+	case desc:
+		keys = append(keys, recencyColumn)
+	default:
+		keys = append(keys, "-"+recencyColumn)
+	}
+	return searchOrder{keys: append(keys, "m.id"), desc: desc}
+}
+
+// sessionOrder sorts sessions by the key of their best message, which
+// SearchBySession selects as best, in the same direction as messages.
+func sessionOrder(messages searchOrder) searchOrder {
+	return searchOrder{keys: []string{"best", "session_id"}, desc: messages.desc}
+}
+
+// rankedMatchSQL is matchSQL with the full-text rank weighted per column.
+func (r Ranking) rankedMatchSQL(fs *FilterSet, project string) (string, []interface{}) {
+	from, params := matchSQL(fs, project)
+	if fs.HasFTS() {
+		from += " AND " + fs.FTSTable() + ".rank MATCH ?"
+		params = append(params, r.bm25(fs))
+	}
+	return from, params
+}
+
+// num formats a number for inlining in SQL.
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
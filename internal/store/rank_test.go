@@ -0,0 +1,140 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParse_Sort(t *testing.T) {
+	fs := Parse("deploy sort:recent model:opus")
+	if fs.Err != nil {
+		t.Fatal(fs.Err)
+	}
+	if fs.Sort != SortRecent || fs.Root.String() != "deploy model:opus" {
+		t.Errorf("sort = %s, root = %q", fs.Sort, fs.Root)
+	}
+	if fs := Parse("sort:tokens"); fs.Err != nil || fs.Sort != SortTokens || !fs.IsEmpty() {
+		t.Errorf("sort only = %+v", fs)
+	}
+
+	for _, q := range []string{
+		"deploy sort:newest",
+		"deploy -sort:recent",
+		"deploy | sort:tokens",
+		"deploy sort:recent sort:tokens",
+	} {
+		if Parse(q).Err == nil {
+			t.Errorf("Parse(%q) = no error", q)
+		}
+	}
+}
+
+// seedRanking indexes a session per project, named after it and the given
+// number of days old, in which the prompt and a tool's output say the same.
+func seedRanking(t *testing.T, s *Store, days map[string]int) {
+	t.Helper()
+	dir := t.TempDir()
+	for project, age := range days {
+		ts := time.Now().Add(-time.Duration(age) * 24 * time.Hour).UTC().Format(time.RFC3339)
+		path := filepath.Join(dir, project+".jsonl")
+		writeFile(t, path, fmt.Sprintf(`{"type":"user","uuid":"%[1]s-u","timestamp":"%[2]s","message":{"role":"user","content":"rotate the signing keys"}}
+{"type":"assistant","uuid":"%[1]s-a","timestamp":"%[2]s","message":{"role":"assistant","model":"opus","content":[{"type":"tool_use","id":"t","name":"Bash","input":{"command":"ls"}}],"usage":{"input_tokens":%[3]d,"output_tokens":10}}}
+{"type":"tool-result","uuid":"%[1]s-t","timestamp":"%[2]s","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t","content":"rotate the signing keys"}]}}
+`, project, ts, 100+age))
+		if _, err := s.indexFile(path, project); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func uuids(results []SearchResult) []string {
+	var ids []string
+	for _, r := range results {
+		ids = append(ids, r.UUID)
+	}
+	return ids
+}
+
+func TestRanking(t *testing.T) {
+	s := openTestStore(t)
+	seedRanking(t, s, map[string]int{"old": 200, "new": 1})
+
+	// Prompts over tool output, and recent matches first among each
+	results, _, err := s.SearchPage("signing keys", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(uuids(results)); got != "[new-u old-u new-t old-t]" {
+		t.Errorf("default order = %s", got)
+	}
+	// Each session is represented by its best match, whichever row
+	// SQLite reaches first
+	groups, _, err := s.SearchBySession("signing keys", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var best []string
+	for _, g := range groups {
+		best = append(best, fmt.Sprintf("%s:%d", g.UUID, g.Hits))
+	}
+	if got := fmt.Sprint(best); got != "[new-u:2 old-u:2]" {
+		t.Errorf("default groups = %s", got)
+	}
+
+	// The current project outranks a newer match once recency is off
+	s.SetRanking(Ranking{RecencyBoost: -1, ProjectBoost: 4})
+	results, _, _ = s.SearchPage("signing keys", SearchOptions{Boost: "old"})
+	if got := fmt.Sprint(uuids(results)); got != "[old-u old-t new-u new-t]" {
+		t.Errorf("boosted order = %s", got)
+	}
+
+	// At 3 the boosted tool output scores the same as the newer prompt;
+	// the newer match goes first, on every page
+	s.SetRanking(Ranking{RecencyBoost: -1, ProjectBoost: 3})
+	results, _, _ = s.SearchPage("signing keys", SearchOptions{Boost: "old"})
+	if got := fmt.Sprint(uuids(results)); got != "[old-u new-u old-t new-t]" {
+		t.Errorf("tied order = %s", got)
+	}
+	var paged []SearchResult
+	cursor := ""
+	for {
+		page, next, err := s.SearchPage("signing keys", SearchOptions{Boost: "old", Limit: 1, Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		paged = append(paged, page...)
+		if cursor = next; cursor == "" {
+			break
+		}
+	}
+	if got := fmt.Sprint(uuids(paged)); got != "[old-u new-u old-t new-t]" {
+		t.Errorf("tied order, paged = %s", got)
+	}
+	groups, _, _ = s.SearchBySession("signing keys", SearchOptions{Boost: "old"})
+	if len(groups) != 2 || groups[0].SessionID != "old" || groups[0].UUID != "old-u" {
+		t.Errorf("boosted groups = %+v", groups)
+	}
+
+	// Tool output over prompts, when weighted so
+	s.SetRanking(Ranking{UserWeight: 0.1, RecencyBoost: -1})
+	results, _, _ = s.SearchPage("signing keys", SearchOptions{})
+	if results[0].MessageType != "tool-result" {
+		t.Errorf("reweighted order = %v", uuids(results))
+	}
+	s.SetRanking(Ranking{})
+
+	results, _, _ = s.SearchPage("signing keys sort:recent", SearchOptions{Boost: "old"})
+	if results[0].Project != "new" || results[len(results)-1].Project != "old" {
+		t.Errorf("sort:recent = %v", uuids(results))
+	}
+	results, _, _ = s.SearchPage("tool:Bash sort:tokens", SearchOptions{})
+	if got := fmt.Sprint(uuids(results)); got != "[old-a new-a]" {
+		t.Errorf("sort:tokens = %s", got)
+	}
+	groups, _, _ = s.SearchBySession("type:assistant sort:tokens", SearchOptions{Limit: 1})
+	if len(groups) != 1 || groups[0].SessionID != "old" {
+		t.Errorf("sort:tokens groups = %+v", groups)
+	}
+}
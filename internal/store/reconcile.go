@@ -0,0 +1,137 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+// SetKeepMissing chooses what indexing does with sessions whose JSONL file
+// no longer exists: purge them (the default), or keep them searchable as
+// archived sessions flagged source-missing. Sessions held in the transcript
+// archive are always kept.
+func (s *Store) SetKeepMissing(keep bool) {
+	s.mu.Lock()
+	s.keepMissing = keep
+	s.mu.Unlock()
+}
+
+// ReconcileResult counts what a reconciliation pass changed.
+type ReconcileResult struct {
+	Purged   int // sessions deleted because their file vanished
+	Archived int // sessions newly flagged source-missing
+	Restored int // archived sessions whose file is back
+}
+
+// Total is the number of sessions affected.
+func (r ReconcileResult) Total() int {
+	return r.Purged + r.Archived + r.Restored
+}
+
+// reconcile brings the files table in line with what is on disk. present
+// holds the paths found by the current walk; anything else is stat'ed, and
+// files that are gone are purged or archived. Files under a projects
+// directory that is itself missing (an unmounted volume, say) are left
+// alone. The caller must hold the write lock.
+func (s *Store) reconcile(projectPaths []string, present map[string]bool) (ReconcileResult, error) {
+	var res ReconcileResult
+
+	type fileRow struct {
+		id           int64
+		path         string
+		sessionID    string
+		missingSince int64
+	}
+	rows, err := s.db.Query("SELECT id, path, session_id, missing_since FROM files")
+	if err != nil {
+		return res, err
+	}
+	var files []fileRow
+	for rows.Next() {
+		var f fileRow
+		if err := rows.Scan(&f.id, &f.path, &f.sessionID, &f.missingSince); err != nil {
+			continue
+		}
+		files = append(files, f)
+	}
+	rows.Close()
+
+	roots := claude.AllProjectsDirs(projectPaths)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixMilli()
+	for _, f := range files {
+		if present[f.path] || !vanished(f.path, roots) {
+			if f.missingSince > 0 {
+				if _, err := tx.Exec("UPDATE files SET missing_since = 0 WHERE id = ?", f.id); err != nil {
+					return res, err
+				}
+				res.Restored++
+			}
+			continue
+		}
+
+		if s.keepMissing || s.isArchived(f.path) {
+			if f.missingSince == 0 {
+				if _, err := tx.Exec("UPDATE files SET missing_since = ? WHERE id = ?", now, f.id); err != nil {
+					return res, err
+				}
+				res.Archived++
+			}
+			continue
+		}
+
+		if err := purgeFile(tx, f.id, f.sessionID); err != nil {
+			return res, err
+		}
+		res.Purged++
+	}
+
+	return res, tx.Commit()
+}
+
+// vanished reports whether path is gone for good: it does not exist, and
+// neither is the projects directory it was found under unavailable.
+func vanished(path string, roots []string) bool {
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	for _, root := range roots {
+		if strings.HasPrefix(path, filepath.Clean(root)+string(filepath.Separator)) {
+			_, err := os.Stat(root)
+			return err == nil
+		}
+	}
+	return true
+}
+
+// purgeFile deletes a file and everything derived from it. Children are
+// deleted explicitly rather than through ON DELETE CASCADE, since
+// foreign_keys is a per-connection setting and the pool may hand us a
+// connection without it. FTS rows go via the triggers.
+func purgeFile(tx *sql.Tx, fileID int64, sessionID string) error {
+	for _, q := range []string{
+		"DELETE FROM watchlist_matches WHERE session_id = ?",
+		"DELETE FROM tool_calls WHERE session_id = ?",
+		"DELETE FROM messages WHERE session_id = ?",
+		"DELETE FROM sessions WHERE session_id = ?",
+		"DELETE FROM watchlist_seen WHERE session_id = ?",
+	} {
+		if _, err := tx.Exec(q, sessionID); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec("DELETE FROM files WHERE id = ?", fileID)
+	return err
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeProject lays out a projects root with one project holding the given
+// sessions, and returns the root and the session file paths.
+func writeProject(t *testing.T, sessions ...string) (string, []string) {
+	t.Helper()
+	t.Setenv("CLAUDE_CONFIG_DIR", t.TempDir())
+	root := t.TempDir()
+	dir := filepath.Join(root, "-src-app")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, id := range sessions {
+		path := filepath.Join(dir, id+".jsonl")
+		writeFile(t, path, `{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"deploy `+id+`"}}
+`)
+		paths = append(paths, path)
+	}
+	return root, paths
+}
+
+func sessionCount(t *testing.T, s *Store) int {
+	t.Helper()
+	var n int
+	s.db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&n)
+	return n
+}
+
+func TestIndexChanged_PurgesVanishedFiles(t *testing.T) {
+	s := openTestStore(t)
+	root, paths := writeProject(t, "keep", "gone")
+	if _, err := s.IndexChanged([]string{root}); err != nil {
+		t.Fatal(err)
+	}
+	w, err := s.AddWatch("deploys", "deploy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessionCount(t, s) != 2 {
+		t.Fatalf("sessions = %d, want 2", sessionCount(t, s))
+	}
+
+	os.Remove(paths[1])
+	changed, err := s.IndexChanged([]string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 1 {
+		t.Errorf("changed = %d, want 1", changed)
+	}
+	if sessionCount(t, s) != 1 || s.FileCount() != 1 || s.MessageCount() != 1 {
+		t.Errorf("after purge: %d sessions, %d files, %d messages", sessionCount(t, s), s.FileCount(), s.MessageCount())
+	}
+	if results, _ := s.Search("deploy", 10); len(results) != 1 || results[0].SessionID != "keep" {
+		t.Errorf("search after purge = %+v", results)
+	}
+	var matches int
+	s.db.QueryRow("SELECT COUNT(*) FROM watchlist_matches WHERE watchlist_id = ?", w.ID).Scan(&matches)
+	if matches != 1 {
+		t.Errorf("watchlist matches = %d, want 1", matches)
+	}
+}
+
+func TestIndexChangedContext_Cancelled(t *testing.T) {
+	s := openTestStore(t)
+	root, _ := writeProject(t, "one", "two")
+	if _, err := s.IndexChanged([]string{root}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A pass stopped before any file is reached purges nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.IndexChangedContext(ctx, []string{root}); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled pass = %v, want context.Canceled", err)
+	}
+	if sessionCount(t, s) != 2 {
+		t.Errorf("sessions after cancelled pass = %d, want 2", sessionCount(t, s))
+	}
+}
+
+func TestIndexChanged_ArchivesVanishedFiles(t *testing.T) {
+	s := openTestStore(t)
+	s.SetKeepMissing(true)
+	root, paths := writeProject(t, "gone")
+	s.IndexChanged([]string{root})
+
+	data, _ := os.ReadFile(paths[0])
+	os.Remove(paths[0])
+	if _, err := s.IndexChanged([]string{root}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, _ := s.Search("deploy", 10)
+	if len(results) != 1 || !results[0].SourceMissing {
+		t.Fatalf("archived session search = %+v", results)
+	}
+	sessions, _ := s.SessionsByIDs([]string{"gone"})
+	if len(sessions) != 1 || !sessions[0].Missing {
+		t.Errorf("session not flagged missing: %+v", sessions)
+	}
+	msgs, err := s.SessionMessages("gone")
+	if err != nil || len(msgs) != 1 || msgs[0].Text != "deploy gone" {
+		t.Errorf("SessionMessages = %+v, %v", msgs, err)
+	}
+
+	// A second pass leaves it archived; restoring the file clears the flag
+	if changed, _ := s.IndexChanged([]string{root}); changed != 0 {
+		t.Errorf("changed on second pass = %d, want 0", changed)
+	}
+	os.WriteFile(paths[0], data, 0o644)
+	s.IndexChanged([]string{root})
+	if results, _ := s.Search("deploy", 10); len(results) != 1 || results[0].SourceMissing {
+		t.Errorf("restored session search = %+v", results)
+	}
+}
+
+func TestIndexChanged_KeepsFilesUnderUnavailableRoot(t *testing.T) {
+	s := openTestStore(t)
+	root, _ := writeProject(t, "offline")
+	s.IndexChanged([]string{root})
+
+	// The whole projects directory disappearing looks like an unmounted
+	// volume, not a deleted session.
+	os.RemoveAll(root)
+	if _, err := s.IndexChanged([]string{root}); err != nil {
+		t.Fatal(err)
+	}
+	if sessionCount(t, s) != 1 {
+		t.Errorf("sessions = %d, want 1", sessionCount(t, s))
+	}
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SavedSearch is a named query in the Parse syntax, kept in saved_filters.
+type SavedSearch struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Query     string `json:"query"`
+	Pinned    bool   `json:"pinned"` // shown in the project pane with a live count
+	CreatedAt string `json:"created_at"`
+}
+
+// savedFilter is the filter_json column of saved_filters.
+type savedFilter struct {
+	Query  string `json:"query"`
+	Pinned bool   `json:"pinned,omitempty"`
+}
+
+// SaveSearch saves query under name. Saving under a name that is already
+// taken replaces that search's query and keeps it pinned if it was.
+func (s *Store) SaveSearch(name, query string) (*SavedSearch, error) {
+	name, query = strings.TrimSpace(name), strings.TrimSpace(query)
+	if name == "" {
+		return nil, errors.New("saved search needs a name")
+	}
+	fs := Parse(query)
+	if fs.Err != nil {
+		return nil, fs.Err
+	}
+	if fs.IsEmpty() {
+		return nil, errors.New("saved search needs a query")
+	}
+
+	existing, err := s.savedSearchByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		existing.Query = query
+		if err := s.writeSavedFilter(existing); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	data, _ := json.Marshal(savedFilter{Query: query})
+	now := time.Now().Format(time.RFC3339)
+	res, err := s.db.Exec(
+		"INSERT INTO saved_filters (name, filter_json, created_at) VALUES (?, ?, ?)",
+		name, string(data), now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("save search: %w", err)
+	}
+	id, _ := res.LastInsertId()
+	return &SavedSearch{ID: id, Name: name, Query: query, CreatedAt: now}, nil
+}
+
+// ListSavedSearches returns every saved search, by name.
+func (s *Store) ListSavedSearches() ([]SavedSearch, error) {
+	rows, err := s.db.Query(
+		"SELECT id, name, filter_json, created_at FROM saved_filters ORDER BY name COLLATE NOCASE, id",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	var searches []SavedSearch
+	for rows.Next() {
+		ss, err := scanSavedSearch(rows)
+		if err != nil {
+			continue
+		}
+		searches = append(searches, *ss)
+	}
+	return searches, rows.Err()
+}
+
+// GetSavedSearch returns the saved search with the given id.
+func (s *Store) GetSavedSearch(id int64) (*SavedSearch, error) {
+	ss, err := scanSavedSearch(s.db.QueryRow(
+		"SELECT id, name, filter_json, created_at FROM saved_filters WHERE id = ?", id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no saved search %d", id)
+	}
+	return ss, err
+}
+
+// RenameSavedSearch renames a saved search. Names are unique.
+func (s *Store) RenameSavedSearch(id int64, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("saved search needs a name")
+	}
+	existing, err := s.savedSearchByName(name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != id {
+		return fmt.Errorf("a saved search named %q already exists", name)
+	}
+	if _, err := s.db.Exec("UPDATE saved_filters SET name = ? WHERE id = ?", name, id); err != nil {
+		return fmt.Errorf("rename saved search: %w", err)
+	}
+	return nil
+}
+
+// PinSavedSearch pins or unpins a saved search in the project pane.
+func (s *Store) PinSavedSearch(id int64, pinned bool) error {
+	ss, err := s.GetSavedSearch(id)
+	if err != nil {
+		return err
+	}
+	ss.Pinned = pinned
+	return s.writeSavedFilter(ss)
+}
+
+// DeleteSavedSearch deletes a saved search.
+func (s *Store) DeleteSavedSearch(id int64) error {
+	if _, err := s.db.Exec("DELETE FROM saved_filters WHERE id = ?", id); err != nil {
+		return fmt.Errorf("delete saved search: %w", err)
+	}
+	return nil
+}
+
+// savedSearchByName returns the saved search called name, matched without
+// regard to case, or nil if there is none.
+func (s *Store) savedSearchByName(name string) (*SavedSearch, error) {
+	ss, err := scanSavedSearch(s.db.QueryRow(
+		"SELECT id, name, filter_json, created_at FROM saved_filters WHERE name = ? COLLATE NOCASE ORDER BY id LIMIT 1", name,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return ss, err
+}
+
+func (s *Store) writeSavedFilter(ss *SavedSearch) error {
+	data, _ := json.Marshal(savedFilter{Query: ss.Query, Pinned: ss.Pinned})
+	if _, err := s.db.Exec("UPDATE saved_filters SET filter_json = ? WHERE id = ?", string(data), ss.ID); err != nil {
+		return fmt.Errorf("update saved search: %w", err)
+	}
+	return nil
+}
+
+func scanSavedSearch(row interface{ Scan(...any) error }) (*SavedSearch, error) {
+	var ss SavedSearch
+	var data string
+	if err := row.Scan(&ss.ID, &ss.Name, &data, &ss.CreatedAt); err != nil {
+		return nil, err
+	}
+	var f savedFilter
+	if err := json.Unmarshal([]byte(data), &f); err != nil {
+		return nil, fmt.Errorf("saved search %q: %w", ss.Name, err)
+	}
+	ss.Query, ss.Pinned = f.Query, f.Pinned
+	return &ss, nil
+}
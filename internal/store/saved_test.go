@@ -0,0 +1,85 @@
+package store
+
+import (
+	"testing"
+)
+
+func TestSavedSearches(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, err := s.SaveSearch("", "tool:Bash"); err == nil {
+		t.Error("saved a search without a name")
+	}
+	if _, err := s.SaveSearch("empty", "  "); err == nil {
+		t.Error("saved a search without a query")
+	}
+
+	bash, err := s.SaveSearch("big bash", "tool:Bash tokens:>50000 age:<7d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SaveSearch("Deploys", "deploy"); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.ListSavedSearches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "big bash" || list[1].Name != "Deploys" {
+		t.Fatalf("list = %+v", list)
+	}
+	if list[0].Query != "tool:Bash tokens:>50000 age:<7d" || list[0].Pinned {
+		t.Errorf("saved = %+v", list[0])
+	}
+
+	// Saving under a taken name replaces the query and keeps the pin
+	if err := s.PinSavedSearch(bash.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.SaveSearch("BIG BASH", "tool:Bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != bash.ID || again.Query != "tool:Bash" || !again.Pinned {
+		t.Errorf("re-save = %+v", again)
+	}
+
+	if err := s.RenameSavedSearch(bash.ID, "deploys"); err == nil {
+		t.Error("renamed onto a taken name")
+	}
+	if err := s.RenameSavedSearch(bash.ID, "bash"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetSavedSearch(bash.ID)
+	if err != nil || got.Name != "bash" || !got.Pinned {
+		t.Errorf("after rename = %+v, %v", got, err)
+	}
+
+	if err := s.DeleteSavedSearch(bash.ID); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.ListSavedSearches(); len(list) != 1 || list[0].Name != "Deploys" {
+		t.Errorf("after delete = %+v", list)
+	}
+}
+
+func TestCountSessions(t *testing.T) {
+	s := openTestStore(t)
+	seedTestData(t, s)
+
+	for query, want := range map[string]int{
+		"deploy":          1,
+		"tool:Bash":       1,
+		"tool:Bash panic": 0,
+		"":                0,
+	} {
+		n, err := s.CountSessions(query)
+		if err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		if n != want {
+			t.Errorf("CountSessions(%q) = %d, want %d", query, n, want)
+		}
+	}
+}
@@ -1,187 +1,510 @@
 package store
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/thinkwright/claude-chronicle/internal/claude"
 )
 
 type SearchResult struct {
-	MessageID   int64
-	SessionID   string
-	Project     string
-	MessageType string
-	Timestamp   string
-	Text        string
-	Highlighted string
-	FirstPrompt string
-	GitBranch   string
-	Model       string
-	Rank        float64
+	MessageID   int64   `json:"message_id"`
+	SessionID   string  `json:"session_id"`
+	Project     string  `json:"project"`
+	MessageType string  `json:"type"`
+	Timestamp   string  `json:"timestamp"`
+	Text        string  `json:"text"`
+	Highlighted string  `json:"highlighted"`
+	FirstPrompt string  `json:"first_prompt"`
+	GitBranch   string  `json:"git_branch"` // branch when the message was written
+	Cwd         string  `json:"cwd,omitempty"`
+	Version     string  `json:"version,omitempty"`
+	Model       string  `json:"model"`
+	Rank        float64 `json:"rank"`
+	UUID        string  `json:"uuid,omitempty"`
+
+	// Subagent is the subagent transcript a match came from. SessionID is
+	// then the session whose Task call spawned it.
+	Subagent string `json:"subagent,omitempty"`
+
+	// Abandoned is set for messages on a branch the conversation left when
+	// a prompt was edited or rewound.
+	Abandoned bool `json:"abandoned,omitempty"`
+
+	// SourceMissing is set for archived sessions whose JSONL file is gone.
+	SourceMissing bool `json:"source_missing,omitempty"`
+}
+
+// sessionJoin joins each message (m) to its top-level session (s), so that
+// matches in a subagent transcript (c) are attributed to the session that
+// spawned it.
+const sessionJoin = `JOIN sessions c ON c.session_id = m.session_id
+	JOIN sessions s ON s.session_id = COALESCE(NULLIF(c.parent_session_id, ''), c.session_id)`
+
+// subagentColumn is the subagent transcript a message came from, if any.
+const subagentColumn = `CASE WHEN c.session_id != s.session_id THEN c.session_id ELSE '' END`
+
+// messageBranch is the branch a message was written on, falling back to the
+// session's for rows indexed without one.
+const messageBranch = "COALESCE(NULLIF(m.git_branch, ''), s.git_branch)"
+
+// SearchOptions narrow, rank and page a search.
+type SearchOptions struct {
+	Project string     // only this project's sessions; empty searches all
+	Boost   string     // project whose matches rank higher; see Ranking
+	Mode    SearchMode // how free text is matched
+	Limit   int        // results per page; 0 means defaultSearchLimit
+	Cursor  string     // where the previous page ended; empty for the first
+}
+
+const defaultSearchLimit = 50
+
+// SessionHits is one session's share of a search: how many of its messages
+// matched, and the best match among them.
+type SessionHits struct {
+	SearchResult
+	Hits int `json:"hits"`
 }
 
 // Search executes a full-text + structured filter query and returns matching messages.
 func (s *Store) Search(query string, limit int) ([]SearchResult, error) {
+	return s.SearchProject(query, "", limit)
+}
+
+// SearchProject is Search restricted to one project. An empty project
+// searches everything.
+func (s *Store) SearchProject(query, project string, limit int) ([]SearchResult, error) {
+	results, _, err := s.SearchPage(query, SearchOptions{Project: project, Limit: limit})
+	return results, err
+}
+
+// SearchPage returns one page of matching messages, best first, and the
+// cursor for the next page, which is empty after the last. Pages follow
+// the ranking at the time each is read, so messages indexed in between can
+// shift results across a page boundary.
+func (s *Store) SearchPage(query string, opts SearchOptions) ([]SearchResult, string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	fs := Parse(query)
+	fs := ParseMode(query, opts.Mode)
+	if fs.Err != nil {
+		return nil, "", fs.Err
+	}
 	if fs.IsEmpty() {
-		return nil, nil
+		return nil, "", nil
 	}
 
-	where, params := fs.ToSQL()
+	cur, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	ranking := s.ranking.withDefaults()
+	order := ranking.messageOrder(fs, opts.Boost, cur.Now)
+	from, params := ranking.rankedMatchSQL(fs, opts.Project)
+	after, afterParams, err := order.after(cur)
+	if err != nil {
+		return nil, "", err
+	}
+	if after != "" {
+		from += " AND " + after
+	}
+	limit := opts.limit()
+	params = append(append(params, afterParams...), limit+1)
 
-	// Build the query
-	var sqlStr string
-	if fs.HasFTS() {
-		sqlStr = fmt.Sprintf(`
-			SELECT m.id, m.session_id, s.project, m.type, m.timestamp,
-				m.text,
-				highlight(messages_fts, 0, '<<', '>>'),
-				s.first_prompt, s.git_branch, s.model,
-				rank
-			FROM messages m
-			JOIN messages_fts ON messages_fts.rowid = m.id
-			JOIN sessions s ON s.session_id = m.session_id
-			WHERE %s
-			ORDER BY rank
-			LIMIT ?
-		`, where)
-	} else {
-		sqlStr = fmt.Sprintf(`
-			SELECT m.id, m.session_id, s.project, m.type, m.timestamp,
-				m.text, m.text,
-				s.first_prompt, s.git_branch, s.model,
-				0
-			FROM messages m
-			JOIN sessions s ON s.session_id = m.session_id
-			WHERE %s
-			ORDER BY s.modified_at DESC, m.timestamp DESC
-			LIMIT ?
-		`, where)
-	}
-
-	params = append(params, limit)
-
-	rows, err := s.db.Query(sqlStr, params...)
+	rows, err := s.db.Query(`SELECT `+resultColumns(fs)+`, `+strings.Join(order.keys, ", ")+from+`
+		ORDER BY `+order.orderBy()+`
+		LIMIT ?`, params...)
 	if err != nil {
-		return nil, fmt.Errorf("search query: %w", err)
+		return nil, "", fmt.Errorf("search query: %w", err)
 	}
 	defer rows.Close()
 
 	var results []SearchResult
+	var keys [][]interface{}
 	for rows.Next() {
-		var r SearchResult
-		if err := rows.Scan(
-			&r.MessageID, &r.SessionID, &r.Project, &r.MessageType,
-			&r.Timestamp, &r.Text, &r.Highlighted,
-			&r.FirstPrompt, &r.GitBranch, &r.Model, &r.Rank,
-		); err != nil {
+		key := make([]interface{}, len(order.keys))
+		r, err := scanResult(rows, key...)
+		if err != nil {
 			continue
 		}
-		// Truncate text for display
-		if len(r.Text) > 200 {
-			r.Text = r.Text[:200] + "..."
-		}
-		if len(r.Highlighted) > 300 {
-			r.Highlighted = r.Highlighted[:300] + "..."
-		}
 		results = append(results, r)
+		keys = append(keys, key)
 	}
 
-	return results, nil
+	next := ""
+	if len(results) > limit {
+		results = results[:limit]
+		next = encodeCursor(searchCursor{Key: keys[limit-1], Now: cur.Now})
+	}
+	return results, next, nil
 }
 
-// SearchSessions returns sessions matching the query, for use in the session list.
-func (s *Store) SearchSessions(query string, project string) ([]claude.SessionEntry, error) {
+// SearchBySession returns one page of the sessions with matching messages,
+// each with its number of matches and its best match, and the cursor for
+// the next page. Sessions are ordered by their best match in the query's
+// sort order.
+func (s *Store) SearchBySession(query string, opts SearchOptions) ([]SessionHits, string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	fs := Parse(query)
+	fs := ParseMode(query, opts.Mode)
+	if fs.Err != nil {
+		return nil, "", fs.Err
+	}
 	if fs.IsEmpty() {
-		return nil, nil
+		return nil, "", nil
 	}
 
-	where, params := fs.ToSQL()
-
-	// Add project filter if not searching globally
-	if project != "" {
-		// Check if there's already a project filter in the query
-		hasProjectFilter := false
-		for _, f := range fs.Filters {
-			if f.Field == FilterProject {
-				hasProjectFilter = true
-				break
-			}
+	cur, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	// Each session's matches are numbered in the message order; the first
+	// is its best match.
+	ranking := s.ranking.withDefaults()
+	msgOrder := ranking.messageOrder(fs, opts.Boost, cur.Now)
+	order := sessionOrder(msgOrder)
+	from, params := ranking.rankedMatchSQL(fs, opts.Project)
+	after, afterParams, err := order.after(cur)
+	if err != nil {
+		return nil, "", err
+	}
+	if after != "" {
+		after = " AND " + after
+	}
+	limit := opts.limit()
+	params = append(append(params, afterParams...), limit+1)
+
+	rows, err := s.db.Query(`SELECT hits, id, `+strings.Join(order.keys, ", ")+` FROM (
+			SELECT s.session_id, m.id, `+msgOrder.keys[0]+` AS best,
+				COUNT(*) OVER (PARTITION BY s.session_id) AS hits,
+				ROW_NUMBER() OVER (PARTITION BY s.session_id ORDER BY `+msgOrder.orderBy()+`) AS pick`+from+`
+		)
+		WHERE pick = 1`+after+`
+		ORDER BY `+order.orderBy()+`
+		LIMIT ?`, params...)
+	if err != nil {
+		return nil, "", fmt.Errorf("search sessions: %w", err)
+	}
+	var groups []SessionHits
+	var keys [][]interface{}
+	var ids []interface{}
+	for rows.Next() {
+		var g SessionHits
+		key := make([]interface{}, len(order.keys))
+		dest := append([]interface{}{&g.Hits, &g.MessageID}, pointers(key)...)
+		if err := rows.Scan(dest...); err != nil {
+			continue
 		}
-		if !hasProjectFilter {
-			where += " AND s.project = ?"
-			params = append(params, project)
+		groups = append(groups, g)
+		keys = append(keys, key)
+		ids = append(ids, g.MessageID)
+	}
+	rows.Close()
+
+	next := ""
+	if len(groups) > limit {
+		groups, ids = groups[:limit], ids[:limit]
+		next = encodeCursor(searchCursor{Key: keys[limit-1], Now: cur.Now})
+	}
+	if len(groups) == 0 {
+		return nil, "", nil
+	}
+
+	// Highlighting cannot be aggregated, so the best matches are read
+	// again on their own.
+	from, params = ranking.rankedMatchSQL(fs, "")
+	rows, err = s.db.Query(`SELECT `+resultColumns(fs)+from+`
+		AND m.id IN (`+questionMarks(len(ids))+`)`, append(params, ids...)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("search sessions: %w", err)
+	}
+	defer rows.Close()
+	byID := make(map[int64]SearchResult, len(ids))
+	for rows.Next() {
+		if r, err := scanResult(rows); err == nil {
+			byID[r.MessageID] = r
 		}
 	}
+	for i := range groups {
+		groups[i].SearchResult = byID[groups[i].MessageID]
+	}
+	return groups, next, nil
+}
+
+func (o SearchOptions) limit() int {
+	if o.Limit <= 0 {
+		return defaultSearchLimit
+	}
+	return o.Limit
+}
 
-	var sqlStr string
+// resultColumns is the select list scanResult expects, for queries built
+// on matchSQL.
+func resultColumns(fs *FilterSet) string {
+	textCol, hl, rank := "m.text", "m.text", "0"
 	if fs.HasFTS() {
-		sqlStr = fmt.Sprintf(`
-			SELECT DISTINCT s.session_id,
-				COALESCE(f.path, ''),
-				s.first_prompt, s.message_count,
-				s.created_at, s.modified_at,
-				s.git_branch, s.project
-			FROM messages m
-			JOIN messages_fts ON messages_fts.rowid = m.id
-			JOIN sessions s ON s.session_id = m.session_id
-			LEFT JOIN files f ON f.id = s.file_id
-			WHERE %s
-			ORDER BY s.modified_at DESC
-			LIMIT 100
-		`, where)
-	} else {
-		sqlStr = fmt.Sprintf(`
-			SELECT DISTINCT s.session_id,
-				COALESCE(f.path, ''),
-				s.first_prompt, s.message_count,
-				s.created_at, s.modified_at,
-				s.git_branch, s.project
-			FROM sessions s
-			JOIN messages m ON m.session_id = s.session_id
-			LEFT JOIN files f ON f.id = s.file_id
-			WHERE %s
-			ORDER BY s.modified_at DESC
-			LIMIT 100
-		`, where)
-	}
-
-	rows, err := s.db.Query(sqlStr, params...)
+		// Show the reasoning, not the reply, for thinking-only searches
+		col := 0
+		if fs.ThinkingOnly() {
+			textCol, col = "m.thinking", 2
+		}
+		hl = fmt.Sprintf("highlight(%s, %d, '<<', '>>')", fs.FTSTable(), col)
+		rank = "rank"
+	}
+	return `m.id, s.session_id, s.project, m.type, m.timestamp,
+		` + textCol + `, ` + hl + `,
+		s.first_prompt, ` + messageBranch + `, m.cwd, m.version, s.model,
+		` + rank + `, m.uuid, m.abandoned, ` + subagentColumn + `, COALESCE(f.missing_since, 0) > 0`
+}
+
+// scanResult scans a row selected with resultColumns, followed by any
+// extra columns into extra, and truncates its text for display.
+func scanResult(rows *sql.Rows, extra ...interface{}) (SearchResult, error) {
+	var r SearchResult
+	dest := []interface{}{
+		&r.MessageID, &r.SessionID, &r.Project, &r.MessageType,
+		&r.Timestamp, &r.Text, &r.Highlighted,
+		&r.FirstPrompt, &r.GitBranch, &r.Cwd, &r.Version, &r.Model, &r.Rank,
+		&r.UUID, &r.Abandoned, &r.Subagent, &r.SourceMissing,
+	}
+	if err := rows.Scan(append(dest, pointers(extra)...)...); err != nil {
+		return r, err
+	}
+	if len(r.Text) > 200 {
+		r.Text = r.Text[:200] + "..."
+	}
+	if len(r.Highlighted) > 300 {
+		r.Highlighted = r.Highlighted[:300] + "..."
+	}
+	return r, nil
+}
+
+// pointers returns a pointer to each element of vals, for scanning into.
+func pointers(vals []interface{}) []interface{} {
+	ptrs := make([]interface{}, len(vals))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	return ptrs
+}
+
+// questionMarks returns n comma-separated parameter placeholders.
+func questionMarks(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// searchOrder is the sort of a search: key expressions compared together as
+// one row value, the last of them unique, so that a cursor holding a row's
+// keys resumes right after that row.
+type searchOrder struct {
+	keys []string
+	desc bool
+}
+
+func (o searchOrder) orderBy() string {
+	dir := ""
+	if o.desc {
+		dir = " DESC"
+	}
+	return strings.Join(o.keys, dir+", ") + dir
+}
+
+// searchCursor is where a page of results ended: the sort keys of its last
+// row, and the time relevance was computed at, so that later pages age
+// matches the same way.
+type searchCursor struct {
+	Key []interface{} `json:"k"`
+	Now float64       `json:"t"` // julian day
+}
+
+// after returns the condition selecting rows past cur, and its parameters.
+// The first page needs no condition.
+func (o searchOrder) after(cur searchCursor) (string, []interface{}, error) {
+	if cur.Key == nil {
+		return "", nil, nil
+	}
+	if len(cur.Key) != len(o.keys) {
+		return "", nil, fmt.Errorf("search cursor is for a different sort")
+	}
+	op := ">"
+	if o.desc {
+		op = "<"
+	}
+	return fmt.Sprintf("(%s) %s (%s)", strings.Join(o.keys, ", "), op, questionMarks(len(cur.Key))), cur.Key, nil
+}
+
+// encodeCursor packs a cursor into an opaque string.
+func encodeCursor(cur searchCursor) string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor unpacks a cursor. The empty cursor starts the first page now.
+func decodeCursor(cursor string) (searchCursor, error) {
+	if cursor == "" {
+		return searchCursor{Now: julianDay(time.Now())}, nil
+	}
+	cur, err := unpackCursor(cursor)
+	if err != nil {
+		return searchCursor{}, fmt.Errorf("invalid search cursor %q", cursor)
+	}
+	return cur, nil
+}
+
+func unpackCursor(cursor string) (searchCursor, error) {
+	var cur searchCursor
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return cur, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&cur); err != nil {
+		return cur, err
+	}
+	if cur.Key == nil {
+		return cur, errors.New("no sort key")
+	}
+	// Ids must compare as integers, ranks as reals
+	for i, v := range cur.Key {
+		n, ok := v.(json.Number)
+		if !ok {
+			continue
+		}
+		if id, err := n.Int64(); err == nil {
+			cur.Key[i] = id
+		} else if f, err := n.Float64(); err == nil {
+			cur.Key[i] = f
+		} else {
+			return cur, err
+		}
+	}
+	return cur, nil
+}
+
+// julianDay converts t to SQLite's julian day number.
+func julianDay(t time.Time) float64 {
+	return float64(t.UnixMilli())/86400000 + 2440587.5
+}
+
+// SearchSessions returns sessions matching the query, for use in the session list.
+func (s *Store) SearchSessions(query string, project string) ([]claude.SessionEntry, error) {
+	return s.SearchSessionsMode(query, project, SearchAuto)
+}
+
+// SearchSessionsMode is SearchSessions with free text matched in mode.
+func (s *Store) SearchSessionsMode(query, project string, mode SearchMode) ([]claude.SessionEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fs := ParseMode(query, mode)
+	if fs.Err != nil {
+		return nil, fs.Err
+	}
+	if fs.IsEmpty() {
+		return nil, nil
+	}
+
+	from, params := matchSQL(fs, project)
+	rows, err := s.db.Query(`SELECT DISTINCT `+sessionColumns+from+`
+		ORDER BY s.modified_at DESC
+		LIMIT 100`, params...)
 	if err != nil {
 		return nil, fmt.Errorf("search sessions: %w", err)
 	}
 	defer rows.Close()
 
+	return scanSessions(rows), nil
+}
+
+// CountSessions returns how many sessions SearchSessions would find for the
+// query across all projects, without its limit.
+func (s *Store) CountSessions(query string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fs := Parse(query)
+	if fs.Err != nil {
+		return 0, fs.Err
+	}
+	if fs.IsEmpty() {
+		return 0, nil
+	}
+
+	from, params := matchSQL(fs, "")
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(DISTINCT s.session_id)`+from, params...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count sessions: %w", err)
+	}
+	return n, nil
+}
+
+// matchSQL returns the FROM and WHERE clauses selecting the messages that
+// match fs (m), with their top-level sessions (s) and files (f), restricted
+// to project unless it is empty or the query names a project itself.
+func matchSQL(fs *FilterSet, project string) (string, []interface{}) {
+	where, params := fs.ToSQL()
+	if project != "" && !slices.ContainsFunc(fs.Filters, func(f Filter) bool { return f.Field == FilterProject }) {
+		where += " AND s.project = ?"
+		params = append(params, project)
+	}
+
+	fts := ""
+	if fs.HasFTS() {
+		fts = fmt.Sprintf("JOIN %[1]s ON %[1]s.rowid = m.id", fs.FTSTable())
+	}
+	return fmt.Sprintf(`
+		FROM messages m
+		%s
+		`+sessionJoin+`
+		LEFT JOIN files f ON f.id = s.file_id
+		WHERE %s`, fts, where), params
+}
+
+// sessionColumns is the select list scanSessions expects. Queries using it
+// alias sessions as s and LEFT JOIN files as f.
+const sessionColumns = `s.session_id, COALESCE(f.path, ''), s.first_prompt, s.message_count,
+	s.created_at, s.modified_at, s.git_branch, s.project, s.branches, s.cwd, s.version,
+	COALESCE(f.missing_since, 0) > 0`
+
+func scanSessions(rows *sql.Rows) []claude.SessionEntry {
 	var sessions []claude.SessionEntry
 	for rows.Next() {
 		var se claude.SessionEntry
 		var fullPath sql.NullString
+		var branches string
 		if err := rows.Scan(
 			&se.SessionID, &fullPath, &se.FirstPrompt, &se.MessageCount,
 			&se.Created, &se.Modified, &se.GitBranch, &se.ProjectPath,
+			&branches, &se.Cwd, &se.Version, &se.Missing,
 		); err != nil {
 			continue
 		}
 		if fullPath.Valid {
 			se.FullPath = fullPath.String
 		}
+		se.Branches = splitBranches(branches)
 		sessions = append(sessions, se)
 	}
-
-	return sessions, nil
+	return sessions
 }
 
 // SearchInSession searches within a specific session's messages.
 func (s *Store) SearchInSession(sessionID string, query string) ([]SearchResult, error) {
+	return s.SearchInSessionMode(sessionID, query, SearchAuto)
+}
+
+// SearchInSessionMode is SearchInSession with the query matched in mode.
+// The whole query is free text; filters do not apply.
+func (s *Store) SearchInSessionMode(sessionID, query string, mode SearchMode) ([]SearchResult, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -189,19 +512,22 @@ func (s *Store) SearchInSession(sessionID string, query string) ([]SearchResult,
 		return nil, nil
 	}
 
-	ftsQ := ftsQuery(query)
+	table, ftsQ := "messages_fts", ftsQuery(query)
+	if mode.Resolve(query) == SearchExact {
+		table, ftsQ = "messages_code", codeQuery(query)
+	}
 
-	rows, err := s.db.Query(`
+	rows, err := s.db.Query(fmt.Sprintf(`
 		SELECT m.id, m.session_id, '' as project, m.type, m.timestamp,
 			m.text,
-			highlight(messages_fts, 0, '<<', '>>'),
-			'' as first_prompt, '' as git_branch, m.model,
-			rank
+			highlight(%[1]s, 0, '<<', '>>'),
+			'' as first_prompt, m.git_branch, m.cwd, m.version, m.model,
+			rank, m.uuid, m.abandoned
 		FROM messages m
-		JOIN messages_fts ON messages_fts.rowid = m.id
-		WHERE m.session_id = ? AND messages_fts MATCH ?
+		JOIN %[1]s ON %[1]s.rowid = m.id
+		WHERE m.session_id = ? AND %[1]s MATCH ?
 		ORDER BY m.timestamp ASC
-	`, sessionID, ftsQ)
+	`, table), sessionID, ftsQ)
 	if err != nil {
 		return nil, err
 	}
@@ -213,7 +539,8 @@ func (s *Store) SearchInSession(sessionID string, query string) ([]SearchResult,
 		if err := rows.Scan(
 			&r.MessageID, &r.SessionID, &r.Project, &r.MessageType,
 			&r.Timestamp, &r.Text, &r.Highlighted,
-			&r.FirstPrompt, &r.GitBranch, &r.Model, &r.Rank,
+			&r.FirstPrompt, &r.GitBranch, &r.Cwd, &r.Version, &r.Model, &r.Rank,
+			&r.UUID, &r.Abandoned,
 		); err != nil {
 			continue
 		}
@@ -229,11 +556,10 @@ func (s *Store) SessionsByProject(project string) ([]claude.SessionEntry, error)
 	defer s.mu.RUnlock()
 
 	rows, err := s.db.Query(`
-		SELECT s.session_id, COALESCE(f.path, ''), s.first_prompt, s.message_count,
-			s.created_at, s.modified_at, s.git_branch, s.project
+		SELECT `+sessionColumns+`
 		FROM sessions s
 		LEFT JOIN files f ON f.id = s.file_id
-		WHERE s.project = ?
+		WHERE s.project = ? AND s.parent_session_id = ''
 		ORDER BY s.modified_at DESC
 	`, project)
 	if err != nil {
@@ -241,23 +567,29 @@ func (s *Store) SessionsByProject(project string) ([]claude.SessionEntry, error)
 	}
 	defer rows.Close()
 
-	var sessions []claude.SessionEntry
-	for rows.Next() {
-		var se claude.SessionEntry
-		var fullPath sql.NullString
-		if err := rows.Scan(
-			&se.SessionID, &fullPath, &se.FirstPrompt, &se.MessageCount,
-			&se.Created, &se.Modified, &se.GitBranch, &se.ProjectPath,
-		); err != nil {
-			continue
-		}
-		if fullPath.Valid {
-			se.FullPath = fullPath.String
-		}
-		sessions = append(sessions, se)
+	return scanSessions(rows), nil
+}
+
+// RecentSessions returns the most recently modified sessions, across all
+// projects when project is empty. Subagent transcripts are left out.
+func (s *Store) RecentSessions(project string, limit int) ([]claude.SessionEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT `+sessionColumns+`
+		FROM sessions s
+		LEFT JOIN files f ON f.id = s.file_id
+		WHERE (? = '' OR s.project = ?) AND s.parent_session_id = ''
+		ORDER BY s.modified_at DESC
+		LIMIT ?
+	`, project, project, limit)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return sessions, nil
+	return scanSessions(rows), nil
 }
 
 // SessionsByIDs returns sessions matching any of the given session IDs.
@@ -277,8 +609,7 @@ func (s *Store) SessionsByIDs(sessionIDs []string) ([]claude.SessionEntry, error
 	}
 
 	rows, err := s.db.Query(fmt.Sprintf(`
-		SELECT s.session_id, COALESCE(f.path, ''), s.first_prompt, s.message_count,
-			s.created_at, s.modified_at, s.git_branch, s.project
+		SELECT `+sessionColumns+`
 		FROM sessions s
 		LEFT JOIN files f ON f.id = s.file_id
 		WHERE s.session_id IN (%s)
@@ -289,44 +620,149 @@ func (s *Store) SessionsByIDs(sessionIDs []string) ([]claude.SessionEntry, error
 	}
 	defer rows.Close()
 
-	var sessions []claude.SessionEntry
+	return scanSessions(rows), nil
+}
+
+// ResolveSession finds the indexed session whose ID is, or starts with, id.
+// A prefix must be unambiguous.
+func (s *Store) ResolveSession(id string) (*claude.SessionEntry, error) {
+	if id == "" {
+		return nil, fmt.Errorf("empty session id")
+	}
+
+	s.mu.RLock()
+	rows, err := s.db.Query(`
+		SELECT session_id FROM sessions
+		WHERE substr(session_id, 1, ?) = ?
+		ORDER BY session_id = ? DESC
+		LIMIT 2
+	`, len(id), id, id)
+	if err != nil {
+		s.mu.RUnlock()
+		return nil, err
+	}
+	var ids []string
 	for rows.Next() {
-		var se claude.SessionEntry
-		var fullPath sql.NullString
-		if err := rows.Scan(
-			&se.SessionID, &fullPath, &se.FirstPrompt, &se.MessageCount,
-			&se.Created, &se.Modified, &se.GitBranch, &se.ProjectPath,
-		); err != nil {
+		var sid string
+		if rows.Scan(&sid) == nil {
+			ids = append(ids, sid)
+		}
+	}
+	rows.Close()
+	s.mu.RUnlock()
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("session %q not found", id)
+	}
+	if len(ids) > 1 && ids[0] != id {
+		return nil, fmt.Errorf("session id %q is ambiguous", id)
+	}
+
+	sessions, err := s.SessionsByIDs(ids[:1])
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, fmt.Errorf("session %q not found", id)
+	}
+	return &sessions[0], nil
+}
+
+// SessionMessages rebuilds a session's conversation from the index, for
+// archived sessions whose JSONL file is gone. Text is as indexed, so very
+// long messages are truncated and tool results keep only their summary.
+func (s *Store) SessionMessages(sessionID string) ([]claude.Message, error) {
+	calls, err := s.ToolCallsForSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	byMessage := make(map[int64][]claude.ToolCall)
+	for _, tc := range calls {
+		call := claude.ToolCall{
+			ID:       tc.ToolUseID,
+			Name:     tc.Name,
+			Result:   tc.Result,
+			IsError:  tc.IsError,
+			Answered: tc.Answered,
+			Duration: tc.Duration,
+		}
+		if tc.Input != "" {
+			json.Unmarshal([]byte(tc.Input), &call.Input)
+		}
+		byMessage[tc.MessageID] = append(byMessage[tc.MessageID], call)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT id, type, timestamp, model, text, tool_calls,
+			input_tokens, output_tokens, cache_read_tokens, cache_write_tokens,
+			git_branch, cwd, version, user_type, entrypoint, thinking,
+			uuid, parent_uuid, is_sidechain
+		FROM messages
+		WHERE session_id = ?
+		ORDER BY id
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []claude.Message
+	for rows.Next() {
+		var id int64
+		var typ, tools string
+		var m claude.Message
+		if err := rows.Scan(&id, &typ, &m.Timestamp, &m.Model, &m.Text, &tools,
+			&m.InputTokens, &m.OutputTokens, &m.CacheReadTokens, &m.CacheWriteTokens,
+			&m.GitBranch, &m.Cwd, &m.Version, &m.UserType, &m.Entrypoint, &m.Thinking,
+			&m.UUID, &m.ParentUUID, &m.IsSidechain); err != nil {
 			continue
 		}
-		if fullPath.Valid {
-			se.FullPath = fullPath.String
+		m.Type = claude.MessageType(typ)
+		m.Role = roleFor(m.Type)
+		if tools != "" {
+			m.ToolCalls = strings.Split(tools, ", ")
 		}
-		sessions = append(sessions, se)
+		m.Tools = byMessage[id]
+		messages = append(messages, m)
 	}
+	return messages, nil
+}
 
-	return sessions, nil
+func roleFor(t claude.MessageType) string {
+	switch t {
+	case claude.TypeUser, claude.TypeAssistant, claude.TypeSystem:
+		return string(t)
+	case claude.TypeToolResult:
+		return "tool"
+	}
+	return ""
 }
 
 // MatchCount returns the number of FTS matches for a query (for result count display).
 func (s *Store) MatchCount(query string) int {
-	fs := Parse(query)
-	if !fs.HasFTS() {
+	if !Parse(query).HasFTS() {
 		return 0
 	}
+	n, _ := s.CountMatches(query, SearchOptions{})
+	return n
+}
 
-	where, params := fs.ToSQL()
-	sqlStr := fmt.Sprintf(`
-		SELECT COUNT(*)
-		FROM messages m
-		JOIN messages_fts ON messages_fts.rowid = m.id
-		JOIN sessions s ON s.session_id = m.session_id
-		WHERE %s
-	`, where)
-
-	var count int
-	s.db.QueryRow(sqlStr, params...).Scan(&count)
-	return count
+// CountMatches returns how many messages match the query, and in how many
+// sessions, across every page.
+func (s *Store) CountMatches(query string, opts SearchOptions) (messages, sessions int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fs := ParseMode(query, opts.Mode)
+	if fs.Err != nil || fs.IsEmpty() {
+		return 0, 0
+	}
+	from, params := matchSQL(fs, opts.Project)
+	s.db.QueryRow(`SELECT COUNT(*), COUNT(DISTINCT s.session_id)`+from, params...).Scan(&messages, &sessions)
+	return messages, sessions
 }
 
 // FormatHighlight converts <<matched>> markers to styled text.
@@ -2,6 +2,8 @@ package store
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -190,6 +192,274 @@ func TestSearch_TextTruncation(t *testing.T) {
 	}
 }
 
+func TestSearchProject(t *testing.T) {
+	s := openTestStore(t)
+	seedTestData(t, s)
+
+	results, err := s.SearchProject("deploy", "TestProject", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Fatal("expected results in TestProject")
+	}
+	for _, r := range results {
+		if r.Project != "TestProject" {
+			t.Errorf("result from wrong project: %q", r.Project)
+		}
+	}
+
+	results, err = s.SearchProject("deploy", "OtherProject", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no results in OtherProject, got %d", len(results))
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	s := openTestStore(t)
+	seedTestData(t, s)
+
+	for _, query := range []string{"deploy | fix | tests | production", "model:sonnet"} {
+		all, err := s.Search(query, 100)
+		if err != nil || len(all) < 3 {
+			t.Fatalf("%q: %d results, %v", query, len(all), err)
+		}
+		var paged []SearchResult
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > len(all) {
+				t.Fatalf("%q: pages never end", query)
+			}
+			results, next, err := s.SearchPage(query, SearchOptions{Limit: 2, Cursor: cursor})
+			if err != nil {
+				t.Fatalf("%q: %v", query, err)
+			}
+			paged = append(paged, results...)
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if len(paged) != len(all) {
+			t.Fatalf("%q: paged %d results, want %d", query, len(paged), len(all))
+		}
+		for i := range all {
+			if paged[i].MessageID != all[i].MessageID {
+				t.Errorf("%q: result %d = %d, want %d", query, i, paged[i].MessageID, all[i].MessageID)
+			}
+		}
+	}
+
+	if _, _, err := s.SearchPage("deploy", SearchOptions{Cursor: "bogus"}); err == nil {
+		t.Error("invalid cursor returned no error")
+	}
+}
+
+func TestSearchBySession(t *testing.T) {
+	s := openTestStore(t)
+	seedTestData(t, s)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "other.jsonl")
+	writeFile(t, path, `{"type":"user","uuid":"o1","timestamp":"2025-02-01T00:00:00Z","message":{"role":"user","content":"deploy the other service"}}
+`)
+	if _, err := s.indexFile(path, "OtherProject"); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, next, err := s.SearchBySession("deploy | fix", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || next != "" {
+		t.Fatalf("groups = %+v, next = %q", groups, next)
+	}
+	hits := map[string]int{}
+	for _, g := range groups {
+		hits[g.SessionID] = g.Hits
+		if !strings.Contains(g.Highlighted, "<<") || g.UUID == "" {
+			t.Errorf("best match of %s = %+v", g.SessionID, g.SearchResult)
+		}
+	}
+	if hits["test-session-abc"] != 2 || hits["other"] != 1 {
+		t.Errorf("hits = %v", hits)
+	}
+	if messages, sessions := s.CountMatches("deploy | fix", SearchOptions{}); messages != 3 || sessions != 2 {
+		t.Errorf("counts = %d messages, %d sessions", messages, sessions)
+	}
+
+	// One session per page, then the project filter in SQL
+	first, next, _ := s.SearchBySession("deploy", SearchOptions{Limit: 1})
+	second, last, _ := s.SearchBySession("deploy", SearchOptions{Limit: 1, Cursor: next})
+	if len(first) != 1 || len(second) != 1 || first[0].SessionID == second[0].SessionID || last != "" {
+		t.Errorf("pages = %+v / %+v, last = %q", first, second, last)
+	}
+	groups, _, _ = s.SearchBySession("deploy", SearchOptions{Project: "OtherProject"})
+	if len(groups) != 1 || groups[0].SessionID != "other" {
+		t.Errorf("project groups = %+v", groups)
+	}
+
+	groups, _, _ = s.SearchBySession("model:sonnet", SearchOptions{})
+	if len(groups) != 1 || groups[0].Hits != 6 || groups[0].UUID != "a3" {
+		t.Errorf("filter-only groups = %+v", groups)
+	}
+}
+
+func TestSearch_EnvironmentFilters(t *testing.T) {
+	s := openTestStore(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "branchy.jsonl")
+	writeFile(t, path, `{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","gitBranch":"main","cwd":"/src/app","version":"1.0.79","message":{"role":"user","content":"deploy on main"}}
+{"type":"user","uuid":"u2","timestamp":"2025-01-01T00:00:01Z","gitBranch":"feature/x","cwd":"/src/app/web","version":"1.0.80","message":{"role":"user","content":"deploy on feature"}}
+`)
+	if _, err := s.indexFile(path, "TestProject"); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.Search("deploy branch:feature/*", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].GitBranch != "feature/x" || results[0].Cwd != "/src/app/web" {
+		t.Errorf("branch filter results = %+v", results)
+	}
+
+	// The session ended on feature/x but is still found under main
+	sessions, _ := s.SearchSessions("branch:main", "")
+	if len(sessions) != 1 {
+		t.Errorf("sessions on main = %d, want 1", len(sessions))
+	}
+
+	if results, _ := s.Search("cwd:web", 10); len(results) != 1 {
+		t.Errorf("cwd:web results = %d, want 1", len(results))
+	}
+	if results, _ := s.Search("version:1.0", 10); len(results) != 2 {
+		t.Errorf("version:1.0 results = %d, want 2", len(results))
+	}
+	if results, _ := s.Search("version:1.0.79", 10); len(results) != 1 || results[0].Version != "1.0.79" {
+		t.Errorf("version:1.0.79 results = %+v", results)
+	}
+}
+
+func TestSearch_InThinking(t *testing.T) {
+	s := openTestStore(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "thinky.jsonl")
+	writeFile(t, path, `{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"assistant","model":"opus","content":[{"type":"thinking","thinking":"The flaky test needs a retry loop."},{"type":"text","text":"I added a sleep."}]}}
+{"type":"assistant","uuid":"a2","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"opus","content":[{"type":"text","text":"A retry would be better."}]}}
+`)
+	if _, err := s.indexFile(path, "TestProject"); err != nil {
+		t.Fatal(err)
+	}
+
+	if results, _ := s.Search("retry", 10); len(results) != 2 {
+		t.Errorf("retry results = %d, want 2", len(results))
+	}
+
+	results, err := s.Search("retry in:thinking", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("in:thinking results = %d, want 1", len(results))
+	}
+	if results[0].Text != "The flaky test needs a retry loop." || !strings.Contains(results[0].Highlighted, "<<retry>>") {
+		t.Errorf("in:thinking result = %+v", results[0])
+	}
+
+	if results, _ := s.Search("retry in:text", 10); len(results) != 1 || results[0].Text != "A retry would be better." {
+		t.Errorf("in:text results = %+v", results)
+	}
+	if results, _ := s.Search("in:thinking", 10); len(results) != 1 {
+		t.Errorf("bare in:thinking results = %d, want 1", len(results))
+	}
+
+	msgs, err := s.SessionMessages("thinky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Thinking != "The flaky test needs a retry loop." {
+		t.Errorf("indexed messages = %+v", msgs)
+	}
+}
+
+func TestSearch_Exact(t *testing.T) {
+	s := openTestStore(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "code.jsonl")
+	writeFile(t, path, `{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"rerun clog --reindex after changing handleKeyPress"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"opus","content":[{"type":"text","text":"Reading the store."},{"type":"tool_use","id":"t1","name":"Read","input":{"file_path":"/src/internal/store/search.go"}}]}}
+{"type":"user","uuid":"u2","timestamp":"2025-01-01T00:00:02Z","message":{"role":"user","content":"the keys are handled elsewhere; also check user_id"}}
+`)
+	if _, err := s.indexFile(path, "TestProject"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Punctuation picks the exact index on its own
+	for query, want := range map[string]string{
+		"--reindex":                "u1",
+		"internal/store":           "a1", // only in the tool input
+		"user_id":                  "u2",
+		"handleKey":                "u1", // a prefix of the identifier
+		"store/search.go in:tools": "a1",
+	} {
+		results, err := s.Search(query, 10)
+		if err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		if len(results) != 1 || results[0].UUID != want {
+			t.Errorf("%q results = %+v, want %s", query, results, want)
+		}
+	}
+	if results, _ := s.Search("--reindex", 10); len(results) == 1 && !strings.Contains(results[0].Highlighted, "<<--reindex>>") {
+		t.Errorf("highlight = %q", results[0].Highlighted)
+	}
+
+	// Words still stem in natural mode, and are substrings in exact mode
+	if results, _ := s.Search("handled", 10); len(results) != 1 {
+		t.Errorf("natural handled results = %d, want 1", len(results))
+	}
+	if results, _, _ := s.SearchPage("handle", SearchOptions{Mode: SearchNatural}); len(results) != 1 {
+		t.Errorf("natural handle results = %d, want 1", len(results))
+	}
+	if results, _, _ := s.SearchPage("handle", SearchOptions{Mode: SearchExact}); len(results) != 2 {
+		t.Errorf("exact handle results = %d, want 2", len(results))
+	}
+	if n, _ := s.CountMatches("handle", SearchOptions{Mode: SearchExact}); n != 2 {
+		t.Errorf("exact MatchCount = %d, want 2", n)
+	}
+	if sessions, _ := s.SearchSessionsMode("internal/store", "", SearchExact); len(sessions) != 1 {
+		t.Errorf("exact sessions = %d, want 1", len(sessions))
+	}
+	if results, _ := s.SearchInSession("code", "--reindex"); len(results) != 1 {
+		t.Errorf("in-session results = %d, want 1", len(results))
+	}
+
+	if _, _, err := s.SearchPage("id", SearchOptions{Mode: SearchExact}); err == nil {
+		t.Error("exact search for a 2-character term returned no error")
+	}
+}
+
+func TestResolveSession(t *testing.T) {
+	s := openTestStore(t)
+	seedTestData(t, s)
+
+	for _, id := range []string{"test-session-abc", "test-sess"} {
+		sess, err := s.ResolveSession(id)
+		if err != nil {
+			t.Fatalf("ResolveSession(%q): %v", id, err)
+		}
+		if sess.SessionID != "test-session-abc" || sess.FullPath == "" {
+			t.Errorf("ResolveSession(%q) = %+v", id, sess)
+		}
+	}
+	if _, err := s.ResolveSession("nope"); err == nil {
+		t.Error("expected error for unknown session")
+	}
+}
+
 func TestSearchSessions_ByProject(t *testing.T) {
 	s := openTestStore(t)
 	seedTestData(t, s)
@@ -284,6 +554,24 @@ func TestSessionsByProject_Empty(t *testing.T) {
 	}
 }
 
+func TestRecentSessions(t *testing.T) {
+	s := openTestStore(t)
+	seedTestData(t, s)
+
+	for _, project := range []string{"", "TestProject"} {
+		sessions, err := s.RecentSessions(project, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 1 || sessions[0].SessionID != "test-session-abc" {
+			t.Errorf("RecentSessions(%q) = %+v", project, sessions)
+		}
+	}
+	if sessions, _ := s.RecentSessions("NonExistent", 10); len(sessions) != 0 {
+		t.Errorf("expected 0 sessions for unknown project, got %d", len(sessions))
+	}
+}
+
 func TestMatchCount(t *testing.T) {
 	s := openTestStore(t)
 	seedTestData(t, s)
@@ -8,7 +8,9 @@ import (
 	"regexp"
 	"runtime"
 	"sync"
+	"time"
 
+	"github.com/thinkwright/claude-chronicle/internal/archive"
 	_ "modernc.org/sqlite"
 )
 
@@ -17,6 +19,19 @@ type Store struct {
 	mu        sync.RWMutex
 	reCache   map[string]*regexp.Regexp
 	reCacheMu sync.RWMutex
+
+	keepMissing bool // archive sessions whose JSONL vanished instead of purging
+
+	ranking Ranking // see SetRanking
+
+	archive       *archive.Archive // nil unless archive mode is on
+	archiveBudget int64            // bytes; 0 = unlimited
+
+	onMatch   []func([]WatchMatch) // see OnMatch
+	lastPrune time.Time            // see pruneDeliveryLog
+
+	lease   lease      // see TryAcquireWriter
+	writeMu sync.Mutex // held for a whole index pass, so the lease is not released mid-pass
 }
 
 func dataDir() string {
@@ -34,27 +49,35 @@ func DBPath() string {
 	return filepath.Join(dataDir(), "index.db")
 }
 
+// ArchiveDir is where archive mode keeps transcript snapshots.
+func ArchiveDir() string {
+	return filepath.Join(dataDir(), "archive")
+}
+
 func Open(dbPath string) (*Store, error) {
 	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
 		return nil, fmt.Errorf("create db dir: %w", err)
 	}
 
-	db, err := sql.Open("sqlite", dbPath)
+	// Pragmas in the DSN apply to every connection in the pool: wait for
+	// other writers instead of failing with SQLITE_BUSY, enforce foreign
+	// keys, and use WAL for concurrent reads during writes. Transactions
+	// take the write lock up front: one that reads first and writes later
+	// fails at once, without waiting, if another connection wrote between.
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_txlock=immediate")
 	if err != nil {
 		return nil, fmt.Errorf("open db: %w", err)
 	}
-
-	// Enable WAL for concurrent reads during writes
-	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("set WAL: %w", err)
-	}
-	if _, err := db.Exec("PRAGMA foreign_keys=ON"); err != nil {
+	if err := db.Ping(); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("enable FK: %w", err)
+		return nil, fmt.Errorf("open db: %w", err)
 	}
 
-	s := &Store{db: db, reCache: make(map[string]*regexp.Regexp)}
+	s := &Store{
+		db:      db,
+		reCache: make(map[string]*regexp.Regexp),
+		lease:   lease{token: newLeaseToken(), kind: "clog"},
+	}
 	if err := s.migrate(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("migrate: %w", err)
@@ -62,7 +85,9 @@ func Open(dbPath string) (*Store, error) {
 	return s, nil
 }
 
+// Close releases the writer lease, if held, and closes the database.
 func (s *Store) Close() error {
+	s.releaseLease()
 	return s.db.Close()
 }
 
@@ -89,136 +114,97 @@ func (s *Store) compiledRegex(pattern string) *regexp.Regexp {
 	return re
 }
 
-func (s *Store) migrate() error {
-	// Check schema version
-	var version int
-	row := s.db.QueryRow("PRAGMA user_version")
-	row.Scan(&version)
-
-	if version == 0 {
-		return s.createSchema()
-	}
-	return nil
-}
-
-func (s *Store) createSchema() error {
-	schema := `
-CREATE TABLE IF NOT EXISTS files (
-    id          INTEGER PRIMARY KEY,
-    path        TEXT    UNIQUE NOT NULL,
-    project     TEXT    NOT NULL,
-    session_id  TEXT    NOT NULL,
-    mtime       INTEGER NOT NULL,
-    size        INTEGER NOT NULL,
-    indexed_at  INTEGER NOT NULL
-);
-
-CREATE TABLE IF NOT EXISTS sessions (
-    id           INTEGER PRIMARY KEY,
-    session_id   TEXT    UNIQUE NOT NULL,
-    file_id      INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
-    project      TEXT    NOT NULL,
-    first_prompt TEXT    DEFAULT '',
-    git_branch   TEXT    DEFAULT '',
-    model        TEXT    DEFAULT '',
-    created_at   TEXT    DEFAULT '',
-    modified_at  TEXT    DEFAULT '',
-    message_count       INTEGER DEFAULT 0,
-    total_input_tokens  INTEGER DEFAULT 0,
-    total_output_tokens INTEGER DEFAULT 0,
-    tool_count          INTEGER DEFAULT 0
-);
-
-CREATE INDEX IF NOT EXISTS idx_sessions_project ON sessions(project);
-CREATE INDEX IF NOT EXISTS idx_sessions_branch ON sessions(git_branch);
-CREATE INDEX IF NOT EXISTS idx_sessions_model ON sessions(model);
-CREATE INDEX IF NOT EXISTS idx_sessions_modified ON sessions(modified_at);
-
-CREATE TABLE IF NOT EXISTS messages (
-    id            INTEGER PRIMARY KEY,
-    session_id    TEXT    NOT NULL,
-    type          TEXT    NOT NULL,
-    timestamp     TEXT    NOT NULL,
-    model         TEXT    DEFAULT '',
-    text          TEXT    DEFAULT '',
-    tool_calls    TEXT    DEFAULT '',
-    input_tokens  INTEGER DEFAULT 0,
-    output_tokens INTEGER DEFAULT 0
-);
-
-CREATE INDEX IF NOT EXISTS idx_messages_session ON messages(session_id);
-CREATE INDEX IF NOT EXISTS idx_messages_type ON messages(type);
-
-CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
-    text, tool_calls,
-    content=messages, content_rowid=id,
-    tokenize='porter unicode61'
-);
-
-CREATE TRIGGER IF NOT EXISTS messages_ai AFTER INSERT ON messages BEGIN
-    INSERT INTO messages_fts(rowid, text, tool_calls) VALUES (new.id, new.text, new.tool_calls);
-END;
-
-CREATE TRIGGER IF NOT EXISTS messages_ad AFTER DELETE ON messages BEGIN
-    INSERT INTO messages_fts(messages_fts, rowid, text, tool_calls) VALUES ('delete', old.id, old.text, old.tool_calls);
-END;
-
-CREATE TABLE IF NOT EXISTS watchlist (
-    id         INTEGER PRIMARY KEY,
-    name       TEXT    NOT NULL,
-    pattern    TEXT    NOT NULL,
-    enabled    BOOLEAN DEFAULT 1,
-    color      TEXT    DEFAULT '#b56a6a',
-    created_at TEXT    NOT NULL
-);
-
-CREATE TABLE IF NOT EXISTS watchlist_matches (
-    id           INTEGER PRIMARY KEY,
-    watchlist_id INTEGER NOT NULL REFERENCES watchlist(id) ON DELETE CASCADE,
-    message_id   INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
-    session_id   TEXT    NOT NULL,
-    matched_text TEXT    DEFAULT '',
-    seen         BOOLEAN DEFAULT 0
-);
-
-CREATE INDEX IF NOT EXISTS idx_wm_watchlist ON watchlist_matches(watchlist_id);
-CREATE INDEX IF NOT EXISTS idx_wm_session ON watchlist_matches(session_id);
-
-CREATE TABLE IF NOT EXISTS saved_filters (
-    id          INTEGER PRIMARY KEY,
-    name        TEXT    NOT NULL,
-    filter_json TEXT    NOT NULL,
-    created_at  TEXT    NOT NULL
-);
-
-PRAGMA user_version = 1;
-`
-	_, err := s.db.Exec(schema)
-	return err
+// derivedTables hold data rebuilt from the JSONL files, children first.
+// Everything else (watchlist, saved_filters, watchlist_seen,
+// watchlist_deliveries) is user-authored or history, and survives Reset.
+var derivedTables = []string{
+	"watchlist_matches",
+	"tool_calls",
+	"messages",
+	"sessions",
+	"files",
 }
 
-// Reset drops all data and recreates the schema. Used by --reindex.
+// Reset clears all indexed data so the next IndexAll rebuilds it from
+// scratch. Used by --reindex. Watchlist items, their seen state and saved
+// filters are kept. Returns ErrReadOnly when another process holds the
+// writer lease.
 func (s *Store) Reset() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.requireWriter(); err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Drop all tables including FTS and triggers, then recreate from scratch.
-	// This is faster than DELETE FROM each table (which fires per-row FTS triggers).
-	drops := []string{
-		"DROP TABLE IF EXISTS watchlist_matches",
-		"DROP TABLE IF EXISTS watchlist",
-		"DROP TABLE IF EXISTS saved_filters",
-		"DROP TRIGGER IF EXISTS messages_ai",
-		"DROP TRIGGER IF EXISTS messages_ad",
-		"DROP TABLE IF EXISTS messages_fts",
-		"DROP TABLE IF EXISTS messages",
-		"DROP TABLE IF EXISTS sessions",
-		"DROP TABLE IF EXISTS files",
-	}
-	for _, stmt := range drops {
-		if _, err := s.db.Exec(stmt); err != nil {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := stashSeenMatches(tx, "1 = 1"); err != nil {
+		return err
+	}
+
+	// Deleting through the FTS triggers is slow (one FTS write per row), so
+	// drop them for the duration, clear the FTS indexes wholesale, and
+	// restore the triggers exactly as the migrations defined them.
+	triggers, err := queryStrings(tx, "SELECT sql FROM sqlite_master WHERE type = 'trigger' AND tbl_name = 'messages'")
+	if err != nil {
+		return err
+	}
+	names, err := queryStrings(tx, "SELECT name FROM sqlite_master WHERE type = 'trigger' AND tbl_name = 'messages'")
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := tx.Exec(fmt.Sprintf("DROP TRIGGER %q", name)); err != nil {
+			return err
+		}
+	}
+	for _, table := range derivedTables {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
 			return err
 		}
 	}
-	return s.createSchema()
+	fts, err := queryStrings(tx, "SELECT name FROM sqlite_master WHERE type = 'table' AND sql LIKE 'CREATE VIRTUAL TABLE%USING fts5%'")
+	if err != nil {
+		return err
+	}
+	for _, name := range fts {
+		if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %q(%q) VALUES ('delete-all')", name, name)); err != nil {
+			return err
+		}
+	}
+	for _, stmt := range triggers {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// queryer is satisfied by *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func queryStrings(q queryer, query string, args ...interface{}) ([]string, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
 }
@@ -3,6 +3,7 @@ package store
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -242,3 +243,352 @@ func TestCompiledRegex_Invalid(t *testing.T) {
 		t.Error("expected nil for invalid regex")
 	}
 }
+
+func TestRefreshFile_AppendsNewLines(t *testing.T) {
+	s := openTestStore(t)
+	dir := t.TempDir()
+
+	first := `{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"start the migration"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"sonnet","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"make migrate"}}],"usage":{"input_tokens":100,"output_tokens":10}}}
+`
+	path := filepath.Join(dir, "append-test.jsonl")
+	os.WriteFile(path, []byte(first), 0o644)
+
+	ids, updated, err := s.refreshFile(path, "TestProject")
+	if err != nil || !updated {
+		t.Fatalf("initial refresh: updated=%v err=%v", updated, err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("initial ids = %d, want 2", len(ids))
+	}
+	firstIDs := ids
+
+	// Unchanged file is a no-op
+	if _, updated, _ := s.refreshFile(path, "TestProject"); updated {
+		t.Error("expected unchanged file to be skipped")
+	}
+
+	// Append a tool result and a partial line still being written
+	more := `{"type":"user","uuid":"u2","timestamp":"2025-01-01T00:00:03Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","is_error":true,"content":"migration failed"}]}}
+{"type":"assistant","uuid":"a2","timestamp":"2025-01-01T00:00:04Z","message":{"role":"assistant","model":"opus","content":[{"type":"text","text":"The migration failed."}],"usage":{"input_tokens":200,"output_tokens":20}}}
+{"type":"user","uuid":"u3","timest`
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(more)
+	f.Close()
+
+	ids, updated, err = s.refreshFile(path, "TestProject")
+	if err != nil || !updated {
+		t.Fatalf("append refresh: updated=%v err=%v", updated, err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("appended ids = %d, want 2 (partial line excluded)", len(ids))
+	}
+	for _, id := range ids {
+		for _, old := range firstIDs {
+			if id == old {
+				t.Errorf("append re-inserted message %d", id)
+			}
+		}
+	}
+	if s.MessageCount() != 4 {
+		t.Errorf("message_count = %d, want 4", s.MessageCount())
+	}
+
+	var msgCount, totalIn, totalOut int
+	var model string
+	s.db.QueryRow(`SELECT message_count, total_input_tokens, total_output_tokens, model
+		FROM sessions WHERE session_id = ?`, "append-test").Scan(&msgCount, &totalIn, &totalOut, &model)
+	if msgCount != 4 || totalIn != 300 || totalOut != 30 {
+		t.Errorf("session stats = %d msgs, %d in, %d out", msgCount, totalIn, totalOut)
+	}
+	if model != "opus" {
+		t.Errorf("model = %q, want opus", model)
+	}
+
+	// The tool call indexed in the first pass picks up its late result
+	calls, _ := s.ToolCallsForSession("append-test")
+	if len(calls) != 1 || !calls[0].Answered || !calls[0].IsError || calls[0].Result != "migration failed" {
+		t.Errorf("tool call after append = %+v", calls)
+	}
+
+	var offset int64
+	var lines int
+	s.db.QueryRow("SELECT indexed_offset, line_count FROM files WHERE path = ?", path).Scan(&offset, &lines)
+	if lines != 4 {
+		t.Errorf("line_count = %d, want 4", lines)
+	}
+	if info, _ := os.Stat(path); offset >= info.Size() {
+		t.Errorf("offset %d should stop before the partial line (size %d)", offset, info.Size())
+	}
+}
+
+// streamedLines is a prompt, one API response streamed as four lines, and
+// the results of its two tool calls, as Claude Code writes them.
+var streamedLines = []string{
+	`{"parentUuid":null,"type":"user","message":{"role":"user","content":"why is the build failing?"},"uuid":"7d1e","timestamp":"2025-08-20T10:00:00.000Z"}`,
+	`{"parentUuid":"7d1e","message":{"id":"msg_01XdQ","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"thinking","thinking":"Check the build log first.","signature":"EqkB"}],"usage":{"input_tokens":4,"cache_creation_input_tokens":1200,"cache_read_input_tokens":15000,"output_tokens":8}},"requestId":"req_011CS","type":"assistant","uuid":"a1b2","timestamp":"2025-08-20T10:00:02.100Z"}`,
+	`{"parentUuid":"a1b2","message":{"id":"msg_01XdQ","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"text","text":"Let me look at the build output."}],"usage":{"input_tokens":4,"cache_creation_input_tokens":1200,"cache_read_input_tokens":15000,"output_tokens":8}},"requestId":"req_011CS","type":"assistant","uuid":"c3d4","timestamp":"2025-08-20T10:00:02.600Z"}`,
+	`{"parentUuid":"c3d4","message":{"id":"msg_01XdQ","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"tool_use","id":"toolu_01A","name":"Bash","input":{"command":"make build"}}],"usage":{"input_tokens":4,"cache_creation_input_tokens":1200,"cache_read_input_tokens":15000,"output_tokens":8}},"requestId":"req_011CS","type":"assistant","uuid":"e5f6","timestamp":"2025-08-20T10:00:03.000Z"}`,
+	`{"parentUuid":"e5f6","message":{"id":"msg_01XdQ","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"tool_use","id":"toolu_01B","name":"Read","input":{"file_path":"/work/api/Makefile"}}],"stop_reason":"tool_use","usage":{"input_tokens":4,"cache_creation_input_tokens":1200,"cache_read_input_tokens":15000,"output_tokens":142}},"requestId":"req_011CS","type":"assistant","uuid":"g7h8","timestamp":"2025-08-20T10:00:03.400Z"}`,
+	`{"parentUuid":"g7h8","type":"user","message":{"role":"user","content":[{"tool_use_id":"toolu_01A","type":"tool_result","content":"undefined: NewClient","is_error":true}]},"uuid":"i9j0","timestamp":"2025-08-20T10:00:05.000Z"}`,
+	`{"parentUuid":"i9j0","type":"user","message":{"role":"user","content":[{"tool_use_id":"toolu_01B","type":"tool_result","content":"build: go build ./..."}]},"uuid":"k1l2","timestamp":"2025-08-20T10:00:05.200Z"}`,
+}
+
+func TestRefreshFile_MergesStreamedResponse(t *testing.T) {
+	s := openTestStore(t)
+	path := filepath.Join(t.TempDir(), "stream.jsonl")
+
+	// The first pass runs while the response is still streaming
+	os.WriteFile(path, []byte(strings.Join(streamedLines[:3], "\n")+"\n"), 0o644)
+	if _, _, err := s.refreshFile(path, "TestProject"); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(strings.Join(streamedLines[3:], "\n") + "\n")
+	f.Close()
+	ids, _, err := s.refreshFile(path, "TestProject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 {
+		t.Errorf("ids = %v, want the merged response and two results", ids)
+	}
+
+	type totals struct{ msgs, in, out, cacheRead, cacheWrite, tools int }
+	sessionTotals := func() totals {
+		var tt totals
+		s.db.QueryRow(`
+			SELECT message_count, total_input_tokens, total_output_tokens,
+				total_cache_read_tokens, total_cache_write_tokens, tool_count
+			FROM sessions WHERE session_id = 'stream'
+		`).Scan(&tt.msgs, &tt.in, &tt.out, &tt.cacheRead, &tt.cacheWrite, &tt.tools)
+		return tt
+	}
+	want := totals{msgs: 4, in: 4, out: 142, cacheRead: 15000, cacheWrite: 1200, tools: 2}
+	if got := sessionTotals(); got != want {
+		t.Errorf("after append: %+v, want %+v", got, want)
+	}
+	if s.MessageCount() != 4 {
+		t.Errorf("messages = %d, want 4", s.MessageCount())
+	}
+
+	var text, tools, parent string
+	s.db.QueryRow("SELECT text, tool_calls FROM messages WHERE uuid = 'a1b2'").Scan(&text, &tools)
+	if text != "Let me look at the build output." || tools != "Bash, Read" {
+		t.Errorf("merged row: text %q, tools %q", text, tools)
+	}
+	s.db.QueryRow("SELECT parent_uuid FROM messages WHERE uuid = 'i9j0'").Scan(&parent)
+	if parent != "a1b2" {
+		t.Errorf("result parent = %q, want a1b2", parent)
+	}
+	var hits int
+	s.db.QueryRow("SELECT COUNT(*) FROM messages_fts WHERE messages_fts MATCH 'tool_calls:Read'").Scan(&hits)
+	if hits != 1 {
+		t.Errorf("merged tool calls not searchable: %d hits", hits)
+	}
+
+	calls, _ := s.ToolCallsForSession("stream")
+	if len(calls) != 2 || !calls[0].Answered || !calls[0].IsError || !calls[1].Answered {
+		t.Errorf("tool calls = %+v", calls)
+	}
+
+	// Indexing the finished file from scratch agrees
+	if _, err := s.indexFile(path, "TestProject"); err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionTotals(); got != want {
+		t.Errorf("full index: %+v, want %+v", got, want)
+	}
+}
+
+func TestRefreshFile_ShrunkFileReindexes(t *testing.T) {
+	s := openTestStore(t)
+	dir := t.TempDir()
+
+	jsonl := `{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"one"}}
+{"type":"user","uuid":"u2","timestamp":"2025-01-01T00:00:01Z","message":{"role":"user","content":"two"}}
+`
+	path := filepath.Join(dir, "shrink-test.jsonl")
+	os.WriteFile(path, []byte(jsonl), 0o644)
+	s.refreshFile(path, "TestProject")
+
+	os.WriteFile(path, []byte(`{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"one"}}
+`), 0o644)
+	if _, updated, err := s.refreshFile(path, "TestProject"); err != nil || !updated {
+		t.Fatalf("refresh after shrink: updated=%v err=%v", updated, err)
+	}
+	if s.MessageCount() != 1 {
+		t.Errorf("message_count after shrink = %d, want 1", s.MessageCount())
+	}
+}
+
+func TestRefreshFile_RewrittenPrefixReindexes(t *testing.T) {
+	s := openTestStore(t)
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "rewrite-test.jsonl")
+	os.WriteFile(path, []byte(`{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"original"}}
+`), 0o644)
+	s.refreshFile(path, "TestProject")
+
+	// Same-or-larger file with different leading content must not be appended to
+	os.WriteFile(path, []byte(`{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"replaced"}}
+{"type":"user","uuid":"u2","timestamp":"2025-01-01T00:00:01Z","message":{"role":"user","content":"second"}}
+`), 0o644)
+	if _, _, err := s.refreshFile(path, "TestProject"); err != nil {
+		t.Fatal(err)
+	}
+	if s.MessageCount() != 2 {
+		t.Errorf("message_count = %d, want 2", s.MessageCount())
+	}
+	var n int
+	s.db.QueryRow("SELECT COUNT(*) FROM messages WHERE text = 'original'").Scan(&n)
+	if n != 0 {
+		t.Error("stale message from rewritten prefix still indexed")
+	}
+}
+
+func TestIndexFile_RecordsEnvironment(t *testing.T) {
+	s := openTestStore(t)
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "env-test.jsonl")
+	os.WriteFile(path, []byte(`{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","gitBranch":"main","cwd":"/src/app","version":"1.0.79","userType":"external","entrypoint":"cli","message":{"role":"user","content":"start"}}
+{"type":"user","uuid":"u2","timestamp":"2025-01-01T00:00:01Z","gitBranch":"feature/x","cwd":"/src/app/web","version":"1.0.80","userType":"external","entrypoint":"cli","message":{"role":"user","content":"switched"}}
+`), 0o644)
+	if _, _, err := s.refreshFile(path, "TestProject"); err != nil {
+		t.Fatal(err)
+	}
+
+	var branch, cwd, version string
+	s.db.QueryRow("SELECT git_branch, cwd, version FROM messages WHERE text = 'start'").Scan(&branch, &cwd, &version)
+	if branch != "main" || cwd != "/src/app" || version != "1.0.79" {
+		t.Errorf("message env = %q %q %q", branch, cwd, version)
+	}
+
+	// Switch back to main in an appended line
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`{"type":"user","uuid":"u3","timestamp":"2025-01-01T00:00:02Z","gitBranch":"main","cwd":"/src/app","version":"1.0.80","message":{"role":"user","content":"back"}}
+`)
+	f.Close()
+	if _, _, err := s.refreshFile(path, "TestProject"); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := s.SessionsByIDs([]string{"env-test"})
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("SessionsByIDs = %v, %v", sessions, err)
+	}
+	se := sessions[0]
+	if se.GitBranch != "main" || se.Cwd != "/src/app" || se.Version != "1.0.80" {
+		t.Errorf("session env = %q %q %q", se.GitBranch, se.Cwd, se.Version)
+	}
+	if len(se.Branches) != 2 || se.Branches[0] != "main" || se.Branches[1] != "feature/x" {
+		t.Errorf("branches = %v, want [main feature/x]", se.Branches)
+	}
+
+	var userType, entrypoint string
+	s.db.QueryRow("SELECT user_type, entrypoint FROM sessions WHERE session_id = 'env-test'").Scan(&userType, &entrypoint)
+	if userType != "external" || entrypoint != "cli" {
+		t.Errorf("session user_type/entrypoint = %q %q", userType, entrypoint)
+	}
+}
+
+func TestIndexFile_RecordsForks(t *testing.T) {
+	s := openTestStore(t)
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "fork-test.jsonl")
+	os.WriteFile(path, []byte(`{"type":"user","uuid":"u1","parentUuid":null,"timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"start"}}
+{"type":"assistant","uuid":"a1","parentUuid":"u1","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"opus","content":[{"type":"text","text":"ok"}]}}
+{"type":"user","uuid":"u2","parentUuid":"a1","timestamp":"2025-01-01T00:00:02Z","message":{"role":"user","content":"first attempt"}}
+`), 0o644)
+	if _, _, err := s.refreshFile(path, "TestProject"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The prompt is edited: a new branch from a1, appended incrementally
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`{"type":"user","uuid":"u2b","parentUuid":"a1","timestamp":"2025-01-01T00:00:03Z","message":{"role":"user","content":"second attempt"}}
+`)
+	f.Close()
+	if _, _, err := s.refreshFile(path, "TestProject"); err != nil {
+		t.Fatal(err)
+	}
+
+	var forks int
+	s.db.QueryRow("SELECT fork_count FROM sessions WHERE session_id = 'fork-test'").Scan(&forks)
+	if forks != 1 {
+		t.Errorf("fork_count = %d, want 1", forks)
+	}
+
+	results, err := s.Search("attempt", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abandoned := map[string]bool{}
+	for _, r := range results {
+		abandoned[r.UUID] = r.Abandoned
+	}
+	if len(abandoned) != 2 || !abandoned["u2"] || abandoned["u2b"] {
+		t.Errorf("abandoned = %v, want only u2", abandoned)
+	}
+
+	var parent string
+	s.db.QueryRow("SELECT parent_uuid FROM messages WHERE uuid = 'u2b'").Scan(&parent)
+	if parent != "a1" {
+		t.Errorf("u2b parent = %q, want a1", parent)
+	}
+}
+
+func TestIndexFile_AttributesSubagents(t *testing.T) {
+	s := openTestStore(t)
+	dir := t.TempDir()
+
+	parent := filepath.Join(dir, "sess-1.jsonl")
+	os.WriteFile(parent, []byte(`{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"assistant","model":"opus","content":[{"type":"tool_use","id":"toolu_1","name":"Task","input":{"prompt":"audit the parser"}}],"usage":{"input_tokens":10,"output_tokens":5}}}
+`), 0o644)
+	agent := filepath.Join(dir, "sess-1", "subagents", "agent-abc.jsonl")
+	os.MkdirAll(filepath.Dir(agent), 0o755)
+	os.WriteFile(agent, []byte(`{"type":"user","uuid":"b1","isSidechain":true,"timestamp":"2025-01-01T00:00:01Z","message":{"role":"user","content":"audit the parser"}}
+{"type":"assistant","uuid":"b2","parentUuid":"b1","isSidechain":true,"timestamp":"2025-01-01T00:00:02Z","message":{"role":"assistant","model":"haiku","content":[{"type":"text","text":"the tokenizer drops quotes"}],"usage":{"input_tokens":100,"output_tokens":50}}}
+`), 0o644)
+
+	paths := collectJSONLFiles(dir)
+	if len(paths) != 2 {
+		t.Fatalf("collected %v, want the session and its subagent", paths)
+	}
+	for _, p := range paths {
+		if _, err := s.indexFile(p, "TestProject"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := s.SessionsByProject("TestProject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].SessionID != "sess-1" {
+		t.Errorf("sessions = %+v, want only sess-1", sessions)
+	}
+
+	results, err := s.Search("tokenizer", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].SessionID != "sess-1" || results[0].Subagent != "agent-abc" {
+		t.Errorf("results = %+v, want a match in sess-1 from agent-abc", results)
+	}
+
+	usage, err := s.SessionUsage("TestProject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, mu := range usage["sess-1"] {
+		total += mu.Total()
+	}
+	if total != 165 || len(usage) != 1 {
+		t.Errorf("usage = %+v, want 165 tokens on sess-1", usage)
+	}
+}
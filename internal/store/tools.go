@@ -0,0 +1,51 @@
+package store
+
+import (
+	"time"
+)
+
+// ToolCallRecord is a persisted tool invocation, paired with its result.
+type ToolCallRecord struct {
+	ID        int64
+	MessageID int64
+	SessionID string
+	ToolUseID string
+	Name      string
+	Input     string // JSON-encoded tool input
+	Result    string
+	IsError   bool
+	Answered  bool
+	Duration  time.Duration
+	Timestamp string
+}
+
+// ToolCallsForSession returns every tool invocation in a session, in call order.
+func (s *Store) ToolCallsForSession(sessionID string) ([]ToolCallRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT id, message_id, session_id, tool_use_id, name, input, result,
+			is_error, answered, duration_ms, timestamp
+		FROM tool_calls
+		WHERE session_id = ?
+		ORDER BY timestamp ASC, id ASC
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calls []ToolCallRecord
+	for rows.Next() {
+		var tc ToolCallRecord
+		var durationMs int64
+		if err := rows.Scan(&tc.ID, &tc.MessageID, &tc.SessionID, &tc.ToolUseID, &tc.Name,
+			&tc.Input, &tc.Result, &tc.IsError, &tc.Answered, &durationMs, &tc.Timestamp); err != nil {
+			continue
+		}
+		tc.Duration = time.Duration(durationMs) * time.Millisecond
+		calls = append(calls, tc)
+	}
+	return calls, nil
+}
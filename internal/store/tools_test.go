@@ -0,0 +1,72 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIndexFile_PersistsToolCalls(t *testing.T) {
+	s := openTestStore(t)
+	dir := t.TempDir()
+
+	jsonl := `{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"run the tests"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"sonnet","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"go test ./..."}}]}}
+{"type":"user","uuid":"u2","timestamp":"2025-01-01T00:00:04Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","is_error":true,"content":"exit status 1"}]}}
+{"type":"assistant","uuid":"a2","timestamp":"2025-01-01T00:00:05Z","message":{"role":"assistant","model":"sonnet","content":[{"type":"tool_use","id":"toolu_2","name":"Read","input":{"file_path":"main_test.go"}}]}}
+`
+	path := filepath.Join(dir, "tools-session.jsonl")
+	if err := os.WriteFile(path, []byte(jsonl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.indexFile(path, "TestProject"); err != nil {
+		t.Fatal(err)
+	}
+
+	calls, err := s.ToolCallsForSession("tools-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(calls))
+	}
+
+	bash := calls[0]
+	if bash.Name != "Bash" || bash.ToolUseID != "toolu_1" {
+		t.Errorf("call[0] = %q/%q", bash.Name, bash.ToolUseID)
+	}
+	if bash.Input != `{"command":"go test ./..."}` {
+		t.Errorf("input = %q", bash.Input)
+	}
+	if !bash.Answered || !bash.IsError || bash.Result != "exit status 1" {
+		t.Errorf("result = %+v", bash)
+	}
+	if bash.Duration != 3*time.Second {
+		t.Errorf("duration = %v, want 3s", bash.Duration)
+	}
+
+	if calls[1].Answered {
+		t.Error("expected Read call to be unanswered")
+	}
+}
+
+func TestIndexFile_ReindexReplacesToolCalls(t *testing.T) {
+	s := openTestStore(t)
+	dir := t.TempDir()
+
+	jsonl := `{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"sonnet","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"ls"}}]}}
+`
+	path := filepath.Join(dir, "tools-reindex.jsonl")
+	os.WriteFile(path, []byte(jsonl), 0o644)
+	s.indexFile(path, "TestProject")
+	s.indexFile(path, "TestProject")
+
+	calls, err := s.ToolCallsForSession("tools-reindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("after reindex tool calls = %d, want 1", len(calls))
+	}
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+)
+
+// ModelUsage is token usage attributed to a single model. Pricing is per
+// model, so costs are computed from these rather than from session totals.
+type ModelUsage struct {
+	Model string
+	claude.Usage
+}
+
+// SessionUsage returns per-model token usage for every session in a project,
+// keyed by session ID. A session's usage includes the subagents it spawned.
+// An empty project covers all projects.
+func (s *Store) SessionUsage(project string) (map[string][]ModelUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT s.session_id, m.model,
+			SUM(m.input_tokens), SUM(m.output_tokens),
+			SUM(m.cache_read_tokens), SUM(m.cache_write_tokens)
+		FROM messages m
+		`+sessionJoin+`
+		WHERE m.model != '' AND (? = '' OR s.project = ?)
+		GROUP BY s.session_id, m.model
+	`, project, project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make(map[string][]ModelUsage)
+	for rows.Next() {
+		var sessionID string
+		var mu ModelUsage
+		if err := rows.Scan(&sessionID, &mu.Model, &mu.InputTokens, &mu.OutputTokens,
+			&mu.CacheReadTokens, &mu.CacheWriteTokens); err != nil {
+			continue
+		}
+		usage[sessionID] = append(usage[sessionID], mu)
+	}
+	return usage, nil
+}
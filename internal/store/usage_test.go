@@ -0,0 +1,49 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionUsage_SeparatesCacheTokens(t *testing.T) {
+	s := openTestStore(t)
+	dir := t.TempDir()
+
+	jsonl := `{"type":"user","uuid":"u1","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"hello"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-01-01T00:00:01Z","message":{"role":"assistant","model":"claude-opus-4-1","content":[{"type":"text","text":"hi"}],"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":1000,"cache_creation_input_tokens":200}}}
+{"type":"assistant","uuid":"a2","timestamp":"2025-01-01T00:00:02Z","message":{"role":"assistant","model":"claude-haiku-4-5","content":[{"type":"text","text":"quick"}],"usage":{"input_tokens":20,"output_tokens":2}}}
+{"type":"assistant","uuid":"a3","timestamp":"2025-01-01T00:00:03Z","message":{"role":"assistant","model":"claude-opus-4-1","content":[{"type":"text","text":"more"}],"usage":{"input_tokens":5,"output_tokens":5,"cache_read_input_tokens":1200}}}
+`
+	path := filepath.Join(dir, "usage-session.jsonl")
+	writeFile(t, path, jsonl)
+	if _, err := s.indexFile(path, "UsageProject"); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := s.SessionUsage("UsageProject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byModel := make(map[string]ModelUsage)
+	for _, mu := range usage["usage-session"] {
+		byModel[mu.Model] = mu
+	}
+	opus := byModel["claude-opus-4-1"]
+	if opus.InputTokens != 15 || opus.OutputTokens != 10 || opus.CacheReadTokens != 2200 || opus.CacheWriteTokens != 200 {
+		t.Errorf("opus usage = %+v", opus.Usage)
+	}
+	if byModel["claude-haiku-4-5"].InputTokens != 20 {
+		t.Errorf("haiku usage = %+v", byModel["claude-haiku-4-5"].Usage)
+	}
+
+	var in, cacheRead, cacheWrite int
+	s.db.QueryRow(`SELECT total_input_tokens, total_cache_read_tokens, total_cache_write_tokens
+		FROM sessions WHERE session_id = 'usage-session'`).Scan(&in, &cacheRead, &cacheWrite)
+	if in != 35 || cacheRead != 2200 || cacheWrite != 200 {
+		t.Errorf("session totals = %d in, %d cache read, %d cache write", in, cacheRead, cacheWrite)
+	}
+
+	if other, _ := s.SessionUsage("OtherProject"); len(other) != 0 {
+		t.Errorf("expected no usage for other project, got %v", other)
+	}
+}
@@ -1,32 +1,109 @@
 package store
 
 import (
+	"database/sql"
 	"fmt"
+	"net/url"
+	"os"
 	"regexp"
+	"runtime"
 	"strings"
 	"time"
 )
 
 type WatchItem struct {
-	ID          int64
-	Name        string
-	Pattern     string
-	Compiled    *regexp.Regexp
-	Enabled     bool
-	Color       string
-	CreatedAt   string
-	UnseenCount int
+	ID          int64          `json:"id"`
+	Name        string         `json:"name"`
+	Pattern     string         `json:"pattern"`
+	Compiled    *regexp.Regexp `json:"-"`
+	Enabled     bool           `json:"enabled"`
+	Color       string         `json:"color"`
+	CreatedAt   string         `json:"created_at"`
+	UnseenCount int            `json:"unseen_count"`
+	Action      WatchAction    `json:"action"`
+}
+
+// Watch action kinds. An empty kind means the watch only counts matches.
+const (
+	ActionCommand = "command" // run Target with sh -c
+	ActionWebhook = "webhook" // POST JSON to Target
+	ActionPipe    = "pipe"    // write a JSON line to the named pipe at Target
+)
+
+// DefaultActionLimit is the number of deliveries a watch may make per hour
+// when its action sets no limit.
+const DefaultActionLimit = 30
+
+// WatchAction is what happens when new messages match a watch.
+type WatchAction struct {
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Limit  int    `json:"limit"` // deliveries per hour; 0 means DefaultActionLimit
+}
+
+// HourlyLimit returns the action's delivery limit per hour.
+func (a WatchAction) HourlyLimit() int {
+	if a.Limit > 0 {
+		return a.Limit
+	}
+	return DefaultActionLimit
+}
+
+// Validate reports whether the action can be run. The zero action is valid.
+func (a WatchAction) Validate() error {
+	switch a.Kind {
+	case "":
+		return nil
+	case ActionCommand, ActionPipe:
+	case ActionWebhook:
+		u, err := url.Parse(a.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook target must be an http or https URL: %q", a.Target)
+		}
+	default:
+		return fmt.Errorf("unknown action %q (want command, webhook or pipe)", a.Kind)
+	}
+	if strings.TrimSpace(a.Target) == "" {
+		return fmt.Errorf("%s action needs a target", a.Kind)
+	}
+	if a.Limit < 0 {
+		return fmt.Errorf("action limit must not be negative")
+	}
+	if a.Kind == ActionPipe {
+		return checkPipe(a.Target)
+	}
+	return nil
+}
+
+// checkPipe reports whether path is a named pipe. Pipe actions append to
+// their target, so anything else, a regular file or a symlink to one, is
+// refused rather than written to.
+func checkPipe(path string) error {
+	if runtime.GOOS == "windows" {
+		if !strings.HasPrefix(path, `\\.\pipe\`) {
+			return fmt.Errorf("pipe target must be a named pipe under \\\\.\\pipe\\: %q", path)
+		}
+		return nil
+	}
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("pipe target: %w", err)
+	}
+	if fi.Mode()&os.ModeNamedPipe == 0 {
+		return fmt.Errorf("pipe target is not a named pipe: %q", path)
+	}
+	return nil
 }
 
 type WatchMatch struct {
-	ID          int64
-	WatchItemID int64
-	MessageID   int64
-	SessionID   string
-	Project     string
-	MatchedText string
-	Seen        bool
-	Timestamp   string
+	ID          int64  `json:"id"`
+	WatchItemID int64  `json:"watch_id"`
+	MessageID   int64  `json:"message_id"`
+	SessionID   string `json:"session_id"`
+	Project     string `json:"project"`
+	MatchedText string `json:"matched_text"`
+	Seen        bool   `json:"seen"`
+	Timestamp   string `json:"timestamp"`
 }
 
 // AddWatch creates a new watchlist item. Returns error if the pattern is invalid.
@@ -89,8 +166,9 @@ func (s *Store) UpdateWatch(id int64, name, pattern string) error {
 		return err
 	}
 
-	// Re-run matching
+	// Re-run matching; a new pattern starts with nothing seen
 	s.db.Exec("DELETE FROM watchlist_matches WHERE watchlist_id = ?", id)
+	s.db.Exec("DELETE FROM watchlist_seen WHERE watchlist_id = ?", id)
 
 	item, err := s.GetWatch(id)
 	if err == nil && item.Enabled {
@@ -99,14 +177,41 @@ func (s *Store) UpdateWatch(id int64, name, pattern string) error {
 	return nil
 }
 
+// SetWatchAction sets the action run when new messages match a watch. The
+// zero action removes it.
+func (s *Store) SetWatchAction(id int64, action WatchAction) error {
+	if err := action.Validate(); err != nil {
+		return err
+	}
+	res, err := s.db.Exec("UPDATE watchlist SET action_kind = ?, action_target = ?, action_limit = ? WHERE id = ?",
+		action.Kind, action.Target, action.Limit, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// OnMatch registers fn to receive the matches each call to MatchNewMessages
+// records, so actions can react to them. Matches backfilled for a new or
+// edited watch are not passed on. Register before indexing starts; fn runs
+// on the indexing goroutine and should not block.
+func (s *Store) OnMatch(fn func([]WatchMatch)) {
+	s.onMatch = append(s.onMatch, fn)
+}
+
 // GetWatch loads a single watchlist item.
 func (s *Store) GetWatch(id int64) (*WatchItem, error) {
 	var item WatchItem
 	err := s.db.QueryRow(`
 		SELECT w.id, w.name, w.pattern, w.enabled, w.color, w.created_at,
-			COALESCE((SELECT COUNT(*) FROM watchlist_matches wm WHERE wm.watchlist_id = w.id AND wm.seen = 0), 0)
+			COALESCE((SELECT COUNT(*) FROM watchlist_matches wm WHERE wm.watchlist_id = w.id AND wm.seen = 0), 0),
+			w.action_kind, w.action_target, w.action_limit
 		FROM watchlist w WHERE w.id = ?
-	`, id).Scan(&item.ID, &item.Name, &item.Pattern, &item.Enabled, &item.Color, &item.CreatedAt, &item.UnseenCount)
+	`, id).Scan(&item.ID, &item.Name, &item.Pattern, &item.Enabled, &item.Color, &item.CreatedAt, &item.UnseenCount,
+		&item.Action.Kind, &item.Action.Target, &item.Action.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -121,7 +226,8 @@ func (s *Store) ListWatches() ([]WatchItem, error) {
 
 	rows, err := s.db.Query(`
 		SELECT w.id, w.name, w.pattern, w.enabled, w.color, w.created_at,
-			COALESCE((SELECT COUNT(*) FROM watchlist_matches wm WHERE wm.watchlist_id = w.id AND wm.seen = 0), 0)
+			COALESCE((SELECT COUNT(*) FROM watchlist_matches wm WHERE wm.watchlist_id = w.id AND wm.seen = 0), 0),
+			w.action_kind, w.action_target, w.action_limit
 		FROM watchlist w
 		ORDER BY w.created_at ASC
 	`)
@@ -133,7 +239,8 @@ func (s *Store) ListWatches() ([]WatchItem, error) {
 	var items []WatchItem
 	for rows.Next() {
 		var item WatchItem
-		if err := rows.Scan(&item.ID, &item.Name, &item.Pattern, &item.Enabled, &item.Color, &item.CreatedAt, &item.UnseenCount); err != nil {
+		if err := rows.Scan(&item.ID, &item.Name, &item.Pattern, &item.Enabled, &item.Color, &item.CreatedAt, &item.UnseenCount,
+			&item.Action.Kind, &item.Action.Target, &item.Action.Limit); err != nil {
 			continue
 		}
 		item.Compiled = s.compiledRegex(item.Pattern)
@@ -142,6 +249,35 @@ func (s *Store) ListWatches() ([]WatchItem, error) {
 	return items, nil
 }
 
+// insertMatchSQL records a match; args are (matched_text, watchlist_id, message_id).
+// A match the user saw before the message was re-indexed comes back seen.
+const insertMatchSQL = `
+	INSERT INTO watchlist_matches (watchlist_id, message_id, session_id, matched_text, seen)
+	SELECT ws.id, m.id, m.session_id, ?, EXISTS (
+		SELECT 1 FROM watchlist_seen ss
+		WHERE ss.watchlist_id = ws.id AND ss.session_id = m.session_id
+			AND ss.timestamp = m.timestamp AND ss.type = m.type
+	)
+	FROM messages m, watchlist ws
+	WHERE ws.id = ? AND m.id = ?
+	AND NOT EXISTS (
+		SELECT 1 FROM watchlist_matches wm WHERE wm.watchlist_id = ws.id AND wm.message_id = m.id
+	)
+`
+
+// stashSeenMatches copies the seen state of the matches selected by where
+// (over watchlist_matches wm) into watchlist_seen before their messages are
+// deleted, so insertMatchSQL can restore it after re-indexing.
+func stashSeenMatches(tx *sql.Tx, where string, args ...interface{}) error {
+	_, err := tx.Exec(`
+		INSERT OR IGNORE INTO watchlist_seen (watchlist_id, session_id, timestamp, type)
+		SELECT wm.watchlist_id, m.session_id, m.timestamp, m.type
+		FROM watchlist_matches wm
+		JOIN messages m ON m.id = wm.message_id
+		WHERE wm.seen = 1 AND `+where, args...)
+	return err
+}
+
 // MatchNewMessages runs all enabled watchlist patterns against the given message IDs.
 // Returns the number of new matches found.
 func (s *Store) MatchNewMessages(messageIDs []int64) (int, error) {
@@ -155,6 +291,7 @@ func (s *Store) MatchNewMessages(messageIDs []int64) (int, error) {
 	}
 
 	total := 0
+	var inserted []int64
 	for _, item := range items {
 		if !item.Enabled || item.Compiled == nil {
 			continue
@@ -169,7 +306,7 @@ func (s *Store) MatchNewMessages(messageIDs []int64) (int, error) {
 		}
 
 		rows, err := s.db.Query(fmt.Sprintf(
-			"SELECT id, session_id, text FROM messages WHERE id IN (%s)",
+			"SELECT id, text FROM messages WHERE id IN (%s)",
 			strings.Join(placeholders, ",")),
 			args...,
 		)
@@ -179,8 +316,8 @@ func (s *Store) MatchNewMessages(messageIDs []int64) (int, error) {
 
 		for rows.Next() {
 			var msgID int64
-			var sessionID, text string
-			if rows.Scan(&msgID, &sessionID, &text) != nil {
+			var text string
+			if rows.Scan(&msgID, &text) != nil {
 				continue
 			}
 
@@ -192,18 +329,63 @@ func (s *Store) MatchNewMessages(messageIDs []int64) (int, error) {
 			// Extract context snippet around match
 			snippet := extractSnippet(text, loc[0], loc[1], 100)
 
-			s.db.Exec(`
-				INSERT INTO watchlist_matches (watchlist_id, message_id, session_id, matched_text, seen)
-				VALUES (?, ?, ?, ?, 0)
-			`, item.ID, msgID, sessionID, snippet)
+			res, err := s.db.Exec(insertMatchSQL, snippet, item.ID, msgID)
+			if err == nil {
+				if n, _ := res.RowsAffected(); n == 1 {
+					id, _ := res.LastInsertId()
+					inserted = append(inserted, id)
+				}
+			}
 			total++
 		}
 		rows.Close()
 	}
 
+	if len(s.onMatch) > 0 && len(inserted) > 0 {
+		if matches, err := s.matchesByID(inserted); err == nil && len(matches) > 0 {
+			for _, fn := range s.onMatch {
+				fn(matches)
+			}
+		}
+	}
 	return total, nil
 }
 
+// matchesByID loads the given matches, oldest first.
+func (s *Store) matchesByID(ids []int64) ([]WatchMatch, error) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT wm.id, wm.watchlist_id, wm.message_id, wm.session_id, wm.matched_text, wm.seen,
+			COALESCE(m.timestamp, ''),
+			COALESCE(s.project, '')
+		FROM watchlist_matches wm
+		LEFT JOIN messages m ON m.id = wm.message_id
+		LEFT JOIN sessions s ON s.session_id = wm.session_id
+		WHERE wm.id IN (%s)
+		ORDER BY wm.id
+	`, strings.Join(placeholders, ",")), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []WatchMatch
+	for rows.Next() {
+		var m WatchMatch
+		if err := rows.Scan(&m.ID, &m.WatchItemID, &m.MessageID, &m.SessionID,
+			&m.MatchedText, &m.Seen, &m.Timestamp, &m.Project); err != nil {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
 // MatchesForWatch returns matches for a specific watchlist item.
 func (s *Store) MatchesForWatch(watchID int64, limit int) ([]WatchMatch, error) {
 	s.mu.RLock()
@@ -237,6 +419,47 @@ func (s *Store) MatchesForWatch(watchID int64, limit int) ([]WatchMatch, error)
 	return matches, nil
 }
 
+// MatchesSince returns up to limit matches with an ID above afterID, oldest
+// first, for clients that follow new matches as they are found.
+func (s *Store) MatchesSince(afterID int64, limit int) ([]WatchMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT wm.id, wm.watchlist_id, wm.message_id, wm.session_id, wm.matched_text, wm.seen,
+			COALESCE(m.timestamp, ''),
+			COALESCE(s.project, '')
+		FROM watchlist_matches wm
+		LEFT JOIN messages m ON m.id = wm.message_id
+		LEFT JOIN sessions s ON s.session_id = wm.session_id
+		WHERE wm.id > ?
+		ORDER BY wm.id
+		LIMIT ?
+	`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []WatchMatch
+	for rows.Next() {
+		var m WatchMatch
+		if err := rows.Scan(&m.ID, &m.WatchItemID, &m.MessageID, &m.SessionID,
+			&m.MatchedText, &m.Seen, &m.Timestamp, &m.Project); err != nil {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// LastMatchID returns the ID of the newest watchlist match, or 0.
+func (s *Store) LastMatchID() int64 {
+	var id int64
+	s.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM watchlist_matches").Scan(&id)
+	return id
+}
+
 // MarkWatchSeen marks all matches for a watchlist item as seen.
 func (s *Store) MarkWatchSeen(watchID int64) error {
 	_, err := s.db.Exec("UPDATE watchlist_matches SET seen = 1 WHERE watchlist_id = ?", watchID)
@@ -265,7 +488,7 @@ func (s *Store) matchAllForWatch(item *WatchItem) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	rows, err := s.db.Query("SELECT id, session_id, text FROM messages")
+	rows, err := s.db.Query("SELECT id, text FROM messages")
 	if err != nil {
 		return
 	}
@@ -277,10 +500,7 @@ func (s *Store) matchAllForWatch(item *WatchItem) {
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`
-		INSERT INTO watchlist_matches (watchlist_id, message_id, session_id, matched_text, seen)
-		VALUES (?, ?, ?, ?, 0)
-	`)
+	stmt, err := tx.Prepare(insertMatchSQL)
 	if err != nil {
 		return
 	}
@@ -289,8 +509,8 @@ func (s *Store) matchAllForWatch(item *WatchItem) {
 	batch := 0
 	for rows.Next() {
 		var msgID int64
-		var sessionID, text string
-		if rows.Scan(&msgID, &sessionID, &text) != nil {
+		var text string
+		if rows.Scan(&msgID, &text) != nil {
 			continue
 		}
 
@@ -300,7 +520,7 @@ func (s *Store) matchAllForWatch(item *WatchItem) {
 		}
 
 		snippet := extractSnippet(text, loc[0], loc[1], 100)
-		stmt.Exec(item.ID, msgID, sessionID, snippet)
+		stmt.Exec(snippet, item.ID, msgID)
 		batch++
 
 		if batch%1000 == 0 {
@@ -312,10 +532,7 @@ func (s *Store) matchAllForWatch(item *WatchItem) {
 			if err != nil {
 				return
 			}
-			stmt, err = tx.Prepare(`
-				INSERT INTO watchlist_matches (watchlist_id, message_id, session_id, matched_text, seen)
-				VALUES (?, ?, ?, ?, 0)
-			`)
+			stmt, err = tx.Prepare(insertMatchSQL)
 			if err != nil {
 				tx.Rollback()
 				return
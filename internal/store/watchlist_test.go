@@ -1,6 +1,8 @@
 package store
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -229,6 +231,29 @@ func TestMatchesForWatch(t *testing.T) {
 	}
 }
 
+func TestMatchesSince(t *testing.T) {
+	s := openTestStore(t)
+	seedTestData(t, s)
+
+	if s.LastMatchID() != 0 {
+		t.Errorf("LastMatchID = %d before any watch", s.LastMatchID())
+	}
+	s.AddWatch("deploy-match", "deploy", "")
+	time.Sleep(200 * time.Millisecond)
+
+	all, err := s.MatchesSince(0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) < 2 || all[len(all)-1].ID != s.LastMatchID() {
+		t.Fatalf("matches = %+v, want oldest first up to LastMatchID", all)
+	}
+	rest, _ := s.MatchesSince(all[0].ID, 100)
+	if len(rest) != len(all)-1 {
+		t.Errorf("MatchesSince(first) = %d matches, want %d", len(rest), len(all)-1)
+	}
+}
+
 func TestMarkWatchSeen(t *testing.T) {
 	s := openTestStore(t)
 	seedTestData(t, s)
@@ -284,6 +309,121 @@ func TestTotalUnseenCount(t *testing.T) {
 	}
 }
 
+func TestSetWatchAction(t *testing.T) {
+	s := openTestStore(t)
+	item, _ := s.AddWatch("prod", "production", "")
+
+	action := WatchAction{Kind: ActionWebhook, Target: "http://127.0.0.1:9000/hook", Limit: 5}
+	if err := s.SetWatchAction(item.ID, action); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.GetWatch(item.ID)
+	if got.Action != action {
+		t.Errorf("action = %+v, want %+v", got.Action, action)
+	}
+	items, _ := s.ListWatches()
+	if items[0].Action != action {
+		t.Errorf("listed action = %+v", items[0].Action)
+	}
+
+	// Pipe actions append to their target, so it must be a named pipe
+	regular := filepath.Join(t.TempDir(), "feed")
+	os.WriteFile(regular, nil, 0o644)
+	for _, bad := range []WatchAction{
+		{Kind: "email", Target: "me@example.com"},
+		{Kind: ActionWebhook, Target: "file:///etc/passwd"},
+		{Kind: ActionCommand, Target: "  "},
+		{Kind: ActionPipe, Target: "/tmp/p", Limit: -1},
+		{Kind: ActionPipe, Target: regular},
+		{Kind: ActionPipe, Target: regular + ".missing"},
+	} {
+		if err := s.SetWatchAction(item.ID, bad); err == nil {
+			t.Errorf("SetWatchAction(%+v) succeeded", bad)
+		}
+	}
+	if err := s.SetWatchAction(999, action); err == nil {
+		t.Error("expected error for unknown watch")
+	}
+
+	s.SetWatchAction(item.ID, WatchAction{})
+	if got, _ := s.GetWatch(item.ID); got.Action.Kind != "" {
+		t.Errorf("action after clearing = %+v", got.Action)
+	}
+}
+
+func TestOnMatch_OnlyNewMatches(t *testing.T) {
+	s := openTestStore(t)
+	var got []WatchMatch
+	s.OnMatch(func(m []WatchMatch) { got = append(got, m...) })
+
+	_, msgIDs := seedTestData(t, s)
+	item, _ := s.AddWatch("deploy", "deploy", "")
+	if len(got) != 0 {
+		t.Fatalf("backfill reached OnMatch: %+v", got)
+	}
+
+	// Already-recorded matches are not passed on again
+	s.MatchNewMessages(msgIDs)
+	if len(got) != 0 {
+		t.Fatalf("existing matches reached OnMatch: %+v", got)
+	}
+
+	s.db.Exec("DELETE FROM watchlist_matches")
+	s.MatchNewMessages(msgIDs)
+	if len(got) < 2 {
+		t.Fatalf("OnMatch got %d matches, want the deploy messages", len(got))
+	}
+	for _, m := range got {
+		if m.WatchItemID != item.ID || m.Project != "TestProject" || m.Timestamp == "" {
+			t.Errorf("match = %+v", m)
+		}
+	}
+}
+
+func TestDeliveries(t *testing.T) {
+	s := openTestStore(t)
+	seedTestData(t, s)
+	item, _ := s.AddWatch("deploy", "deploy", "")
+	matches, _ := s.MatchesForWatch(item.ID, 10)
+	if len(matches) < 2 {
+		t.Fatalf("matches = %d", len(matches))
+	}
+	action := WatchAction{Kind: ActionCommand, Target: "true"}
+
+	if s.Delivered(matches[0]) {
+		t.Error("Delivered before any delivery")
+	}
+	id, err := s.LogDelivery(matches[0], action, DeliveryPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FinishDelivery(id, DeliveryFailed, "exit status 1", 15*time.Millisecond)
+	s.LogDelivery(matches[1], action, DeliveryRateLimited)
+
+	if !s.Delivered(matches[0]) || !s.Delivered(matches[1]) {
+		t.Error("Delivered = false after logging")
+	}
+	// Rate-limited attempts do not count toward the limit
+	if n := s.DeliveryCount(item.ID, time.Now().Add(-time.Hour)); n != 1 {
+		t.Errorf("DeliveryCount = %d, want 1", n)
+	}
+
+	log, err := s.Deliveries(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 2 || log[0].Status != DeliveryRateLimited || log[1].Status != DeliveryFailed {
+		t.Fatalf("log = %+v", log)
+	}
+	if log[1].WatchName != "deploy" || log[1].Error != "exit status 1" || log[1].DurationMs != 15 {
+		t.Errorf("failed delivery = %+v", log[1])
+	}
+
+	if n, _ := s.PruneDeliveries(time.Now().Add(time.Minute)); n != 2 {
+		t.Errorf("pruned %d, want 2", n)
+	}
+}
+
 func TestExtractSnippet(t *testing.T) {
 	tests := []struct {
 		name       string
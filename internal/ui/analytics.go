@@ -0,0 +1,357 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+	"github.com/thinkwright/claude-chronicle/internal/config"
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+// analyticsRange is a selectable reporting window.
+type analyticsRange struct {
+	label string
+	span  time.Duration // 0 = all time
+}
+
+var analyticsRanges = []analyticsRange{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+	{"all", 0},
+}
+
+const analyticsTopN = 8
+
+// AnalyticsModal is a full-screen usage report built from store aggregates.
+type AnalyticsModal struct {
+	visible  bool
+	rangeIdx int
+	scroll   int
+	width    int
+	height   int
+	lines    []string
+	now      time.Time
+	err      error
+
+	hourly   []store.Bucket
+	daily    []store.Bucket
+	tools    []store.RankedItem
+	models   []store.RankedItem
+	projects []store.RankedItem
+	pricing  config.Config
+}
+
+func NewAnalyticsModal() AnalyticsModal {
+	return AnalyticsModal{rangeIdx: 1}
+}
+
+func (a *AnalyticsModal) IsVisible() bool {
+	return a.visible
+}
+
+// Show loads the report for the current range and opens the view.
+func (a *AnalyticsModal) Show(db *store.Store, pricing config.Config) {
+	a.visible = true
+	a.pricing = pricing
+	a.load(db)
+}
+
+func (a *AnalyticsModal) Close() {
+	a.visible = false
+}
+
+func (a *AnalyticsModal) SetSize(w, h int) {
+	a.width = w
+	a.height = h
+	if a.visible {
+		a.renderLines()
+	}
+}
+
+// SetRange switches to range i and reloads.
+func (a *AnalyticsModal) SetRange(db *store.Store, i int) {
+	if i < 0 || i >= len(analyticsRanges) {
+		return
+	}
+	a.rangeIdx = i
+	a.load(db)
+}
+
+// CycleRange moves to the next (dir=1) or previous (dir=-1) range.
+func (a *AnalyticsModal) CycleRange(db *store.Store, dir int) {
+	n := len(analyticsRanges)
+	a.SetRange(db, (a.rangeIdx+dir+n)%n)
+}
+
+func (a *AnalyticsModal) ScrollUp(n int) {
+	a.scroll = max(a.scroll-n, 0)
+}
+
+func (a *AnalyticsModal) ScrollDown(n int) {
+	a.scroll = min(a.scroll+n, max(len(a.lines)-a.contentHeight(), 0))
+}
+
+func (a *AnalyticsModal) since() time.Time {
+	r := analyticsRanges[a.rangeIdx]
+	if r.span == 0 {
+		return time.Time{}
+	}
+	return a.now.Add(-r.span)
+}
+
+func (a *AnalyticsModal) load(db *store.Store) {
+	a.now = time.Now()
+	a.scroll = 0
+	a.err = nil
+	a.hourly, a.daily, a.tools, a.models, a.projects = nil, nil, nil, nil, nil
+	if db == nil {
+		a.renderLines()
+		return
+	}
+
+	since := a.since()
+	var err error
+	if a.hourly, err = db.HourlyUsage(since); err != nil {
+		a.err = err
+	}
+	if a.daily, err = db.DailyUsage(since); err != nil {
+		a.err = err
+	}
+	if a.tools, err = db.TopTools(since, analyticsTopN); err != nil {
+		a.err = err
+	}
+	if a.models, err = db.ModelMix(since); err != nil {
+		a.err = err
+	}
+	if a.projects, err = db.TopProjects(since, analyticsTopN); err != nil {
+		a.err = err
+	}
+	a.renderLines()
+}
+
+func (a *AnalyticsModal) contentHeight() int {
+	// title, range tabs, separator, footer, bottom border
+	return max(a.height-5, 3)
+}
+
+// seriesStart is where charts begin: the range cutoff, or the first
+// recorded activity for all-time.
+func (a *AnalyticsModal) seriesStart(step time.Duration) time.Time {
+	if s := a.since(); !s.IsZero() {
+		return s.Truncate(step)
+	}
+	if len(a.hourly) > 0 {
+		return a.hourly[0].Start.Truncate(step)
+	}
+	return a.now.Truncate(step)
+}
+
+// hourlySeries fills the gaps between hourly buckets with zeros.
+func (a *AnalyticsModal) hourlySeries() []float64 {
+	start := a.seriesStart(time.Hour)
+	n := int(a.now.Sub(start)/time.Hour) + 1
+	values := make([]float64, n)
+	for _, b := range a.hourly {
+		if i := int(b.Start.Sub(start) / time.Hour); i >= 0 && i < n {
+			values[i] += float64(b.Tokens)
+		}
+	}
+	return values
+}
+
+// dailySeries fills the gaps between daily buckets with zeros.
+func (a *AnalyticsModal) dailySeries(value func(store.Bucket) int) []float64 {
+	start := store.StartOfDay(a.seriesStart(time.Hour))
+	end := store.StartOfDay(a.now)
+	var days []time.Time
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	values := make([]float64, len(days))
+	idx := 0
+	for _, b := range a.daily {
+		for idx < len(days) && days[idx].Before(b.Start) {
+			idx++
+		}
+		if idx < len(days) && days[idx].Equal(b.Start) {
+			values[idx] = float64(value(b))
+		}
+	}
+	return values
+}
+
+// binSum condenses values into at most width bins by summing, so a long
+// series is not point-sampled by Sparkline.
+func binSum(values []float64, width int) []float64 {
+	if width <= 0 || len(values) <= width {
+		return values
+	}
+	bins := make([]float64, width)
+	for i, v := range values {
+		bins[i*width/len(values)] += v
+	}
+	return bins
+}
+
+func (a *AnalyticsModal) renderLines() {
+	a.lines = nil
+	innerW := a.width - 4
+	label := lipgloss.NewStyle().Foreground(ColorCyan).Bold(true)
+	dim := DimStyle
+	val := NormalStyle
+
+	if a.err != nil {
+		a.lines = append(a.lines, ErrorStyle.Render("Error: "+a.err.Error()))
+	}
+
+	// Totals
+	var usage claude.Usage
+	var messages, sessions int
+	var cost float64
+	for _, m := range a.models {
+		usage.Add(m.Usage)
+		cost += a.pricing.Cost(m.Name, m.Usage)
+	}
+	for _, b := range a.hourly {
+		messages += b.Messages
+	}
+	for _, b := range a.daily {
+		sessions += b.Sessions
+	}
+	totals := []string{
+		label.Render("TOKENS ") + val.Render(claude.FormatTokens(usage.Total())),
+		label.Render("MSGS ") + val.Render(claude.FormatTokens(messages)),
+		label.Render("NEW SESSIONS ") + val.Render(fmt.Sprintf("%d", sessions)),
+	}
+	if cost > 0 {
+		totals = append(totals, label.Render("COST ")+val.Render(claude.FormatCost(cost)))
+	}
+	a.lines = append(a.lines, strings.Join(totals, dim.Render("  │  ")), "")
+
+	// Time series
+	chartW := max(innerW-30, 10)
+	chart := func(name string, values []float64, color lipgloss.Color, format func(float64) string) {
+		values = binSum(values, chartW)
+		peak := 0.0
+		for _, v := range values {
+			peak = max(peak, v)
+		}
+		spark := lipgloss.NewStyle().Foreground(color).Render(Sparkline(values, len(values)))
+		a.lines = append(a.lines,
+			label.Render(fmt.Sprintf("%-18s", name))+spark+dim.Render("  peak "+format(peak)))
+	}
+	tokens := func(v float64) string { return claude.FormatTokens(int(v)) }
+	count := func(v float64) string { return fmt.Sprintf("%.0f", v) }
+
+	chart("TOKENS / DAY", a.dailySeries(func(b store.Bucket) int { return b.Tokens }), ColorGreen, tokens)
+	chart("TOKENS / HOUR", a.hourlySeries(), ColorCyan, tokens)
+	chart("SESSIONS / DAY", a.dailySeries(func(b store.Bucket) int { return b.Sessions }), ColorYellow, count)
+	a.lines = append(a.lines, "")
+
+	// Breakdowns
+	barW := max(min(innerW-50, 30), 5)
+	ranked := func(title string, items []store.RankedItem, metric func(store.RankedItem) int, detail func(store.RankedItem) string) {
+		a.lines = append(a.lines, label.Render(title))
+		if len(items) == 0 {
+			a.lines = append(a.lines, dim.Render("  no activity in range"), "")
+			return
+		}
+		top := 1
+		for _, it := range items {
+			top = max(top, metric(it))
+		}
+		for _, it := range items {
+			name := it.Name
+			if utf8.RuneCountInString(name) > 22 {
+				name = string([]rune(name)[:21]) + "…"
+			}
+			n := metric(it) * barW / top
+			bar := lipgloss.NewStyle().Foreground(ColorCyanDim).Render(strings.Repeat("█", max(n, 1)))
+			a.lines = append(a.lines, fmt.Sprintf("  %s %s %s %s",
+				val.Render(fmt.Sprintf("%-22s", name)), bar+strings.Repeat(" ", barW-max(n, 1)),
+				val.Render(fmt.Sprintf("%7s", claude.FormatTokens(metric(it)))), dim.Render(detail(it))))
+		}
+		a.lines = append(a.lines, "")
+	}
+
+	ranked("TOP TOOLS", a.tools,
+		func(it store.RankedItem) int { return it.Count },
+		func(it store.RankedItem) string { return "calls" })
+	ranked("MODEL MIX", a.models,
+		func(it store.RankedItem) int { return it.Usage.Total() },
+		func(it store.RankedItem) string {
+			s := fmt.Sprintf("tokens  %d msgs", it.Count)
+			if share := percent(it.Usage.Total(), usage.Total()); share != "" {
+				s += "  " + share
+			}
+			if c := a.pricing.Cost(it.Name, it.Usage); c > 0 {
+				s += "  " + claude.FormatCost(c)
+			}
+			return s
+		})
+	ranked("BUSIEST PROJECTS", a.projects,
+		func(it store.RankedItem) int { return it.Usage.Total() },
+		func(it store.RankedItem) string { return fmt.Sprintf("tokens  %d sessions", it.Count) })
+}
+
+func percent(part, whole int) string {
+	if whole == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.0f%%", float64(part)*100/float64(whole))
+}
+
+// View renders the analytics screen.
+func (a *AnalyticsModal) View() string {
+	if !a.visible {
+		return ""
+	}
+
+	innerW := max(a.width-2, 20)
+	contentH := a.contentHeight()
+
+	bc := lipgloss.NewStyle().Foreground(ColorGreen)
+	tc := lipgloss.NewStyle().Foreground(ColorGreen).Bold(true)
+	dim := lipgloss.NewStyle().Foreground(ColorDim)
+	side := bc.Render("┃")
+	row := func(content string) string {
+		content = truncateToWidth(content, innerW)
+		return side + content + strings.Repeat(" ", max(innerW-visibleLen(content), 0)) + side
+	}
+
+	var rows []string
+
+	title := " ANALYTICS "
+	fillLen := max(innerW-3-utf8.RuneCountInString(title), 0)
+	rows = append(rows, bc.Render("┏━╸")+tc.Render(title)+bc.Render("╺"+strings.Repeat("━", fillLen)+"┓"))
+
+	var tabs []string
+	for i, r := range analyticsRanges {
+		label := fmt.Sprintf(" %d %s ", i+1, r.label)
+		if i == a.rangeIdx {
+			tabs = append(tabs, lipgloss.NewStyle().Foreground(ColorSelect).Bold(true).Render(label))
+		} else {
+			tabs = append(tabs, dim.Render(label))
+		}
+	}
+	rows = append(rows, row("  "+strings.Join(tabs, dim.Render("│"))))
+	rows = append(rows, row(dim.Render("  "+strings.Repeat("─", max(innerW-4, 0)))))
+
+	for i := 0; i < contentH; i++ {
+		content := ""
+		if idx := a.scroll + i; idx < len(a.lines) {
+			content = "  " + a.lines[idx]
+		}
+		rows = append(rows, row(content))
+	}
+
+	rows = append(rows, row(dim.Render("  1-4 / ←→ range  ↑/↓ scroll  Esc close")))
+	rows = append(rows, bc.Render("┗"+strings.Repeat("━", innerW)+"┛"))
+
+	return strings.Join(rows, "\n")
+}
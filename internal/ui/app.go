@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -15,6 +16,7 @@ import (
 	"github.com/mattn/go-runewidth"
 	"github.com/thinkwright/claude-chronicle/internal/claude"
 	"github.com/thinkwright/claude-chronicle/internal/config"
+	"github.com/thinkwright/claude-chronicle/internal/daemon"
 	"github.com/thinkwright/claude-chronicle/internal/store"
 	"github.com/thinkwright/claude-chronicle/internal/watcher"
 )
@@ -56,15 +58,54 @@ func tailTickCmd() tea.Cmd {
 	})
 }
 
+// leaseMsg reports this instance's role after a writer lease check.
+type leaseMsg struct {
+	writer bool
+	holder *store.LeaseHolder // the process writing the index, when not this one
+}
+
+// leaseCmd checks the writer lease again in two seconds.
+func leaseCmd(db *store.Store) tea.Cmd {
+	return tea.Tick(2*time.Second, func(time.Time) tea.Msg {
+		return checkLease(db)
+	})
+}
+
+// checkLease takes the writer lease when it is free, and hands it over
+// when a daemon has started: the daemon gets to write the index whenever
+// one is running.
+func checkLease(db *store.Store) leaseMsg {
+	_, daemonUp := daemon.Running(daemon.PIDFile())
+	switch {
+	case db.IsWriter() && daemonUp:
+		db.ReleaseWriter()
+	case !db.IsWriter() && !daemonUp:
+		db.TryAcquireWriter()
+	}
+	msg := leaseMsg{writer: db.IsWriter()}
+	if !msg.writer {
+		msg.holder, _ = db.WriterLease()
+	}
+	return msg
+}
+
+// indexStamp changes whenever a file is indexed or dropped from the index.
+type indexStamp struct {
+	lastIndexed int64
+	files       int
+}
+
 type Model struct {
 	projects           ProjectList
 	sessions           SessionList
 	detail             DetailPane
 	search             SearchOverlay
 	filterBar          FilterBar
+	saved              SavedPicker
 	watchlist          WatchlistPane
 	memory             MemoryModal
 	hooks              HooksModal
+	analytics          AnalyticsModal
 	store              *store.Store
 	focus              pane
 	width              int
@@ -80,9 +121,16 @@ type Model struct {
 	settingsPathError  string
 	settingsConfirmDel bool
 	confirmQuit        bool
-	indexing           bool   // true while background index is running
-	indexStatus        string // status text for status bar
-	activeWatchName    string // non-empty when viewing watchlist matches
+	confirmExport      bool               // export format prompt is open
+	notice             string             // one-off status bar message, cleared on the next key
+	zPrefix            bool               // z pressed in the detail pane; o or c follows
+	indexing           bool               // true while background index is running
+	indexStatus        string             // status text for status bar
+	activeWatchName    string             // non-empty when viewing watchlist matches
+	reader             bool               // another process writes the index; read what it writes
+	writer             *store.LeaseHolder // that process, while reader
+	readStamp          indexStamp         // index state when last polled as a reader
+	watching           bool               // a watcher.Watch is pending
 }
 
 func NewModel(db *store.Store) Model {
@@ -101,10 +149,12 @@ func NewModel(db *store.Store) Model {
 		sessions:          NewSessionList(),
 		detail:            NewDetailPane(),
 		search:            NewSearchOverlay(),
+		saved:             NewSavedPicker(),
 		filterBar:         NewFilterBar(),
 		watchlist:         NewWatchlistPane(),
 		memory:            NewMemoryModal(),
 		hooks:             NewHooksModal(),
+		analytics:         NewAnalyticsModal(),
 		store:             db,
 		focus:             paneProjects,
 		cfg:               cfg,
@@ -114,11 +164,93 @@ func NewModel(db *store.Store) Model {
 	if cfg.WatchlistVisible {
 		m.watchlist.Show()
 	}
+	m.detail.SetPricing(cfg)
+	if db != nil {
+		m.detail.SetTextMatcher(db.TextMatches)
+	}
+
+	// Only one clog process writes the index; when another one is, read
+	// what it writes
+	if db != nil {
+		lease := checkLease(db)
+		m.reader, m.writer = !lease.writer, lease.holder
+	}
+	if m.reader {
+		m.indexing = false
+		m.readStamp = m.currentStamp()
+		m.indexStatus = m.readerStatus()
+	} else {
+		m.watching = true
+	}
 	return m
 }
 
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(tickCmd(), tailTickCmd(), watcher.Watch(m.cfg.ProjectPaths), m.indexAllCmd())
+	cmds := []tea.Cmd{tickCmd(), tailTickCmd()}
+	if m.store != nil {
+		cmds = append(cmds, leaseCmd(m.store))
+	}
+	if !m.reader {
+		cmds = append(cmds, watcher.Watch(m.cfg.ProjectPaths), m.indexAllCmd())
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m Model) currentStamp() indexStamp {
+	return indexStamp{lastIndexed: m.store.LastIndexedAt(), files: m.store.FileCount()}
+}
+
+func (m Model) readerStatus() string {
+	return fmt.Sprintf("%d files  %s msgs", m.store.FileCount(), claude.FormatTokens(m.store.MessageCount()))
+}
+
+// applyLease starts or stops indexing as the writer lease moves, and
+// reloads a reader's views when the writer has indexed something.
+func (m Model) applyLease(msg leaseMsg) (tea.Model, tea.Cmd) {
+	cmds := []tea.Cmd{leaseCmd(m.store)}
+	wasReader := m.reader
+	m.reader, m.writer = !msg.writer, msg.holder
+	switch {
+	case wasReader && !m.reader:
+		m.notice = "LEASE FREE — indexing here"
+		m.indexStatus = ""
+		m.loadProjects()
+		if !m.watching {
+			m.watching = true
+			cmds = append(cmds, watcher.Watch(m.cfg.ProjectPaths))
+		}
+		cmds = append(cmds, m.indexChangedCmd())
+	case !wasReader && m.reader:
+		m.notice = fmt.Sprintf("HANDED INDEXING TO %s", m.writerName())
+		m.readStamp = m.currentStamp()
+		m.indexStatus = m.readerStatus()
+	case m.reader:
+		if stamp := m.currentStamp(); stamp != m.readStamp {
+			m.readStamp = stamp
+			m.loadProjects()
+			m.refreshWatchlist()
+			m.indexStatus = m.readerStatus()
+		}
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// writerName describes the process writing the index while this one reads.
+func (m Model) writerName() string {
+	if m.writer == nil {
+		return "another clog"
+	}
+	return m.writer.String()
+}
+
+// readOnly reports whether another process writes the index, telling the
+// user so.
+func (m *Model) readOnly(action string) bool {
+	if !m.reader {
+		return false
+	}
+	m.notice = fmt.Sprintf("READ-ONLY — %s is indexing; %s", m.writerName(), action)
+	return true
 }
 
 func (m Model) indexAllCmd() tea.Cmd {
@@ -173,6 +305,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.layoutPanes()
 		m.memory.SetSize(m.width, m.height)
 		m.hooks.SetSize(m.width, m.height)
+		m.analytics.SetSize(m.width, m.height)
 		if firstReady {
 			m.loadProjects()
 		}
@@ -186,9 +319,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.detail.Refresh()
 		return m, tailTickCmd()
 
+	case leaseMsg:
+		return m.applyLease(msg)
+
 	case indexDoneMsg:
 		m.indexing = false
-		if msg.err != nil {
+		m.refreshSessionCosts()
+		m.refreshPins()
+		m.detail.ReloadFilters()
+		if m.projects.SelectedSearch() != nil && m.activeWatchName == "" {
+			m.doSelectProject()
+		}
+		if errors.Is(msg.err, store.ErrReadOnly) {
+			// Lost the lease mid-pass; the next lease check catches up
+			m.reader = true
+			m.indexStatus = m.readerStatus()
+		} else if msg.err != nil {
 			m.indexStatus = fmt.Sprintf("INDEX ERR: %v", msg.err)
 		} else {
 			m.indexStatus = fmt.Sprintf("INDEXED %d files  %s msgs",
@@ -196,23 +342,45 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case exportDoneMsg:
+		if msg.err != nil {
+			m.notice = fmt.Sprintf("EXPORT ERR: %v", msg.err)
+		} else {
+			m.notice = "EXPORTED " + msg.path
+		}
+		return m, nil
+
 	case watcher.RefreshMsg:
+		m.watching = false
 		m.loadProjects()
+		if m.reader {
+			return m, nil
+		}
+		m.watching = true
 		return m, tea.Batch(watcher.Watch(m.cfg.ProjectPaths), m.indexChangedCmd())
 
 	case tea.KeyMsg:
 		if m.confirmQuit {
 			return m.handleConfirmQuit(msg)
 		}
+		if m.confirmExport {
+			return m.handleExportKey(msg)
+		}
 		if m.memory.IsVisible() {
 			return m.handleMemoryKey(msg)
 		}
 		if m.hooks.IsVisible() {
 			return m.handleHooksKey(msg)
 		}
+		if m.analytics.IsVisible() {
+			return m.handleAnalyticsKey(msg)
+		}
 		if m.showSettings {
 			return m.handleSettingsKey(msg)
 		}
+		if m.saved.IsOpen() {
+			return m.handleSavedKey(msg)
+		}
 		if m.search.IsActive() {
 			return m.handleSearchKey(msg)
 		}
@@ -271,6 +439,28 @@ func (m Model) handleHooksKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m Model) handleAnalyticsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key := msg.String(); key {
+	case "esc", "A":
+		m.analytics.Close()
+	case "up", "k":
+		m.analytics.ScrollUp(3)
+	case "down", "j":
+		m.analytics.ScrollDown(3)
+	case "pgup":
+		m.analytics.ScrollUp(m.height / 2)
+	case "pgdown":
+		m.analytics.ScrollDown(m.height / 2)
+	case "left", "h", "shift+tab":
+		m.analytics.CycleRange(m.store, -1)
+	case "right", "l", "tab":
+		m.analytics.CycleRange(m.store, 1)
+	case "1", "2", "3", "4":
+		m.analytics.SetRange(m.store, int(key[0]-'1'))
+	}
+	return m, nil
+}
+
 // settingsItemCount returns the total number of navigable items in settings.
 // Layout: [reindex, rebuild, ...paths, add-path]
 func (m Model) settingsItemCount() int {
@@ -313,10 +503,16 @@ func (m Model) handleSettingsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		switch {
 		case m.settingsCursor == 0: // reindex
 			m.showSettings = false
+			if m.readOnly("it keeps the index current") {
+				return m, nil
+			}
 			m.indexing = true
 			return m, m.indexAllCmd()
 		case m.settingsCursor == 1: // rebuild
 			m.showSettings = false
+			if m.readOnly("close it to rebuild") {
+				return m, nil
+			}
 			m.indexing = true
 			return m, m.rebuildIndexCmd()
 		case m.settingsCursor == maxIdx: // add path
@@ -329,10 +525,16 @@ func (m Model) handleSettingsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 	case "r":
 		m.showSettings = false
+		if m.readOnly("it keeps the index current") {
+			return m, nil
+		}
 		m.indexing = true
 		return m, m.indexAllCmd()
 	case "R":
 		m.showSettings = false
+		if m.readOnly("close it to rebuild") {
+			return m, nil
+		}
 		m.indexing = true
 		return m, m.rebuildIndexCmd()
 	case "a":
@@ -385,6 +587,10 @@ func (m Model) handleSettingsAddPath(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.settingsPathError = ""
 		config.Save(m.cfg)
 		m.loadProjects()
+		if m.readOnly("restart it to index the new path") {
+			return m, nil
+		}
+		m.watching = true
 		return m, tea.Batch(watcher.Watch(m.cfg.ProjectPaths), m.indexChangedCmd())
 	default:
 		m.settingsPathError = ""
@@ -407,6 +613,10 @@ func (m Model) handleSettingsDeleteConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd)
 			config.Save(m.cfg)
 			m.loadProjects()
 			m.settingsConfirmDel = false
+			if m.readOnly("restart it to drop the path") {
+				return m, nil
+			}
+			m.watching = true
 			return m, tea.Batch(watcher.Watch(m.cfg.ProjectPaths), m.indexChangedCmd())
 		}
 		m.settingsConfirmDel = false
@@ -441,6 +651,14 @@ func (m Model) handleSearchKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.search.CycleScope()
 		m.doSearch()
 		return m, nil
+	case "ctrl+t":
+		m.search.CycleMode()
+		m.doSearch()
+		return m, nil
+	case "ctrl+g":
+		m.search.ToggleGrouped()
+		m.doSearch()
+		return m, nil
 	case "enter":
 		query := m.search.Value()
 		if m.search.HasResults() {
@@ -459,6 +677,14 @@ func (m Model) handleSearchKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case "down":
 		m.search.ResultDown()
+		if m.search.WantsMore() {
+			m.searchPage(m.search.Value(), m.search.Next())
+		}
+		return m, nil
+	case "ctrl+s":
+		return m.startSaveSearch(m.search.Value())
+	case "ctrl+o":
+		m.openSavedPicker()
 		return m, nil
 	}
 
@@ -477,8 +703,14 @@ func (m Model) handleFilterKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.filterBar.CloseEditor()
 		return m, nil
 	case "enter":
-		m.filterBar.ApplyFromInput()
-		m.doApplyFilters()
+		if m.filterBar.ApplyFromInput() {
+			m.doApplyFilters()
+		}
+		return m, nil
+	case "ctrl+s":
+		return m.startSaveSearch(m.filterBar.Value())
+	case "ctrl+o":
+		m.openSavedPicker()
 		return m, nil
 	default:
 		cmd := m.filterBar.UpdateInput(msg)
@@ -486,6 +718,134 @@ func (m Model) handleFilterKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 }
 
+// startSaveSearch prompts for a name to save the query in the search
+// overlay or filter editor under.
+func (m Model) startSaveSearch(query string) (tea.Model, tea.Cmd) {
+	if m.store == nil {
+		return m, nil
+	}
+	fs := store.Parse(query)
+	if fs.Err != nil {
+		m.notice = "CANNOT SAVE: " + fs.Err.Error()
+		return m, nil
+	}
+	if fs.IsEmpty() {
+		m.notice = "NOTHING TO SAVE"
+		return m, nil
+	}
+	m.saved.StartSave(query)
+	return m, textinput.Blink
+}
+
+func (m *Model) openSavedPicker() {
+	if m.store == nil {
+		return
+	}
+	list, err := m.store.ListSavedSearches()
+	if err != nil {
+		m.notice = "SAVED SEARCHES: " + err.Error()
+		return
+	}
+	m.saved.Open(list)
+}
+
+// handleSavedKey drives the saved search picker. Picking a search puts its
+// query into whichever of the search overlay or filter editor opened it.
+func (m Model) handleSavedKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.saved.IsNaming() {
+		switch msg.String() {
+		case "esc":
+			m.saved.CancelNaming()
+		case "enter":
+			name, query, id := m.saved.FinishNaming()
+			var err error
+			if id != 0 {
+				err = m.store.RenameSavedSearch(id, name)
+			} else {
+				_, err = m.store.SaveSearch(name, query)
+			}
+			if err != nil {
+				m.notice = "CAN'T SAVE: " + err.Error()
+			} else if id == 0 {
+				m.notice = "SAVED " + strings.TrimSpace(name)
+			}
+			m.refreshSaved()
+		default:
+			return m, m.saved.UpdateInput(msg)
+		}
+		return m, nil
+	}
+
+	ss := m.saved.Selected()
+	switch msg.String() {
+	case "esc":
+		m.saved.Close()
+	case "up", "k":
+		m.saved.Up()
+	case "down", "j":
+		m.saved.Down()
+	case "enter":
+		if ss == nil {
+			return m, nil
+		}
+		m.saved.Close()
+		if m.search.IsActive() {
+			m.search.SetValue(ss.Query)
+			m.search.input.CursorEnd()
+			m.doSearch()
+		} else {
+			m.filterBar.SetValue(ss.Query)
+			m.filterBar.input.CursorEnd()
+		}
+	case "p":
+		if ss != nil {
+			m.store.PinSavedSearch(ss.ID, !ss.Pinned)
+			m.refreshSaved()
+		}
+	case "r":
+		if ss != nil {
+			m.saved.StartRename()
+			return m, textinput.Blink
+		}
+	case "d":
+		if ss != nil {
+			m.store.DeleteSavedSearch(ss.ID)
+			m.notice = "DELETED " + ss.Name
+			m.refreshSaved()
+		}
+	}
+	return m, nil
+}
+
+// refreshSaved reloads the saved searches into the picker and the pins.
+func (m *Model) refreshSaved() {
+	if list, err := m.store.ListSavedSearches(); err == nil {
+		m.saved.SetItems(list)
+	}
+	m.refreshPins()
+}
+
+// refreshPins reloads the pinned saved searches in the project pane and
+// counts the sessions each finds.
+func (m *Model) refreshPins() {
+	if m.store == nil {
+		return
+	}
+	list, err := m.store.ListSavedSearches()
+	if err != nil {
+		return
+	}
+	var pins []PinnedSearch
+	for _, ss := range list {
+		if !ss.Pinned {
+			continue
+		}
+		n, _ := m.store.CountSessions(ss.Query)
+		pins = append(pins, PinnedSearch{SavedSearch: ss, Count: n})
+	}
+	m.projects.SetPins(pins)
+}
+
 func (m Model) handleWatchEditKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "esc":
@@ -529,6 +889,18 @@ func (m Model) handleWatchDeleteConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.notice = ""
+	if m.zPrefix {
+		m.zPrefix = false
+		switch msg.String() {
+		case "o":
+			m.notice = m.detail.ExpandAll()
+			return m, nil
+		case "c":
+			m.notice = m.detail.CollapseAll()
+			return m, nil
+		}
+	}
 	switch msg.String() {
 	case "ctrl+c":
 		return m, tea.Quit
@@ -559,6 +931,10 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.hooks.SetSize(m.width, m.height)
 		m.hooks.Show(projName, sources)
 
+	case "A":
+		m.analytics.SetSize(m.width, m.height)
+		m.analytics.Show(m.store, m.cfg)
+
 	case "esc":
 		// Clear search highlights and restore session list
 		m.detail.ClearSearch()
@@ -644,6 +1020,44 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.detail.ScrollToTop()
 		}
 
+	case "e":
+		if m.focus == paneDetail && m.detail.session != nil {
+			m.confirmExport = true
+		}
+
+	case "t":
+		if m.focus == paneDetail {
+			m.detail.ToggleThinking()
+		}
+
+	case "b":
+		if m.focus == paneDetail {
+			m.notice = m.detail.CycleBranch()
+		}
+
+	case "x":
+		if m.focus == paneDetail {
+			m.notice = m.detail.ToggleSubagent()
+		}
+
+	case "X":
+		if m.focus == paneDetail {
+			m.notice = m.detail.ToggleAllSubagents()
+		}
+
+	case "]":
+		if m.focus == paneDetail {
+			m.detail.MoveCursor(1)
+		}
+
+	case "[":
+		if m.focus == paneDetail {
+			m.detail.MoveCursor(-1)
+		}
+
+	case "z":
+		m.zPrefix = m.focus == paneDetail
+
 	case "n":
 		m.detail.NextMatch()
 
@@ -693,6 +1107,8 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		case paneWatchlist:
 			m.doSelectWatchlist()
 			m.focus = paneSessions
+		case paneDetail:
+			m.notice = m.detail.ToggleMessage()
 		}
 	}
 
@@ -719,10 +1135,15 @@ func (m *Model) loadProjects() {
 		return
 	}
 	m.projects.SetProjects(projects)
+	m.refreshPins()
 	m.doSelectProject()
 }
 
 func (m *Model) doSelectProject() {
+	if pin := m.projects.SelectedSearch(); pin != nil {
+		m.doSelectPin(pin)
+		return
+	}
 	proj := m.projects.Selected()
 	if proj == nil {
 		return
@@ -734,6 +1155,49 @@ func (m *Model) doSelectProject() {
 	m.activeWatchName = ""
 	m.allSessions = sessions
 	m.doFilterSessions()
+	m.refreshSessionCosts()
+}
+
+// doSelectPin lists the sessions a pinned search finds, across projects.
+func (m *Model) doSelectPin(pin *PinnedSearch) {
+	if m.store == nil {
+		return
+	}
+	sessions, err := m.store.SearchSessions(pin.Query, "")
+	if err != nil {
+		return
+	}
+	m.activeWatchName = ""
+	m.allSessions = sessions
+	m.doFilterSessions()
+	m.refreshSessionCosts()
+}
+
+// refreshSessionCosts prices the indexed token usage of the sessions in
+// view, including their subagents'. Watchlist views span projects, so they
+// load usage for all of them.
+func (m *Model) refreshSessionCosts() {
+	if m.store == nil {
+		return
+	}
+	project := ""
+	if proj := m.projects.Selected(); proj != nil && m.activeWatchName == "" {
+		project = proj.Name
+	}
+	usage, err := m.store.SessionUsage(project)
+	if err != nil {
+		return
+	}
+	costs := make(map[string]float64, len(usage))
+	tokens := make(map[string]int, len(usage))
+	for sessionID, models := range usage {
+		for _, mu := range models {
+			costs[sessionID] += m.cfg.Cost(mu.Model, mu.Usage)
+			tokens[sessionID] += mu.Total()
+		}
+	}
+	m.sessions.SetCosts(costs)
+	m.sessions.SetTokens(tokens)
 }
 
 func (m *Model) doSelectWatchlist() {
@@ -767,6 +1231,7 @@ func (m *Model) doSelectWatchlist() {
 	m.activeWatchName = item.Name
 	m.allSessions = sessions
 	m.doFilterSessions()
+	m.refreshSessionCosts()
 	m.store.MarkWatchSeen(item.ID)
 	m.refreshWatchlist()
 }
@@ -775,6 +1240,8 @@ func (m *Model) doFilterSessions() {
 	name := ""
 	if m.activeWatchName != "" {
 		name = "WATCH: " + m.activeWatchName
+	} else if pin := m.projects.SelectedSearch(); pin != nil {
+		name = "★ " + pin.Name
 	} else if proj := m.projects.Selected(); proj != nil {
 		name = proj.Name
 	}
@@ -796,7 +1263,7 @@ func (m *Model) doFilterSessions() {
 func (m *Model) doSearch() {
 	query := m.search.Value()
 	if query == "" {
-		m.search.SetResults(nil, 0)
+		m.search.SetResults(nil, "")
 		m.doFilterSessions()
 		return
 	}
@@ -807,14 +1274,18 @@ func (m *Model) doSearch() {
 		return
 	}
 
+	mode := m.search.Mode()
 	switch m.search.Scope() {
 	case ScopeLocal:
 		// Search within current session
 		sess := m.sessions.Selected()
 		if sess != nil {
-			results, err := m.store.SearchInSession(sess.SessionID, query)
+			results, err := m.store.SearchInSessionMode(sess.SessionID, query, mode)
 			if err == nil {
-				m.search.SetResults(results, len(results))
+				m.search.SetResults(results, "")
+				m.search.SetCounts(len(results), 1)
+			} else {
+				m.search.SetError(err)
 			}
 		}
 	case ScopeProject:
@@ -824,20 +1295,11 @@ func (m *Model) doSearch() {
 		if proj != nil {
 			projectName = proj.Name
 		}
-		results, err := m.store.Search(query, 50)
-		if err == nil {
-			// Filter results to current project
-			var filtered []store.SearchResult
-			for _, r := range results {
-				if projectName == "" || r.Project == projectName {
-					filtered = append(filtered, r)
-				}
-			}
-			count := m.store.MatchCount(query)
-			m.search.SetResults(filtered, count)
+		if m.searchPage(query, "") {
+			m.search.SetCounts(m.store.CountMatches(query, m.searchOptions("")))
 		}
 		// Also filter session list
-		sessions, err := m.store.SearchSessions(query, projectName)
+		sessions, err := m.store.SearchSessionsMode(query, projectName, mode)
 		if err == nil && len(sessions) > 0 {
 			name := ""
 			if proj != nil {
@@ -847,18 +1309,60 @@ func (m *Model) doSearch() {
 		}
 	case ScopeGlobal:
 		// Search across all projects
-		results, err := m.store.Search(query, 50)
-		if err == nil {
-			count := m.store.MatchCount(query)
-			m.search.SetResults(results, count)
+		if m.searchPage(query, "") {
+			m.search.SetCounts(m.store.CountMatches(query, m.searchOptions("")))
 		}
-		sessions, err := m.store.SearchSessions(query, "")
+		sessions, err := m.store.SearchSessionsMode(query, "", mode)
 		if err == nil && len(sessions) > 0 {
 			m.sessions.SetSessions(sessions, "ALL PROJECTS")
 		}
 	}
 }
 
+// searchOptions returns the store options for the overlay's scope and mode,
+// starting from cursor. The selected project's matches rank higher.
+func (m *Model) searchOptions(cursor string) store.SearchOptions {
+	opts := store.SearchOptions{Mode: m.search.Mode(), Cursor: cursor}
+	if proj := m.projects.Selected(); proj != nil {
+		opts.Boost = proj.Name
+		if m.search.Scope() == ScopeProject {
+			opts.Project = proj.Name
+		}
+	}
+	return opts
+}
+
+// searchPage loads a page of project or global results into the search
+// overlay: the first page for an empty cursor, the next one otherwise. It
+// reports whether the query could be searched.
+func (m *Model) searchPage(query, cursor string) bool {
+	opts := m.searchOptions(cursor)
+	if m.search.Grouped() {
+		groups, next, err := m.store.SearchBySession(query, opts)
+		switch {
+		case err != nil:
+			m.search.SetError(err)
+			return false
+		case cursor == "":
+			m.search.SetGroups(groups, next)
+		default:
+			m.search.AddGroups(groups, next)
+		}
+		return true
+	}
+	results, next, err := m.store.SearchPage(query, opts)
+	switch {
+	case err != nil:
+		m.search.SetError(err)
+		return false
+	case cursor == "":
+		m.search.SetResults(results, next)
+	default:
+		m.search.AddResults(results, next)
+	}
+	return true
+}
+
 func (m *Model) navigateToResult(r *store.SearchResult) {
 	// Find the session and load it
 	if r.SessionID == "" {
@@ -869,14 +1373,60 @@ func (m *Model) navigateToResult(r *store.SearchResult) {
 	for i, sess := range m.allSessions {
 		if sess.SessionID == r.SessionID {
 			m.sessions.cursor = i
-			messages, err := claude.LoadMessages(sess.FullPath)
-			if err == nil {
-				m.detail.SetSession(&m.allSessions[i], messages)
+			if m.openSession(&m.allSessions[i]) {
+				m.detail.Reveal(r.UUID)
+				m.detail.ExpandSubagent(r.Subagent)
 				m.focus = paneDetail
 			}
 			return
 		}
 	}
+
+	// Not in the current list: another project, or an archived session
+	// whose file is gone.
+	if m.store == nil {
+		return
+	}
+	found, err := m.store.SessionsByIDs([]string{r.SessionID})
+	if err != nil || len(found) == 0 {
+		m.notice = "SESSION NOT FOUND " + r.SessionID
+		return
+	}
+	if m.openSession(&found[0]) {
+		m.detail.Reveal(r.UUID)
+		m.detail.ExpandSubagent(r.Subagent)
+		m.focus = paneDetail
+	}
+}
+
+// openSession shows a session in the detail pane, reading its JSONL file or,
+// when the file is gone, the indexed copy. Returns false if neither loads.
+func (m *Model) openSession(sess *claude.SessionEntry) bool {
+	messages, err := claude.LoadMessages(sess.FullPath)
+	if err != nil && m.store != nil {
+		if indexed, ierr := m.store.SessionMessages(sess.SessionID); ierr == nil && len(indexed) > 0 {
+			sess.Missing = true
+			m.notice = "SOURCE MISSING: showing indexed text"
+			messages, err = indexed, nil
+		}
+	}
+	if err != nil {
+		m.notice = "CANNOT OPEN SESSION: " + err.Error()
+		return false
+	}
+	m.detail.SetProject(m.sessionProject(sess))
+	m.detail.SetSession(sess, messages)
+	return true
+}
+
+// sessionProject returns the name the index knows sess's project by.
+func (m *Model) sessionProject(sess *claude.SessionEntry) string {
+	if proj := m.projects.Selected(); proj != nil && proj.DataDir != "" &&
+		strings.HasPrefix(sess.FullPath, proj.DataDir+string(filepath.Separator)) {
+		return proj.Name
+	}
+	// Sessions found through the index carry the project name here
+	return sess.ProjectPath
 }
 
 func (m *Model) doApplyFilters() {
@@ -902,11 +1452,7 @@ func (m *Model) doSelectSession() {
 	if sess == nil {
 		return
 	}
-	messages, err := claude.LoadMessages(sess.FullPath)
-	if err != nil {
-		return
-	}
-	m.detail.SetSession(sess, messages)
+	m.openSession(sess)
 }
 
 func (m *Model) layoutPanes() {
@@ -947,6 +1493,7 @@ func (m *Model) layoutPanes() {
 
 	m.search.SetWidth(m.width)
 	m.filterBar.SetWidth(m.width)
+	m.saved.SetWidth(m.width)
 }
 
 func (m Model) View() string {
@@ -969,11 +1516,17 @@ func (m Model) View() string {
 	if m.filterBar.HasFilters() || m.filterBar.IsEditing() {
 		extraLines += 1
 	}
+	if m.filterBar.IsEditing() && m.filterBar.Err() != nil {
+		extraLines += 1
+	}
 	bodyH := m.height - extraLines
 
 	var leftParts []string
 
 	sessTitle := fmt.Sprintf("SESSIONS (%s)", strings.ToUpper(m.sessions.ProjectName()))
+	if cost := m.sessions.TotalCost(); cost > 0 {
+		sessTitle = fmt.Sprintf("SESSIONS (%s) %s", strings.ToUpper(m.sessions.ProjectName()), claude.FormatCost(cost))
+	}
 	detailTitle := m.detail.Title()
 
 	if m.watchlist.IsVisible() {
@@ -1030,6 +1583,12 @@ func (m Model) View() string {
 	if m.confirmQuit {
 		return overlayCenter(b.String(), m.renderConfirmQuit(), m.width, m.height)
 	}
+	if m.confirmExport {
+		return overlayCenter(b.String(), m.renderExportPrompt(), m.width, m.height)
+	}
+	if m.saved.IsOpen() {
+		return overlayCenter(b.String(), m.saved.View(), m.width, m.height)
+	}
 	if m.showSettings {
 		return m.renderSettings()
 	}
@@ -1039,6 +1598,9 @@ func (m Model) View() string {
 	if m.hooks.IsVisible() {
 		return m.hooks.View()
 	}
+	if m.analytics.IsVisible() {
+		return m.analytics.View()
+	}
 
 	return b.String()
 }
@@ -1438,6 +2000,11 @@ func (m Model) renderStatusBar() string {
 		}
 	}
 
+	if m.notice != "" {
+		rightParts = append(rightParts, bg.Foreground(ColorCyan).Render(m.notice))
+		rightLen += runewidth.StringWidth(m.notice)
+	}
+
 	// Index status indicator
 	if m.indexing {
 		indexText := "INDEXING..."
@@ -1449,7 +2016,7 @@ func (m Model) renderStatusBar() string {
 	}
 
 	// Stale index nag (>7 days since last full index)
-	if !m.indexing && m.store != nil {
+	if !m.indexing && m.store != nil && !m.reader {
 		age := m.store.IndexAge()
 		if age > 7*24*time.Hour {
 			days := int(age.Hours() / 24)
@@ -1459,6 +2026,16 @@ func (m Model) renderStatusBar() string {
 		}
 	}
 
+	// Whether this instance writes the index or reads another's writes
+	if m.store != nil {
+		modeText, modeColor := "WRITER", ColorGreen
+		if m.reader {
+			modeText, modeColor = "READER · "+m.writerName(), ColorYellow
+		}
+		rightParts = append(rightParts, bg.Foreground(modeColor).Render(modeText))
+		rightLen += runewidth.StringWidth(modeText)
+	}
+
 	if len(rightParts) == 0 {
 		spacerLen := max(m.width-len(leftText), 1)
 		spacer := bg.Render(strings.Repeat(" ", spacerLen))
@@ -1,12 +1,17 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/thinkwright/claude-chronicle/internal/claude"
+	"github.com/thinkwright/claude-chronicle/internal/config"
 	"github.com/thinkwright/claude-chronicle/internal/store"
 )
 
@@ -16,26 +21,122 @@ type DetailPane struct {
 	width        int
 	height       int
 	session      *claude.SessionEntry
-	lines        []string       // pre-rendered lines
-	tailing      bool           // auto-scroll to bottom on new content
-	prevMsgCount int            // track message count for change detection
-	searchQuery  string         // current in-pane search highlight
-	matchLines   []int          // line indices containing matches
-	matchIdx     int            // current position in matchLines
-	filters      []store.Filter // active conversation filters
+	lines        []string         // pre-rendered lines
+	tailing      bool             // auto-scroll to bottom on new content
+	prevEnd      int64            // contentEnd at the last load, for change detection
+	searchQuery  string           // current in-pane search highlight
+	matchLines   []int            // line indices containing matches
+	matchIdx     int              // current position in matchLines
+	filters      *store.FilterSet // active conversation filters; nil when none
+	project      string           // the session's project name, for project: filters
+	pricing      config.Config    // per-model prices for cost display
+	showThinking bool             // expand extended-thinking sections
+
+	// Free text in filters is matched through the index, like search: text
+	// holds which of the session's messages each term matches, looked up
+	// with matchText (see SetTextMatcher).
+	text      store.TextMatches
+	matchText func(*store.FilterSet, string) (store.TextMatches, error)
+
+	// Conversation tree. Only the active branch is shown; branchChoice maps
+	// a fork's uuid to the child the user switched to.
+	tree         *claude.Tree
+	branchChoice map[string]string
+	forkLines    map[int]int // fork message index → line of its branch marker
+
+	// Subagents spawned by Task calls, keyed by agent id. Each can be
+	// expanded inline under its Task call; expanded is keyed by tool call id.
+	subagents map[string]*claude.Subagent
+	expanded  map[string]bool
+	taskLines map[string]int // tool call id → line of its subagent summary
+
+	// Message cursor and per-message expansion. Collapsed messages show a
+	// preview; expanded ones show everything, with tool results read back
+	// from the session file when expanded (see claude.FullText). All three
+	// maps and cursor are keyed by index in messages.
+	cursor    int            // selected message; -1 for none
+	blocks    []msgBlock     // rendered messages in order, set by renderLines
+	open      map[int]bool   // expanded messages
+	fullText  map[int]string // full tool result text of expanded messages
+	fullBytes int            // total size of fullText
 }
 
+// msgBlock is where a message starts in the rendered lines.
+type msgBlock struct {
+	msg  int // index in messages
+	line int // line of the message's header
+}
+
+// Collapsed messages show this many bytes of their text.
+const (
+	assistantPreview  = 500
+	toolResultPreview = 200
+)
+
+// fullTextBudget caps the tool result text held for expanded messages, so
+// expanding everything in a huge session cannot exhaust memory.
+const fullTextBudget = 8 << 20
+
+var (
+	thinkingStyle = DimStyle.Italic(true)
+	cursorStyle   = lipgloss.NewStyle().Foreground(ColorAccent)
+)
+
 func NewDetailPane() DetailPane {
 	return DetailPane{tailing: true}
 }
 
 func (d *DetailPane) SetSession(session *claude.SessionEntry, messages []claude.Message) {
 	d.session = session
-	d.messages = messages
-	d.prevMsgCount = len(messages)
+	d.load(messages)
+	d.loadTextMatches()
+	d.branchChoice = make(map[string]string)
+	d.expanded = make(map[string]bool)
+	d.open = make(map[int]bool)
+	d.fullText = make(map[int]string)
+	d.fullBytes = 0
+	d.cursor = -1
 	d.tailing = true
 	d.renderLines()
 	d.scrollToBottom()
+	if len(d.blocks) > 0 {
+		d.cursor = d.blocks[len(d.blocks)-1].msg
+	}
+}
+
+// load replaces the session's messages and reloads its subagents.
+func (d *DetailPane) load(messages []claude.Message) {
+	d.messages = messages
+	d.tree = claude.BuildTree(messages)
+	d.subagents = claude.LoadSubagents(d.session.FullPath, messages)
+	d.prevEnd = d.contentEnd()
+}
+
+// contentEnd sums how far into their files the session's messages and its
+// subagents' reach. Unlike a message count it also grows while a reply is
+// still streaming, as lines are appended to the last message.
+func (d *DetailPane) contentEnd() int64 {
+	n := lastEnd(d.messages)
+	for _, a := range d.subagents {
+		if a.Path != "" {
+			n += lastEnd(a.Messages)
+		}
+	}
+	return n
+}
+
+func lastEnd(messages []claude.Message) int64 {
+	var end int64
+	for _, m := range messages {
+		end = max(end, m.End)
+	}
+	return end
+}
+
+// SetPricing sets the price table used for per-message and session cost.
+func (d *DetailPane) SetPricing(cfg config.Config) {
+	d.pricing = cfg
+	d.renderLines()
 }
 
 func (d *DetailPane) SetSize(w, h int) {
@@ -46,66 +147,252 @@ func (d *DetailPane) SetSize(w, h int) {
 
 // SetFilters applies conversation-level filters. Messages that don't match
 // are hidden from the rendered view. Pass nil to clear.
-func (d *DetailPane) SetFilters(filters []store.Filter) {
+func (d *DetailPane) SetFilters(filters *store.FilterSet) {
 	d.filters = filters
+	d.loadTextMatches()
 	d.renderLines()
 	if d.tailing {
 		d.scrollToBottom()
+	} else {
+		d.followCursor()
 	}
 }
 
-// HasFilters returns true if conversation filters are active.
-func (d *DetailPane) HasFilters() bool {
-	return len(d.filters) > 0
+// ToggleThinking expands or collapses every thinking section.
+func (d *DetailPane) ToggleThinking() {
+	d.showThinking = !d.showThinking
+	d.renderLines()
+	if d.searchQuery != "" {
+		d.findMatches()
+		d.matchIdx = min(d.matchIdx, max(len(d.matchLines)-1, 0))
+	}
+	if d.tailing {
+		d.scrollToBottom()
+	} else {
+		d.ScrollDown(0)
+	}
 }
 
-// messageMatchesFilters returns true if a message passes all active filters.
-func (d *DetailPane) messageMatchesFilters(msg claude.Message) bool {
-	for _, f := range d.filters {
-		switch f.Field {
-		case store.FilterType:
-			if string(msg.Type) != f.Value {
-				return false
-			}
-		case store.FilterModel:
-			model := claude.FormatModel(msg.Model)
-			if !strings.Contains(model, strings.ToLower(f.Value)) {
-				return false
-			}
-		case store.FilterTool:
-			found := false
-			for _, tc := range msg.ToolCalls {
-				if strings.EqualFold(tc, f.Value) {
-					found = true
-					break
+// visibleMessages returns the indices of the messages on the active branch,
+// plus subagent sidechains, in file order. Sidechains linked to a Task call
+// are left out; they are shown nested under the call instead.
+func (d *DetailPane) visibleMessages() []int {
+	if d.tree == nil {
+		return nil
+	}
+	nested := make(map[string]bool)
+	for _, m := range d.messages {
+		for _, tc := range m.Tools {
+			if a := d.subagents[tc.AgentID]; a != nil && a.Path == "" {
+				for _, sm := range a.Messages {
+					nested[sm.UUID] = true
 				}
 			}
-			if !found {
-				return false
+		}
+	}
+	path := d.tree.Path(d.branchChoice)
+	var visible []int
+	for i, msg := range d.messages {
+		if msg.IsSidechain && !nested[msg.UUID] {
+			visible = append(visible, i)
+		}
+	}
+	visible = append(visible, path...)
+	slices.Sort(visible)
+	return visible
+}
+
+// CycleBranch switches to the next branch at the fork nearest the bottom of
+// the view, and returns a description for the status bar.
+func (d *DetailPane) CycleBranch() string {
+	if d.tree == nil || len(d.forkLines) == 0 {
+		return "NO BRANCHES"
+	}
+	// The last fork above the bottom of the view, else the first one
+	fork, line := -1, -1
+	bottom := d.scroll + d.height
+	for f, l := range d.forkLines {
+		if l <= bottom && l > line {
+			fork, line = f, l
+		}
+	}
+	if fork < 0 {
+		for f, l := range d.forkLines {
+			if fork < 0 || l < line {
+				fork, line = f, l
 			}
-		case store.FilterTokens:
-			// Parse threshold
-			var threshold int
-			fmt.Sscanf(f.Value, "%d", &threshold)
-			total := msg.InputTokens + msg.OutputTokens
-			switch f.Op {
-			case store.OpGreaterThan:
-				if total <= threshold {
-					return false
-				}
-			case store.OpLessThan:
-				if total >= threshold {
-					return false
-				}
-			default:
-				if total != threshold {
-					return false
+		}
+	}
+
+	kids := d.tree.Children(fork)
+	path := d.tree.Path(d.branchChoice)
+	cur := 0
+	for i, c := range kids {
+		if slices.Contains(path, c) {
+			cur = i
+		}
+	}
+	next := kids[(cur+1)%len(kids)]
+	if d.branchChoice == nil {
+		d.branchChoice = make(map[string]string)
+	}
+	d.branchChoice[d.messages[fork].UUID] = d.messages[next].UUID
+
+	d.renderLines()
+	d.tailing = false
+	if l, ok := d.forkLines[fork]; ok {
+		d.scrollToLine(l)
+	}
+	return fmt.Sprintf("BRANCH %d/%d", (cur+1)%len(kids)+1, len(kids))
+}
+
+// Reveal switches branches so the message with the given uuid is shown.
+func (d *DetailPane) Reveal(uuid string) {
+	if d.tree == nil || uuid == "" {
+		return
+	}
+	if i, ok := d.tree.Index(uuid); ok {
+		if d.branchChoice == nil {
+			d.branchChoice = make(map[string]string)
+		}
+		d.tree.Reveal(i, d.branchChoice)
+		d.renderLines()
+	}
+}
+
+// ToggleSubagent expands or collapses the subagent of the Task call nearest
+// the bottom of the view, and returns a description for the status bar.
+func (d *DetailPane) ToggleSubagent() string {
+	if len(d.taskLines) == 0 {
+		return "NO SUBAGENTS"
+	}
+	// The last call above the bottom of the view, else the first one
+	call, line := "", -1
+	bottom := d.scroll + d.height
+	for id, l := range d.taskLines {
+		if l <= bottom && l > line {
+			call, line = id, l
+		}
+	}
+	if call == "" {
+		for id, l := range d.taskLines {
+			if call == "" || l < line {
+				call, line = id, l
+			}
+		}
+	}
+
+	d.expanded[call] = !d.expanded[call]
+	d.renderLines()
+	d.tailing = false
+	d.scrollToLine(d.taskLines[call])
+	if d.expanded[call] {
+		return "SUBAGENT EXPANDED"
+	}
+	return "SUBAGENT COLLAPSED"
+}
+
+// ToggleAllSubagents expands every subagent, or collapses them all when they
+// already are.
+func (d *DetailPane) ToggleAllSubagents() string {
+	if len(d.taskLines) == 0 {
+		return "NO SUBAGENTS"
+	}
+	expand := false
+	for id := range d.taskLines {
+		if !d.expanded[id] {
+			expand = true
+		}
+	}
+	for id := range d.taskLines {
+		d.expanded[id] = expand
+	}
+	d.renderLines()
+	if d.tailing {
+		d.scrollToBottom()
+	} else {
+		d.ScrollDown(0)
+	}
+	if expand {
+		return fmt.Sprintf("%d SUBAGENTS EXPANDED", len(d.taskLines))
+	}
+	return "SUBAGENTS COLLAPSED"
+}
+
+// ExpandSubagent expands the Task call whose subagent transcript is the
+// indexed session sessionID, and scrolls to it.
+func (d *DetailPane) ExpandSubagent(sessionID string) {
+	for _, a := range d.subagents {
+		if a.Path == "" || strings.TrimSuffix(filepath.Base(a.Path), ".jsonl") != sessionID {
+			continue
+		}
+		for _, m := range d.messages {
+			for _, tc := range m.Tools {
+				if tc.AgentID == a.AgentID {
+					d.expanded[tc.ID] = true
+					d.renderLines()
+					d.tailing = false
+					d.scrollToLine(d.taskLines[tc.ID])
+					return
 				}
 			}
 		}
-		// FilterBranch, FilterProject, FilterAge don't apply at message level — skip
 	}
-	return true
+}
+
+// HasFilters returns true if conversation filters are active.
+func (d *DetailPane) HasFilters() bool {
+	return d.filters != nil
+}
+
+// SetTextMatcher sets how free text in filters is matched: through the
+// index, as Store.TextMatches does, so the pane hides the same messages a
+// search would leave out. Without one, free text matches nothing.
+func (d *DetailPane) SetTextMatcher(match func(*store.FilterSet, string) (store.TextMatches, error)) {
+	d.matchText = match
+}
+
+// loadTextMatches looks up which of the session's messages the filters'
+// free text matches.
+func (d *DetailPane) loadTextMatches() {
+	d.text = nil
+	if d.filters == nil || d.session == nil || d.matchText == nil {
+		return
+	}
+	d.text, _ = d.matchText(d.filters, d.session.SessionID)
+}
+
+// ReloadFilters matches the filters' free text again, once the index has
+// caught up with messages the pane already shows.
+func (d *DetailPane) ReloadFilters() {
+	if d.filters == nil {
+		return
+	}
+	d.loadTextMatches()
+	d.renderLines()
+}
+
+// SetProject names the project of the session about to be shown.
+func (d *DetailPane) SetProject(name string) {
+	d.project = name
+}
+
+// filterSubject returns the session's values the filters compare against,
+// worked out from its messages the way the indexer does.
+func (d *DetailPane) filterSubject() store.Subject {
+	sub := store.Subject{Project: d.project, Text: d.text}
+	if d.session != nil {
+		sub.SessionID = d.session.SessionID
+	}
+	for _, msg := range d.messages {
+		if msg.Model != "" {
+			sub.Model = claude.FormatModel(msg.Model)
+		}
+		if msg.Timestamp != "" {
+			sub.Modified = msg.Timestamp
+		}
+	}
+	return sub
 }
 
 // Refresh re-reads the JSONL file for the current session.
@@ -116,12 +403,15 @@ func (d *DetailPane) Refresh() bool {
 	}
 
 	messages, err := claude.LoadMessages(d.session.FullPath)
-	if err != nil || len(messages) == d.prevMsgCount {
+	if err != nil {
 		return false
 	}
-
-	d.messages = messages
-	d.prevMsgCount = len(messages)
+	prev := d.prevEnd
+	d.load(messages)
+	if d.prevEnd == prev {
+		return false
+	}
+	d.loadTextMatches()
 	d.renderLines()
 
 	if d.tailing {
@@ -137,6 +427,7 @@ func (d *DetailPane) scrollToBottom() {
 	}
 	d.scroll = maxScroll
 	d.tailing = true
+	d.followCursor()
 }
 
 func (d *DetailPane) IsAtBottom() bool {
@@ -150,6 +441,7 @@ func (d *DetailPane) IsAtBottom() bool {
 func (d *DetailPane) ScrollToTop() {
 	d.scroll = 0
 	d.tailing = false
+	d.followCursor()
 }
 
 func (d *DetailPane) ScrollUp(n int) {
@@ -159,6 +451,7 @@ func (d *DetailPane) ScrollUp(n int) {
 	}
 	// User scrolled up — pause auto-tail
 	d.tailing = false
+	d.followCursor()
 }
 
 func (d *DetailPane) ScrollDown(n int) {
@@ -174,6 +467,7 @@ func (d *DetailPane) ScrollDown(n int) {
 	if d.scroll >= maxScroll {
 		d.tailing = true
 	}
+	d.followCursor()
 }
 
 func (d *DetailPane) IsTailing() bool {
@@ -189,18 +483,23 @@ func (d *DetailPane) SetSearch(query string) {
 	if query == "" {
 		return
 	}
+	d.findMatches()
 
-	lower := strings.ToLower(query)
+	// Jump to first match
+	if len(d.matchLines) > 0 {
+		d.scrollToLine(d.matchLines[0])
+	}
+}
+
+// findMatches records the rendered lines containing the search query.
+func (d *DetailPane) findMatches() {
+	d.matchLines = nil
+	lower := strings.ToLower(d.searchQuery)
 	for i, line := range d.lines {
 		if strings.Contains(strings.ToLower(stripAnsi(line)), lower) {
 			d.matchLines = append(d.matchLines, i)
 		}
 	}
-
-	// Jump to first match
-	if len(d.matchLines) > 0 {
-		d.scrollToLine(d.matchLines[0])
-	}
 }
 
 // ClearSearch removes the search highlight.
@@ -255,10 +554,196 @@ func (d *DetailPane) scrollToLine(line int) {
 		d.scroll = maxScroll
 	}
 	d.tailing = false
+	d.followCursor()
+}
+
+// blockOf returns the position in blocks of message i, or -1 if it is not
+// rendered.
+func (d *DetailPane) blockOf(i int) int {
+	return slices.IndexFunc(d.blocks, func(b msgBlock) bool { return b.msg == i })
+}
+
+// followCursor keeps the selected message on screen as the view scrolls,
+// moving the cursor to the nearest message that is.
+func (d *DetailPane) followCursor() {
+	if len(d.blocks) == 0 {
+		d.cursor = -1
+		return
+	}
+	top, bottom := d.scroll, d.scroll+max(d.height, 1)
+	cur := d.blockOf(d.cursor)
+	if cur >= 0 && d.blocks[cur].line >= top && d.blocks[cur].line < bottom {
+		return
+	}
+	first, last, above := -1, -1, 0
+	for i, b := range d.blocks {
+		switch {
+		case b.line < top:
+			above = i
+		case b.line < bottom:
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	switch {
+	case first < 0:
+		// The screen is inside one long message
+		d.cursor = d.blocks[above].msg
+	case cur >= 0 && d.blocks[cur].line >= bottom:
+		d.cursor = d.blocks[last].msg
+	default:
+		d.cursor = d.blocks[first].msg
+	}
+}
+
+// MoveCursor selects the message delta messages after the selected one,
+// scrolling it into view.
+func (d *DetailPane) MoveCursor(delta int) {
+	if len(d.blocks) == 0 {
+		return
+	}
+	b := max(d.blockOf(d.cursor), 0)
+	b = min(max(b+delta, 0), len(d.blocks)-1)
+	d.cursor = d.blocks[b].msg
+	if line := d.blocks[b].line; line < d.scroll || line >= d.scroll+d.height {
+		d.scrollToLine(line)
+	}
+}
+
+// collapsible reports whether a collapsed message leaves anything out.
+func (d *DetailPane) collapsible(msg claude.Message) bool {
+	switch msg.Type {
+	case claude.TypeAssistant:
+		return len(msg.Text) > assistantPreview || (msg.Thinking != "" && !d.showThinking)
+	case claude.TypeToolResult:
+		return msg.FullLen > 0 || len(msg.Text) > toolResultPreview
+	}
+	return false
+}
+
+// expand marks message i expanded, first reading back its full text if it
+// was only loaded as a preview.
+func (d *DetailPane) expand(i int) error {
+	msg := d.messages[i]
+	if _, loaded := d.fullText[i]; msg.FullLen > 0 && !loaded {
+		if d.fullBytes+msg.FullLen > fullTextBudget {
+			return errors.New("too much expanded; zc collapses all")
+		}
+		text, err := claude.FullText(d.session.FullPath, msg)
+		if err != nil {
+			return err
+		}
+		d.fullText[i] = text
+		d.fullBytes += len(text)
+	}
+	d.open[i] = true
+	return nil
+}
+
+// collapse marks message i collapsed and drops its full text.
+func (d *DetailPane) collapse(i int) {
+	delete(d.open, i)
+	if text, ok := d.fullText[i]; ok {
+		d.fullBytes -= len(text)
+		delete(d.fullText, i)
+	}
+}
+
+// ToggleMessage expands the selected message, or collapses it if it is
+// expanded, and returns a description for the status bar.
+func (d *DetailPane) ToggleMessage() string {
+	if d.cursor < 0 || d.cursor >= len(d.messages) {
+		return "NO MESSAGE SELECTED"
+	}
+	switch {
+	case d.open[d.cursor]:
+		d.collapse(d.cursor)
+	case !d.collapsible(d.messages[d.cursor]):
+		return "NOTHING HIDDEN"
+	default:
+		if err := d.expand(d.cursor); err != nil {
+			return "CAN'T EXPAND: " + err.Error()
+		}
+	}
+	d.rerender()
+	return ""
+}
+
+// ExpandAll expands every collapsible message on screen or off, and
+// returns a description for the status bar.
+func (d *DetailPane) ExpandAll() string {
+	n, skipped := 0, 0
+	for _, b := range d.blocks {
+		if d.open[b.msg] || !d.collapsible(d.messages[b.msg]) {
+			continue
+		}
+		if d.expand(b.msg) != nil {
+			skipped++
+			continue
+		}
+		n++
+	}
+	d.rerender()
+	if skipped > 0 {
+		return fmt.Sprintf("EXPANDED %d · %d LEFT COLLAPSED", n, skipped)
+	}
+	return fmt.Sprintf("EXPANDED %d", n)
+}
+
+// CollapseAll collapses every expanded message.
+func (d *DetailPane) CollapseAll() string {
+	n := len(d.open)
+	for i := range d.open {
+		d.collapse(i)
+	}
+	d.rerender()
+	return fmt.Sprintf("COLLAPSED %d", n)
+}
+
+// rerender renders again after messages changed size, keeping the selected
+// message where it was on screen.
+func (d *DetailPane) rerender() {
+	offset := -1
+	if b := d.blockOf(d.cursor); b >= 0 {
+		offset = d.blocks[b].line - d.scroll
+	}
+	d.renderLines()
+	if d.searchQuery != "" {
+		d.findMatches()
+		d.matchIdx = min(d.matchIdx, max(len(d.matchLines)-1, 0))
+	}
+	if d.tailing {
+		d.scrollToBottom()
+		return
+	}
+	if b := d.blockOf(d.cursor); b >= 0 && offset >= 0 {
+		d.scroll = max(d.blocks[b].line-offset, 0)
+	}
+	d.ScrollDown(0)
+}
+
+// previewText cuts s to at most n bytes on a rune boundary, returning the
+// preview and how many bytes it leaves out.
+func previewText(s string, n int) (string, int) {
+	if len(s) <= n {
+		return s, 0
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n], len(s) - n
+}
+
+// moreLine tells the reader how much of a collapsed message is hidden.
+func moreLine(hidden int) string {
+	return DimStyle.Render(fmt.Sprintf("  ┃   … %s more bytes · enter to expand", claude.FormatTokens(hidden)))
 }
 
 func (d *DetailPane) renderLines() {
 	d.lines = nil
+	d.blocks = nil
 	if d.session == nil || len(d.messages) == 0 {
 		return
 	}
@@ -282,18 +767,30 @@ func (d *DetailPane) renderLines() {
 		return style.Render("  ┃") + DimStyle.Render(strings.Repeat("╌", dashLen)+" "+tsStr)
 	}
 
-	for i, msg := range d.messages {
+	d.forkLines = make(map[int]int)
+	d.taskLines = make(map[string]int)
+	var sub store.Subject
+	if d.filters != nil {
+		sub = d.filterSubject()
+	}
+	for n, i := range d.visibleMessages() {
+		msg := d.messages[i]
 		// Skip messages that don't match active filters
-		if len(d.filters) > 0 && !d.messageMatchesFilters(msg) {
-			continue
+		if d.filters != nil {
+			sub.Message = msg
+			if !d.filters.Match(sub) {
+				continue
+			}
 		}
+		d.renderBranchMarker(i)
 
 		switch msg.Type {
 		case claude.TypeUser:
-			if i > 0 {
+			if n > 0 {
 				d.lines = append(d.lines, makeSep(UserMsgStyle, msg.Timestamp))
 			}
 			tag := UserMsgStyle.Render("  ┃ ▶ USER")
+			d.blocks = append(d.blocks, msgBlock{i, len(d.lines)})
 			d.lines = append(d.lines, tag)
 			for _, line := range WrapText(msg.Text, contentWidth) {
 				d.lines = append(d.lines, UserMsgStyle.Render("  ┃ ")+NormalStyle.Render(line))
@@ -301,7 +798,7 @@ func (d *DetailPane) renderLines() {
 			d.lines = append(d.lines, "")
 
 		case claude.TypeAssistant:
-			if i > 0 {
+			if n > 0 {
 				d.lines = append(d.lines, makeSep(AssistantMsgStyle, msg.Timestamp))
 			}
 			model := claude.FormatModel(msg.Model)
@@ -314,25 +811,34 @@ func (d *DetailPane) renderLines() {
 			}
 
 			tag := AssistantMsgStyle.Render("  ┃ ") + modelDot + AssistantMsgStyle.Render(fmt.Sprintf(" CLAUDE [%s]", model))
+			d.blocks = append(d.blocks, msgBlock{i, len(d.lines)})
 			d.lines = append(d.lines, tag)
+			open := d.open[i]
+			d.renderThinking(msg, contentWidth, d.showThinking || open)
 
-			if len(msg.ToolCalls) > 0 {
+			if len(msg.Tools) > 0 {
+				for _, tc := range msg.Tools {
+					d.lines = append(d.lines, renderToolCall(tc, contentWidth))
+					d.renderSubagent(tc)
+				}
+			} else if len(msg.ToolCalls) > 0 {
 				tools := ToolMsgStyle.Render("  ┃   ⚙ " + strings.Join(msg.ToolCalls, " · "))
 				d.lines = append(d.lines, tools)
 			}
 
-			text := msg.Text
-			if len(text) > 500 {
-				text = text[:500] + "..."
+			text, hidden := msg.Text, 0
+			if !open {
+				text, hidden = previewText(text, assistantPreview)
 			}
 			for _, line := range WrapText(text, contentWidth) {
 				d.lines = append(d.lines, AssistantMsgStyle.Render("  ┃ ")+NormalStyle.Render(line))
 			}
+			if hidden > 0 {
+				d.lines = append(d.lines, moreLine(hidden))
+			}
 
 			if msg.OutputTokens > 0 {
-				tokens := DimStyle.Render(fmt.Sprintf("  ┃   ⊘ %s in / %s out",
-					claude.FormatTokens(msg.InputTokens), claude.FormatTokens(msg.OutputTokens)))
-				d.lines = append(d.lines, tokens)
+				d.lines = append(d.lines, DimStyle.Render("  ┃   "+d.usageLine(msg)))
 			}
 			d.lines = append(d.lines, "")
 
@@ -340,28 +846,38 @@ func (d *DetailPane) renderLines() {
 			if msg.Text == "" {
 				continue
 			}
-			if i > 0 {
+			if n > 0 {
 				d.lines = append(d.lines, makeSep(ToolMsgStyle, msg.Timestamp))
 			}
 			tag := ToolMsgStyle.Render("  ┃ ⚙ TOOL RESULT")
+			d.blocks = append(d.blocks, msgBlock{i, len(d.lines)})
 			d.lines = append(d.lines, tag)
-			text := msg.Text
-			if len(text) > 200 {
-				text = text[:200] + "..."
+			text, hidden := msg.Text, 0
+			if full, ok := d.fullText[i]; ok && d.open[i] {
+				text = full
+			} else if !d.open[i] {
+				text, hidden = previewText(text, toolResultPreview)
+				if msg.FullLen > 0 {
+					hidden = msg.FullLen - len(text)
+				}
 			}
 			for _, line := range WrapText(text, contentWidth) {
 				d.lines = append(d.lines, ToolMsgStyle.Render("  ┃ ")+DimStyle.Render(line))
 			}
+			if hidden > 0 {
+				d.lines = append(d.lines, moreLine(hidden))
+			}
 			d.lines = append(d.lines, "")
 
 		case claude.TypeSystem:
 			if msg.Text == "" {
 				continue
 			}
-			if i > 0 {
+			if n > 0 {
 				d.lines = append(d.lines, makeSep(SystemMsgStyle, msg.Timestamp))
 			}
 			tag := SystemMsgStyle.Render("  ┃ ◌ SYSTEM")
+			d.blocks = append(d.blocks, msgBlock{i, len(d.lines)})
 			d.lines = append(d.lines, tag)
 			for _, line := range WrapText(msg.Text, contentWidth-4) {
 				d.lines = append(d.lines, SystemMsgStyle.Render("  ┃ "+line))
@@ -371,6 +887,120 @@ func (d *DetailPane) renderLines() {
 	}
 }
 
+// renderBranchMarker marks where message i starts one of several branches
+// from the same message.
+func (d *DetailPane) renderBranchMarker(i int) {
+	fork := d.tree.Parent(i)
+	if fork < 0 || d.messages[i].IsSidechain || !d.tree.IsFork(fork) {
+		return
+	}
+	kids := d.tree.Children(fork)
+	d.forkLines[fork] = len(d.lines)
+	d.lines = append(d.lines, BadgeStyle.Render(fmt.Sprintf("  ╟─⑂ branch %d of %d", slices.Index(kids, i)+1, len(kids)))+
+		DimStyle.Render("  b to switch"))
+}
+
+// renderSubagent adds a summary of the subagent a Task call spawned, and
+// when it is expanded, the subagent's conversation nested under the call.
+func (d *DetailPane) renderSubagent(tc claude.ToolCall) {
+	a := d.subagents[tc.AgentID]
+	if a == nil {
+		return
+	}
+	gutter := AssistantMsgStyle.Render("  ┃   ")
+	summary := fmt.Sprintf("subagent · %d msgs · %s tok", len(a.Messages), claude.FormatTokens(a.Usage().Total()))
+
+	d.taskLines[tc.ID] = len(d.lines)
+	if !d.expanded[tc.ID] {
+		d.lines = append(d.lines, gutter+ToolMsgStyle.Render("▸ "+summary)+DimStyle.Render("  x to expand"))
+		return
+	}
+	d.lines = append(d.lines, gutter+ToolMsgStyle.Render("▾ "+summary))
+
+	sub := DetailPane{
+		session:      d.session,
+		messages:     a.Messages,
+		tree:         claude.BuildTree(a.Messages),
+		width:        d.width - 4,
+		pricing:      d.pricing,
+		showThinking: d.showThinking,
+	}
+	sub.renderLines()
+	prefix := AssistantMsgStyle.Render("  ┃ ")
+	for _, line := range sub.lines {
+		d.lines = append(d.lines, prefix+line)
+	}
+}
+
+// renderThinking adds a message's extended thinking as a dimmed section,
+// collapsed to a one-line summary unless show is set.
+func (d *DetailPane) renderThinking(msg claude.Message, width int, show bool) {
+	if msg.Thinking == "" && msg.RedactedThinking == 0 {
+		return
+	}
+	gutter := AssistantMsgStyle.Render("  ┃   ")
+
+	var info []string
+	if msg.Thinking != "" {
+		info = append(info, fmt.Sprintf("%d words", len(strings.Fields(msg.Thinking))))
+	}
+	if msg.RedactedThinking > 0 {
+		info = append(info, fmt.Sprintf("%d redacted", msg.RedactedThinking))
+	}
+	summary := "thinking · " + strings.Join(info, " · ")
+
+	if !show || msg.Thinking == "" {
+		d.lines = append(d.lines, gutter+thinkingStyle.Render("▸ "+summary))
+		return
+	}
+	d.lines = append(d.lines, gutter+thinkingStyle.Render("▾ "+summary))
+	for _, line := range WrapText(msg.Thinking, width-4) {
+		d.lines = append(d.lines, gutter+DimStyle.Render("│ ")+thinkingStyle.Render(line))
+	}
+}
+
+// renderToolCall renders a single tool invocation as one line: the tool
+// name, what it was asked to do, and whether it succeeded.
+func renderToolCall(tc claude.ToolCall, width int) string {
+	status := DimStyle.Render("…")
+	if tc.Answered {
+		if tc.IsError {
+			status = ErrorStyle.Render("✗ failed")
+		} else {
+			status = AssistantMsgStyle.Render("✓")
+		}
+		if tc.Duration > 0 {
+			status += DimStyle.Render(" " + formatToolDuration(tc.Duration))
+		}
+	}
+
+	summary := tc.Summary()
+	maxSummary := width - len(tc.Name) - 16
+	if maxSummary < 10 {
+		maxSummary = 10
+	}
+	if runes := []rune(summary); len(runes) > maxSummary {
+		summary = string(runes[:maxSummary-3]) + "..."
+	}
+
+	line := ToolMsgStyle.Render("  ┃   ⚙ " + tc.Name)
+	if summary != "" {
+		line += "  " + NormalStyle.Render(summary)
+	}
+	return line + "  " + status
+}
+
+// formatToolDuration renders a tool call duration compactly.
+func formatToolDuration(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	if d < time.Minute {
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	return d.Round(time.Second).String()
+}
+
 // formatMsgTime parses an ISO timestamp and returns a compact time string.
 func formatMsgTime(ts string) string {
 	if ts == "" {
@@ -408,7 +1038,7 @@ func (d *DetailPane) Title() string {
 			title += fmt.Sprintf("  ↑ L%d/%d (%d%%)  [g] live", pos, total, pct)
 		}
 	}
-	if len(d.filters) > 0 {
+	if d.filters != nil {
 		title += "  ⚡ FILTERED"
 	}
 	if d.searchQuery != "" {
@@ -443,6 +1073,11 @@ func (d *DetailPane) View() string {
 	scrollbar := RenderScrollbar(available, len(d.lines), d.scroll)
 	innerW := d.width - 3 // content width inside panel border (w-2), minus 1 for scrollbar gutter
 
+	cursorLine := -1
+	if b := d.blockOf(d.cursor); b >= 0 {
+		cursorLine = d.blocks[b].line
+	}
+
 	for idx := 0; idx < available; idx++ {
 		contentIdx := d.scroll + idx
 		content := ""
@@ -451,6 +1086,12 @@ func (d *DetailPane) View() string {
 			if d.searchQuery != "" {
 				content = highlightMatches(content, d.searchQuery)
 			}
+			// Mark the selected message in the margin
+			if contentIdx == cursorLine {
+				if i := strings.IndexByte(content, ' '); i >= 0 {
+					content = cursorStyle.Render("▌") + content[:i] + content[i+1:]
+				}
+			}
 		}
 		sb := " "
 		if idx < len(scrollbar) {
@@ -471,6 +1112,21 @@ func (d *DetailPane) View() string {
 	return strings.Join(lines, "\n")
 }
 
+// usageLine summarizes an assistant message's tokens and cost, e.g.
+// "⊘ 1.2K in + 40.0K cached / 300 out  $0.03".
+func (d *DetailPane) usageLine(msg claude.Message) string {
+	u := msg.Usage()
+	line := "⊘ " + claude.FormatTokens(u.InputTokens) + " in"
+	if cached := u.CacheReadTokens + u.CacheWriteTokens; cached > 0 {
+		line += " + " + claude.FormatTokens(cached) + " cached"
+	}
+	line += " / " + claude.FormatTokens(u.OutputTokens) + " out"
+	if _, ok := d.pricing.PriceFor(msg.Model); ok {
+		line += "  " + claude.FormatCost(d.pricing.Cost(msg.Model, u))
+	}
+	return line
+}
+
 // HeaderStats returns session metrics styled for the header bar.
 // Returns empty string if no session is loaded.
 func (d *DetailPane) HeaderStats() string {
@@ -478,15 +1134,28 @@ func (d *DetailPane) HeaderStats() string {
 		return ""
 	}
 
-	var totalIn, totalOut, toolCount int
+	var usage claude.Usage
+	var cost float64
+	var toolCount int
 	var model string
+	var branches []string
 	for _, m := range d.messages {
-		totalIn += m.InputTokens
-		totalOut += m.OutputTokens
+		usage.Add(m.Usage())
+		cost += d.pricing.Cost(m.Model, m.Usage())
 		toolCount += len(m.ToolCalls)
 		if m.Model != "" {
 			model = claude.FormatModel(m.Model)
 		}
+		// Record each branch switch, not each distinct branch
+		if m.GitBranch != "" && (len(branches) == 0 || branches[len(branches)-1] != m.GitBranch) {
+			branches = append(branches, m.GitBranch)
+		}
+	}
+	if len(branches) == 0 && d.session.GitBranch != "" {
+		branches = []string{d.session.GitBranch}
+	}
+	if len(branches) > 3 {
+		branches = append([]string{"…"}, branches[len(branches)-2:]...)
 	}
 
 	bg := lipgloss.NewStyle().Background(ColorBarBg)
@@ -496,10 +1165,14 @@ func (d *DetailPane) HeaderStats() string {
 		bg.Foreground(ColorCyan).Render(
 			fmt.Sprintf("MSGS %d", len(d.messages))),
 		bg.Foreground(ColorGreen).Render(
-			fmt.Sprintf("TOK %s", claude.FormatTokens(totalIn+totalOut))),
-		bg.Foreground(ColorYellow).Render(
-			fmt.Sprintf("TOOLS %d", toolCount)),
+			fmt.Sprintf("TOK %s", claude.FormatTokens(usage.Total()))),
 	}
+	if cost > 0 {
+		parts = append(parts, bg.Foreground(ColorWhite).Render(
+			fmt.Sprintf("COST %s", claude.FormatCost(cost))))
+	}
+	parts = append(parts, bg.Foreground(ColorYellow).Render(
+		fmt.Sprintf("TOOLS %d", toolCount)))
 	if model != "" {
 		modelColor := ColorGreen
 		if strings.Contains(model, "opus") {
@@ -509,8 +1182,24 @@ func (d *DetailPane) HeaderStats() string {
 		}
 		parts = append(parts, bg.Foreground(modelColor).Bold(true).Render(model))
 	}
-	if d.session.GitBranch != "" {
-		parts = append(parts, bg.Foreground(ColorWhite).Render(d.session.GitBranch))
+	if len(branches) > 0 {
+		parts = append(parts, bg.Foreground(ColorWhite).Render(strings.Join(branches, " → ")))
+	}
+	if len(d.subagents) > 0 {
+		var agentUsage claude.Usage
+		for _, a := range d.subagents {
+			agentUsage.Add(a.Usage())
+		}
+		parts = append(parts, bg.Foreground(ColorCyan).Render(
+			fmt.Sprintf("AGENTS %d +%s", len(d.subagents), claude.FormatTokens(agentUsage.Total()))))
+	}
+	if d.tree != nil {
+		if forks := d.tree.Forks(); forks > 0 {
+			parts = append(parts, bg.Foreground(ColorYellowDim).Render(fmt.Sprintf("⑂ %d", forks)))
+		}
+	}
+	if d.session.Missing {
+		parts = append(parts, bg.Foreground(ColorRed).Bold(true).Render("SOURCE MISSING"))
 	}
 	return strings.Join(parts, sep)
 }
@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thinkwright/claude-chronicle/internal/claude"
+	"github.com/thinkwright/claude-chronicle/internal/export"
+)
+
+type exportDoneMsg struct {
+	path string
+	err  error
+}
+
+// exportCmd writes the session to the working directory in the background.
+func exportCmd(sess claude.SessionEntry, f export.Format) tea.Cmd {
+	return func() tea.Msg {
+		path, err := filepath.Abs(export.Filename(sess, f))
+		if err != nil {
+			return exportDoneMsg{err: err}
+		}
+		return exportDoneMsg{path: path, err: export.WriteFile(path, sess, f)}
+	}
+}
+
+func (m Model) handleExportKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var f export.Format
+	switch msg.String() {
+	case "m":
+		f = export.Markdown
+	case "h":
+		f = export.HTML
+	case "j":
+		f = export.JSON
+	case "esc", "q", "e":
+		m.confirmExport = false
+		return m, nil
+	default:
+		return m, nil
+	}
+
+	m.confirmExport = false
+	sess := m.detail.session
+	if sess == nil {
+		return m, nil
+	}
+	m.notice = "EXPORTING..."
+	return m, exportCmd(*sess, f)
+}
+
+func (m Model) renderExportPrompt() string {
+	bc := lipgloss.NewStyle().Foreground(ColorCyan)
+	tc := lipgloss.NewStyle().Foreground(ColorCyan).Bold(true)
+	dim := lipgloss.NewStyle().Foreground(ColorDim)
+
+	innerW := 40
+
+	side := bc.Render("┃")
+
+	var rows []string
+
+	title := " EXPORT "
+	fillLen := innerW - 3 - len(title)
+	if fillLen < 0 {
+		fillLen = 0
+	}
+	rows = append(rows, bc.Render("┏━╸")+tc.Render(title)+bc.Render("╺"+strings.Repeat("━", fillLen)+"┓"))
+	rows = append(rows, side+strings.Repeat(" ", innerW)+side)
+
+	q := "  Save transcript to ./ as"
+	qStyled := lipgloss.NewStyle().Foreground(ColorWhite).Bold(true).Render(q)
+	rows = append(rows, side+qStyled+strings.Repeat(" ", max(innerW-visibleLen(qStyled), 0))+side)
+	rows = append(rows, side+strings.Repeat(" ", innerW)+side)
+
+	opts := fmt.Sprintf("  %s md  %s html  %s json  %s",
+		SelectedStyle.Render("[m]"), SelectedStyle.Render("[h]"), SelectedStyle.Render("[j]"), dim.Render("[esc]"))
+	rows = append(rows, side+opts+strings.Repeat(" ", max(innerW-visibleLen(opts), 0))+side)
+
+	rows = append(rows, side+strings.Repeat(" ", innerW)+side)
+	rows = append(rows, bc.Render("┗"+strings.Repeat("━", innerW)+"┛"))
+
+	return strings.Join(rows, "\n")
+}
@@ -1,7 +1,6 @@
 package ui
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/textinput"
@@ -11,8 +10,9 @@ import (
 )
 
 type FilterBar struct {
-	filters []store.Filter
-	active  bool
+	query   *store.FilterSet // applied filters; nil when none
+	applied string           // the text query was parsed from
+	err     error            // why the text being edited does not parse
 	editing bool
 	input   textinput.Model
 	width   int
@@ -20,7 +20,7 @@ type FilterBar struct {
 
 func NewFilterBar() FilterBar {
 	ti := textinput.New()
-	ti.Placeholder = "type:user  model:opus  tool:Bash  tokens:>10000"
+	ti.Placeholder = "type:user  -tool:Bash  (model:opus OR model:sonnet)  tokens:1000..5000"
 	ti.CharLimit = 256
 	ti.Prompt = "filter log: "
 	ti.PromptStyle = lipgloss.NewStyle().Foreground(ColorYellow)
@@ -36,13 +36,16 @@ func (f *FilterBar) SetWidth(w int) {
 
 func (f *FilterBar) OpenEditor() {
 	f.editing = true
+	f.err = nil
 	// Pre-fill with current filters
 	f.input.SetValue(f.String())
+	f.input.CursorEnd()
 	f.input.Focus()
 }
 
 func (f *FilterBar) CloseEditor() {
 	f.editing = false
+	f.err = nil
 	f.input.Blur()
 }
 
@@ -50,32 +53,44 @@ func (f *FilterBar) IsEditing() bool {
 	return f.editing
 }
 
-func (f *FilterBar) ApplyFromInput() {
-	raw := f.input.Value()
-	f.editing = false
-	f.input.Blur()
-
-	if raw == "" {
-		f.filters = nil
-		return
+// ApplyFromInput applies the query being edited and closes the editor. A
+// query that does not parse is not applied; the editor stays open showing
+// why, and ApplyFromInput returns false.
+func (f *FilterBar) ApplyFromInput() bool {
+	raw := strings.TrimSpace(f.input.Value())
+	fs := store.Parse(raw)
+	if fs.Err != nil {
+		f.err = fs.Err
+		return false
 	}
 
-	fs := store.Parse(raw)
-	f.filters = fs.Filters
+	f.CloseEditor()
+	if fs.IsEmpty() {
+		f.query, f.applied = nil, ""
+		return true
+	}
+	f.query, f.applied = fs, raw
+	return true
 }
 
 func (f *FilterBar) Clear() {
-	f.filters = nil
+	f.query, f.applied = nil, ""
+	f.err = nil
 	f.editing = false
 	f.input.SetValue("")
 }
 
 func (f *FilterBar) HasFilters() bool {
-	return len(f.filters) > 0
+	return f.query != nil
+}
+
+func (f *FilterBar) Filters() *store.FilterSet {
+	return f.query
 }
 
-func (f *FilterBar) Filters() []store.Filter {
-	return f.filters
+// Err returns the parse error shown in the editor, if any.
+func (f *FilterBar) Err() error {
+	return f.err
 }
 
 // FilterQuery returns the raw filter expression for use with the store.
@@ -84,11 +99,7 @@ func (f *FilterBar) FilterQuery() string {
 }
 
 func (f *FilterBar) String() string {
-	var parts []string
-	for _, fl := range f.filters {
-		parts = append(parts, filterToString(fl))
-	}
-	return strings.Join(parts, " ")
+	return f.applied
 }
 
 func (f *FilterBar) Value() string {
@@ -97,23 +108,35 @@ func (f *FilterBar) Value() string {
 
 func (f *FilterBar) SetValue(v string) {
 	f.input.SetValue(v)
+	f.err = nil
 }
 
 // UpdateInput passes a tea.Msg to the textinput for native cursor handling.
+// An error shown from the last Enter is rechecked as the query is edited,
+// so it goes away once the query is fixed.
 func (f *FilterBar) UpdateInput(msg tea.Msg) tea.Cmd {
 	var cmd tea.Cmd
 	f.input, cmd = f.input.Update(msg)
+	if f.err != nil {
+		f.err = store.Parse(f.input.Value()).Err
+	}
 	return cmd
 }
 
 func (f *FilterBar) View() string {
 	if f.editing {
+		border := ColorYellow
+		content := f.input.View()
+		if f.err != nil {
+			border = ColorRed
+			content += "\n" + lipgloss.NewStyle().Foreground(ColorRed).Render("✗ "+f.err.Error())
+		}
 		box := lipgloss.NewStyle().
 			Border(lipgloss.DoubleBorder()).
-			BorderForeground(ColorYellow).
+			BorderForeground(border).
 			Padding(0, 1).
 			Width(f.width - 4)
-		return box.Render(f.input.View())
+		return box.Render(content)
 	}
 
 	if !f.HasFilters() {
@@ -126,41 +149,11 @@ func (f *FilterBar) View() string {
 		Foreground(ColorYellow).
 		Bold(true)
 
-	for _, fl := range f.filters {
-		chips = append(chips, chipStyle.Render(filterToString(fl)))
+	for _, n := range f.query.Nodes() {
+		chips = append(chips, chipStyle.Render(n.String()))
 	}
 
 	hint := DimStyle.Render("  [f:edit  F:clear]")
 	label := lipgloss.NewStyle().Foreground(ColorYellowDim).Render("  FILTERS: ")
 	return label + strings.Join(chips, "  ") + hint
 }
-
-func filterToString(f store.Filter) string {
-	field := ""
-	switch f.Field {
-	case store.FilterModel:
-		field = "model"
-	case store.FilterBranch:
-		field = "branch"
-	case store.FilterProject:
-		field = "project"
-	case store.FilterType:
-		field = "type"
-	case store.FilterTool:
-		field = "tool"
-	case store.FilterTokens:
-		field = "tokens"
-	case store.FilterAge:
-		field = "age"
-	}
-
-	op := ""
-	switch f.Op {
-	case store.OpGreaterThan:
-		op = ">"
-	case store.OpLessThan:
-		op = "<"
-	}
-
-	return fmt.Sprintf("%s:%s%s", field, op, f.Value)
-}
@@ -7,26 +7,57 @@ import (
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/thinkwright/claude-chronicle/internal/claude"
+	"github.com/thinkwright/claude-chronicle/internal/store"
 )
 
+// ProjectList lists pinned saved searches above the projects. The cursor
+// runs over both: below len(pins) it is on a pinned search.
 type ProjectList struct {
+	pins     []PinnedSearch
 	projects []claude.Project
 	cursor   int
 	width    int
 	height   int
 }
 
+// PinnedSearch is a pinned saved search and how many sessions it finds.
+type PinnedSearch struct {
+	store.SavedSearch
+	Count int
+}
+
 func NewProjectList() ProjectList {
 	return ProjectList{}
 }
 
 func (p *ProjectList) SetProjects(projects []claude.Project) {
 	p.projects = projects
-	if p.cursor >= len(projects) {
+	if p.cursor >= p.count() {
 		p.cursor = 0
 	}
 }
 
+// SetPins replaces the pinned searches, keeping the cursor on the same
+// project or search.
+func (p *ProjectList) SetPins(pins []PinnedSearch) {
+	var selID int64
+	if ss := p.SelectedSearch(); ss != nil {
+		selID = ss.ID
+	}
+	p.cursor += len(pins) - len(p.pins)
+	p.pins = pins
+	for i, pin := range pins {
+		if pin.ID == selID {
+			p.cursor = i
+		}
+	}
+	p.cursor = min(max(p.cursor, 0), max(p.count()-1, 0))
+}
+
+func (p *ProjectList) count() int {
+	return len(p.pins) + len(p.projects)
+}
+
 func (p *ProjectList) SetSize(w, h int) {
 	p.width = w
 	p.height = h
@@ -39,16 +70,27 @@ func (p *ProjectList) Up() {
 }
 
 func (p *ProjectList) Down() {
-	if p.cursor < len(p.projects)-1 {
+	if p.cursor < p.count()-1 {
 		p.cursor++
 	}
 }
 
+// Selected returns the project under the cursor, or nil when the cursor is
+// on a pinned search.
 func (p *ProjectList) Selected() *claude.Project {
-	if len(p.projects) == 0 {
+	i := p.cursor - len(p.pins)
+	if i < 0 || i >= len(p.projects) {
 		return nil
 	}
-	return &p.projects[p.cursor]
+	return &p.projects[i]
+}
+
+// SelectedSearch returns the pinned search under the cursor, if any.
+func (p *ProjectList) SelectedSearch() *PinnedSearch {
+	if p.cursor < len(p.pins) {
+		return &p.pins[p.cursor]
+	}
+	return nil
 }
 
 // projectGlyph returns a status glyph based on recency.
@@ -67,7 +109,7 @@ func projectGlyph(lastMod int64) string {
 }
 
 func (p *ProjectList) View() string {
-	if len(p.projects) == 0 {
+	if p.count() == 0 {
 		return "\n" + DimStyle.Render("  No projects found")
 	}
 
@@ -83,11 +125,11 @@ func (p *ProjectList) View() string {
 		start = p.cursor - available + 1
 	}
 	end := start + available
-	if end > len(p.projects) {
-		end = len(p.projects)
+	if end > p.count() {
+		end = p.count()
 	}
 
-	scrollbar := RenderScrollbar(available, len(p.projects), start)
+	scrollbar := RenderScrollbar(available, p.count(), start)
 	innerW := p.width - 3
 
 	for idx := 0; idx < available; idx++ {
@@ -102,11 +144,19 @@ func (p *ProjectList) View() string {
 			continue
 		}
 
-		proj := p.projects[i]
-		glyph := projectGlyph(proj.LastModified)
-		count := BadgeStyle.Render(fmt.Sprintf("(%d)", proj.SessionCount))
+		var glyph, name string
+		var n int
+		if i < len(p.pins) {
+			pin := p.pins[i]
+			glyph = lipgloss.NewStyle().Foreground(ColorYellow).Render("★")
+			name, n = pin.Name, pin.Count
+		} else {
+			proj := p.projects[i-len(p.pins)]
+			glyph = projectGlyph(proj.LastModified)
+			name, n = proj.Name, proj.SessionCount
+		}
+		count := BadgeStyle.Render(fmt.Sprintf("(%d)", n))
 
-		name := proj.Name
 		if len(name) > p.width-14 {
 			name = name[:p.width-17] + "..."
 		}
@@ -116,7 +166,7 @@ func (p *ProjectList) View() string {
 			sel := lipgloss.NewStyle().Background(ColorSelectBg)
 			marker := sel.Foreground(ColorSelect).Render("▸")
 			nameStr := sel.Foreground(ColorSelect).Bold(true).Render(name)
-			countStr := sel.Foreground(ColorSelect).Bold(false).Render(fmt.Sprintf("(%d)", proj.SessionCount))
+			countStr := sel.Foreground(ColorSelect).Bold(false).Render(fmt.Sprintf("(%d)", n))
 			glyphStr := sel.Render(glyph) // keep glyph original color but add bg
 			line = fmt.Sprintf(" %s %s %s %s", marker, glyphStr, nameStr, countStr)
 			pad := innerW - visibleLen(line)
@@ -0,0 +1,201 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thinkwright/claude-chronicle/internal/store"
+)
+
+// SavedPicker is the saved search modal shared by the search overlay and the
+// filter editor: a list to pick a saved query from, and a name prompt for
+// saving or renaming one.
+type SavedPicker struct {
+	items    []store.SavedSearch
+	cursor   int
+	open     bool
+	naming   bool
+	renameID int64  // search being renamed; 0 when saving a new one
+	query    string // query being saved
+	input    textinput.Model
+	width    int
+}
+
+func NewSavedPicker() SavedPicker {
+	ti := textinput.New()
+	ti.Placeholder = "name"
+	ti.CharLimit = 64
+	ti.Prompt = "name: "
+	ti.PromptStyle = lipgloss.NewStyle().Foreground(ColorCyan)
+	ti.TextStyle = lipgloss.NewStyle().Foreground(ColorWhite)
+	ti.PlaceholderStyle = lipgloss.NewStyle().Foreground(ColorDim)
+	return SavedPicker{input: ti}
+}
+
+func (p *SavedPicker) SetWidth(w int) {
+	p.width = w
+	p.input.Width = p.innerWidth() - 10
+}
+
+func (p *SavedPicker) innerWidth() int {
+	return max(min(p.width-8, 72), 30)
+}
+
+func (p *SavedPicker) SetItems(items []store.SavedSearch) {
+	p.items = items
+	if p.cursor >= len(items) {
+		p.cursor = max(len(items)-1, 0)
+	}
+}
+
+// Open shows the list of saved searches.
+func (p *SavedPicker) Open(items []store.SavedSearch) {
+	p.SetItems(items)
+	p.open = true
+	p.naming = false
+}
+
+// StartSave prompts for a name to save query under.
+func (p *SavedPicker) StartSave(query string) {
+	p.open = true
+	p.naming = true
+	p.renameID = 0
+	p.query = query
+	p.input.SetValue("")
+	p.input.Focus()
+}
+
+// StartRename prompts for a new name for the selected search.
+func (p *SavedPicker) StartRename() {
+	ss := p.Selected()
+	if ss == nil {
+		return
+	}
+	p.naming = true
+	p.renameID = ss.ID
+	p.query = ss.Query
+	p.input.SetValue(ss.Name)
+	p.input.CursorEnd()
+	p.input.Focus()
+}
+
+// FinishNaming closes the name prompt and returns the name entered, the
+// query being saved and the search being renamed (0 for a new one).
+func (p *SavedPicker) FinishNaming() (name, query string, renameID int64) {
+	name, query, renameID = p.input.Value(), p.query, p.renameID
+	p.CancelNaming()
+	return name, query, renameID
+}
+
+// CancelNaming closes the name prompt, back to the list if it was renaming.
+func (p *SavedPicker) CancelNaming() {
+	p.naming = false
+	p.input.Blur()
+	if p.renameID == 0 {
+		p.open = false
+	}
+}
+
+func (p *SavedPicker) Close() {
+	p.open = false
+	p.naming = false
+	p.input.Blur()
+}
+
+func (p *SavedPicker) IsOpen() bool {
+	return p.open
+}
+
+func (p *SavedPicker) IsNaming() bool {
+	return p.naming
+}
+
+func (p *SavedPicker) Up() {
+	if p.cursor > 0 {
+		p.cursor--
+	}
+}
+
+func (p *SavedPicker) Down() {
+	if p.cursor < len(p.items)-1 {
+		p.cursor++
+	}
+}
+
+func (p *SavedPicker) Selected() *store.SavedSearch {
+	if p.cursor >= 0 && p.cursor < len(p.items) {
+		return &p.items[p.cursor]
+	}
+	return nil
+}
+
+// UpdateInput forwards a key message to the name prompt.
+func (p *SavedPicker) UpdateInput(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return cmd
+}
+
+func (p *SavedPicker) View() string {
+	bc := lipgloss.NewStyle().Foreground(ColorCyan)
+	tc := lipgloss.NewStyle().Foreground(ColorCyan).Bold(true)
+	innerW := p.innerWidth()
+	side := bc.Render("┃")
+	row := func(s string) string {
+		return side + s + strings.Repeat(" ", max(innerW-visibleLen(s), 0)) + side
+	}
+
+	title := " SAVED SEARCHES "
+	switch {
+	case p.naming && p.renameID != 0:
+		title = " RENAME SEARCH "
+	case p.naming:
+		title = " SAVE SEARCH "
+	}
+	fillLen := max(innerW-3-len(title), 0)
+
+	var rows []string
+	rows = append(rows, bc.Render("┏━╸")+tc.Render(title)+bc.Render("╺"+strings.Repeat("━", fillLen)+"┓"))
+	rows = append(rows, row(""))
+
+	if p.naming {
+		rows = append(rows, row("  "+DimStyle.Render(truncateToWidth(p.query, innerW-4))))
+		rows = append(rows, row("  "+p.input.View()))
+		rows = append(rows, row(""))
+		rows = append(rows, row(fmt.Sprintf("  %s save  %s", SelectedStyle.Render("[enter]"), DimStyle.Render("[esc]"))))
+	} else {
+		if len(p.items) == 0 {
+			rows = append(rows, row(DimStyle.Render("  No saved searches — ctrl+s saves the current query")))
+		}
+		nameW := 0
+		for _, ss := range p.items {
+			nameW = max(nameW, visibleLen(ss.Name))
+		}
+		nameW = min(nameW, innerW/3)
+		for i, ss := range p.items {
+			pin := "  "
+			if ss.Pinned {
+				pin = lipgloss.NewStyle().Foreground(ColorYellow).Render("★ ")
+			}
+			name := truncateToWidth(ss.Name, nameW)
+			name += strings.Repeat(" ", nameW-visibleLen(name))
+			query := truncateToWidth(ss.Query, innerW-nameW-10)
+			if i == p.cursor {
+				rows = append(rows, row("  "+SelectedStyle.Render("▸ ")+pin+SelectedStyle.Render(name)+"  "+NormalStyle.Render(query)))
+			} else {
+				rows = append(rows, row("    "+pin+NormalStyle.Render(name)+"  "+DimStyle.Render(query)))
+			}
+		}
+		rows = append(rows, row(""))
+		rows = append(rows, row(fmt.Sprintf("  %s use  %s pin  %s rename  %s delete  %s",
+			SelectedStyle.Render("[enter]"), SelectedStyle.Render("[p]"), SelectedStyle.Render("[r]"),
+			SelectedStyle.Render("[d]"), DimStyle.Render("[esc]"))))
+	}
+
+	rows = append(rows, row(""))
+	rows = append(rows, bc.Render("┗"+strings.Repeat("━", innerW)+"┛"))
+	return strings.Join(rows, "\n")
+}
@@ -30,19 +30,29 @@ func (s SearchScope) String() string {
 	return ""
 }
 
+// searchRows is how many result lines the overlay shows at once.
+const searchRows = 10
+
 type SearchOverlay struct {
-	input       textinput.Model
-	active      bool
-	scope       SearchScope
-	width       int
-	results     []store.SearchResult
-	resultIdx   int
-	resultCount int
+	input        textinput.Model
+	active       bool
+	scope        SearchScope
+	mode         store.SearchMode
+	flat         bool // one row per message instead of per session
+	width        int
+	results      []store.SearchResult
+	groups       []store.SessionHits
+	next         string // cursor for the next page; empty when all are loaded
+	resultIdx    int
+	offset       int // first result in view
+	resultCount  int
+	sessionCount int
+	err          error // why the last search failed
 }
 
 func NewSearchOverlay() SearchOverlay {
 	ti := textinput.New()
-	ti.Placeholder = "search... (Tab: scope, Enter: go)"
+	ti.Placeholder = "search... (Tab scope, ^T mode, ^G group, ^S save, ^O saved)"
 	ti.CharLimit = 256
 	ti.Prompt = "/ "
 	ti.PromptStyle = lipgloss.NewStyle().Foreground(ColorCyan)
@@ -53,24 +63,32 @@ func NewSearchOverlay() SearchOverlay {
 
 func (s *SearchOverlay) SetWidth(w int) {
 	s.width = w
-	s.input.Width = w - 20 // leave room for scope badge
+	s.input.Width = max(w-56, 20) // leave room for the badges and counts
 }
 
 func (s *SearchOverlay) Open() {
 	s.active = true
 	s.input.SetValue("")
 	s.input.Focus()
-	s.results = nil
-	s.resultIdx = 0
-	s.resultCount = 0
+	s.clearResults()
 }
 
 func (s *SearchOverlay) Close() {
 	s.active = false
 	s.input.Blur()
 	s.input.SetValue("")
+	s.clearResults()
+}
+
+func (s *SearchOverlay) clearResults() {
 	s.results = nil
+	s.groups = nil
+	s.next = ""
+	s.resultIdx = 0
+	s.offset = 0
 	s.resultCount = 0
+	s.sessionCount = 0
+	s.err = nil
 }
 
 func (s *SearchOverlay) IsActive() bool {
@@ -100,33 +118,134 @@ func (s *SearchOverlay) CycleScope() {
 	}
 }
 
-func (s *SearchOverlay) SetResults(results []store.SearchResult, count int) {
+// Mode returns how free text is matched: natural, exact or chosen per
+// query.
+func (s *SearchOverlay) Mode() store.SearchMode {
+	return s.mode
+}
+
+// CycleMode switches between automatic, exact and natural matching.
+func (s *SearchOverlay) CycleMode() {
+	switch s.mode {
+	case store.SearchAuto:
+		s.mode = store.SearchExact
+	case store.SearchExact:
+		s.mode = store.SearchNatural
+	default:
+		s.mode = store.SearchAuto
+	}
+}
+
+// Grouped reports whether results are listed by session. Local search is
+// always one session, so it lists messages.
+func (s *SearchOverlay) Grouped() bool {
+	return !s.flat && s.scope != ScopeLocal
+}
+
+// ToggleGrouped switches between results by session and by message.
+func (s *SearchOverlay) ToggleGrouped() {
+	s.flat = !s.flat
+}
+
+// SetResults replaces the results with the first page of messages; next is
+// the cursor for the following page.
+func (s *SearchOverlay) SetResults(results []store.SearchResult, next string) {
+	s.clearResults()
 	s.results = results
-	s.resultCount = count
-	s.resultIdx = 0
+	s.next = next
+}
+
+// SetGroups replaces the results with the first page of sessions.
+func (s *SearchOverlay) SetGroups(groups []store.SessionHits, next string) {
+	s.clearResults()
+	s.groups = groups
+	s.next = next
+}
+
+// AddResults appends the next page of messages.
+func (s *SearchOverlay) AddResults(results []store.SearchResult, next string) {
+	s.results = append(s.results, results...)
+	s.next = next
+}
+
+// AddGroups appends the next page of sessions.
+func (s *SearchOverlay) AddGroups(groups []store.SessionHits, next string) {
+	s.groups = append(s.groups, groups...)
+	s.next = next
+}
+
+// SetCounts sets the totals shown beside the input: matching messages and
+// the sessions they are in.
+func (s *SearchOverlay) SetCounts(messages, sessions int) {
+	s.resultCount = messages
+	s.sessionCount = sessions
+}
+
+// Next returns the cursor for the page after the loaded results.
+func (s *SearchOverlay) Next() string {
+	return s.next
+}
+
+// WantsMore reports whether the selection is close enough to the end of the
+// loaded results that the next page should be loaded.
+func (s *SearchOverlay) WantsMore() bool {
+	return s.next != "" && s.resultIdx >= s.rows()-3
+}
+
+// SetError shows why the query could not be searched, in place of results.
+func (s *SearchOverlay) SetError(err error) {
+	s.clearResults()
+	s.err = err
+}
+
+// rows returns how many results are loaded: sessions when grouped,
+// messages otherwise.
+func (s *SearchOverlay) rows() int {
+	if s.groups != nil {
+		return len(s.groups)
+	}
+	return len(s.results)
 }
 
+// SelectedResult returns the selected message, or the best match of the
+// selected session.
 func (s *SearchOverlay) SelectedResult() *store.SearchResult {
-	if s.resultIdx >= 0 && s.resultIdx < len(s.results) {
-		return &s.results[s.resultIdx]
+	if s.resultIdx < 0 || s.resultIdx >= s.rows() {
+		return nil
 	}
-	return nil
+	if s.groups != nil {
+		return &s.groups[s.resultIdx].SearchResult
+	}
+	return &s.results[s.resultIdx]
 }
 
 func (s *SearchOverlay) ResultUp() {
 	if s.resultIdx > 0 {
 		s.resultIdx--
 	}
+	s.offset = min(s.offset, s.resultIdx)
 }
 
 func (s *SearchOverlay) ResultDown() {
-	if s.resultIdx < len(s.results)-1 {
+	if s.resultIdx < s.rows()-1 {
 		s.resultIdx++
 	}
+	if shown := s.shown(); s.resultIdx >= s.offset+shown {
+		s.offset = s.resultIdx - shown + 1
+	}
+}
+
+// shown returns how many results fit in the overlay: sessions take two
+// lines, messages one.
+func (s *SearchOverlay) shown() int {
+	if s.groups != nil {
+		return searchRows / 2
+	}
+	return searchRows
 }
 
 func (s *SearchOverlay) HasResults() bool {
-	return len(s.results) > 0
+	return s.rows() > 0
 }
 
 // UpdateInput forwards a key message to the underlying textinput.
@@ -157,13 +276,22 @@ func (s *SearchOverlay) View() string {
 		Bold(true)
 	scopeBadge := scopeStyle.Render(fmt.Sprintf("[%s]", s.scope))
 
+	// Mode badge: a chosen mode stands out, an automatic one shows its pick
+	modeBadge := scopeStyle.Render(fmt.Sprintf("[%s]", strings.ToUpper(s.mode.String())))
+	if s.mode == store.SearchAuto {
+		modeBadge = DimStyle.Render(fmt.Sprintf("[%s]", s.mode.Resolve(s.input.Value())))
+	}
+
 	// Count indicator
 	countStr := ""
-	if s.resultCount > 0 {
+	switch {
+	case s.resultCount > 0 && s.groups != nil:
+		countStr = DimStyle.Render(fmt.Sprintf("  %d results in %d sessions", s.resultCount, s.sessionCount))
+	case s.resultCount > 0:
 		countStr = DimStyle.Render(fmt.Sprintf("  %d results", s.resultCount))
 	}
 
-	searchLine := fmt.Sprintf("%s %s%s", scopeBadge, s.input.View(), countStr)
+	searchLine := fmt.Sprintf("%s%s %s%s", scopeBadge, modeBadge, s.input.View(), countStr)
 
 	box := lipgloss.NewStyle().
 		Border(lipgloss.DoubleBorder()).
@@ -171,7 +299,11 @@ func (s *SearchOverlay) View() string {
 		Padding(0, 1).
 		Width(s.width - 4)
 
-	if len(s.results) == 0 {
+	if s.err != nil {
+		errLine := lipgloss.NewStyle().Foreground(ColorRed).Render("✗ " + s.err.Error())
+		return box.Render(searchLine + "\n" + errLine)
+	}
+	if s.rows() == 0 {
 		return box.Render(searchLine)
 	}
 
@@ -180,19 +312,14 @@ func (s *SearchOverlay) View() string {
 	lines = append(lines, searchLine)
 	lines = append(lines, DimStyle.Render(strings.Repeat("─", s.width-8)))
 
-	maxShow := 10
-	if len(s.results) < maxShow {
-		maxShow = len(s.results)
-	}
-
-	for i := 0; i < maxShow; i++ {
-		r := s.results[i]
-
-		// Format: project/branch: "highlighted text..."
-		prefix := lipgloss.NewStyle().Foreground(ColorCyan).Render(r.Project)
-		if r.GitBranch != "" {
-			prefix += DimStyle.Render("/" + r.GitBranch)
+	end := min(s.offset+s.shown(), s.rows())
+	for i := s.offset; i < end; i++ {
+		if s.groups != nil {
+			lines = append(lines, s.groupLines(s.groups[i], i == s.resultIdx)...)
+			continue
 		}
+		r := s.results[i]
+		prefix := resultPrefix(r)
 
 		text := r.Text
 		if len(text) > s.width-30 {
@@ -210,10 +337,70 @@ func (s *SearchOverlay) View() string {
 		}
 	}
 
-	if len(s.results) > maxShow {
+	total := s.resultCount
+	if s.groups != nil {
+		total = s.sessionCount
+	}
+	if more := max(total, s.rows()) - end; more > 0 {
 		lines = append(lines, DimStyle.Render(
-			fmt.Sprintf("    ... and %d more", len(s.results)-maxShow)))
+			fmt.Sprintf("    ... and %d more", more)))
 	}
 
 	return box.Render(strings.Join(lines, "\n"))
 }
+
+// resultPrefix renders where a match is: project/branch, and whether it is
+// on an abandoned branch or its session file is gone.
+func resultPrefix(r store.SearchResult) string {
+	prefix := lipgloss.NewStyle().Foreground(ColorCyan).Render(r.Project)
+	if r.GitBranch != "" {
+		prefix += DimStyle.Render("/" + r.GitBranch)
+	}
+	if r.Abandoned {
+		prefix += BadgeStyle.Render(" ⑂")
+	}
+	if r.SourceMissing {
+		prefix += lipgloss.NewStyle().Foreground(ColorRed).Render(" ✗")
+	}
+	return prefix
+}
+
+// groupLines renders a session's row: where it is, its first prompt and
+// number of matches, then its best match.
+func (s *SearchOverlay) groupLines(g store.SessionHits, selected bool) []string {
+	hits := "1 match"
+	if g.Hits != 1 {
+		hits = fmt.Sprintf("%d matches", g.Hits)
+	}
+	head := resultPrefix(g.SearchResult) + DimStyle.Render("  "+hits+"  ")
+	prompt := strings.Join(strings.Fields(g.FirstPrompt), " ")
+	marker := "    "
+	if selected {
+		marker = "  " + SelectedStyle.Render("▸ ")
+		prompt = SelectedStyle.Render(prompt)
+	} else {
+		prompt = NormalStyle.Render(prompt)
+	}
+	head = truncateToWidth(marker+head+prompt, s.width-8)
+	return []string{head, "      " + snippet(g.Highlighted, s.width-14)}
+}
+
+// snippet renders highlighted match text on one line of width cells,
+// starting shortly before the first match so that it is in view.
+func snippet(highlighted string, width int) string {
+	text := strings.Join(strings.Fields(highlighted), " ")
+	if i := strings.Index(text, "<<"); i > 0 {
+		if lead := []rune(text[:i]); len(lead) > 20 {
+			text = "…" + string(lead[len(lead)-20:]) + text[i:]
+		}
+	}
+	var b strings.Builder
+	for i, part := range strings.Split(strings.ReplaceAll(text, ">>", "<<"), "<<") {
+		if i%2 == 1 {
+			b.WriteString(SearchHighlightStyle.Render(part))
+		} else {
+			b.WriteString(DimStyle.Render(part))
+		}
+	}
+	return truncateToWidth(b.String(), width)
+}
@@ -15,6 +15,8 @@ type SessionList struct {
 	width       int
 	height      int
 	projectName string
+	costs       map[string]float64 // session ID -> dollar cost
+	tokens      map[string]int     // session ID -> tokens, subagents included
 }
 
 func NewSessionList() SessionList {
@@ -27,6 +29,25 @@ func (s *SessionList) SetSessions(sessions []claude.SessionEntry, projectName st
 	s.cursor = 0
 }
 
+// SetCosts sets the per-session dollar cost shown beside each session.
+func (s *SessionList) SetCosts(costs map[string]float64) {
+	s.costs = costs
+}
+
+// SetTokens sets the per-session token totals shown beside each session.
+func (s *SessionList) SetTokens(tokens map[string]int) {
+	s.tokens = tokens
+}
+
+// TotalCost sums the cost of the sessions currently listed.
+func (s *SessionList) TotalCost() float64 {
+	var total float64
+	for _, sess := range s.sessions {
+		total += s.costs[sess.SessionID]
+	}
+	return total
+}
+
 func (s *SessionList) SetSize(w, h int) {
 	s.width = w
 	s.height = h
@@ -105,6 +126,12 @@ func (s *SessionList) View() string {
 
 	innerW := s.width - 3
 	maxPromptLen := s.width - 22
+	if len(s.costs) > 0 {
+		maxPromptLen -= 8
+	}
+	if len(s.tokens) > 0 {
+		maxPromptLen -= 8
+	}
 
 	scrollbar := RenderScrollbar(available, len(s.sessions), start)
 
@@ -122,6 +149,10 @@ func (s *SessionList) View() string {
 
 		sess := s.sessions[i]
 		sizeBar := sessionSizeGlyph(sess.MessageCount)
+		if sess.Missing {
+			// Archived: the JSONL file is gone, only the index remains
+			sizeBar = lipgloss.NewStyle().Foreground(ColorRed).Render("✗")
+		}
 
 		prompt := strings.ReplaceAll(sess.FirstPrompt, "\n", " ")
 		if len(prompt) > maxPromptLen {
@@ -132,6 +163,12 @@ func (s *SessionList) View() string {
 		}
 
 		age := formatAge(sess.Modified)
+		if cost, ok := s.costs[sess.SessionID]; ok && cost > 0 {
+			age = claude.FormatCost(cost) + "  " + age
+		}
+		if tok := s.tokens[sess.SessionID]; tok > 0 {
+			age = claude.FormatTokens(tok) + "  " + age
+		}
 
 		var line string
 		if i == s.cursor {
@@ -270,6 +270,9 @@ func (w *WatchlistPane) View() string {
 			countStr = lipgloss.NewStyle().Foreground(lipgloss.Color(item.Color)).Render(
 				fmt.Sprintf("%d new", item.UnseenCount))
 		}
+		if item.Action.Kind != "" {
+			countStr = strings.TrimSpace(countStr + " " + DimStyle.Render("→ "+item.Action.Kind))
+		}
 
 		if i == w.cursor {
 			sel := lipgloss.NewStyle().Background(ColorSelectBg)
@@ -1,6 +1,7 @@
 package watcher
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"time"
@@ -12,6 +13,9 @@ import (
 
 type RefreshMsg struct{}
 
+// debounce is how long changes must settle before they are reported.
+const debounce = 500 * time.Millisecond
+
 func Watch(projectPaths []string) tea.Cmd {
 	return func() tea.Msg {
 		w, err := fsnotify.NewWatcher()
@@ -19,39 +23,88 @@ func Watch(projectPaths []string) tea.Cmd {
 			return nil
 		}
 
-		for _, dir := range claude.AllProjectsDirs(projectPaths) {
-			_ = w.Add(dir)
-		}
+		addDirs(w, projectPaths)
+
+		// Debounce — wait for changes to settle
+		timer := time.NewTimer(time.Hour)
+		timer.Stop()
 
-		// Also watch individual project dirs and UUID subdirs for session changes
-		projects, _ := claude.DiscoverProjects(projectPaths)
-		for _, p := range projects {
-			_ = w.Add(p.DataDir)
-			// Watch UUID subdirectories (newer Claude Code layout)
-			entries, _ := os.ReadDir(p.DataDir)
-			for _, e := range entries {
-				if e.IsDir() {
-					_ = w.Add(filepath.Join(p.DataDir, e.Name()))
+		for {
+			select {
+			case _, ok := <-w.Events:
+				if !ok {
+					return nil
 				}
+				timer.Reset(debounce)
+			case <-timer.C:
+				return RefreshMsg{}
+			case <-w.Errors:
+				continue
 			}
 		}
+	}
+}
 
-		// Debounce — wait for changes to settle
-		debounce := time.NewTimer(time.Hour)
-		debounce.Stop()
+// Changes reports on the returned channel each time session files change,
+// debounced like Watch, until ctx is done. Directories created since the
+// last change, such as new projects, are picked up as it goes.
+func Changes(ctx context.Context, projectPaths []string) (<-chan struct{}, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	addDirs(w, projectPaths)
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer w.Close()
+		defer close(changes)
 
+		timer := time.NewTimer(time.Hour)
+		timer.Stop()
 		for {
 			select {
+			case <-ctx.Done():
+				return
 			case _, ok := <-w.Events:
 				if !ok {
-					return nil
+					return
+				}
+				timer.Reset(debounce)
+			case <-timer.C:
+				addDirs(w, projectPaths)
+				// A change is already pending if the reader is busy
+				select {
+				case changes <- struct{}{}:
+				default:
 				}
-				debounce.Reset(500 * time.Millisecond)
-			case <-debounce.C:
-				return RefreshMsg{}
 			case <-w.Errors:
 				continue
 			}
 		}
+	}()
+	return changes, nil
+}
+
+// addDirs watches the projects directories, each project's data dir and its
+// UUID subdirectories.
+func addDirs(w *fsnotify.Watcher, projectPaths []string) {
+	for _, dir := range claude.AllProjectsDirs(projectPaths) {
+		_ = w.Add(dir)
+	}
+
+	// Also watch individual project dirs and UUID subdirs for session changes
+	projects, _ := claude.DiscoverProjects(projectPaths)
+	for _, p := range projects {
+		_ = w.Add(p.DataDir)
+		// Watch UUID subdirectories (newer Claude Code layout)
+		entries, _ := os.ReadDir(p.DataDir)
+		for _, e := range entries {
+			if e.IsDir() {
+				_ = w.Add(filepath.Join(p.DataDir, e.Name()))
+				// Subagent transcripts
+				_ = w.Add(filepath.Join(p.DataDir, e.Name(), "subagents"))
+			}
+		}
 	}
 }